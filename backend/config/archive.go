@@ -0,0 +1,371 @@
+package config
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"taco/backend/models"
+	"taco/backend/services/novel"
+	"taco/backend/utils"
+)
+
+// 项目归档是一个 zip 文件，目录结构如下，素材路径在归档内统一写成相对路径：
+//
+//	manifest.json
+//	config.json            API Key 已清空，novelFile 指向 uploads/ 下的文件
+//	characters.json
+//	scenes.json
+//	uploads/<小说文件>
+//	generated/images/<图片>
+//	generated/audio/<音频>
+//
+// 素材版本（imageVariants、audioVariants）引用的文件同样会被打包；小说的章节文件
+// （.chapters.json）和上传的原始文件（.orig）存在时与正文一起放在 uploads/ 下。
+const (
+	archiveFormat    = "taco-project"
+	archiveVersion   = 1
+	archiveImagesDir = "generated/images/"
+	archiveAudioDir  = "generated/audio/"
+	archiveUploadDir = "uploads/"
+)
+
+// ErrInvalidArchive 表示归档本身有问题（格式、版本、内容或大小），与磁盘等存储错误区分。
+var ErrInvalidArchive = errors.New("不是有效的项目归档")
+
+type archiveManifest struct {
+	Format     string    `json:"format"`
+	Version    int       `json:"version"`
+	Name       string    `json:"name"`
+	ExportedAt time.Time `json:"exportedAt"`
+}
+
+// ExportArchive 把项目的配置、角色、场景、小说原文以及被引用的图片和音频写入 zip。
+func (p *Project) ExportArchive(w io.Writer) error {
+	info, err := p.Info()
+	if err != nil {
+		return err
+	}
+	cfg, err := p.LoadConfig()
+	if err != nil {
+		return err
+	}
+	characters, err := p.LoadCharactersData()
+	if err != nil {
+		return err
+	}
+	scenes, err := p.LoadScenesData()
+	if err != nil {
+		return err
+	}
+
+	zw := zip.NewWriter(w)
+	exporter := &archiveExporter{zw: zw, assets: p.Assets, added: map[string]bool{}}
+
+	cfg = StripSecrets(cfg)
+	if cfg.NovelFile != "" {
+		name := archiveUploadDir + filepath.Base(cfg.NovelFile)
+		if err := exporter.addFile(name, cfg.NovelFile); err != nil {
+			log.Printf("[WARN] 导出小说文件失败: %v", err)
+			cfg.NovelFile = ""
+		} else {
+			exporter.addNovelSidecars(cfg.NovelFile)
+			cfg.NovelFile = name
+		}
+	}
+
+	for i := range characters {
+		characters[i].ImagePath = exporter.addImage(characters[i].ImagePath)
+		rewriteVariantPaths(characters[i].ImageVariants, exporter.addImage)
+	}
+	for i := range scenes {
+		scenes[i].ImagePath = exporter.addImage(scenes[i].ImagePath)
+		scenes[i].AudioPath = exporter.addAudio(scenes[i].AudioPath)
+		rewriteVariantPaths(scenes[i].ImageVariants, exporter.addImage)
+		rewriteVariantPaths(scenes[i].AudioVariants, exporter.addAudio)
+	}
+
+	manifest := archiveManifest{
+		Format:     archiveFormat,
+		Version:    archiveVersion,
+		Name:       info.Name,
+		ExportedAt: time.Now(),
+	}
+	for name, value := range map[string]any{
+		"manifest.json":   manifest,
+		"config.json":     cfg,
+		"characters.json": characters,
+		"scenes.json":     scenes,
+	} {
+		if err := exporter.addJSON(name, value); err != nil {
+			return err
+		}
+	}
+	return zw.Close()
+}
+
+type archiveExporter struct {
+	zw     *zip.Writer
+	assets utils.AssetDirs
+	added  map[string]bool
+}
+
+func (e *archiveExporter) addJSON(name string, value any) error {
+	writer, err := e.zw.Create(name)
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(writer)
+	enc.SetIndent("", "  ")
+	return enc.Encode(value)
+}
+
+func (e *archiveExporter) addFile(name, srcPath string) error {
+	if e.added[name] {
+		return nil
+	}
+	src, err := os.Open(srcPath)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	writer, err := e.zw.Create(name)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(writer, src); err != nil {
+		return err
+	}
+	e.added[name] = true
+	return nil
+}
+
+// addNovelSidecars 写入小说正文旁的章节文件和原始文件，导入后章节序号和重新转码保持可用。
+func (e *archiveExporter) addNovelSidecars(novelPath string) {
+	for _, file := range []string{novel.ChaptersPath(novelPath), novelPath + novel.OriginalSuffix} {
+		err := e.addFile(archiveUploadDir+filepath.Base(file), file)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			log.Printf("[WARN] 导出小说附属文件失败 %s: %v", filepath.Base(file), err)
+		}
+	}
+}
+
+// addImage 把本项目的图片写入归档并返回归档内路径；外部链接或缺失的文件保持原样。
+func (e *archiveExporter) addImage(relPath string) string {
+	file, ok := e.assets.ImageFile(relPath)
+	if !ok {
+		return relPath
+	}
+	name := archiveImagesDir + filepath.Base(file)
+	if err := e.addFile(name, file); err != nil {
+		log.Printf("[WARN] 导出图片失败 %s: %v", relPath, err)
+		return relPath
+	}
+	return name
+}
+
+func (e *archiveExporter) addAudio(relPath string) string {
+	file, ok := e.assets.AudioFile(relPath)
+	if !ok {
+		return relPath
+	}
+	name := archiveAudioDir + filepath.Base(file)
+	if err := e.addFile(name, file); err != nil {
+		log.Printf("[WARN] 导出音频失败 %s: %v", relPath, err)
+		return relPath
+	}
+	return name
+}
+
+// ImportArchive 从归档新建项目，并把归档内的相对素材路径改写为新项目的访问路径。
+// API Key 不在归档中，新项目沿用默认项目的密钥。导入失败时会删除已创建的项目。
+func ImportArchive(r io.ReaderAt, size int64, name string) (models.ProjectInfo, error) {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return models.ProjectInfo{}, ErrInvalidArchive
+	}
+
+	documents := map[string][]byte{}
+	for _, file := range zr.File {
+		switch file.Name {
+		case "manifest.json", "config.json", "characters.json", "scenes.json":
+			data, err := readArchiveFile(file, utils.MaxFileSize)
+			if err != nil {
+				return models.ProjectInfo{}, err
+			}
+			documents[file.Name] = data
+		}
+	}
+
+	var manifest archiveManifest
+	if err := json.Unmarshal(documents["manifest.json"], &manifest); err != nil || manifest.Format != archiveFormat {
+		return models.ProjectInfo{}, ErrInvalidArchive
+	}
+	if manifest.Version > archiveVersion {
+		return models.ProjectInfo{}, fmt.Errorf("%w: 归档版本 %d 高于当前支持的版本 %d", ErrInvalidArchive, manifest.Version, archiveVersion)
+	}
+
+	if strings.TrimSpace(name) == "" {
+		name = manifest.Name
+	}
+	if strings.TrimSpace(name) == "" {
+		name = "导入的项目"
+	}
+	info, err := CreateProject(name)
+	if err != nil {
+		return models.ProjectInfo{}, err
+	}
+	project := newProject(info.ID)
+	if err := project.importArchive(zr, documents); err != nil {
+		if removeErr := DeleteProject(info.ID); removeErr != nil {
+			log.Printf("[WARN] 清理导入失败的项目出错: %v", removeErr)
+		}
+		return models.ProjectInfo{}, err
+	}
+	return info, nil
+}
+
+func (p *Project) importArchive(zr *zip.Reader, documents map[string][]byte) error {
+	var total int64
+	for _, file := range zr.File {
+		name := path.Clean(file.Name)
+		var targetDir, prefix string
+		switch {
+		case strings.HasPrefix(name, archiveImagesDir):
+			targetDir, prefix = p.Assets.ImagesDir, archiveImagesDir
+		case strings.HasPrefix(name, archiveAudioDir):
+			targetDir, prefix = p.Assets.AudioDir, archiveAudioDir
+		case strings.HasPrefix(name, archiveUploadDir):
+			targetDir, prefix = p.UploadDir, archiveUploadDir
+		default:
+			continue
+		}
+		// 只取文件名，防止归档中的 ../ 路径写到项目目录之外。
+		base := path.Base(strings.TrimPrefix(name, prefix))
+		if file.FileInfo().IsDir() || base == "." || base == ".." || base == "/" {
+			continue
+		}
+		written, err := extractArchiveFile(file, filepath.Join(targetDir, base), utils.MaxArchiveSize-total)
+		if err != nil {
+			return err
+		}
+		total += written
+	}
+
+	cfg, err := p.LoadConfig()
+	if err != nil {
+		return err
+	}
+	if data, ok := documents["config.json"]; ok {
+		var imported models.Config
+		if err := json.Unmarshal(data, &imported); err != nil {
+			return fmt.Errorf("%w: 解析归档配置失败: %v", ErrInvalidArchive, err)
+		}
+		cfg = keepSecrets(imported, cfg)
+	}
+	if strings.HasPrefix(cfg.NovelFile, archiveUploadDir) {
+		cfg.NovelFile = filepath.Join(p.UploadDir, path.Base(cfg.NovelFile))
+	} else {
+		cfg.NovelFile = ""
+	}
+	if err := p.SaveConfig(cfg); err != nil {
+		return err
+	}
+
+	var characters []models.CharacterProfile
+	if data, ok := documents["characters.json"]; ok {
+		if err := json.Unmarshal(data, &characters); err != nil {
+			return fmt.Errorf("%w: 解析归档角色失败: %v", ErrInvalidArchive, err)
+		}
+	}
+	for i := range characters {
+		characters[i].ImagePath = p.importedAssetURL(characters[i].ImagePath)
+		rewriteVariantPaths(characters[i].ImageVariants, p.importedAssetURL)
+	}
+	if err := p.SaveCharactersData(characters); err != nil {
+		return err
+	}
+
+	var scenes []models.Scene
+	if data, ok := documents["scenes.json"]; ok {
+		if err := json.Unmarshal(data, &scenes); err != nil {
+			return fmt.Errorf("%w: 解析归档场景失败: %v", ErrInvalidArchive, err)
+		}
+	}
+	for i := range scenes {
+		scenes[i].ImagePath = p.importedAssetURL(scenes[i].ImagePath)
+		scenes[i].AudioPath = p.importedAssetURL(scenes[i].AudioPath)
+		rewriteVariantPaths(scenes[i].ImageVariants, p.importedAssetURL)
+		rewriteVariantPaths(scenes[i].AudioVariants, p.importedAssetURL)
+	}
+	return p.SaveScenesData(scenes)
+}
+
+// importedAssetURL 把归档内的 generated/... 相对路径改写为本项目的访问路径。
+func (p *Project) importedAssetURL(relPath string) string {
+	switch {
+	case strings.HasPrefix(relPath, archiveImagesDir):
+		return p.Assets.ImageURL(path.Base(relPath))
+	case strings.HasPrefix(relPath, archiveAudioDir):
+		return p.Assets.AudioURL(path.Base(relPath))
+	default:
+		return relPath
+	}
+}
+
+func rewriteVariantPaths(variants []models.AssetVariant, rewrite func(string) string) {
+	for i := range variants {
+		variants[i].Path = rewrite(variants[i].Path)
+	}
+}
+
+func readArchiveFile(file *zip.File, limit int64) ([]byte, error) {
+	rc, err := file.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	data, err := io.ReadAll(io.LimitReader(rc, limit+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > limit {
+		return nil, fmt.Errorf("%w: 归档中的 %s 过大", ErrInvalidArchive, file.Name)
+	}
+	return data, nil
+}
+
+// extractArchiveFile 解压单个文件，limit 为剩余可写入的字节数，用于防止解压炸弹。
+func extractArchiveFile(file *zip.File, target string, limit int64) (int64, error) {
+	if err := utils.EnsureDir(filepath.Dir(target)); err != nil {
+		return 0, err
+	}
+	rc, err := file.Open()
+	if err != nil {
+		return 0, err
+	}
+	defer rc.Close()
+
+	dst, err := os.Create(target)
+	if err != nil {
+		return 0, err
+	}
+	defer dst.Close()
+
+	written, err := io.Copy(dst, io.LimitReader(rc, limit+1))
+	if err != nil {
+		return written, err
+	}
+	if written > limit {
+		return written, fmt.Errorf("%w: 归档解压后的内容过大", ErrInvalidArchive)
+	}
+	return written, nil
+}
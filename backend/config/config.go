@@ -3,6 +3,7 @@ package config
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
 	"log"
 	"os"
 	"path/filepath"
@@ -11,43 +12,36 @@ import (
 	"taco/backend/models"
 )
 
-var (
-	projectRoot    = mustFindProjectRoot()
-	configPath     = filepath.Join(projectRoot, "config", "config.json")
-	charactersPath = filepath.Join(projectRoot, "config", "characters.json")
-	scenesPath     = filepath.Join(projectRoot, "config", "scenes.json")
-)
+func LoadConfig() (models.Config, error) {
+	return DefaultProject().LoadConfig()
+}
 
-func mustFindProjectRoot() string {
-	dir, err := os.Getwd()
-	if err != nil {
-		log.Fatalf("获取工作目录失败: %v", err)
-	}
-	seen := map[string]bool{}
-	for {
-		if _, err := os.Stat(filepath.Join(dir, "go.mod")); err == nil {
-			return dir
-		}
-		if seen[dir] {
-			break
-		}
-		seen[dir] = true
-		parent := filepath.Dir(dir)
-		if parent == dir {
-			break
-		}
-		dir = parent
-	}
-	log.Printf("未找到 go.mod，默认使用当前目录: %s", dir)
-	return dir
+func SaveConfig(cfg models.Config) error {
+	return DefaultProject().SaveConfig(cfg)
 }
 
-func LoadConfig() (models.Config, error) {
-	if err := os.MkdirAll(filepath.Dir(configPath), 0o755); err != nil {
+func LoadCharactersData() ([]models.CharacterProfile, error) {
+	return DefaultProject().LoadCharactersData()
+}
+
+func SaveCharactersData(characters []models.CharacterProfile) error {
+	return DefaultProject().SaveCharactersData(characters)
+}
+
+func LoadScenesData() ([]models.Scene, error) {
+	return DefaultProject().LoadScenesData()
+}
+
+func SaveScenesData(scenes []models.Scene) error {
+	return DefaultProject().SaveScenesData(scenes)
+}
+
+func (p *Project) LoadConfig() (models.Config, error) {
+	if err := os.MkdirAll(filepath.Dir(p.ConfigPath), 0o755); err != nil {
 		return models.Config{}, err
 	}
 
-	data, err := os.ReadFile(configPath)
+	data, err := os.ReadFile(p.ConfigPath)
 	if err != nil {
 		if errors.Is(err, os.ErrNotExist) {
 			defaultCfg := models.Config{
@@ -74,13 +68,19 @@ func LoadConfig() (models.Config, error) {
 				CharacterCount: 2,
 				SceneCount:     5,
 			}
-			if err := SaveConfig(defaultCfg); err != nil {
+			if err := p.SaveConfig(defaultCfg); err != nil {
+				return models.Config{}, err
+			}
+			if err := p.SaveCharactersData([]models.CharacterProfile{}); err != nil {
+				return models.Config{}, err
+			}
+			if err := p.SaveScenesData([]models.Scene{}); err != nil {
 				return models.Config{}, err
 			}
-			if err := SaveCharactersData([]models.CharacterProfile{}); err != nil {
+			if err := p.applySecrets(&defaultCfg); err != nil {
 				return models.Config{}, err
 			}
-			if err := SaveScenesData([]models.Scene{}); err != nil {
+			if err := applyEnvOverrides(&defaultCfg); err != nil {
 				return models.Config{}, err
 			}
 			return defaultCfg, nil
@@ -88,76 +88,20 @@ func LoadConfig() (models.Config, error) {
 		return models.Config{}, err
 	}
 
+	data, err = p.migrateFile(p.ConfigPath, data, configMigrations)
+	if err != nil {
+		return models.Config{}, err
+	}
 	var cfg models.Config
 	if err := json.Unmarshal(data, &cfg); err != nil {
 		return models.Config{}, err
 	}
 
-	var legacy struct {
-		LLMModel     string                  `json:"llmModel"`
-		LLMBaseURL   string                  `json:"llmBaseUrl"`
-		LLMAPIKey    string                  `json:"llmApiKey"`
-		ImageModel   string                  `json:"imageModel"`
-		ImageBaseURL string                  `json:"imageBaseUrl"`
-		ImageAPIKey  string                  `json:"imageApiKey"`
-		ImageSize    string                  `json:"imageSize"`
-		ImageQuality string                  `json:"imageQuality"`
-		Characters   []models.CharacterProfile `json:"characters"`
-		Scenes       []models.Scene            `json:"scenes"`
-	}
-	if err := json.Unmarshal(data, &legacy); err == nil {
-		migrated := false
-		if cfg.LLM.Model == "" && legacy.LLMModel != "" {
-			cfg.LLM.Model = legacy.LLMModel
-		}
-		if cfg.LLM.BaseURL == "" && legacy.LLMBaseURL != "" {
-			cfg.LLM.BaseURL = legacy.LLMBaseURL
-		}
-		if cfg.LLM.APIKey == "" && legacy.LLMAPIKey != "" {
-			cfg.LLM.APIKey = legacy.LLMAPIKey
-		}
-		if cfg.Image.Model == "" {
-			if legacy.ImageModel != "" {
-				cfg.Image.Model = legacy.ImageModel
-			} else {
-				cfg.Image.Model = "gpt-4o-image"
-			}
-		}
-		if cfg.Image.BaseURL == "" {
-			if legacy.ImageBaseURL != "" {
-				cfg.Image.BaseURL = legacy.ImageBaseURL
-			} else if cfg.LLM.BaseURL != "" {
-				cfg.Image.BaseURL = cfg.LLM.BaseURL
-			}
-		}
-		if cfg.Image.APIKey == "" && legacy.ImageAPIKey != "" {
-			cfg.Image.APIKey = legacy.ImageAPIKey
-		}
-		if cfg.Image.Size == "" && legacy.ImageSize != "" {
-			cfg.Image.Size = legacy.ImageSize
-		}
-		if cfg.Image.Quality == "" && legacy.ImageQuality != "" {
-			cfg.Image.Quality = legacy.ImageQuality
-		}
-		if characters := legacy.Characters; len(characters) > 0 {
-			if err := SaveCharactersData(characters); err != nil {
-				log.Printf("迁移旧角色数据失败: %v", err)
-			} else {
-				migrated = true
-			}
-		}
-		if scenes := legacy.Scenes; len(scenes) > 0 {
-			if err := SaveScenesData(scenes); err != nil {
-				log.Printf("迁移旧场景数据失败: %v", err)
-			} else {
-				migrated = true
-			}
-		}
-		if migrated {
-			if err := SaveConfig(cfg); err != nil {
-				log.Printf("更新配置文件失败: %v", err)
-			}
-		}
+	if err := p.applySecrets(&cfg); err != nil {
+		return models.Config{}, err
+	}
+	if err := applyEnvOverrides(&cfg); err != nil {
+		return models.Config{}, err
 	}
 
 	if strings.TrimSpace(cfg.Image.Model) == "" {
@@ -197,12 +141,20 @@ func LoadConfig() (models.Config, error) {
 	return cfg, nil
 }
 
-func SaveConfig(cfg models.Config) error {
-	if err := os.MkdirAll(filepath.Dir(configPath), 0o755); err != nil {
+func (p *Project) SaveConfig(cfg models.Config) error {
+	if err := os.MkdirAll(filepath.Dir(p.ConfigPath), 0o755); err != nil {
+		return err
+	}
+	cfg, err := p.splitSecrets(cfg)
+	if err != nil {
+		return err
+	}
+	cfg, err = p.stripEnvOverrides(cfg)
+	if err != nil {
 		return err
 	}
 
-	tmpPath := configPath + ".tmp"
+	tmpPath := p.ConfigPath + ".tmp"
 	file, err := os.OpenFile(tmpPath, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o644)
 	if err != nil {
 		return err
@@ -211,11 +163,11 @@ func SaveConfig(cfg models.Config) error {
 
 	encoder := json.NewEncoder(file)
 	encoder.SetIndent("", "  ")
-	if err := encoder.Encode(cfg); err != nil {
+	if err := encoder.Encode(configFile{SchemaVersion: configSchemaVersion, Config: cfg}); err != nil {
 		return err
 	}
 
-	return os.Rename(tmpPath, configPath)
+	return os.Rename(tmpPath, p.ConfigPath)
 }
 
 func ValidateConfig(cfg models.Config) error {
@@ -225,6 +177,19 @@ func ValidateConfig(cfg models.Config) error {
 	if strings.TrimSpace(cfg.LLM.BaseURL) == "" {
 		return errors.New("LLM 接口地址不能为空")
 	}
+	switch strings.ToLower(strings.TrimSpace(cfg.LLM.Provider)) {
+	case "", "openai", "anthropic", "ollama", "dashscope":
+	default:
+		return fmt.Errorf("LLM 接口类型无效: %s（可选 openai、anthropic、ollama、dashscope）", cfg.LLM.Provider)
+	}
+	if cfg.LLM.MaxTokens < 0 {
+		return errors.New("LLM maxTokens 不能为负数")
+	}
+	switch strings.ToLower(strings.TrimSpace(cfg.LLM.JSONMode)) {
+	case "", "auto", "on", "off":
+	default:
+		return fmt.Errorf("LLM JSON 模式无效: %s（可选 auto、on、off）", cfg.LLM.JSONMode)
+	}
 	if strings.TrimSpace(cfg.Image.Model) == "" {
 		return errors.New("图像模型不能为空")
 	}
@@ -255,38 +220,53 @@ func ValidateConfig(cfg models.Config) error {
 	if cfg.SceneCount < 0 {
 		return errors.New("场景数必须是非负整数")
 	}
-	return nil
+	if cfg.Retry.MaxAttempts < 0 || cfg.Retry.BaseDelayMs < 0 || cfg.Retry.MaxDelayMs < 0 {
+		return errors.New("重试参数不能为负数")
+	}
+	return validateProfiles(cfg)
 }
 
-func LoadCharactersData() ([]models.CharacterProfile, error) {
-	if err := os.MkdirAll(filepath.Dir(charactersPath), 0o755); err != nil {
+func (p *Project) loadCharacters() ([]models.CharacterProfile, error) {
+	if err := os.MkdirAll(filepath.Dir(p.CharactersPath), 0o755); err != nil {
 		return nil, err
 	}
-	data, err := os.ReadFile(charactersPath)
+	data, err := os.ReadFile(p.CharactersPath)
 	if err != nil {
 		if errors.Is(err, os.ErrNotExist) {
 			return []models.CharacterProfile{}, nil
 		}
 		return nil, err
 	}
-	var characters []models.CharacterProfile
-	if err := json.Unmarshal(data, &characters); err != nil {
+	data, err = p.migrateFile(p.CharactersPath, data, charactersMigrations)
+	if err != nil {
+		return nil, err
+	}
+	var doc charactersFile
+	if err := json.Unmarshal(data, &doc); err != nil {
 		return nil, err
 	}
+	characters := doc.Characters
 	if characters == nil {
 		return []models.CharacterProfile{}, nil
 	}
+	if EnsureCharacterIDs(characters) {
+		log.Printf("[INFO] 为旧角色数据补充 ID: %s", p.CharactersPath)
+		if err := p.saveCharacters(characters); err != nil {
+			return nil, err
+		}
+	}
 	return characters, nil
 }
 
-func SaveCharactersData(characters []models.CharacterProfile) error {
+func (p *Project) saveCharacters(characters []models.CharacterProfile) error {
 	if characters == nil {
 		characters = []models.CharacterProfile{}
 	}
-	if err := os.MkdirAll(filepath.Dir(charactersPath), 0o755); err != nil {
+	EnsureCharacterIDs(characters)
+	if err := os.MkdirAll(filepath.Dir(p.CharactersPath), 0o755); err != nil {
 		return err
 	}
-	tmpPath := charactersPath + ".tmp"
+	tmpPath := p.CharactersPath + ".tmp"
 	file, err := os.OpenFile(tmpPath, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o644)
 	if err != nil {
 		return err
@@ -295,42 +275,59 @@ func SaveCharactersData(characters []models.CharacterProfile) error {
 
 	enc := json.NewEncoder(file)
 	enc.SetIndent("", "  ")
-	if err := enc.Encode(characters); err != nil {
+	if err := enc.Encode(charactersFile{SchemaVersion: charactersSchemaVersion, Characters: characters}); err != nil {
+		return err
+	}
+	if err := os.Rename(tmpPath, p.CharactersPath); err != nil {
 		return err
 	}
-	return os.Rename(tmpPath, charactersPath)
+	p.recordRevision(HistoryCharacters, characters, len(characters))
+	return nil
 }
 
-func LoadScenesData() ([]models.Scene, error) {
-	if err := os.MkdirAll(filepath.Dir(scenesPath), 0o755); err != nil {
+func (p *Project) loadScenes() ([]models.Scene, error) {
+	if err := os.MkdirAll(filepath.Dir(p.ScenesPath), 0o755); err != nil {
 		return nil, err
 	}
-	data, err := os.ReadFile(scenesPath)
+	data, err := os.ReadFile(p.ScenesPath)
 	if err != nil {
 		if errors.Is(err, os.ErrNotExist) {
 			return []models.Scene{}, nil
 		}
 		return nil, err
 	}
-	var scenes []models.Scene
-	if err := json.Unmarshal(data, &scenes); err != nil {
+	data, err = p.migrateFile(p.ScenesPath, data, scenesMigrations)
+	if err != nil {
+		return nil, err
+	}
+	var doc scenesFile
+	if err := json.Unmarshal(data, &doc); err != nil {
 		return nil, err
 	}
+	scenes := doc.Scenes
 	if scenes == nil {
 		return []models.Scene{}, nil
 	}
-	return NormalizeScenes(scenes), nil
+	scenes = NormalizeScenes(scenes)
+	if EnsureSceneIDs(scenes) {
+		log.Printf("[INFO] 为旧场景数据补充 ID: %s", p.ScenesPath)
+		if err := p.saveScenes(scenes); err != nil {
+			return nil, err
+		}
+	}
+	return scenes, nil
 }
 
-func SaveScenesData(scenes []models.Scene) error {
+func (p *Project) saveScenes(scenes []models.Scene) error {
 	if scenes == nil {
 		scenes = []models.Scene{}
 	}
+	EnsureSceneIDs(scenes)
 	scenes = NormalizeScenes(scenes)
-	if err := os.MkdirAll(filepath.Dir(scenesPath), 0o755); err != nil {
+	if err := os.MkdirAll(filepath.Dir(p.ScenesPath), 0o755); err != nil {
 		return err
 	}
-	tmpPath := scenesPath + ".tmp"
+	tmpPath := p.ScenesPath + ".tmp"
 	file, err := os.OpenFile(tmpPath, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o644)
 	if err != nil {
 		return err
@@ -339,10 +336,14 @@ func SaveScenesData(scenes []models.Scene) error {
 
 	enc := json.NewEncoder(file)
 	enc.SetIndent("", "  ")
-	if err := enc.Encode(scenes); err != nil {
+	if err := enc.Encode(scenesFile{SchemaVersion: scenesSchemaVersion, Scenes: scenes}); err != nil {
 		return err
 	}
-	return os.Rename(tmpPath, scenesPath)
+	if err := os.Rename(tmpPath, p.ScenesPath); err != nil {
+		return err
+	}
+	p.recordRevision(HistoryScenes, scenes, len(scenes))
+	return nil
 }
 
 func NormalizeScenes(scenes []models.Scene) []models.Scene {
@@ -352,6 +353,7 @@ func NormalizeScenes(scenes []models.Scene) []models.Scene {
 
 	normalized := make([]models.Scene, len(scenes))
 	for i, scene := range scenes {
+		scene.ID = strings.TrimSpace(scene.ID)
 		scene.Title = strings.TrimSpace(scene.Title)
 		scene.Description = strings.TrimSpace(scene.Description)
 		scene.Narration = strings.TrimSpace(scene.Narration)
@@ -1,17 +1,16 @@
 package config
 
 import (
+	"os"
 	"path/filepath"
 	"testing"
 
 	"taco/backend/models"
+	"taco/backend/utils"
 )
 
 func TestLoadConfigDefault(t *testing.T) {
-	tmpDir := t.TempDir()
-	originalConfigPath := configPath
-	configPath = filepath.Join(tmpDir, "config.json")
-	defer func() { configPath = originalConfigPath }()
+	useTempPaths(t)
 
 	cfg, err := LoadConfig()
 	if err != nil {
@@ -30,10 +29,7 @@ func TestLoadConfigDefault(t *testing.T) {
 }
 
 func TestSaveAndLoadConfig(t *testing.T) {
-	tmpDir := t.TempDir()
-	originalConfigPath := configPath
-	configPath = filepath.Join(tmpDir, "config.json")
-	defer func() { configPath = originalConfigPath }()
+	useTempPaths(t)
 
 	testCfg := models.Config{
 		NovelFile: "/test/novel.txt",
@@ -139,10 +135,7 @@ func TestValidateConfigNegativeCharacterCount(t *testing.T) {
 }
 
 func TestLoadCharactersData(t *testing.T) {
-	tmpDir := t.TempDir()
-	originalPath := charactersPath
-	charactersPath = filepath.Join(tmpDir, "characters.json")
-	defer func() { charactersPath = originalPath }()
+	useTempPaths(t)
 
 	characters, err := LoadCharactersData()
 	if err != nil {
@@ -158,10 +151,7 @@ func TestLoadCharactersData(t *testing.T) {
 }
 
 func TestSaveAndLoadCharactersData(t *testing.T) {
-	tmpDir := t.TempDir()
-	originalPath := charactersPath
-	charactersPath = filepath.Join(tmpDir, "characters.json")
-	defer func() { charactersPath = originalPath }()
+	useTempPaths(t)
 
 	testCharacters := []models.CharacterProfile{
 		{Name: "角色1", Description: "描述1", ImagePath: "/images/1.png"},
@@ -190,10 +180,7 @@ func TestSaveAndLoadCharactersData(t *testing.T) {
 }
 
 func TestLoadScenesData(t *testing.T) {
-	tmpDir := t.TempDir()
-	originalPath := scenesPath
-	scenesPath = filepath.Join(tmpDir, "scenes.json")
-	defer func() { scenesPath = originalPath }()
+	useTempPaths(t)
 
 	scenes, err := LoadScenesData()
 	if err != nil {
@@ -209,10 +196,7 @@ func TestLoadScenesData(t *testing.T) {
 }
 
 func TestSaveAndLoadScenesData(t *testing.T) {
-	tmpDir := t.TempDir()
-	originalPath := scenesPath
-	scenesPath = filepath.Join(tmpDir, "scenes.json")
-	defer func() { scenesPath = originalPath }()
+	useTempPaths(t)
 
 	testScenes := []models.Scene{
 		{
@@ -282,3 +266,73 @@ func TestNormalizeScenesNil(t *testing.T) {
 		t.Errorf("Expected empty slice, got %d elements", len(normalized))
 	}
 }
+
+func TestLoadScenesDataAssignsIDsToLegacyData(t *testing.T) {
+	useTempPaths(t)
+
+	if err := os.MkdirAll(filepath.Dir(utils.ScenesPath), 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	legacy := `[{"title":"场景1","description":"描述1"},{"title":"场景2","description":"描述2"}]`
+	if err := os.WriteFile(utils.ScenesPath, []byte(legacy), 0o644); err != nil {
+		t.Fatalf("write legacy scenes: %v", err)
+	}
+
+	scenes, err := LoadScenesData()
+	if err != nil {
+		t.Fatalf("LoadScenesData failed: %v", err)
+	}
+	if len(scenes) != 2 || scenes[0].ID == "" || scenes[1].ID == "" {
+		t.Fatalf("Expected IDs to be assigned, got %+v", scenes)
+	}
+	if scenes[0].ID == scenes[1].ID {
+		t.Errorf("Expected unique IDs, got %s twice", scenes[0].ID)
+	}
+
+	reloaded, err := LoadScenesData()
+	if err != nil {
+		t.Fatalf("LoadScenesData failed: %v", err)
+	}
+	if reloaded[0].ID != scenes[0].ID || reloaded[1].ID != scenes[1].ID {
+		t.Error("Expected assigned IDs to be persisted")
+	}
+}
+
+func TestSaveCharactersDataReassignsDuplicateIDs(t *testing.T) {
+	useTempPaths(t)
+
+	characters := []models.CharacterProfile{
+		{ID: "chr_same", Name: "角色1"},
+		{ID: "chr_same", Name: "角色2"},
+		{Name: "角色3"},
+	}
+	if err := SaveCharactersData(characters); err != nil {
+		t.Fatalf("SaveCharactersData failed: %v", err)
+	}
+
+	loaded, err := LoadCharactersData()
+	if err != nil {
+		t.Fatalf("LoadCharactersData failed: %v", err)
+	}
+	if loaded[0].ID != "chr_same" {
+		t.Errorf("Expected first ID to be kept, got %s", loaded[0].ID)
+	}
+	if loaded[1].ID == "chr_same" || loaded[1].ID == "" || loaded[2].ID == "" {
+		t.Errorf("Expected duplicate and missing IDs to be replaced, got %+v", loaded)
+	}
+}
+
+// useTempPaths 将默认项目和项目目录的全部路径指向临时目录，测试结束后恢复。
+func useTempPaths(t *testing.T) string {
+	t.Helper()
+	tmpDir := t.TempDir()
+	original := []string{utils.ProjectRoot, utils.ConfigPath, utils.CharactersPath, utils.ScenesPath, utils.UploadDir, utils.GeneratedDir, utils.GeneratedImagesDir, utils.GeneratedAudioDir, utils.ProjectsDir}
+	utils.SetDataDir(tmpDir)
+	t.Cleanup(func() {
+		utils.ProjectRoot, utils.ConfigPath, utils.CharactersPath, utils.ScenesPath = original[0], original[1], original[2], original[3]
+		utils.UploadDir, utils.GeneratedDir = original[4], original[5]
+		utils.GeneratedImagesDir, utils.GeneratedAudioDir = original[6], original[7]
+		utils.ProjectsDir = original[8]
+	})
+	return tmpDir
+}
@@ -0,0 +1,141 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"taco/backend/models"
+)
+
+// models.Config 的每个字段都可以用 TACO_* 环境变量覆盖，变量名由 JSON 字段路径得到：
+// llm.baseUrl 对应 TACO_LLM_BASE_URL，characterCount 对应 TACO_CHARACTER_COUNT。
+// 覆盖只在 LoadConfig 中生效，SaveConfig 不会把这些值写回文件。
+const envPrefix = "TACO"
+
+type envField struct {
+	name  string
+	value reflect.Value
+}
+
+// envFields 列出配置中所有可以被环境变量覆盖的字段。新增配置项无需额外登记。
+func envFields(cfg *models.Config) []envField {
+	var fields []envField
+	collectEnvFields(reflect.ValueOf(cfg).Elem(), envPrefix, &fields)
+	return fields
+}
+
+func collectEnvFields(v reflect.Value, prefix string, fields *[]envField) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := strings.Split(field.Tag.Get("json"), ",")[0]
+		if !field.IsExported() || tag == "" || tag == "-" {
+			continue
+		}
+		name := prefix + "_" + envName(tag)
+		switch value := v.Field(i); value.Kind() {
+		case reflect.Struct:
+			collectEnvFields(value, name, fields)
+		case reflect.String, reflect.Bool,
+			reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+			reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+			reflect.Float32, reflect.Float64:
+			*fields = append(*fields, envField{name: name, value: value})
+		}
+	}
+}
+
+// envName 把 camelCase 的 JSON 字段名转换成大写下划线形式，例如 baseUrl -> BASE_URL。
+func envName(tag string) string {
+	var b strings.Builder
+	for i, r := range tag {
+		if i > 0 && unicode.IsUpper(r) {
+			b.WriteByte('_')
+		}
+		b.WriteRune(unicode.ToUpper(r))
+	}
+	return b.String()
+}
+
+func setFromEnv(value reflect.Value, raw string) error {
+	switch value.Kind() {
+	case reflect.String:
+		value.SetString(raw)
+	case reflect.Bool:
+		parsed, err := strconv.ParseBool(raw)
+		if err != nil {
+			return err
+		}
+		value.SetBool(parsed)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		parsed, err := strconv.ParseInt(raw, 10, value.Type().Bits())
+		if err != nil {
+			return err
+		}
+		value.SetInt(parsed)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		parsed, err := strconv.ParseUint(raw, 10, value.Type().Bits())
+		if err != nil {
+			return err
+		}
+		value.SetUint(parsed)
+	case reflect.Float32, reflect.Float64:
+		parsed, err := strconv.ParseFloat(raw, value.Type().Bits())
+		if err != nil {
+			return err
+		}
+		value.SetFloat(parsed)
+	}
+	return nil
+}
+
+// applyEnvOverrides 用已设置的 TACO_* 环境变量覆盖配置，格式错误的值直接报错。
+func applyEnvOverrides(cfg *models.Config) error {
+	for _, field := range envFields(cfg) {
+		raw := strings.TrimSpace(os.Getenv(field.name))
+		if raw == "" {
+			continue
+		}
+		if err := setFromEnv(field.value, raw); err != nil {
+			return fmt.Errorf("环境变量 %s 的值无效: %w", field.name, err)
+		}
+	}
+	return nil
+}
+
+// stripEnvOverrides 在保存前把仍等于环境变量的字段还原为文件中原有的值，
+// 避免环境变量被持久化；文件不存在时还原为零值。
+func (p *Project) stripEnvOverrides(cfg models.Config) (models.Config, error) {
+	overridden := cfg
+	if err := applyEnvOverrides(&overridden); err != nil {
+		return cfg, err
+	}
+
+	var onDisk models.Config
+	data, err := os.ReadFile(p.ConfigPath)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return cfg, err
+	}
+	if err == nil {
+		if err := json.Unmarshal(data, &onDisk); err != nil {
+			return cfg, err
+		}
+	}
+
+	current, fromEnv, disk := envFields(&cfg), envFields(&overridden), envFields(&onDisk)
+	for i, field := range current {
+		if strings.TrimSpace(os.Getenv(field.name)) == "" {
+			continue
+		}
+		if field.value.Interface() == fromEnv[i].value.Interface() {
+			field.value.Set(disk[i].value)
+		}
+	}
+	return cfg, nil
+}
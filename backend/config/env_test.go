@@ -0,0 +1,63 @@
+package config
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"taco/backend/models"
+	"taco/backend/utils"
+)
+
+func TestEnvFieldNames(t *testing.T) {
+	var cfg models.Config
+	names := map[string]bool{}
+	for _, field := range envFields(&cfg) {
+		names[field.name] = true
+	}
+	for _, name := range []string{
+		"TACO_NOVEL_FILE", "TACO_LLM_BASE_URL", "TACO_IMAGE_EDIT_MODEL", "TACO_VOICE_OUTPUT_DIR",
+		"TACO_CHARACTER_COUNT", "TACO_ANIME_STYLE",
+		EnvLLMAPIKey, EnvImageAPIKey, EnvImageEditAPIKey, EnvVoiceAPIKey,
+	} {
+		if !names[name] {
+			t.Errorf("Expected environment variable %s, got %v", name, names)
+		}
+	}
+}
+
+func TestLoadConfigAppliesEnvOverrides(t *testing.T) {
+	useTempPaths(t)
+	SaveConfig(models.Config{LLM: models.LLMConfig{Model: "file-model", BaseURL: "https://file"}, SceneCount: 3})
+	t.Setenv("TACO_LLM_MODEL", "env-model")
+	t.Setenv("TACO_SCENE_COUNT", "8")
+	t.Setenv("TACO_VOICE_VOICE", "Ethan")
+
+	cfg, err := LoadConfig()
+	if err != nil {
+		t.Fatalf("LoadConfig failed: %v", err)
+	}
+	if cfg.LLM.Model != "env-model" || cfg.SceneCount != 8 || cfg.Voice.Voice != "Ethan" {
+		t.Errorf("Environment overrides not applied: %+v", cfg)
+	}
+	if cfg.LLM.BaseURL != "https://file" {
+		t.Errorf("Fields without overrides should come from file, got %q", cfg.LLM.BaseURL)
+	}
+
+	cfg.LLM.BaseURL = "https://edited"
+	if err := SaveConfig(cfg); err != nil {
+		t.Fatalf("SaveConfig failed: %v", err)
+	}
+	data, _ := os.ReadFile(utils.ConfigPath)
+	if strings.Contains(string(data), "env-model") || strings.Contains(string(data), "Ethan") {
+		t.Errorf("Environment values should not be written to disk: %s", data)
+	}
+	if !strings.Contains(string(data), "file-model") || !strings.Contains(string(data), "https://edited") {
+		t.Errorf("Expected file values and edits to be kept: %s", data)
+	}
+
+	t.Setenv("TACO_SCENE_COUNT", "many")
+	if _, err := LoadConfig(); err == nil || !strings.Contains(err.Error(), "TACO_SCENE_COUNT") {
+		t.Errorf("Expected error naming the invalid variable, got %v", err)
+	}
+}
@@ -0,0 +1,175 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"taco/backend/models"
+)
+
+// gcGracePeriod 内修改过的文件不会被清理：生成接口先写文件、后回写条目，
+// 这段时间内的新文件可能只是还没来得及被引用。
+const gcGracePeriod = 10 * time.Minute
+
+// CollectGarbage 扫描项目的生成图片和音频目录，删除（dryRun 时仅列出）未被任何地方引用的文件。
+// 引用来源包括当前角色和场景的图片、音频路径与素材版本，以及历史版本中保存的数据，
+// 这样恢复历史版本后图片依然可用。
+func (p *Project) CollectGarbage(dryRun bool) (models.GCReport, error) {
+	report := models.GCReport{Project: p.ID, DryRun: dryRun, Orphans: []models.GCFile{}}
+
+	images, audio, err := p.referencedAssets()
+	if err != nil {
+		return report, err
+	}
+
+	if err := sweep(&report, p.Assets.ImagesDir, images, p.Assets.ImageURL); err != nil {
+		return report, err
+	}
+	if err := sweep(&report, p.Assets.AudioDir, audio, p.Assets.AudioURL); err != nil {
+		return report, err
+	}
+	return report, nil
+}
+
+// CollectAllGarbage 依次清理所有项目，单个项目失败只记日志。
+func CollectAllGarbage(dryRun bool) ([]models.GCReport, error) {
+	projects, err := ListProjects()
+	if err != nil {
+		return nil, err
+	}
+	reports := make([]models.GCReport, 0, len(projects))
+	for _, info := range projects {
+		project, err := OpenProject(info.ID)
+		if err != nil {
+			log.Printf("[WARN] 打开项目 %s 失败: %v", info.ID, err)
+			continue
+		}
+		report, err := project.CollectGarbage(dryRun)
+		if err != nil {
+			log.Printf("[WARN] 清理项目 %s 失败: %v", info.ID, err)
+			continue
+		}
+		reports = append(reports, report)
+	}
+	return reports, nil
+}
+
+func sweep(report *models.GCReport, dir string, referenced map[string]bool, urlFor func(string) string) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+
+	cutoff := time.Now().Add(-gcGracePeriod)
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		report.Scanned++
+		if referenced[entry.Name()] {
+			report.Referenced++
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		if info.ModTime().After(cutoff) {
+			report.Skipped++
+			continue
+		}
+
+		orphan := models.GCFile{Path: urlFor(entry.Name()), Size: info.Size()}
+		if !report.DryRun {
+			if err := os.Remove(filepath.Join(dir, entry.Name())); err != nil {
+				log.Printf("[WARN] 删除未引用文件失败 %s: %v", orphan.Path, err)
+			} else {
+				orphan.Removed = true
+				report.FreedBytes += orphan.Size
+			}
+		}
+		report.Orphans = append(report.Orphans, orphan)
+	}
+	return nil
+}
+
+// referencedAssets 返回被引用的图片和音频文件名集合。
+func (p *Project) referencedAssets() (images, audio map[string]bool, err error) {
+	images, audio = map[string]bool{}, map[string]bool{}
+	addImage := func(path string) {
+		if file, ok := p.Assets.ImageFile(path); ok {
+			images[filepath.Base(file)] = true
+		}
+	}
+	addAudio := func(path string) {
+		if file, ok := p.Assets.AudioFile(path); ok {
+			audio[filepath.Base(file)] = true
+		}
+	}
+	addCharacters := func(characters []models.CharacterProfile) {
+		for _, c := range characters {
+			addImage(c.ImagePath)
+			for _, v := range c.ImageVariants {
+				addImage(v.Path)
+			}
+		}
+	}
+	addScenes := func(scenes []models.Scene) {
+		for _, s := range scenes {
+			addImage(s.ImagePath)
+			addAudio(s.AudioPath)
+			for _, v := range s.ImageVariants {
+				addImage(v.Path)
+			}
+			for _, v := range s.AudioVariants {
+				addAudio(v.Path)
+			}
+		}
+	}
+
+	characters, err := p.LoadCharactersData()
+	if err != nil {
+		return nil, nil, err
+	}
+	addCharacters(characters)
+	scenes, err := p.LoadScenesData()
+	if err != nil {
+		return nil, nil, err
+	}
+	addScenes(scenes)
+
+	for _, kind := range []string{HistoryCharacters, HistoryScenes} {
+		ids, err := p.revisionIDs(kind)
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, id := range ids {
+			revision, err := p.LoadRevision(kind, id)
+			if err != nil {
+				// 读不出的历史版本无法判断引用关系，为安全起见中止清理。
+				return nil, nil, err
+			}
+			if kind == HistoryCharacters {
+				var data []models.CharacterProfile
+				if err := json.Unmarshal(revision.Data, &data); err != nil {
+					return nil, nil, err
+				}
+				addCharacters(data)
+			} else {
+				var data []models.Scene
+				if err := json.Unmarshal(revision.Data, &data); err != nil {
+					return nil, nil, err
+				}
+				addScenes(data)
+			}
+		}
+	}
+	return images, audio, nil
+}
@@ -0,0 +1,100 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"taco/backend/models"
+	"taco/backend/utils"
+)
+
+// writeAsset 写入一个生成文件，并把修改时间调到宽限期之前。
+func writeAsset(t *testing.T, dir, name string) string {
+	t.Helper()
+	os.MkdirAll(dir, 0o755)
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte("data"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	old := time.Now().Add(-2 * gcGracePeriod)
+	os.Chtimes(path, old, old)
+	return path
+}
+
+func TestCollectGarbage(t *testing.T) {
+	useTempPaths(t)
+	project := DefaultProject()
+	imagesDir, audioDir := utils.GeneratedImagesDir, utils.GeneratedAudioDir
+
+	active := writeAsset(t, imagesDir, "active.png")
+	variant := writeAsset(t, imagesDir, "variant.png")
+	inHistory := writeAsset(t, imagesDir, "history.png")
+	orphan := writeAsset(t, imagesDir, "orphan.png")
+	orphanAudio := writeAsset(t, audioDir, "orphan.mp3")
+	usedAudio := writeAsset(t, audioDir, "used.mp3")
+	fresh := filepath.Join(imagesDir, "fresh.png")
+	os.WriteFile(fresh, []byte("data"), 0o644)
+
+	project.SaveCharactersData([]models.CharacterProfile{{ID: "chr_a", ImagePath: "/generated/images/history.png"}})
+	project.SaveCharactersData([]models.CharacterProfile{{ID: "chr_a", ImagePath: "/generated/images/active.png"}})
+	project.SaveScenesData([]models.Scene{{
+		ID:            "scn_a",
+		AudioPath:     "/generated/audio/used.mp3",
+		ImageVariants: []models.AssetVariant{{ID: "var_a", Path: "/generated/images/variant.png"}},
+	}})
+
+	report, err := project.CollectGarbage(true)
+	if err != nil {
+		t.Fatalf("CollectGarbage failed: %v", err)
+	}
+	if report.Scanned != 7 || report.Referenced != 4 || report.Skipped != 1 || len(report.Orphans) != 2 {
+		t.Fatalf("Unexpected dry-run report: %+v", report)
+	}
+	for _, path := range []string{orphan, orphanAudio} {
+		if _, err := os.Stat(path); err != nil {
+			t.Errorf("Dry run should not delete %s", path)
+		}
+	}
+
+	report, err = project.CollectGarbage(false)
+	if err != nil {
+		t.Fatalf("CollectGarbage failed: %v", err)
+	}
+	if report.FreedBytes != 8 {
+		t.Errorf("Expected 8 bytes freed, got %d", report.FreedBytes)
+	}
+	for _, path := range []string{orphan, orphanAudio} {
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Errorf("Expected %s to be removed", path)
+		}
+	}
+	for _, path := range []string{active, variant, inHistory, usedAudio, fresh} {
+		if _, err := os.Stat(path); err != nil {
+			t.Errorf("Expected %s to be kept: %v", path, err)
+		}
+	}
+}
+
+func TestCollectAllGarbageCoversProjects(t *testing.T) {
+	useTempPaths(t)
+	info, err := CreateProject("另一个项目")
+	if err != nil {
+		t.Fatal(err)
+	}
+	project, _ := OpenProject(info.ID)
+	orphan := writeAsset(t, project.Assets.ImagesDir, "orphan.png")
+	writeAsset(t, utils.GeneratedImagesDir, "default-orphan.png")
+
+	reports, err := CollectAllGarbage(false)
+	if err != nil {
+		t.Fatalf("CollectAllGarbage failed: %v", err)
+	}
+	if len(reports) != 2 {
+		t.Fatalf("Expected a report per project, got %+v", reports)
+	}
+	if _, err := os.Stat(orphan); !os.IsNotExist(err) {
+		t.Error("Expected orphan in project directory to be removed")
+	}
+}
@@ -0,0 +1,282 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"taco/backend/models"
+)
+
+const (
+	HistoryCharacters = "characters"
+	HistoryScenes     = "scenes"
+
+	// maxRevisions 为每类数据保留的历史版本数，超出后删除最旧的记录。
+	maxRevisions = 200
+)
+
+var ErrRevisionNotFound = errors.New("历史版本不存在")
+
+func validHistoryKind(kind string) bool {
+	return kind == HistoryCharacters || kind == HistoryScenes
+}
+
+func (p *Project) historyDir(kind string) string {
+	return filepath.Join(p.HistoryDir, kind)
+}
+
+// recordRevision 在每次保存后追加一条历史版本；内容与最近一次相同时跳过。
+// 调用方需已持有对应数据文件的锁。历史记录失败只记日志，不影响保存本身。
+func (p *Project) recordRevision(kind string, data any, count int) {
+	raw, err := json.Marshal(data)
+	if err != nil {
+		log.Printf("[WARN] 序列化历史版本失败: %v", err)
+		return
+	}
+	version := dataVersion(data)
+
+	ids, err := p.revisionIDs(kind)
+	if err != nil {
+		log.Printf("[WARN] 读取历史版本失败: %v", err)
+		return
+	}
+	if len(ids) > 0 {
+		if latest, err := p.LoadRevision(kind, ids[0]); err == nil && latest.Version == version {
+			return
+		}
+	}
+
+	now := time.Now()
+	revision := models.Revision{
+		RevisionInfo: models.RevisionInfo{
+			ID:        fmt.Sprintf("%020d", now.UnixNano()),
+			Kind:      kind,
+			CreatedAt: now,
+			Version:   version,
+			Count:     count,
+		},
+		Data: raw,
+	}
+	if len(ids) > 0 && ids[0] >= revision.ID {
+		revision.ID = fmt.Sprintf("%020d", parseRevisionID(ids[0])+1)
+	}
+
+	dir := p.historyDir(kind)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		log.Printf("[WARN] 创建历史目录失败: %v", err)
+		return
+	}
+	content, err := json.Marshal(revision)
+	if err != nil {
+		log.Printf("[WARN] 序列化历史版本失败: %v", err)
+		return
+	}
+	if err := os.WriteFile(filepath.Join(dir, revision.ID+".json"), content, 0o644); err != nil {
+		log.Printf("[WARN] 写入历史版本失败: %v", err)
+		return
+	}
+
+	for i := maxRevisions - 1; i < len(ids); i++ {
+		os.Remove(filepath.Join(dir, ids[i]+".json"))
+	}
+}
+
+func parseRevisionID(id string) int64 {
+	var n int64
+	fmt.Sscanf(id, "%d", &n)
+	return n
+}
+
+// revisionIDs 按文件名返回全部历史版本 ID，最新的在前。
+func (p *Project) revisionIDs(kind string) ([]string, error) {
+	entries, err := os.ReadDir(p.historyDir(kind))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return []string{}, nil
+		}
+		return nil, err
+	}
+	ids := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		if id, ok := strings.CutSuffix(entry.Name(), ".json"); ok && !entry.IsDir() {
+			ids = append(ids, id)
+		}
+	}
+	sort.Sort(sort.Reverse(sort.StringSlice(ids)))
+	return ids, nil
+}
+
+// ListRevisions 返回历史版本列表（不含数据），最新的在前。
+func (p *Project) ListRevisions(kind string) ([]models.RevisionInfo, error) {
+	if !validHistoryKind(kind) {
+		return nil, ErrRevisionNotFound
+	}
+	ids, err := p.revisionIDs(kind)
+	if err != nil {
+		return nil, err
+	}
+	infos := make([]models.RevisionInfo, 0, len(ids))
+	for _, id := range ids {
+		revision, err := p.LoadRevision(kind, id)
+		if err != nil {
+			log.Printf("[WARN] 跳过无法读取的历史版本 %s: %v", id, err)
+			continue
+		}
+		infos = append(infos, revision.RevisionInfo)
+	}
+	return infos, nil
+}
+
+func (p *Project) LoadRevision(kind, id string) (models.Revision, error) {
+	if !validHistoryKind(kind) || id == "" || strings.ContainsAny(id, `/\.`) {
+		return models.Revision{}, ErrRevisionNotFound
+	}
+	data, err := os.ReadFile(filepath.Join(p.historyDir(kind), id+".json"))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return models.Revision{}, ErrRevisionNotFound
+		}
+		return models.Revision{}, err
+	}
+	var revision models.Revision
+	if err := json.Unmarshal(data, &revision); err != nil {
+		return models.Revision{}, err
+	}
+	return revision, nil
+}
+
+// RestoreCharactersRevision 把角色列表恢复到指定历史版本，恢复本身也会生成一条新版本。
+func (p *Project) RestoreCharactersRevision(id string) ([]models.CharacterProfile, error) {
+	revision, err := p.LoadRevision(HistoryCharacters, id)
+	if err != nil {
+		return nil, err
+	}
+	var characters []models.CharacterProfile
+	if err := json.Unmarshal(revision.Data, &characters); err != nil {
+		return nil, err
+	}
+	return p.ReplaceCharactersData(characters, "")
+}
+
+// RestoreScenesRevision 把场景列表恢复到指定历史版本，恢复本身也会生成一条新版本。
+func (p *Project) RestoreScenesRevision(id string) ([]models.Scene, error) {
+	revision, err := p.LoadRevision(HistoryScenes, id)
+	if err != nil {
+		return nil, err
+	}
+	var scenes []models.Scene
+	if err := json.Unmarshal(revision.Data, &scenes); err != nil {
+		return nil, err
+	}
+	return p.ReplaceScenesData(scenes, "")
+}
+
+// DiffRevisions 按条目 ID 比较两个历史版本，逐条列出新增、删除、修改和仅位置变化的条目。
+func (p *Project) DiffRevisions(kind, fromID, toID string) (models.RevisionDiff, error) {
+	from, err := p.LoadRevision(kind, fromID)
+	if err != nil {
+		return models.RevisionDiff{}, err
+	}
+	to, err := p.LoadRevision(kind, toID)
+	if err != nil {
+		return models.RevisionDiff{}, err
+	}
+
+	var before, after []map[string]any
+	if err := json.Unmarshal(from.Data, &before); err != nil {
+		return models.RevisionDiff{}, err
+	}
+	if err := json.Unmarshal(to.Data, &after); err != nil {
+		return models.RevisionDiff{}, err
+	}
+
+	return models.RevisionDiff{
+		Kind:    kind,
+		From:    fromID,
+		To:      toID,
+		Changes: diffItems(before, after),
+	}, nil
+}
+
+func diffItems(before, after []map[string]any) []models.ItemChange {
+	beforeIndex := make(map[string]int, len(before))
+	for i, item := range before {
+		beforeIndex[itemID(item)] = i
+	}
+	afterIndex := make(map[string]int, len(after))
+	for i, item := range after {
+		afterIndex[itemID(item)] = i
+	}
+
+	// 只比较两个版本共有条目之间的相对顺序，避免插入或删除一项后其后所有条目都被标记为移动。
+	beforeRank := make(map[string]int, len(before))
+	for _, item := range before {
+		if _, kept := afterIndex[itemID(item)]; kept {
+			beforeRank[itemID(item)] = len(beforeRank)
+		}
+	}
+
+	changes := []models.ItemChange{}
+	afterRank := 0
+	for _, item := range after {
+		id := itemID(item)
+		j, existed := beforeIndex[id]
+		if !existed {
+			changes = append(changes, models.ItemChange{ID: id, Label: itemLabel(item), Type: "added", After: item})
+			continue
+		}
+		if fields := changedFields(before[j], item); len(fields) > 0 {
+			changes = append(changes, models.ItemChange{ID: id, Label: itemLabel(item), Type: "modified", Fields: fields, Before: before[j], After: item})
+		} else if beforeRank[id] != afterRank {
+			changes = append(changes, models.ItemChange{ID: id, Label: itemLabel(item), Type: "moved"})
+		}
+		afterRank++
+	}
+	for _, item := range before {
+		id := itemID(item)
+		if _, kept := afterIndex[id]; !kept {
+			changes = append(changes, models.ItemChange{ID: id, Label: itemLabel(item), Type: "removed", Before: item})
+		}
+	}
+	return changes
+}
+
+func itemID(item map[string]any) string {
+	id, _ := item["id"].(string)
+	return id
+}
+
+// itemLabel 取场景标题或角色名称，便于在界面上展示。
+func itemLabel(item map[string]any) string {
+	if title, ok := item["title"].(string); ok && title != "" {
+		return title
+	}
+	name, _ := item["name"].(string)
+	return name
+}
+
+func changedFields(before, after map[string]any) []string {
+	keys := make(map[string]bool, len(before)+len(after))
+	for key := range before {
+		keys[key] = true
+	}
+	for key := range after {
+		keys[key] = true
+	}
+	var fields []string
+	for key := range keys {
+		if !reflect.DeepEqual(before[key], after[key]) {
+			fields = append(fields, key)
+		}
+	}
+	sort.Strings(fields)
+	return fields
+}
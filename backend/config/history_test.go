@@ -0,0 +1,97 @@
+package config
+
+import (
+	"testing"
+
+	"taco/backend/models"
+)
+
+func TestSaveRecordsRevisions(t *testing.T) {
+	useTempPaths(t)
+	project := DefaultProject()
+
+	project.SaveScenesData([]models.Scene{{ID: "scn_a", Title: "第一版"}})
+	project.SaveScenesData([]models.Scene{{ID: "scn_a", Title: "第一版"}})
+	project.SaveScenesData([]models.Scene{{ID: "scn_a", Title: "第二版"}})
+
+	revisions, err := project.ListRevisions(HistoryScenes)
+	if err != nil {
+		t.Fatalf("ListRevisions failed: %v", err)
+	}
+	if len(revisions) != 2 {
+		t.Fatalf("Expected 2 revisions (identical save skipped), got %d", len(revisions))
+	}
+	if revisions[0].ID <= revisions[1].ID {
+		t.Errorf("Expected newest revision first, got %s then %s", revisions[0].ID, revisions[1].ID)
+	}
+
+	current, _ := project.LoadScenesData()
+	if revisions[0].Version != ScenesVersion(current) {
+		t.Error("Expected latest revision version to match the current ETag version")
+	}
+}
+
+func TestRestoreScenesRevision(t *testing.T) {
+	useTempPaths(t)
+	project := DefaultProject()
+
+	project.SaveScenesData([]models.Scene{{ID: "scn_a", Title: "手工修改"}, {ID: "scn_b", Title: "保留"}})
+	project.SaveScenesData([]models.Scene{{ID: "scn_x", Title: "重新提取"}})
+
+	revisions, _ := project.ListRevisions(HistoryScenes)
+	restored, err := project.RestoreScenesRevision(revisions[1].ID)
+	if err != nil {
+		t.Fatalf("RestoreScenesRevision failed: %v", err)
+	}
+	if len(restored) != 2 || restored[0].Title != "手工修改" {
+		t.Fatalf("Unexpected restored scenes: %+v", restored)
+	}
+
+	revisions, _ = project.ListRevisions(HistoryScenes)
+	if len(revisions) != 3 {
+		t.Errorf("Expected restore to record a new revision, got %d revisions", len(revisions))
+	}
+
+	if _, err := project.RestoreScenesRevision("00000000000000000001"); err != ErrRevisionNotFound {
+		t.Errorf("Expected ErrRevisionNotFound, got %v", err)
+	}
+}
+
+func TestDiffRevisions(t *testing.T) {
+	useTempPaths(t)
+	project := DefaultProject()
+
+	project.SaveCharactersData([]models.CharacterProfile{
+		{ID: "chr_a", Name: "甲", Description: "旧描述"},
+		{ID: "chr_b", Name: "乙"},
+		{ID: "chr_c", Name: "丙"},
+	})
+	project.SaveCharactersData([]models.CharacterProfile{
+		{ID: "chr_new", Name: "丁"},
+		{ID: "chr_a", Name: "甲", Description: "新描述"},
+		{ID: "chr_c", Name: "丙"},
+	})
+
+	revisions, _ := project.ListRevisions(HistoryCharacters)
+	diff, err := project.DiffRevisions(HistoryCharacters, revisions[1].ID, revisions[0].ID)
+	if err != nil {
+		t.Fatalf("DiffRevisions failed: %v", err)
+	}
+
+	types := map[string]string{}
+	for _, change := range diff.Changes {
+		types[change.ID] = change.Type
+		if change.ID == "chr_a" && (len(change.Fields) != 1 || change.Fields[0] != "description") {
+			t.Errorf("Expected only description to change, got %v", change.Fields)
+		}
+	}
+	want := map[string]string{"chr_new": "added", "chr_a": "modified", "chr_b": "removed"}
+	for id, typ := range want {
+		if types[id] != typ {
+			t.Errorf("Expected %s to be %s, got %q", id, typ, types[id])
+		}
+	}
+	if _, ok := types["chr_c"]; ok {
+		t.Error("Unchanged character should not appear in diff")
+	}
+}
@@ -0,0 +1,69 @@
+package config
+
+import (
+	"regexp"
+	"strings"
+
+	"taco/backend/models"
+	"taco/backend/utils"
+)
+
+// ID 会用于生成文件名，只接受 NewID 生成的格式，避免客户端或归档中的 ID 包含路径分隔符。
+var (
+	characterIDPattern = regexp.MustCompile(`^` + models.CharacterIDPrefix + `_[A-Za-z0-9]+$`)
+	sceneIDPattern     = regexp.MustCompile(`^` + models.SceneIDPrefix + `_[A-Za-z0-9]+$`)
+)
+
+// EnsureCharacterIDs 为缺少 ID、ID 格式无效或重复的角色分配新 ID，返回是否有改动。
+func EnsureCharacterIDs(characters []models.CharacterProfile) bool {
+	changed := false
+	seen := make(map[string]bool, len(characters))
+	for i := range characters {
+		id := strings.TrimSpace(characters[i].ID)
+		if !characterIDPattern.MatchString(id) || seen[id] {
+			id = utils.NewID(models.CharacterIDPrefix)
+		}
+		if id != characters[i].ID {
+			characters[i].ID = id
+			changed = true
+		}
+		seen[id] = true
+	}
+	return changed
+}
+
+// EnsureSceneIDs 为缺少 ID、ID 格式无效或重复的场景分配新 ID，返回是否有改动。
+func EnsureSceneIDs(scenes []models.Scene) bool {
+	changed := false
+	seen := make(map[string]bool, len(scenes))
+	for i := range scenes {
+		id := strings.TrimSpace(scenes[i].ID)
+		if !sceneIDPattern.MatchString(id) || seen[id] {
+			id = utils.NewID(models.SceneIDPrefix)
+		}
+		if id != scenes[i].ID {
+			scenes[i].ID = id
+			changed = true
+		}
+		seen[id] = true
+	}
+	return changed
+}
+
+func FindCharacterIndex(characters []models.CharacterProfile, id string) int {
+	for i, character := range characters {
+		if character.ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+func FindSceneIndex(scenes []models.Scene, id string) int {
+	for i, scene := range scenes {
+		if scene.ID == id {
+			return i
+		}
+	}
+	return -1
+}
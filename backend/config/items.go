@@ -0,0 +1,196 @@
+package config
+
+import (
+	"errors"
+
+	"taco/backend/models"
+)
+
+var (
+	ErrCharacterNotFound = errors.New("角色不存在")
+	ErrSceneNotFound     = errors.New("场景不存在")
+	ErrOrderMismatch     = errors.New("排序列表与现有条目不一致")
+)
+
+// UpdateCharacter 在文件锁内按 ID 修改对应角色并保存，update 返回错误时放弃本次修改。
+// 耗时的生成任务结束后使用它回写结果，避免期间列表被重排、删除或被其他请求修改时写错条目。
+func (p *Project) UpdateCharacter(id string, update func(*models.CharacterProfile) error) (models.CharacterProfile, error) {
+	var updated models.CharacterProfile
+	_, err := p.MutateCharactersData(func(characters []models.CharacterProfile) ([]models.CharacterProfile, error) {
+		idx := FindCharacterIndex(characters, id)
+		if idx < 0 {
+			return nil, ErrCharacterNotFound
+		}
+		if err := update(&characters[idx]); err != nil {
+			return nil, err
+		}
+		characters[idx].ID = id
+		updated = characters[idx]
+		return characters, nil
+	})
+	return updated, err
+}
+
+// ReplaceCharactersData 用 characters 整体替换角色列表，ifMatch 非空时要求当前列表版本与之相同。
+func (p *Project) ReplaceCharactersData(characters []models.CharacterProfile, ifMatch string) ([]models.CharacterProfile, error) {
+	return p.MutateCharactersData(func(current []models.CharacterProfile) ([]models.CharacterProfile, error) {
+		if !VersionMatches(ifMatch, CharactersVersion(current)) {
+			return nil, ErrVersionMismatch
+		}
+		keepCharacterVariants(characters, current)
+		return characters, nil
+	})
+}
+
+// InsertCharacter 在 position 处插入角色，position 越界时追加到末尾。
+// 插入、删除和重排的 ifMatch 语义同 ReplaceCharactersData，删除时比较的是目标角色的版本。
+func (p *Project) InsertCharacter(position int, character models.CharacterProfile, ifMatch string) (models.CharacterProfile, error) {
+	characters, err := p.MutateCharactersData(func(characters []models.CharacterProfile) ([]models.CharacterProfile, error) {
+		if !VersionMatches(ifMatch, CharactersVersion(characters)) {
+			return nil, ErrVersionMismatch
+		}
+		if character.ID != "" && FindCharacterIndex(characters, character.ID) >= 0 {
+			character.ID = ""
+		}
+		character.ImageVariants = nil
+		if position < 0 || position > len(characters) {
+			position = len(characters)
+		}
+		characters = append(characters, models.CharacterProfile{})
+		copy(characters[position+1:], characters[position:])
+		characters[position] = character
+		return characters, nil
+	})
+	if err != nil {
+		return models.CharacterProfile{}, err
+	}
+	return characters[position], nil
+}
+
+func (p *Project) DeleteCharacter(id, ifMatch string) error {
+	_, err := p.MutateCharactersData(func(characters []models.CharacterProfile) ([]models.CharacterProfile, error) {
+		idx := FindCharacterIndex(characters, id)
+		if idx < 0 {
+			return nil, ErrCharacterNotFound
+		}
+		if !VersionMatches(ifMatch, CharacterVersion(characters[idx])) {
+			return nil, ErrVersionMismatch
+		}
+		return append(characters[:idx], characters[idx+1:]...), nil
+	})
+	return err
+}
+
+// ReorderCharacters 按 ids 的顺序重排角色，ids 必须恰好覆盖现有全部角色。
+func (p *Project) ReorderCharacters(ids []string, ifMatch string) ([]models.CharacterProfile, error) {
+	return p.MutateCharactersData(func(characters []models.CharacterProfile) ([]models.CharacterProfile, error) {
+		if !VersionMatches(ifMatch, CharactersVersion(characters)) {
+			return nil, ErrVersionMismatch
+		}
+		if len(ids) != len(characters) {
+			return nil, ErrOrderMismatch
+		}
+		reordered := make([]models.CharacterProfile, 0, len(characters))
+		used := make(map[int]bool, len(ids))
+		for _, id := range ids {
+			idx := FindCharacterIndex(characters, id)
+			if idx < 0 || used[idx] {
+				return nil, ErrOrderMismatch
+			}
+			used[idx] = true
+			reordered = append(reordered, characters[idx])
+		}
+		return reordered, nil
+	})
+}
+
+// UpdateScene 在文件锁内按 ID 修改对应场景并保存，update 返回错误时放弃本次修改。
+func (p *Project) UpdateScene(id string, update func(*models.Scene) error) (models.Scene, error) {
+	scenes, err := p.MutateScenesData(func(scenes []models.Scene) ([]models.Scene, error) {
+		idx := FindSceneIndex(scenes, id)
+		if idx < 0 {
+			return nil, ErrSceneNotFound
+		}
+		if err := update(&scenes[idx]); err != nil {
+			return nil, err
+		}
+		scenes[idx].ID = id
+		return scenes, nil
+	})
+	if err != nil {
+		return models.Scene{}, err
+	}
+	return scenes[FindSceneIndex(scenes, id)], nil
+}
+
+// ReplaceScenesData 用 scenes 整体替换场景列表，ifMatch 非空时要求当前列表版本与之相同。
+func (p *Project) ReplaceScenesData(scenes []models.Scene, ifMatch string) ([]models.Scene, error) {
+	return p.MutateScenesData(func(current []models.Scene) ([]models.Scene, error) {
+		if !VersionMatches(ifMatch, ScenesVersion(current)) {
+			return nil, ErrVersionMismatch
+		}
+		keepSceneVariants(scenes, current)
+		return scenes, nil
+	})
+}
+
+// InsertScene 在 position 处插入场景，position 越界时追加到末尾。
+func (p *Project) InsertScene(position int, scene models.Scene, ifMatch string) (models.Scene, error) {
+	scenes, err := p.MutateScenesData(func(scenes []models.Scene) ([]models.Scene, error) {
+		if !VersionMatches(ifMatch, ScenesVersion(scenes)) {
+			return nil, ErrVersionMismatch
+		}
+		if scene.ID != "" && FindSceneIndex(scenes, scene.ID) >= 0 {
+			scene.ID = ""
+		}
+		scene.ImageVariants, scene.AudioVariants = nil, nil
+		if position < 0 || position > len(scenes) {
+			position = len(scenes)
+		}
+		scenes = append(scenes, models.Scene{})
+		copy(scenes[position+1:], scenes[position:])
+		scenes[position] = scene
+		return scenes, nil
+	})
+	if err != nil {
+		return models.Scene{}, err
+	}
+	return scenes[position], nil
+}
+
+func (p *Project) DeleteScene(id, ifMatch string) error {
+	_, err := p.MutateScenesData(func(scenes []models.Scene) ([]models.Scene, error) {
+		idx := FindSceneIndex(scenes, id)
+		if idx < 0 {
+			return nil, ErrSceneNotFound
+		}
+		if !VersionMatches(ifMatch, SceneVersion(scenes[idx])) {
+			return nil, ErrVersionMismatch
+		}
+		return append(scenes[:idx], scenes[idx+1:]...), nil
+	})
+	return err
+}
+
+// ReorderScenes 按 ids 的顺序重排场景，ids 必须恰好覆盖现有全部场景。
+func (p *Project) ReorderScenes(ids []string, ifMatch string) ([]models.Scene, error) {
+	return p.MutateScenesData(func(scenes []models.Scene) ([]models.Scene, error) {
+		if !VersionMatches(ifMatch, ScenesVersion(scenes)) {
+			return nil, ErrVersionMismatch
+		}
+		if len(ids) != len(scenes) {
+			return nil, ErrOrderMismatch
+		}
+		reordered := make([]models.Scene, 0, len(scenes))
+		used := make(map[int]bool, len(ids))
+		for _, id := range ids {
+			idx := FindSceneIndex(scenes, id)
+			if idx < 0 || used[idx] {
+				return nil, ErrOrderMismatch
+			}
+			used[idx] = true
+			reordered = append(reordered, scenes[idx])
+		}
+		return reordered, nil
+	})
+}
@@ -0,0 +1,239 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"taco/backend/models"
+)
+
+// config.json、characters.json 和 scenes.json 都带有 schemaVersion 字段。读取时按顺序执行
+// 从文件版本到当前版本之间的迁移函数，执行前先备份原文件。没有该字段的旧文件视为版本 0。
+//
+// 新增迁移时在对应列表末尾追加一项即可，当前版本就是列表长度，已有的迁移不要修改。
+type migration struct {
+	description string
+	apply       func(p *Project, data []byte) ([]byte, error)
+}
+
+var configMigrations = []migration{
+	{"把扁平的 llmModel/imageModel 等字段改为嵌套结构，并拆出内嵌的角色和场景", migrateFlatConfig},
+	{"把 API Key 从 config.json 移到 secrets.json", migrateConfigSecrets},
+}
+
+var charactersMigrations = []migration{
+	{"把角色数组包装为带版本号的对象", wrapArray("characters")},
+}
+
+var scenesMigrations = []migration{
+	{"把场景数组包装为带版本号的对象", wrapArray("scenes")},
+}
+
+var (
+	configSchemaVersion     = len(configMigrations)
+	charactersSchemaVersion = len(charactersMigrations)
+	scenesSchemaVersion     = len(scenesMigrations)
+)
+
+// configFile、charactersFile 和 scenesFile 是当前版本的磁盘格式。
+type configFile struct {
+	SchemaVersion int `json:"schemaVersion"`
+	models.Config
+}
+
+type charactersFile struct {
+	SchemaVersion int                       `json:"schemaVersion"`
+	Characters    []models.CharacterProfile `json:"characters"`
+}
+
+type scenesFile struct {
+	SchemaVersion int            `json:"schemaVersion"`
+	Scenes        []models.Scene `json:"scenes"`
+}
+
+// schemaVersionOf 读取文件的版本号，数组或没有 schemaVersion 字段的对象为版本 0。
+func schemaVersionOf(data []byte) (int, error) {
+	trimmed := strings.TrimSpace(string(data))
+	if trimmed == "" || trimmed[0] != '{' {
+		return 0, nil
+	}
+	var header struct {
+		SchemaVersion int `json:"schemaVersion"`
+	}
+	if err := json.Unmarshal(data, &header); err != nil {
+		return 0, err
+	}
+	return header.SchemaVersion, nil
+}
+
+// migrateFile 把 data 升级到 migrations 对应的当前版本并写回 path，返回升级后的内容。
+// 文件已是当前版本时原样返回；版本高于当前程序支持的版本时报错，避免旧程序覆盖新数据。
+func (p *Project) migrateFile(path string, data []byte, migrations []migration) ([]byte, error) {
+	version, err := schemaVersionOf(data)
+	if err != nil {
+		return nil, err
+	}
+	current := len(migrations)
+	if version > current {
+		return nil, fmt.Errorf("%s 的版本 %d 高于当前支持的版本 %d，请升级程序", path, version, current)
+	}
+	if version == current {
+		return data, nil
+	}
+
+	backupPath := fmt.Sprintf("%s.v%d-%s.bak", path, version, time.Now().Format("20060102150405"))
+	if err := os.WriteFile(backupPath, data, 0o600); err != nil {
+		return nil, fmt.Errorf("备份 %s 失败: %w", path, err)
+	}
+	log.Printf("[INFO] 迁移 %s: 版本 %d -> %d，原文件已备份到 %s", path, version, current, backupPath)
+
+	for i := version; i < current; i++ {
+		data, err = migrations[i].apply(p, data)
+		if err != nil {
+			return nil, fmt.Errorf("迁移 %s 到版本 %d 失败（%s）: %w", path, i+1, migrations[i].description, err)
+		}
+	}
+
+	var doc map[string]json.RawMessage
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	doc["schemaVersion"] = json.RawMessage(fmt.Sprint(current))
+	data, err = json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	tmpPath := path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0o644); err != nil {
+		return nil, err
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// wrapArray 返回把旧版数组文件包装为 {"<field>": [...]} 的迁移。
+func wrapArray(field string) func(*Project, []byte) ([]byte, error) {
+	return func(_ *Project, data []byte) ([]byte, error) {
+		var items []json.RawMessage
+		if err := json.Unmarshal(data, &items); err != nil {
+			return nil, err
+		}
+		if items == nil {
+			items = []json.RawMessage{}
+		}
+		return json.Marshal(map[string]any{field: items})
+	}
+}
+
+// migrateFlatConfig 处理最早的配置格式：模型字段直接位于顶层，角色和场景也保存在 config.json 中。
+func migrateFlatConfig(p *Project, data []byte) ([]byte, error) {
+	var doc map[string]json.RawMessage
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	var flat struct {
+		LLMModel     string                    `json:"llmModel"`
+		LLMBaseURL   string                    `json:"llmBaseUrl"`
+		LLMAPIKey    string                    `json:"llmApiKey"`
+		ImageModel   string                    `json:"imageModel"`
+		ImageBaseURL string                    `json:"imageBaseUrl"`
+		ImageAPIKey  string                    `json:"imageApiKey"`
+		ImageSize    string                    `json:"imageSize"`
+		ImageQuality string                    `json:"imageQuality"`
+		Characters   []models.CharacterProfile `json:"characters"`
+		Scenes       []models.Scene            `json:"scenes"`
+	}
+	if err := json.Unmarshal(data, &flat); err != nil {
+		return nil, err
+	}
+	var nested struct {
+		LLM   models.LLMConfig   `json:"llm"`
+		Image models.ImageConfig `json:"image"`
+	}
+	if err := json.Unmarshal(data, &nested); err != nil {
+		return nil, err
+	}
+
+	overlayEmpty := func(dst *string, legacy string) {
+		if *dst == "" {
+			*dst = legacy
+		}
+	}
+	overlayEmpty(&nested.LLM.Model, flat.LLMModel)
+	overlayEmpty(&nested.LLM.BaseURL, flat.LLMBaseURL)
+	overlayEmpty(&nested.LLM.APIKey, flat.LLMAPIKey)
+	overlayEmpty(&nested.Image.Model, flat.ImageModel)
+	overlayEmpty(&nested.Image.BaseURL, flat.ImageBaseURL)
+	overlayEmpty(&nested.Image.APIKey, flat.ImageAPIKey)
+	overlayEmpty(&nested.Image.Size, flat.ImageSize)
+	overlayEmpty(&nested.Image.Quality, flat.ImageQuality)
+
+	if len(flat.Characters) > 0 {
+		if err := p.SaveCharactersData(flat.Characters); err != nil {
+			return nil, fmt.Errorf("迁移旧角色数据失败: %w", err)
+		}
+	}
+	if len(flat.Scenes) > 0 {
+		if err := p.SaveScenesData(flat.Scenes); err != nil {
+			return nil, fmt.Errorf("迁移旧场景数据失败: %w", err)
+		}
+	}
+
+	for _, key := range []string{"llmModel", "llmBaseUrl", "llmApiKey", "imageModel", "imageBaseUrl", "imageApiKey", "imageSize", "imageQuality", "characters", "scenes"} {
+		delete(doc, key)
+	}
+	var err error
+	if doc["llm"], err = json.Marshal(nested.LLM); err != nil {
+		return nil, err
+	}
+	if doc["image"], err = json.Marshal(nested.Image); err != nil {
+		return nil, err
+	}
+	return json.Marshal(doc)
+}
+
+// migrateConfigSecrets 把 config.json 中的 Key 移到 secrets.json。secrets.json 中已有的 Key 较新，保持不变。
+func migrateConfigSecrets(p *Project, data []byte) ([]byte, error) {
+	var cfg models.Config
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return nil, err
+	}
+	s, err := p.loadSecrets()
+	if err != nil {
+		return nil, err
+	}
+	for _, field := range secretFields(&cfg, &s) {
+		if *field.stored == "" {
+			*field.stored = *field.key
+		}
+	}
+	if err := p.saveSecrets(s); err != nil {
+		return nil, err
+	}
+
+	var doc map[string]json.RawMessage
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	for _, block := range []string{"llm", "image", "imageEdit", "voice"} {
+		raw, ok := doc[block]
+		if !ok {
+			continue
+		}
+		var fields map[string]json.RawMessage
+		if err := json.Unmarshal(raw, &fields); err != nil || fields == nil {
+			continue
+		}
+		delete(fields, "apiKey")
+		if doc[block], err = json.Marshal(fields); err != nil {
+			return nil, err
+		}
+	}
+	return json.Marshal(doc)
+}
@@ -0,0 +1,173 @@
+package config
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"taco/backend/utils"
+)
+
+func writeDataFile(t *testing.T, path, content string) {
+	t.Helper()
+	os.MkdirAll(filepath.Dir(path), 0o755)
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// assertMigrated 检查文件已升级到 want 版本，并且原内容备份在同目录下。
+func assertMigrated(t *testing.T, path, original string, want int) {
+	t.Helper()
+	data, _ := os.ReadFile(path)
+	if version, err := schemaVersionOf(data); err != nil || version != want {
+		t.Errorf("Expected %s at version %d, got %d (%v): %s", filepath.Base(path), want, version, err, data)
+	}
+	backups, _ := filepath.Glob(path + ".v*.bak")
+	if len(backups) != 1 {
+		t.Fatalf("Expected one backup of %s, got %v", filepath.Base(path), backups)
+	}
+	if backup, _ := os.ReadFile(backups[0]); string(backup) != original {
+		t.Errorf("Backup should hold the original content, got %s", backup)
+	}
+}
+
+func TestMigrateFlatConfig(t *testing.T) {
+	useTempPaths(t)
+	original := `{
+  "novelFile": "/novels/a.txt",
+  "llmModel": "gpt-3.5",
+  "llmBaseUrl": "https://old.example.com",
+  "llmApiKey": "sk-flat-llm",
+  "imageModel": "dalle",
+  "imageSize": "512x512",
+  "characterCount": 4,
+  "characters": [{"name": "张三", "description": "主角"}],
+  "scenes": [{"title": "开场", "description": "清晨"}]
+}`
+	writeDataFile(t, utils.ConfigPath, original)
+
+	cfg, err := LoadConfig()
+	if err != nil {
+		t.Fatalf("LoadConfig failed: %v", err)
+	}
+	if cfg.LLM.Model != "gpt-3.5" || cfg.LLM.BaseURL != "https://old.example.com" || cfg.LLM.APIKey != "sk-flat-llm" {
+		t.Errorf("Flat LLM fields not migrated: %+v", cfg.LLM)
+	}
+	if cfg.Image.Model != "dalle" || cfg.Image.Size != "512x512" || cfg.CharacterCount != 4 || cfg.NovelFile != "/novels/a.txt" {
+		t.Errorf("Unexpected migrated config: %+v", cfg)
+	}
+	assertMigrated(t, utils.ConfigPath, original, configSchemaVersion)
+
+	data, _ := os.ReadFile(utils.ConfigPath)
+	if strings.Contains(string(data), "llmModel") || strings.Contains(string(data), "sk-flat-llm") || strings.Contains(string(data), "张三") {
+		t.Errorf("Legacy fields should be removed from config.json: %s", data)
+	}
+
+	characters, _ := LoadCharactersData()
+	scenes, _ := LoadScenesData()
+	if len(characters) != 1 || characters[0].Name != "张三" || characters[0].ID == "" {
+		t.Errorf("Embedded characters not migrated: %+v", characters)
+	}
+	if len(scenes) != 1 || scenes[0].Title != "开场" {
+		t.Errorf("Embedded scenes not migrated: %+v", scenes)
+	}
+
+	// 再次读取不会重复迁移。
+	if _, err := LoadConfig(); err != nil {
+		t.Fatal(err)
+	}
+	if backups, _ := filepath.Glob(utils.ConfigPath + ".v*.bak"); len(backups) != 1 {
+		t.Errorf("Expected no further backups, got %v", backups)
+	}
+}
+
+func TestMigrateUnversionedNestedConfig(t *testing.T) {
+	useTempPaths(t)
+	original := `{"llm": {"model": "m", "baseUrl": "https://llm", "apiKey": "sk-nested"}, "voice": {"apiKey": "sk-voice"}, "sceneCount": 6}`
+	writeDataFile(t, utils.ConfigPath, original)
+
+	cfg, err := LoadConfig()
+	if err != nil {
+		t.Fatalf("LoadConfig failed: %v", err)
+	}
+	if cfg.LLM.APIKey != "sk-nested" || cfg.Voice.APIKey != "sk-voice" || cfg.SceneCount != 6 {
+		t.Errorf("Unexpected migrated config: %+v", cfg)
+	}
+	assertMigrated(t, utils.ConfigPath, original, configSchemaVersion)
+	data, _ := os.ReadFile(utils.ConfigPath)
+	if strings.Contains(string(data), "sk-") {
+		t.Errorf("Keys should be moved out of config.json: %s", data)
+	}
+}
+
+func TestMigrateConfigKeysKeepsNewerSecrets(t *testing.T) {
+	useTempPaths(t)
+	original := `{"schemaVersion": 1, "llm": {"model": "m", "apiKey": "sk-stale"}, "image": {"apiKey": "sk-image"}}`
+	writeDataFile(t, utils.ConfigPath, original)
+	writeDataFile(t, filepath.Join(filepath.Dir(utils.ConfigPath), "secrets.json"), `{"llmApiKey": "sk-current"}`)
+
+	cfg, err := LoadConfig()
+	if err != nil {
+		t.Fatalf("LoadConfig failed: %v", err)
+	}
+	if cfg.LLM.APIKey != "sk-current" || cfg.Image.APIKey != "sk-image" {
+		t.Errorf("Unexpected keys after migration: llm=%q image=%q", cfg.LLM.APIKey, cfg.Image.APIKey)
+	}
+	assertMigrated(t, utils.ConfigPath, original, configSchemaVersion)
+}
+
+func TestMigrateArrayDataFiles(t *testing.T) {
+	useTempPaths(t)
+	characters := `[{"name": "李四", "description": "配角"}]`
+	scenes := `[{"title": "夜晚", "description": "月光", "characters": ["李四"]}]`
+	writeDataFile(t, utils.CharactersPath, characters)
+	writeDataFile(t, utils.ScenesPath, scenes)
+
+	loadedCharacters, err := LoadCharactersData()
+	if err != nil || len(loadedCharacters) != 1 || loadedCharacters[0].Name != "李四" {
+		t.Fatalf("Unexpected characters %+v (%v)", loadedCharacters, err)
+	}
+	loadedScenes, err := LoadScenesData()
+	if err != nil || len(loadedScenes) != 1 || loadedScenes[0].Characters[0] != "李四" {
+		t.Fatalf("Unexpected scenes %+v (%v)", loadedScenes, err)
+	}
+	assertMigrated(t, utils.CharactersPath, characters, charactersSchemaVersion)
+	assertMigrated(t, utils.ScenesPath, scenes, scenesSchemaVersion)
+
+	var doc charactersFile
+	data, _ := os.ReadFile(utils.CharactersPath)
+	if err := json.Unmarshal(data, &doc); err != nil || len(doc.Characters) != 1 || doc.Characters[0].ID != loadedCharacters[0].ID {
+		t.Errorf("Expected versioned characters file with assigned IDs, got %s", data)
+	}
+}
+
+func TestSaveWritesCurrentSchemaVersion(t *testing.T) {
+	useTempPaths(t)
+	if _, err := LoadConfig(); err != nil {
+		t.Fatal(err)
+	}
+	for path, want := range map[string]int{
+		utils.ConfigPath:     configSchemaVersion,
+		utils.CharactersPath: charactersSchemaVersion,
+		utils.ScenesPath:     scenesSchemaVersion,
+	} {
+		data, _ := os.ReadFile(path)
+		if version, _ := schemaVersionOf(data); version != want {
+			t.Errorf("Expected %s at version %d, got %s", filepath.Base(path), want, data)
+		}
+		if backups, _ := filepath.Glob(path + ".v*.bak"); len(backups) != 0 {
+			t.Errorf("New files should not be backed up: %v", backups)
+		}
+	}
+}
+
+func TestRejectsNewerSchemaVersion(t *testing.T) {
+	useTempPaths(t)
+	writeDataFile(t, utils.ScenesPath, `{"schemaVersion": 99, "scenes": []}`)
+	if _, err := LoadScenesData(); err == nil {
+		t.Error("Expected error for a file written by a newer version")
+	}
+}
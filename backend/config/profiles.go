@@ -0,0 +1,145 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"taco/backend/models"
+)
+
+// 需要调用外部服务的操作，每个操作可以在 Config.Operations 中选用一个配置档案。
+const (
+	OpCharacterExtraction = "characterExtraction"
+	OpSceneExtraction     = "sceneExtraction"
+	OpCharacterImage      = "characterImage"
+	OpSceneImage          = "sceneImage"
+	OpSceneEdit           = "sceneEdit"
+	OpTTS                 = "tts"
+)
+
+var ErrUnknownProfile = errors.New("配置档案不存在")
+
+// operationProfile 返回操作选用的档案名称，空字符串表示使用顶层配置。
+func operationProfile(ops models.OperationProfiles, op string) (string, error) {
+	switch op {
+	case OpCharacterExtraction:
+		return ops.CharacterExtraction, nil
+	case OpSceneExtraction:
+		return ops.SceneExtraction, nil
+	case OpCharacterImage:
+		return ops.CharacterImage, nil
+	case OpSceneImage:
+		return ops.SceneImage, nil
+	case OpSceneEdit:
+		return ops.SceneEdit, nil
+	case OpTTS:
+		return ops.TTS, nil
+	}
+	return "", fmt.Errorf("未知的操作: %s", op)
+}
+
+func findProfile(cfg models.Config, name string) (models.ProviderProfile, bool) {
+	for _, profile := range cfg.Profiles {
+		if profile.Name == name {
+			return profile, true
+		}
+	}
+	return models.ProviderProfile{}, false
+}
+
+// ForOperation 返回执行 op 时实际使用的配置：选用的档案中非空的字段覆盖顶层配置。
+func ForOperation(cfg models.Config, op string) (models.Config, error) {
+	name, err := operationProfile(cfg.Operations, op)
+	if err != nil {
+		return cfg, err
+	}
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return cfg, nil
+	}
+	profile, ok := findProfile(cfg, name)
+	if !ok {
+		return cfg, fmt.Errorf("%w: %s", ErrUnknownProfile, name)
+	}
+
+	overlay(&cfg.LLM.Provider, profile.LLM.Provider)
+	overlay(&cfg.LLM.Model, profile.LLM.Model)
+	overlay(&cfg.LLM.BaseURL, profile.LLM.BaseURL)
+	overlay(&cfg.LLM.APIKey, profile.LLM.APIKey)
+	if profile.LLM.ChunkTokens > 0 {
+		cfg.LLM.ChunkTokens = profile.LLM.ChunkTokens
+	}
+	overlay(&cfg.LLM.JSONMode, profile.LLM.JSONMode)
+	if profile.LLM.RepairAttempts != 0 {
+		cfg.LLM.RepairAttempts = profile.LLM.RepairAttempts
+	}
+	if profile.LLM.MaxTokens > 0 {
+		cfg.LLM.MaxTokens = profile.LLM.MaxTokens
+	}
+	overlayImage(&cfg.Image, profile.Image)
+	overlayImage(&cfg.ImageEdit, profile.ImageEdit)
+	overlay(&cfg.Voice.Model, profile.Voice.Model)
+	overlay(&cfg.Voice.BaseURL, profile.Voice.BaseURL)
+	overlay(&cfg.Voice.APIKey, profile.Voice.APIKey)
+	overlay(&cfg.Voice.Voice, profile.Voice.Voice)
+	overlay(&cfg.Voice.Language, profile.Voice.Language)
+	overlay(&cfg.Voice.OutputDir, profile.Voice.OutputDir)
+	return cfg, nil
+}
+
+func overlayImage(dst *models.ImageConfig, src models.ImageConfig) {
+	overlay(&dst.Model, src.Model)
+	overlay(&dst.BaseURL, src.BaseURL)
+	overlay(&dst.APIKey, src.APIKey)
+	overlay(&dst.Size, src.Size)
+	overlay(&dst.Quality, src.Quality)
+}
+
+func overlay(dst *string, src string) {
+	if strings.TrimSpace(src) != "" {
+		*dst = src
+	}
+}
+
+// validateProfiles 检查档案名称唯一，且每个操作引用的档案都存在。
+func validateProfiles(cfg models.Config) error {
+	seen := map[string]bool{}
+	for _, profile := range cfg.Profiles {
+		name := strings.TrimSpace(profile.Name)
+		if name == "" {
+			return errors.New("配置档案名称不能为空")
+		}
+		if name != profile.Name {
+			return fmt.Errorf("配置档案名称无效: %q", profile.Name)
+		}
+		if seen[name] {
+			return fmt.Errorf("配置档案名称重复: %s", name)
+		}
+		seen[name] = true
+	}
+	for _, op := range []string{OpCharacterExtraction, OpSceneExtraction, OpCharacterImage, OpSceneImage, OpSceneEdit, OpTTS} {
+		name, _ := operationProfile(cfg.Operations, op)
+		if name = strings.TrimSpace(name); name != "" && !seen[name] {
+			return fmt.Errorf("操作 %s 引用的%w: %s", op, ErrUnknownProfile, name)
+		}
+	}
+	return nil
+}
+
+// cloneProfiles 复制档案切片，避免修改 Key 时影响调用方持有的配置。
+func cloneProfiles(cfg models.Config) models.Config {
+	if cfg.Profiles != nil {
+		cfg.Profiles = append([]models.ProviderProfile(nil), cfg.Profiles...)
+	}
+	return cfg
+}
+
+// LoadConfigFor 读取配置并应用操作 op 选用的配置档案。
+func (p *Project) LoadConfigFor(op string) (models.Config, error) {
+	cfg, err := p.LoadConfig()
+	if err != nil {
+		return cfg, err
+	}
+	return ForOperation(cfg, op)
+}
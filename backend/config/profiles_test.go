@@ -0,0 +1,113 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"taco/backend/models"
+	"taco/backend/utils"
+)
+
+func profileConfig() models.Config {
+	return models.Config{
+		LLM:   models.LLMConfig{Model: "draft-llm", BaseURL: "https://llm", APIKey: "sk-top-level-key"},
+		Image: models.ImageConfig{Model: "draft-image", BaseURL: "https://image", Size: "512x512"},
+		Profiles: []models.ProviderProfile{{
+			Name:  "final",
+			LLM:   models.LLMConfig{Model: "final-llm"},
+			Image: models.ImageConfig{Model: "final-image", APIKey: "sk-final-image-key"},
+		}},
+		Operations: models.OperationProfiles{SceneExtraction: "final", SceneImage: "final"},
+	}
+}
+
+func TestForOperation(t *testing.T) {
+	cfg := profileConfig()
+
+	resolved, err := ForOperation(cfg, OpCharacterExtraction)
+	if err != nil || resolved.LLM.Model != "draft-llm" {
+		t.Errorf("Operation without profile should use top-level config, got %q (%v)", resolved.LLM.Model, err)
+	}
+
+	resolved, err = ForOperation(cfg, OpSceneExtraction)
+	if err != nil {
+		t.Fatalf("ForOperation failed: %v", err)
+	}
+	if resolved.LLM.Model != "final-llm" || resolved.LLM.BaseURL != "https://llm" || resolved.LLM.APIKey != "sk-top-level-key" {
+		t.Errorf("Profile fields should override and empty fields inherit: %+v", resolved.LLM)
+	}
+
+	resolved, _ = ForOperation(cfg, OpSceneImage)
+	if resolved.Image.Model != "final-image" || resolved.Image.APIKey != "sk-final-image-key" || resolved.Image.Size != "512x512" {
+		t.Errorf("Unexpected image config: %+v", resolved.Image)
+	}
+
+	cfg.Operations.TTS = "missing"
+	if _, err := ForOperation(cfg, OpTTS); !errors.Is(err, ErrUnknownProfile) {
+		t.Errorf("Expected ErrUnknownProfile, got %v", err)
+	}
+	if _, err := ForOperation(cfg, "video"); err == nil {
+		t.Error("Expected error for unknown operation")
+	}
+}
+
+func TestValidateProfiles(t *testing.T) {
+	cfg := profileConfig()
+	if err := validateProfiles(cfg); err != nil {
+		t.Errorf("Expected valid profiles, got %v", err)
+	}
+
+	cfg.Operations.SceneEdit = "missing"
+	if err := validateProfiles(cfg); err == nil {
+		t.Error("Expected error for operation referencing a missing profile")
+	}
+
+	cfg = profileConfig()
+	cfg.Profiles = append(cfg.Profiles, models.ProviderProfile{Name: "final"})
+	if err := validateProfiles(cfg); err == nil {
+		t.Error("Expected error for duplicate profile names")
+	}
+}
+
+func TestProfileKeysStoredAsSecrets(t *testing.T) {
+	useTempPaths(t)
+	cfg := profileConfig()
+	if err := SaveConfig(cfg); err != nil {
+		t.Fatalf("SaveConfig failed: %v", err)
+	}
+	if cfg.Profiles[0].Image.APIKey != "sk-final-image-key" {
+		t.Error("SaveConfig should not modify the caller's profiles")
+	}
+
+	data, _ := os.ReadFile(utils.ConfigPath)
+	if strings.Contains(string(data), "sk-final-image-key") {
+		t.Errorf("Profile keys should not be written to config.json: %s", data)
+	}
+	secretsData, _ := os.ReadFile(filepath.Join(filepath.Dir(utils.ConfigPath), "secrets.json"))
+	if !strings.Contains(string(secretsData), "sk-final-image-key") {
+		t.Errorf("Expected profile key in secrets file: %s", secretsData)
+	}
+
+	loaded, err := LoadConfig()
+	if err != nil {
+		t.Fatalf("LoadConfig failed: %v", err)
+	}
+	if loaded.Profiles[0].Image.APIKey != "sk-final-image-key" || loaded.Operations.SceneImage != "final" {
+		t.Errorf("Profiles not restored: %+v", loaded)
+	}
+
+	redacted := RedactConfig(loaded)
+	if redacted.Profiles[0].Image.APIKey != "********-key" || loaded.Profiles[0].Image.APIKey != "sk-final-image-key" {
+		t.Errorf("Unexpected profile redaction: %+v", redacted.Profiles[0].Image)
+	}
+
+	// 调整档案顺序后，脱敏值仍按档案名称找回原来的 Key。
+	redacted.Profiles = append([]models.ProviderProfile{{Name: "cheap"}}, redacted.Profiles...)
+	merged := MergeSecrets(redacted, loaded)
+	if merged.Profiles[1].Image.APIKey != "sk-final-image-key" || merged.Profiles[0].Image.APIKey != "" {
+		t.Errorf("Unexpected merge result: %+v", merged.Profiles)
+	}
+}
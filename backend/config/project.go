@@ -0,0 +1,250 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"taco/backend/models"
+	"taco/backend/utils"
+)
+
+const (
+	DefaultProjectID   = "default"
+	defaultProjectName = "默认项目"
+	projectMetaFile    = "project.json"
+)
+
+var (
+	ErrProjectNotFound = errors.New("项目不存在")
+	// ErrProjectName 和 ErrDefaultProject 是请求本身无效的错误，其余错误来自读写磁盘。
+	ErrProjectName    = errors.New("项目名称不能为空")
+	ErrDefaultProject = errors.New("默认项目不能重命名或删除")
+	projectIDPattern  = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+)
+
+// Project 指向一个项目在磁盘上的全部数据：配置、角色、场景、上传文件与生成素材。
+// 默认项目沿用仓库根目录下的 config/、uploads/、generated/ 布局，
+// 其余项目位于 projects/<id>/ 下并拥有同样的子目录结构。
+type Project struct {
+	ID             string
+	Root           string
+	ConfigPath     string
+	CharactersPath string
+	ScenesPath     string
+	HistoryDir     string
+	UploadDir      string
+	GeneratedDir   string
+	Assets         utils.AssetDirs
+}
+
+func DefaultProject() *Project {
+	return &Project{
+		ID:             DefaultProjectID,
+		Root:           utils.ProjectRoot,
+		ConfigPath:     utils.ConfigPath,
+		CharactersPath: utils.CharactersPath,
+		ScenesPath:     utils.ScenesPath,
+		HistoryDir:     filepath.Join(filepath.Dir(utils.ScenesPath), "history"),
+		UploadDir:      utils.UploadDir,
+		GeneratedDir:   utils.GeneratedDir,
+		Assets:         utils.DefaultAssetDirs(),
+	}
+}
+
+func newProject(id string) *Project {
+	root := filepath.Join(utils.ProjectsDir, id)
+	generatedDir := filepath.Join(root, "generated")
+	urlPrefix := utils.ProjectsURLPrefix + id + "/generated/"
+	return &Project{
+		ID:             id,
+		Root:           root,
+		ConfigPath:     filepath.Join(root, "config", "config.json"),
+		CharactersPath: filepath.Join(root, "config", "characters.json"),
+		ScenesPath:     filepath.Join(root, "config", "scenes.json"),
+		HistoryDir:     filepath.Join(root, "config", "history"),
+		UploadDir:      filepath.Join(root, "uploads"),
+		GeneratedDir:   generatedDir,
+		Assets: utils.AssetDirs{
+			ImagesDir:       filepath.Join(generatedDir, "images"),
+			ImagesURLPrefix: urlPrefix + "images/",
+			AudioDir:        filepath.Join(generatedDir, "audio"),
+			AudioURLPrefix:  urlPrefix + "audio/",
+		},
+	}
+}
+
+func IsDefaultProject(id string) bool {
+	return id == "" || id == DefaultProjectID
+}
+
+// OpenProject 按 ID 打开项目，空 ID 表示默认项目。
+func OpenProject(id string) (*Project, error) {
+	id = strings.TrimSpace(id)
+	if IsDefaultProject(id) {
+		return DefaultProject(), nil
+	}
+	if !projectIDPattern.MatchString(id) {
+		return nil, ErrProjectNotFound
+	}
+	project := newProject(id)
+	if _, err := os.Stat(filepath.Join(project.Root, projectMetaFile)); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, ErrProjectNotFound
+		}
+		return nil, err
+	}
+	return project, nil
+}
+
+func (p *Project) EnsureDirs() error {
+	for _, dir := range []string{p.UploadDir, p.Assets.ImagesDir, p.Assets.AudioDir} {
+		if err := utils.EnsureDir(dir); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *Project) Info() (models.ProjectInfo, error) {
+	if IsDefaultProject(p.ID) {
+		return models.ProjectInfo{ID: DefaultProjectID, Name: defaultProjectName}, nil
+	}
+	data, err := os.ReadFile(filepath.Join(p.Root, projectMetaFile))
+	if err != nil {
+		return models.ProjectInfo{}, err
+	}
+	var info models.ProjectInfo
+	if err := json.Unmarshal(data, &info); err != nil {
+		return models.ProjectInfo{}, err
+	}
+	info.ID = p.ID
+	return info, nil
+}
+
+func (p *Project) saveInfo(info models.ProjectInfo) error {
+	if err := utils.EnsureDir(p.Root); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(info, "", "  ")
+	if err != nil {
+		return err
+	}
+	metaPath := filepath.Join(p.Root, projectMetaFile)
+	tmpPath := metaPath + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, metaPath)
+}
+
+func ListProjects() ([]models.ProjectInfo, error) {
+	defaultInfo, _ := DefaultProject().Info()
+	projects := []models.ProjectInfo{defaultInfo}
+
+	entries, err := os.ReadDir(utils.ProjectsDir)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return projects, nil
+		}
+		return nil, err
+	}
+
+	others := []models.ProjectInfo{}
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		project, err := OpenProject(entry.Name())
+		if err != nil {
+			continue
+		}
+		info, err := project.Info()
+		if err != nil {
+			continue
+		}
+		others = append(others, info)
+	}
+	sort.Slice(others, func(i, j int) bool {
+		return others[i].CreatedAt.Before(others[j].CreatedAt)
+	})
+	return append(projects, others...), nil
+}
+
+// CreateProject 新建项目，并沿用默认项目的模型配置（不含小说文件），
+// 避免每个项目都要重新填写接口地址和密钥。
+func CreateProject(name string) (models.ProjectInfo, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return models.ProjectInfo{}, ErrProjectName
+	}
+
+	project := newProject(utils.NewID("prj"))
+	info := models.ProjectInfo{
+		ID:        project.ID,
+		Name:      name,
+		CreatedAt: time.Now(),
+	}
+	if err := project.saveInfo(info); err != nil {
+		return models.ProjectInfo{}, err
+	}
+	if err := project.EnsureDirs(); err != nil {
+		return models.ProjectInfo{}, err
+	}
+
+	cfg, err := DefaultProject().LoadConfig()
+	if err != nil {
+		return models.ProjectInfo{}, fmt.Errorf("读取默认项目配置失败: %w", err)
+	}
+	cfg.NovelFile = ""
+	if err := project.SaveConfig(cfg); err != nil {
+		return models.ProjectInfo{}, err
+	}
+	if err := project.SaveCharactersData([]models.CharacterProfile{}); err != nil {
+		return models.ProjectInfo{}, err
+	}
+	if err := project.SaveScenesData([]models.Scene{}); err != nil {
+		return models.ProjectInfo{}, err
+	}
+	return info, nil
+}
+
+func RenameProject(id, name string) (models.ProjectInfo, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return models.ProjectInfo{}, ErrProjectName
+	}
+	if IsDefaultProject(id) {
+		return models.ProjectInfo{}, ErrDefaultProject
+	}
+	project, err := OpenProject(id)
+	if err != nil {
+		return models.ProjectInfo{}, err
+	}
+	info, err := project.Info()
+	if err != nil {
+		return models.ProjectInfo{}, err
+	}
+	info.Name = name
+	if err := project.saveInfo(info); err != nil {
+		return models.ProjectInfo{}, err
+	}
+	return info, nil
+}
+
+func DeleteProject(id string) error {
+	if IsDefaultProject(id) {
+		return ErrDefaultProject
+	}
+	project, err := OpenProject(id)
+	if err != nil {
+		return err
+	}
+	return os.RemoveAll(project.Root)
+}
@@ -0,0 +1,116 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"taco/backend/models"
+)
+
+func TestOpenProjectDefault(t *testing.T) {
+	useTempPaths(t)
+
+	project, err := OpenProject("")
+	if err != nil {
+		t.Fatalf("OpenProject failed: %v", err)
+	}
+	if project.ID != DefaultProjectID {
+		t.Errorf("Expected default project, got '%s'", project.ID)
+	}
+	if project.Assets.ImagesURLPrefix != "/generated/images/" {
+		t.Errorf("Default project should keep legacy image prefix, got '%s'", project.Assets.ImagesURLPrefix)
+	}
+}
+
+func TestOpenProjectNotFound(t *testing.T) {
+	useTempPaths(t)
+
+	for _, id := range []string{"missing", "../config", "a/b"} {
+		if _, err := OpenProject(id); !errors.Is(err, ErrProjectNotFound) {
+			t.Errorf("Expected ErrProjectNotFound for %q, got %v", id, err)
+		}
+	}
+}
+
+func TestCreateProjectIsolatesData(t *testing.T) {
+	useTempPaths(t)
+
+	if err := SaveConfig(models.Config{NovelFile: "/novels/a.txt", LLM: models.LLMConfig{Model: "m", BaseURL: "https://llm", APIKey: "k"}}); err != nil {
+		t.Fatalf("SaveConfig failed: %v", err)
+	}
+	if err := SaveScenesData([]models.Scene{{Title: "默认项目场景"}}); err != nil {
+		t.Fatalf("SaveScenesData failed: %v", err)
+	}
+
+	info, err := CreateProject("红楼梦")
+	if err != nil {
+		t.Fatalf("CreateProject failed: %v", err)
+	}
+	project, err := OpenProject(info.ID)
+	if err != nil {
+		t.Fatalf("OpenProject failed: %v", err)
+	}
+
+	cfg, err := project.LoadConfig()
+	if err != nil {
+		t.Fatalf("LoadConfig failed: %v", err)
+	}
+	if cfg.NovelFile != "" {
+		t.Errorf("New project should not inherit novel file, got '%s'", cfg.NovelFile)
+	}
+	if cfg.LLM.APIKey != "k" {
+		t.Errorf("New project should inherit provider settings, got API key '%s'", cfg.LLM.APIKey)
+	}
+
+	scenes, err := project.LoadScenesData()
+	if err != nil {
+		t.Fatalf("LoadScenesData failed: %v", err)
+	}
+	if len(scenes) != 0 {
+		t.Errorf("Expected new project to have no scenes, got %d", len(scenes))
+	}
+	if !strings.HasPrefix(project.Assets.ImagesURLPrefix, "/projects/"+info.ID+"/") {
+		t.Errorf("Unexpected image prefix '%s'", project.Assets.ImagesURLPrefix)
+	}
+	if _, err := os.Stat(project.Assets.ImagesDir); err != nil {
+		t.Errorf("Expected images dir to exist: %v", err)
+	}
+}
+
+func TestListRenameDeleteProject(t *testing.T) {
+	useTempPaths(t)
+
+	info, err := CreateProject("西游记")
+	if err != nil {
+		t.Fatalf("CreateProject failed: %v", err)
+	}
+
+	projects, err := ListProjects()
+	if err != nil {
+		t.Fatalf("ListProjects failed: %v", err)
+	}
+	if len(projects) != 2 || projects[0].ID != DefaultProjectID || projects[1].ID != info.ID {
+		t.Fatalf("Unexpected project list: %+v", projects)
+	}
+
+	renamed, err := RenameProject(info.ID, "西游记（修订）")
+	if err != nil {
+		t.Fatalf("RenameProject failed: %v", err)
+	}
+	if renamed.Name != "西游记（修订）" {
+		t.Errorf("Expected renamed project, got '%s'", renamed.Name)
+	}
+
+	if err := DeleteProject(DefaultProjectID); err == nil {
+		t.Error("Expected error when deleting default project")
+	}
+	if err := DeleteProject(info.ID); err != nil {
+		t.Fatalf("DeleteProject failed: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(newProject(info.ID).Root)); !os.IsNotExist(err) {
+		t.Error("Project directory should have been removed")
+	}
+}
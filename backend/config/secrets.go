@@ -0,0 +1,209 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"taco/backend/models"
+)
+
+// API Key 不再写入 config.json，而是保存在同目录下的 secrets.json（权限 0600），
+// 该文件不会通过任何接口返回。环境变量优先级最高，设置后对所有项目生效，且不会被写回文件。
+// 这些变量名与 env.go 中按字段路径生成的名称一致，由 applyEnvOverrides 统一处理。
+const (
+	EnvLLMAPIKey       = "TACO_LLM_API_KEY"
+	EnvImageAPIKey     = "TACO_IMAGE_API_KEY"
+	EnvImageEditAPIKey = "TACO_IMAGE_EDIT_API_KEY"
+	EnvVoiceAPIKey     = "TACO_VOICE_API_KEY"
+
+	secretsFile = "secrets.json"
+	// maskPrefix 开头的值表示前端回传的是脱敏后的 Key，保存时沿用原值。
+	maskPrefix = "********"
+)
+
+type secrets struct {
+	LLMAPIKey       string              `json:"llmApiKey,omitempty"`
+	ImageAPIKey     string              `json:"imageApiKey,omitempty"`
+	ImageEditAPIKey string              `json:"imageEditApiKey,omitempty"`
+	VoiceAPIKey     string              `json:"voiceApiKey,omitempty"`
+	Profiles        map[string]*secrets `json:"profiles,omitempty"`
+}
+
+// secretField 把配置中的一个 Key 与 secrets 字段、环境变量对应起来，
+// id 用于在两份配置之间匹配同一个 Key，不受档案顺序影响。
+type secretField struct {
+	id     string
+	key    *string
+	stored *string
+	env    string
+}
+
+// secretFields 列出顶层和各配置档案中的 Key。档案的 Key 按档案名称保存，不支持环境变量。
+func secretFields(cfg *models.Config, s *secrets) []secretField {
+	fields := []secretField{
+		{"llm", &cfg.LLM.APIKey, &s.LLMAPIKey, EnvLLMAPIKey},
+		{"image", &cfg.Image.APIKey, &s.ImageAPIKey, EnvImageAPIKey},
+		{"imageEdit", &cfg.ImageEdit.APIKey, &s.ImageEditAPIKey, EnvImageEditAPIKey},
+		{"voice", &cfg.Voice.APIKey, &s.VoiceAPIKey, EnvVoiceAPIKey},
+	}
+	for i := range cfg.Profiles {
+		profile := &cfg.Profiles[i]
+		if s.Profiles == nil {
+			s.Profiles = map[string]*secrets{}
+		}
+		stored := s.Profiles[profile.Name]
+		if stored == nil {
+			stored = &secrets{}
+			s.Profiles[profile.Name] = stored
+		}
+		prefix := "profiles/" + profile.Name + "/"
+		fields = append(fields,
+			secretField{prefix + "llm", &profile.LLM.APIKey, &stored.LLMAPIKey, ""},
+			secretField{prefix + "image", &profile.Image.APIKey, &stored.ImageAPIKey, ""},
+			secretField{prefix + "imageEdit", &profile.ImageEdit.APIKey, &stored.ImageEditAPIKey, ""},
+			secretField{prefix + "voice", &profile.Voice.APIKey, &stored.VoiceAPIKey, ""},
+		)
+	}
+	return fields
+}
+
+func (p *Project) secretsPath() string {
+	return filepath.Join(filepath.Dir(p.ConfigPath), secretsFile)
+}
+
+func (p *Project) loadSecrets() (secrets, error) {
+	var s secrets
+	data, err := os.ReadFile(p.secretsPath())
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return s, nil
+		}
+		return s, err
+	}
+	if err := json.Unmarshal(data, &s); err != nil {
+		return s, err
+	}
+	return s, nil
+}
+
+// applySecrets 用 secrets.json 中的 Key 覆盖配置，环境变量随后由 applyEnvOverrides 处理。
+// 旧版 config.json 中的 Key 由迁移移到 secrets.json。
+func (p *Project) applySecrets(cfg *models.Config) error {
+	s, err := p.loadSecrets()
+	if err != nil {
+		return err
+	}
+	for _, field := range secretFields(cfg, &s) {
+		if *field.stored != "" {
+			*field.key = *field.stored
+		}
+	}
+	return nil
+}
+
+// splitSecrets 把 Key 从配置中取出写入 secrets.json，返回不含 Key 的配置。
+// 由环境变量提供的 Key 不写入文件，secrets.json 中原有的值保持不变；LoadConfig 会把
+// LLM Key 填充到图像和语音配置，所以这里按值比较，而不只是看对应的环境变量是否存在。
+func (p *Project) splitSecrets(cfg models.Config) (models.Config, error) {
+	previous, err := p.loadSecrets()
+	if err != nil {
+		return cfg, err
+	}
+	// 已删除档案的 Key 不再保留。
+	s := previous
+	s.Profiles = nil
+	cfg = cloneProfiles(cfg)
+	fromEnv := map[string]bool{}
+	for _, field := range secretFields(&cfg, &s) {
+		if value := strings.TrimSpace(os.Getenv(field.env)); value != "" {
+			fromEnv[value] = true
+		}
+	}
+	for _, field := range secretFields(&cfg, &s) {
+		if !fromEnv[*field.key] {
+			*field.stored = *field.key
+		}
+		*field.key = ""
+	}
+
+	return cfg, p.saveSecrets(s)
+}
+
+func (p *Project) saveSecrets(s secrets) error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmpPath := p.secretsPath() + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, p.secretsPath())
+}
+
+// MaskSecret 返回脱敏后的 Key，只保留最后 4 位。
+func MaskSecret(key string) string {
+	if key == "" {
+		return ""
+	}
+	if len(key) <= 8 {
+		return maskPrefix
+	}
+	return maskPrefix + key[len(key)-4:]
+}
+
+// RedactConfig 返回可以发给浏览器的配置副本，所有 Key 均已脱敏。
+func RedactConfig(cfg models.Config) models.Config {
+	cfg = cloneProfiles(cfg)
+	var s secrets
+	for _, field := range secretFields(&cfg, &s) {
+		*field.key = MaskSecret(*field.key)
+	}
+	return cfg
+}
+
+// MergeSecrets 处理前端提交的配置：脱敏值表示未修改，沿用 current 中对应的 Key。
+// 档案按名称匹配，新增档案中的脱敏值视为空。
+func MergeSecrets(incoming, current models.Config) models.Config {
+	incoming = cloneProfiles(incoming)
+	var s secrets
+	known := map[string]string{}
+	for _, field := range secretFields(&current, &s) {
+		known[field.id] = *field.key
+	}
+	for _, field := range secretFields(&incoming, &s) {
+		if strings.HasPrefix(*field.key, maskPrefix) {
+			*field.key = known[field.id]
+		}
+	}
+	return incoming
+}
+
+// StripSecrets 清空配置中的所有 API Key。
+func StripSecrets(cfg models.Config) models.Config {
+	cfg = cloneProfiles(cfg)
+	var s secrets
+	for _, field := range secretFields(&cfg, &s) {
+		*field.key = ""
+	}
+	return cfg
+}
+
+// keepSecrets 用 current 中的 Key 填充 cfg 里为空的 Key，档案按名称匹配。
+func keepSecrets(cfg, current models.Config) models.Config {
+	cfg = cloneProfiles(cfg)
+	var s secrets
+	known := map[string]string{}
+	for _, field := range secretFields(&current, &s) {
+		known[field.id] = *field.key
+	}
+	for _, field := range secretFields(&cfg, &s) {
+		if *field.key == "" {
+			*field.key = known[field.id]
+		}
+	}
+	return cfg
+}
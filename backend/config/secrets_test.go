@@ -0,0 +1,103 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"taco/backend/models"
+	"taco/backend/utils"
+)
+
+func TestSaveConfigKeepsKeysOutOfConfigFile(t *testing.T) {
+	useTempPaths(t)
+
+	cfg := models.Config{
+		LLM:   models.LLMConfig{Model: "m", BaseURL: "https://llm", APIKey: "sk-llm-secret"},
+		Voice: models.VoiceConfig{APIKey: "sk-voice-secret"},
+	}
+	if err := SaveConfig(cfg); err != nil {
+		t.Fatalf("SaveConfig failed: %v", err)
+	}
+
+	data, _ := os.ReadFile(utils.ConfigPath)
+	if strings.Contains(string(data), "secret") {
+		t.Errorf("config.json should not contain API keys: %s", data)
+	}
+	secretsPath := filepath.Join(filepath.Dir(utils.ConfigPath), "secrets.json")
+	info, err := os.Stat(secretsPath)
+	if err != nil {
+		t.Fatalf("Expected secrets file: %v", err)
+	}
+	if info.Mode().Perm() != 0o600 {
+		t.Errorf("Expected secrets file mode 0600, got %v", info.Mode().Perm())
+	}
+
+	loaded, err := LoadConfig()
+	if err != nil {
+		t.Fatalf("LoadConfig failed: %v", err)
+	}
+	if loaded.LLM.APIKey != "sk-llm-secret" || loaded.Voice.APIKey != "sk-voice-secret" {
+		t.Errorf("Keys not loaded from secrets file: %+v", loaded)
+	}
+}
+
+func TestLegacyKeysInConfigFileStillLoad(t *testing.T) {
+	useTempPaths(t)
+	os.MkdirAll(filepath.Dir(utils.ConfigPath), 0o755)
+	os.WriteFile(utils.ConfigPath, []byte(`{"llm":{"model":"m","baseUrl":"https://llm","apiKey":"sk-legacy"}}`), 0o644)
+
+	cfg, err := LoadConfig()
+	if err != nil {
+		t.Fatalf("LoadConfig failed: %v", err)
+	}
+	if cfg.LLM.APIKey != "sk-legacy" {
+		t.Errorf("Expected legacy key, got %q", cfg.LLM.APIKey)
+	}
+}
+
+func TestEnvironmentKeysOverrideAndAreNotPersisted(t *testing.T) {
+	useTempPaths(t)
+	t.Setenv(EnvLLMAPIKey, "sk-from-env")
+	SaveConfig(models.Config{LLM: models.LLMConfig{APIKey: "sk-from-file"}})
+
+	cfg, _ := LoadConfig()
+	if cfg.LLM.APIKey != "sk-from-env" {
+		t.Errorf("Expected environment key to win, got %q", cfg.LLM.APIKey)
+	}
+
+	SaveConfig(cfg)
+	data, _ := os.ReadFile(filepath.Join(filepath.Dir(utils.ConfigPath), "secrets.json"))
+	if strings.Contains(string(data), "sk-from-env") {
+		t.Errorf("Environment key should not be written to disk: %s", data)
+	}
+	if !strings.Contains(string(data), "sk-from-file") {
+		t.Errorf("Stored key should be kept while the environment overrides it: %s", data)
+	}
+}
+
+func TestRedactAndMergeSecrets(t *testing.T) {
+	current := models.Config{
+		LLM:   models.LLMConfig{APIKey: "sk-1234567890abcd"},
+		Image: models.ImageConfig{APIKey: "short"},
+	}
+
+	redacted := RedactConfig(current)
+	if redacted.LLM.APIKey != "********abcd" || redacted.Image.APIKey != "********" || redacted.Voice.APIKey != "" {
+		t.Errorf("Unexpected redaction: %+v", redacted)
+	}
+	if current.LLM.APIKey != "sk-1234567890abcd" {
+		t.Error("RedactConfig should not modify its argument")
+	}
+
+	incoming := redacted
+	incoming.Image.APIKey = "sk-new-image"
+	merged := MergeSecrets(incoming, current)
+	if merged.LLM.APIKey != "sk-1234567890abcd" {
+		t.Errorf("Masked key should keep current value, got %q", merged.LLM.APIKey)
+	}
+	if merged.Image.APIKey != "sk-new-image" {
+		t.Errorf("New key should be used, got %q", merged.Image.APIKey)
+	}
+}
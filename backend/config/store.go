@@ -0,0 +1,83 @@
+package config
+
+import (
+	"path/filepath"
+	"sync"
+
+	"taco/backend/models"
+)
+
+// fileLocks 按数据文件的绝对路径保存互斥锁，同一文件的读写在进程内串行执行，
+// 不同项目、不同文件之间互不阻塞。
+var fileLocks sync.Map
+
+func lockFile(path string) func() {
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	value, _ := fileLocks.LoadOrStore(path, &sync.Mutex{})
+	mu := value.(*sync.Mutex)
+	mu.Lock()
+	return mu.Unlock
+}
+
+func (p *Project) LoadCharactersData() ([]models.CharacterProfile, error) {
+	defer lockFile(p.CharactersPath)()
+	return p.loadCharacters()
+}
+
+func (p *Project) SaveCharactersData(characters []models.CharacterProfile) error {
+	defer lockFile(p.CharactersPath)()
+	return p.saveCharacters(characters)
+}
+
+// MutateCharactersData 在持有文件锁期间读取角色列表、交给 mutate 修改并保存，
+// mutate 返回错误时不写入。所有针对单个角色的读改写都应通过它完成。
+func (p *Project) MutateCharactersData(mutate func([]models.CharacterProfile) ([]models.CharacterProfile, error)) ([]models.CharacterProfile, error) {
+	defer lockFile(p.CharactersPath)()
+	characters, err := p.loadCharacters()
+	if err != nil {
+		return nil, err
+	}
+	characters, err = mutate(characters)
+	if err != nil {
+		return nil, err
+	}
+	if characters == nil {
+		characters = []models.CharacterProfile{}
+	}
+	if err := p.saveCharacters(characters); err != nil {
+		return nil, err
+	}
+	return characters, nil
+}
+
+func (p *Project) LoadScenesData() ([]models.Scene, error) {
+	defer lockFile(p.ScenesPath)()
+	return p.loadScenes()
+}
+
+func (p *Project) SaveScenesData(scenes []models.Scene) error {
+	defer lockFile(p.ScenesPath)()
+	return p.saveScenes(scenes)
+}
+
+// MutateScenesData 与 MutateCharactersData 相同，作用于场景列表。
+func (p *Project) MutateScenesData(mutate func([]models.Scene) ([]models.Scene, error)) ([]models.Scene, error) {
+	defer lockFile(p.ScenesPath)()
+	scenes, err := p.loadScenes()
+	if err != nil {
+		return nil, err
+	}
+	scenes, err = mutate(scenes)
+	if err != nil {
+		return nil, err
+	}
+	if scenes == nil {
+		scenes = []models.Scene{}
+	}
+	if err := p.saveScenes(scenes); err != nil {
+		return nil, err
+	}
+	return NormalizeScenes(scenes), nil
+}
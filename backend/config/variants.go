@@ -0,0 +1,259 @@
+package config
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"log"
+
+	"taco/backend/models"
+	"taco/backend/utils"
+)
+
+const (
+	AssetImage = "image"
+	AssetAudio = "audio"
+)
+
+var (
+	ErrVariantNotFound = errors.New("素材版本不存在")
+	ErrUnknownAsset    = errors.New("未知的素材类型")
+)
+
+// variantSlot 指向条目上的一组素材版本以及当前使用的路径。
+type variantSlot struct {
+	variants *[]models.AssetVariant
+	active   *string
+}
+
+func characterSlot(c *models.CharacterProfile, asset string) (variantSlot, error) {
+	if asset != AssetImage {
+		return variantSlot{}, ErrUnknownAsset
+	}
+	return variantSlot{&c.ImageVariants, &c.ImagePath}, nil
+}
+
+func sceneSlot(s *models.Scene, asset string) (variantSlot, error) {
+	switch asset {
+	case AssetImage:
+		return variantSlot{&s.ImageVariants, &s.ImagePath}, nil
+	case AssetAudio:
+		return variantSlot{&s.AudioVariants, &s.AudioPath}, nil
+	default:
+		return variantSlot{}, ErrUnknownAsset
+	}
+}
+
+// adopt 把不在版本列表中的当前路径（旧数据或手动填写的路径）登记为一个版本，
+// ID 由路径计算得出，重复调用结果相同。
+func (s variantSlot) adopt() {
+	if *s.active == "" || s.indexOfPath(*s.active) >= 0 {
+		return
+	}
+	sum := sha256.Sum256([]byte(*s.active))
+	*s.variants = append(*s.variants, models.AssetVariant{
+		ID:     models.VariantIDPrefix + "_" + hex.EncodeToString(sum[:8]),
+		Path:   *s.active,
+		Source: models.VariantLegacy,
+	})
+}
+
+func (s variantSlot) indexOfPath(path string) int {
+	for i, v := range *s.variants {
+		if v.Path == path {
+			return i
+		}
+	}
+	return -1
+}
+
+func (s variantSlot) indexOf(id string) int {
+	for i, v := range *s.variants {
+		if v.ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+func (s variantSlot) add(variant models.AssetVariant) {
+	s.adopt()
+	if variant.ID == "" {
+		variant.ID = utils.NewID(models.VariantIDPrefix)
+	}
+	*s.variants = append(*s.variants, variant)
+	*s.active = variant.Path
+}
+
+func (s variantSlot) activate(id string) error {
+	s.adopt()
+	idx := s.indexOf(id)
+	if idx < 0 {
+		return ErrVariantNotFound
+	}
+	*s.active = (*s.variants)[idx].Path
+	return nil
+}
+
+// remove 删除一个版本；删除的是当前版本时改用最新的剩余版本，没有剩余版本则清空路径。
+func (s variantSlot) remove(id string) (models.AssetVariant, error) {
+	s.adopt()
+	idx := s.indexOf(id)
+	if idx < 0 {
+		return models.AssetVariant{}, ErrVariantNotFound
+	}
+	removed := (*s.variants)[idx]
+	*s.variants = append((*s.variants)[:idx], (*s.variants)[idx+1:]...)
+	if *s.active == removed.Path {
+		*s.active = ""
+		if n := len(*s.variants); n > 0 {
+			*s.active = (*s.variants)[n-1].Path
+		}
+	}
+	return removed, nil
+}
+
+func (s variantSlot) list() models.AssetVariantList {
+	s.adopt()
+	list := models.AssetVariantList{Variants: []models.AssetVariant{}}
+	for _, v := range *s.variants {
+		if v.Path == *s.active {
+			list.ActiveID = v.ID
+		}
+		list.Variants = append(list.Variants, v)
+	}
+	return list
+}
+
+// AddCharacterVariant 记录一次新生成或上传的角色图片并设为当前版本，旧版本文件保留。
+func (p *Project) AddCharacterVariant(id, asset string, variant models.AssetVariant) (models.CharacterProfile, error) {
+	return p.UpdateCharacter(id, func(c *models.CharacterProfile) error {
+		slot, err := characterSlot(c, asset)
+		if err != nil {
+			return err
+		}
+		slot.add(variant)
+		return nil
+	})
+}
+
+func (p *Project) CharacterVariants(id, asset string) (models.AssetVariantList, error) {
+	characters, err := p.LoadCharactersData()
+	if err != nil {
+		return models.AssetVariantList{}, err
+	}
+	idx := FindCharacterIndex(characters, id)
+	if idx < 0 {
+		return models.AssetVariantList{}, ErrCharacterNotFound
+	}
+	slot, err := characterSlot(&characters[idx], asset)
+	if err != nil {
+		return models.AssetVariantList{}, err
+	}
+	return slot.list(), nil
+}
+
+func (p *Project) ActivateCharacterVariant(id, asset, variantID string) (models.CharacterProfile, error) {
+	return p.UpdateCharacter(id, func(c *models.CharacterProfile) error {
+		slot, err := characterSlot(c, asset)
+		if err != nil {
+			return err
+		}
+		return slot.activate(variantID)
+	})
+}
+
+// DeleteCharacterVariant 从版本列表中删除一个版本。文件可能仍被历史版本或其他条目引用，
+// 因此不在这里删除，由 CollectGarbage 在宽限期后回收。
+func (p *Project) DeleteCharacterVariant(id, asset, variantID string) (models.CharacterProfile, error) {
+	var removed models.AssetVariant
+	character, err := p.UpdateCharacter(id, func(c *models.CharacterProfile) error {
+		slot, err := characterSlot(c, asset)
+		if err != nil {
+			return err
+		}
+		removed, err = slot.remove(variantID)
+		return err
+	})
+	if err != nil {
+		return models.CharacterProfile{}, err
+	}
+	log.Printf("[INFO] 删除角色 %s 的素材版本 %s: %s", id, removed.ID, removed.Path)
+	return character, nil
+}
+
+func (p *Project) AddSceneVariant(id, asset string, variant models.AssetVariant) (models.Scene, error) {
+	return p.UpdateScene(id, func(s *models.Scene) error {
+		slot, err := sceneSlot(s, asset)
+		if err != nil {
+			return err
+		}
+		slot.add(variant)
+		return nil
+	})
+}
+
+func (p *Project) SceneVariants(id, asset string) (models.AssetVariantList, error) {
+	scenes, err := p.LoadScenesData()
+	if err != nil {
+		return models.AssetVariantList{}, err
+	}
+	idx := FindSceneIndex(scenes, id)
+	if idx < 0 {
+		return models.AssetVariantList{}, ErrSceneNotFound
+	}
+	slot, err := sceneSlot(&scenes[idx], asset)
+	if err != nil {
+		return models.AssetVariantList{}, err
+	}
+	return slot.list(), nil
+}
+
+func (p *Project) ActivateSceneVariant(id, asset, variantID string) (models.Scene, error) {
+	return p.UpdateScene(id, func(s *models.Scene) error {
+		slot, err := sceneSlot(s, asset)
+		if err != nil {
+			return err
+		}
+		return slot.activate(variantID)
+	})
+}
+
+// DeleteSceneVariant 与 DeleteCharacterVariant 相同，文件留给 CollectGarbage 回收。
+func (p *Project) DeleteSceneVariant(id, asset, variantID string) (models.Scene, error) {
+	var removed models.AssetVariant
+	scene, err := p.UpdateScene(id, func(s *models.Scene) error {
+		slot, err := sceneSlot(s, asset)
+		if err != nil {
+			return err
+		}
+		removed, err = slot.remove(variantID)
+		return err
+	})
+	if err != nil {
+		return models.Scene{}, err
+	}
+	log.Printf("[INFO] 删除场景 %s 的素材版本 %s: %s", id, removed.ID, removed.Path)
+	return scene, nil
+}
+
+// keepCharacterVariants 让整体替换沿用已保存的素材版本：版本列表只由生成、上传和版本接口维护，
+// 前端保存列表时不会携带这些字段。
+func keepCharacterVariants(incoming, current []models.CharacterProfile) {
+	for i := range incoming {
+		incoming[i].ImageVariants = nil
+		if idx := FindCharacterIndex(current, incoming[i].ID); idx >= 0 && incoming[i].ID != "" {
+			incoming[i].ImageVariants = current[idx].ImageVariants
+		}
+	}
+}
+
+func keepSceneVariants(incoming, current []models.Scene) {
+	for i := range incoming {
+		incoming[i].ImageVariants, incoming[i].AudioVariants = nil, nil
+		if idx := FindSceneIndex(current, incoming[i].ID); idx >= 0 && incoming[i].ID != "" {
+			incoming[i].ImageVariants = current[idx].ImageVariants
+			incoming[i].AudioVariants = current[idx].AudioVariants
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"taco/backend/models"
+	"taco/backend/utils"
+)
+
+func TestSceneVariantsLifecycle(t *testing.T) {
+	useTempPaths(t)
+	project := DefaultProject()
+	project.SaveScenesData([]models.Scene{{ID: "scn_a", Title: "场景", ImagePath: "/generated/images/old.png"}})
+
+	scene, err := project.AddSceneVariant("scn_a", AssetImage, models.AssetVariant{Path: "/generated/images/new.png", Prompt: "提示词"})
+	if err != nil {
+		t.Fatalf("AddSceneVariant failed: %v", err)
+	}
+	if scene.ImagePath != "/generated/images/new.png" {
+		t.Errorf("Expected new variant to become active, got %s", scene.ImagePath)
+	}
+
+	list, err := project.SceneVariants("scn_a", AssetImage)
+	if err != nil {
+		t.Fatalf("SceneVariants failed: %v", err)
+	}
+	if len(list.Variants) != 2 {
+		t.Fatalf("Expected legacy image to be kept as a variant, got %+v", list.Variants)
+	}
+	legacy, latest := list.Variants[0], list.Variants[1]
+	if legacy.Source != models.VariantLegacy || legacy.Path != "/generated/images/old.png" {
+		t.Errorf("Unexpected legacy variant: %+v", legacy)
+	}
+	if list.ActiveID != latest.ID || latest.Prompt != "提示词" {
+		t.Errorf("Unexpected active variant %s in %+v", list.ActiveID, list.Variants)
+	}
+
+	scene, err = project.ActivateSceneVariant("scn_a", AssetImage, legacy.ID)
+	if err != nil {
+		t.Fatalf("ActivateSceneVariant failed: %v", err)
+	}
+	if scene.ImagePath != "/generated/images/old.png" {
+		t.Errorf("Expected legacy image to be active, got %s", scene.ImagePath)
+	}
+
+	if _, err := project.ActivateSceneVariant("scn_a", AssetImage, "var_missing"); !errors.Is(err, ErrVariantNotFound) {
+		t.Errorf("Expected ErrVariantNotFound, got %v", err)
+	}
+	if _, err := project.SceneVariants("scn_a", "video"); !errors.Is(err, ErrUnknownAsset) {
+		t.Errorf("Expected ErrUnknownAsset, got %v", err)
+	}
+}
+
+func TestDeleteActiveVariantFallsBackAndKeepsFile(t *testing.T) {
+	useTempPaths(t)
+	project := DefaultProject()
+	os.MkdirAll(utils.GeneratedAudioDir, 0o755)
+	for _, name := range []string{"a.mp3", "b.mp3"} {
+		os.WriteFile(filepath.Join(utils.GeneratedAudioDir, name), []byte("mp3"), 0o644)
+	}
+	project.SaveScenesData([]models.Scene{{ID: "scn_a"}})
+	project.AddSceneVariant("scn_a", AssetAudio, models.AssetVariant{ID: "var_a", Path: "/generated/audio/a.mp3"})
+	project.AddSceneVariant("scn_a", AssetAudio, models.AssetVariant{ID: "var_b", Path: "/generated/audio/b.mp3"})
+
+	scene, err := project.DeleteSceneVariant("scn_a", AssetAudio, "var_b")
+	if err != nil {
+		t.Fatalf("DeleteSceneVariant failed: %v", err)
+	}
+	if scene.AudioPath != "/generated/audio/a.mp3" {
+		t.Errorf("Expected fallback to remaining variant, got %s", scene.AudioPath)
+	}
+	// 历史版本可能仍引用该文件，删除版本时不删除文件，由 GC 回收。
+	if _, err := os.Stat(filepath.Join(utils.GeneratedAudioDir, "b.mp3")); err != nil {
+		t.Error("Expected deleted variant file to be left for GC")
+	}
+
+	scene, _ = project.DeleteSceneVariant("scn_a", AssetAudio, "var_a")
+	if scene.AudioPath != "" || len(scene.AudioVariants) != 0 {
+		t.Errorf("Expected no audio after deleting all variants, got %+v", scene)
+	}
+}
+
+func TestReplaceKeepsStoredVariants(t *testing.T) {
+	useTempPaths(t)
+	project := DefaultProject()
+	project.SaveCharactersData([]models.CharacterProfile{{ID: "chr_a", Name: "角色"}})
+	project.AddCharacterVariant("chr_a", AssetImage, models.AssetVariant{ID: "var_a", Path: "/generated/images/a.png"})
+
+	// 前端保存列表时不携带版本字段，替换后版本列表应保持不变。
+	saved, err := project.ReplaceCharactersData([]models.CharacterProfile{
+		{ID: "chr_a", Name: "改名", ImagePath: "/generated/images/a.png"},
+		{ID: "chr_b", Name: "新角色", ImageVariants: []models.AssetVariant{{ID: "var_x", Path: "/generated/images/a.png"}}},
+	}, "")
+	if err != nil {
+		t.Fatalf("ReplaceCharactersData failed: %v", err)
+	}
+	if len(saved[0].ImageVariants) != 1 || saved[0].ImageVariants[0].ID != "var_a" {
+		t.Errorf("Expected stored variants to be kept, got %+v", saved[0].ImageVariants)
+	}
+	if len(saved[1].ImageVariants) != 0 {
+		t.Errorf("Client-supplied variants should be ignored, got %+v", saved[1].ImageVariants)
+	}
+}
@@ -0,0 +1,47 @@
+package config
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+
+	"taco/backend/models"
+)
+
+// ErrVersionMismatch 表示写请求基于的版本已过期，数据在此期间被其他请求修改过。
+var ErrVersionMismatch = errors.New("数据已被其他人修改，请合并后重试")
+
+// dataVersion 以 JSON 序列化结果的 SHA-256 作为版本号，内容不变则版本不变。
+func dataVersion(v any) string {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return ""
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:8])
+}
+
+func CharactersVersion(characters []models.CharacterProfile) string {
+	if characters == nil {
+		characters = []models.CharacterProfile{}
+	}
+	return dataVersion(characters)
+}
+
+func CharacterVersion(character models.CharacterProfile) string {
+	return dataVersion(character)
+}
+
+func ScenesVersion(scenes []models.Scene) string {
+	return dataVersion(NormalizeScenes(scenes))
+}
+
+func SceneVersion(scene models.Scene) string {
+	return dataVersion(NormalizeScenes([]models.Scene{scene})[0])
+}
+
+// VersionMatches 判断写请求携带的版本是否仍是当前版本；未携带或为 "*" 时不做检查。
+func VersionMatches(expected, current string) bool {
+	return expected == "" || expected == "*" || expected == current
+}
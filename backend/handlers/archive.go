@@ -0,0 +1,104 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"taco/backend/config"
+	"taco/backend/utils"
+)
+
+// ExportProjectHandler 把当前项目打包为 zip 下载，归档中不包含 API Key。
+func ExportProjectHandler(w http.ResponseWriter, r *http.Request) {
+	log.Printf("[HTTP] %s %s - 来自 %s", r.Method, r.URL.Path, r.RemoteAddr)
+	if r.Method != http.MethodGet {
+		http.Error(w, "不支持的请求方法", http.StatusMethodNotAllowed)
+		return
+	}
+	project, ok := resolveProject(w, r)
+	if !ok {
+		return
+	}
+
+	// 先写入临时文件，打包失败时还能返回错误状态码，而不是一个残缺的下载；
+	// 图片和语音可能很多，不放在内存中。
+	file, err := os.CreateTemp("", "taco-export-*.zip")
+	if err != nil {
+		log.Printf("[ERROR] 创建临时文件失败: %v", err)
+		http.Error(w, fmt.Sprintf("导出项目失败: %v", err), http.StatusInternalServerError)
+		return
+	}
+	defer os.Remove(file.Name())
+	defer file.Close()
+
+	if err := project.ExportArchive(file); err != nil {
+		log.Printf("[ERROR] 导出项目失败: %v", err)
+		http.Error(w, fmt.Sprintf("导出项目失败: %v", err), http.StatusInternalServerError)
+		return
+	}
+	size, err := file.Seek(0, io.SeekCurrent)
+	if err == nil {
+		_, err = file.Seek(0, io.SeekStart)
+	}
+	if err != nil {
+		log.Printf("[ERROR] 读取项目归档失败: %v", err)
+		http.Error(w, fmt.Sprintf("导出项目失败: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	filename := fmt.Sprintf("taco-%s-%s.zip", project.ID, time.Now().Format("20060102-150405"))
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+	w.Header().Set("Content-Length", fmt.Sprint(size))
+	if _, err := io.Copy(w, file); err != nil {
+		log.Printf("[ERROR] 发送项目归档失败: %v", err)
+		return
+	}
+	log.Printf("[SUCCESS] 已导出项目: %s", project.ID)
+}
+
+// ImportProjectHandler 接收 multipart 字段 archive（可选 name），从归档新建一个项目。
+func ImportProjectHandler(w http.ResponseWriter, r *http.Request) {
+	log.Printf("[HTTP] %s %s - 来自 %s", r.Method, r.URL.Path, r.RemoteAddr)
+	if r.Method != http.MethodPost {
+		http.Error(w, "不支持的请求方法", http.StatusMethodNotAllowed)
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, utils.MaxArchiveSize)
+	if err := r.ParseMultipartForm(utils.MaxFileSize); err != nil {
+		log.Printf("[ERROR] 无法解析上传文件: %v", err)
+		http.Error(w, "无法解析上传文件", http.StatusBadRequest)
+		return
+	}
+	if r.MultipartForm != nil {
+		defer r.MultipartForm.RemoveAll()
+	}
+
+	file, header, err := r.FormFile("archive")
+	if err != nil {
+		http.Error(w, "未选择项目归档", http.StatusBadRequest)
+		return
+	}
+	defer file.Close()
+	log.Printf("[INFO] 接收到项目归档: %s, 大小: %d 字节", header.Filename, header.Size)
+
+	info, err := config.ImportArchive(file, header.Size, strings.TrimSpace(r.FormValue("name")))
+	if err != nil {
+		if errors.Is(err, config.ErrInvalidArchive) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		log.Printf("[ERROR] 导入项目失败: %v", err)
+		http.Error(w, fmt.Sprintf("导入项目失败: %v", err), http.StatusInternalServerError)
+		return
+	}
+	log.Printf("[SUCCESS] 已导入项目: %s (%s)", info.Name, info.ID)
+	utils.WriteJSONStatus(w, http.StatusCreated, info)
+}
@@ -0,0 +1,205 @@
+package handlers
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"taco/backend/config"
+	"taco/backend/models"
+	"taco/backend/services/novel"
+	"taco/backend/utils"
+)
+
+func importRequest(t *testing.T, archive []byte, name string) *http.Request {
+	t.Helper()
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	part, _ := writer.CreateFormFile("archive", "project.zip")
+	part.Write(archive)
+	if name != "" {
+		writer.WriteField("name", name)
+	}
+	writer.Close()
+	req := httptest.NewRequest(http.MethodPost, "/api/import/project", &body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	return req
+}
+
+func TestExportImportProjectRoundTrip(t *testing.T) {
+	useTempPaths(t)
+
+	os.MkdirAll(utils.UploadDir, 0o755)
+	os.MkdirAll(utils.GeneratedImagesDir, 0o755)
+	os.MkdirAll(utils.GeneratedAudioDir, 0o755)
+	novelPath := filepath.Join(utils.UploadDir, "1_novel.txt")
+	os.WriteFile(novelPath, []byte("小说正文"), 0o644)
+	os.WriteFile(novelPath+novel.OriginalSuffix, []byte("原始文件"), 0o644)
+	novel.SaveChapters(novelPath, []models.Chapter{{Index: 1, Title: "序", Start: 0, End: len("小说正文")}})
+	os.WriteFile(filepath.Join(utils.GeneratedImagesDir, "scene_a.png"), []byte("png"), 0o644)
+	os.WriteFile(filepath.Join(utils.GeneratedImagesDir, "character_a.png"), []byte("png"), 0o644)
+	os.WriteFile(filepath.Join(utils.GeneratedAudioDir, "scene_a.mp3"), []byte("mp3"), 0o644)
+
+	cfg, _ := config.LoadConfig()
+	cfg.NovelFile = novelPath
+	cfg.LLM.APIKey = "sk-secret-llm"
+	cfg.Image.APIKey = "sk-secret-image"
+	config.SaveConfig(cfg)
+	config.SaveCharactersData([]models.CharacterProfile{{ID: "chr_a", Name: "角色A", ImagePath: "/generated/images/character_a.png"}})
+	config.SaveScenesData([]models.Scene{{
+		ID:        "scn_a",
+		Title:     "场景A",
+		ImagePath: "/generated/images/scene_a.png",
+		AudioPath: "/generated/audio/scene_a.mp3",
+	}})
+
+	req := httptest.NewRequest(http.MethodGet, "/api/export/project", nil)
+	w := httptest.NewRecorder()
+	ExportProjectHandler(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if w.Header().Get("Content-Type") != "application/zip" {
+		t.Errorf("Expected zip content type, got %s", w.Header().Get("Content-Type"))
+	}
+	archive := w.Body.Bytes()
+
+	zr, err := zip.NewReader(bytes.NewReader(archive), int64(len(archive)))
+	if err != nil {
+		t.Fatalf("Export is not a valid zip: %v", err)
+	}
+	names := map[string]bool{}
+	for _, file := range zr.File {
+		names[file.Name] = true
+		if file.Name == "config.json" {
+			rc, _ := file.Open()
+			data, _ := io.ReadAll(rc)
+			rc.Close()
+			if strings.Contains(string(data), "sk-secret") {
+				t.Error("Exported config should not contain API keys")
+			}
+		}
+	}
+	for _, name := range []string{"manifest.json", "characters.json", "scenes.json", "uploads/1_novel.txt", "uploads/1_novel.txt.orig", "uploads/1_novel.txt.chapters.json", "generated/images/scene_a.png", "generated/images/character_a.png", "generated/audio/scene_a.mp3"} {
+		if !names[name] {
+			t.Errorf("Expected %s in archive, got %v", name, names)
+		}
+	}
+
+	w = httptest.NewRecorder()
+	ImportProjectHandler(w, importRequest(t, archive, "导入测试"))
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d: %s", w.Code, w.Body.String())
+	}
+	var info models.ProjectInfo
+	json.NewDecoder(w.Body).Decode(&info)
+	if info.Name != "导入测试" {
+		t.Errorf("Expected project name 导入测试, got %s", info.Name)
+	}
+
+	project, err := config.OpenProject(info.ID)
+	if err != nil {
+		t.Fatalf("Imported project not found: %v", err)
+	}
+	scenes, _ := project.LoadScenesData()
+	if len(scenes) != 1 || scenes[0].ID != "scn_a" {
+		t.Fatalf("Unexpected imported scenes: %+v", scenes)
+	}
+	wantImage := project.Assets.ImageURL("scene_a.png")
+	if scenes[0].ImagePath != wantImage {
+		t.Errorf("Expected image path %s, got %s", wantImage, scenes[0].ImagePath)
+	}
+	if file, ok := project.Assets.AudioFile(scenes[0].AudioPath); !ok {
+		t.Errorf("Audio path not rewritten: %s", scenes[0].AudioPath)
+	} else if data, _ := os.ReadFile(file); string(data) != "mp3" {
+		t.Errorf("Audio file not restored, got %q", data)
+	}
+	characters, _ := project.LoadCharactersData()
+	if len(characters) != 1 || characters[0].ImagePath != project.Assets.ImageURL("character_a.png") {
+		t.Errorf("Unexpected imported characters: %+v", characters)
+	}
+
+	importedCfg, _ := project.LoadConfig()
+	if data, err := os.ReadFile(importedCfg.NovelFile); err != nil || string(data) != "小说正文" {
+		t.Errorf("Novel file not restored: %s (%v)", importedCfg.NovelFile, err)
+	}
+	if chapters := novel.ReadChapters(importedCfg.NovelFile, "小说正文"); len(chapters) != 1 || chapters[0].Title != "序" {
+		t.Errorf("Chapter structure not restored, got %+v", chapters)
+	}
+	if data, err := os.ReadFile(importedCfg.NovelFile + novel.OriginalSuffix); err != nil || string(data) != "原始文件" {
+		t.Errorf("Original upload not restored (%v)", err)
+	}
+	if importedCfg.LLM.APIKey != "sk-secret-llm" {
+		t.Errorf("Imported project should keep local API keys, got %q", importedCfg.LLM.APIKey)
+	}
+}
+
+func TestImportProjectInvalidArchive(t *testing.T) {
+	useTempPaths(t)
+
+	w := httptest.NewRecorder()
+	ImportProjectHandler(w, importRequest(t, []byte("not a zip"), ""))
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("Expected status 400, got %d", w.Code)
+	}
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	zw.Create("scenes.json")
+	zw.Close()
+	w = httptest.NewRecorder()
+	ImportProjectHandler(w, importRequest(t, buf.Bytes(), ""))
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("Expected status 400 for archive without manifest, got %d", w.Code)
+	}
+
+	buf.Reset()
+	zw = zip.NewWriter(&buf)
+	manifest, _ := zw.Create("manifest.json")
+	manifest.Write([]byte(`{"format":"taco-project","version":1}`))
+	scenes, _ := zw.Create("scenes.json")
+	scenes.Write([]byte("{not json"))
+	zw.Close()
+	w = httptest.NewRecorder()
+	ImportProjectHandler(w, importRequest(t, buf.Bytes(), ""))
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("Expected status 400 for malformed scenes.json, got %d", w.Code)
+	}
+
+	projects, _ := config.ListProjects()
+	if len(projects) != 1 {
+		t.Errorf("Failed imports should not create projects, got %+v", projects)
+	}
+}
+
+func TestImportProjectIgnoresPathTraversal(t *testing.T) {
+	tmpDir := useTempPaths(t)
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	manifest, _ := zw.Create("manifest.json")
+	manifest.Write([]byte(`{"format":"taco-project","version":1,"name":"恶意"}`))
+	evil, _ := zw.Create("generated/images/../../../../escape.png")
+	evil.Write([]byte("x"))
+	zw.Close()
+
+	w := httptest.NewRecorder()
+	ImportProjectHandler(w, importRequest(t, buf.Bytes(), ""))
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d: %s", w.Code, w.Body.String())
+	}
+	if _, err := os.Stat(filepath.Join(tmpDir, "escape.png")); err == nil {
+		t.Error("Archive entry escaped the project directory")
+	}
+	if _, err := os.Stat(filepath.Join(filepath.Dir(tmpDir), "escape.png")); err == nil {
+		t.Error("Archive entry escaped the project directory")
+	}
+}
@@ -0,0 +1,163 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"taco/backend/config"
+	"taco/backend/models"
+)
+
+// newFakeProviders 启动一个同时模拟图像与语音接口的服务。生成请求会被挂起，
+// 直到 expected 个请求全部到达后才一起返回，让各请求回写结果的时间尽量重叠。
+func newFakeProviders(t *testing.T, expected int) *httptest.Server {
+	t.Helper()
+	var (
+		mu      sync.Mutex
+		arrived int
+		release = make(chan struct{})
+	)
+	wait := func() {
+		mu.Lock()
+		arrived++
+		if arrived == expected {
+			close(release)
+		}
+		mu.Unlock()
+		select {
+		case <-release:
+		case <-time.After(5 * time.Second):
+		}
+	}
+
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/chat/completions":
+			wait()
+			json.NewEncoder(w).Encode(map[string]any{
+				"choices": []map[string]any{
+					{"message": map[string]any{"content": server.URL + "/files/image.png"}},
+				},
+			})
+		case "/api/v1/services/aigc/multimodal-generation/generation":
+			wait()
+			json.NewEncoder(w).Encode(map[string]any{
+				"output": map[string]any{
+					"audio": map[string]any{"url": server.URL + "/files/audio.mp3"},
+				},
+			})
+		default:
+			w.Write([]byte("fake data"))
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestConcurrentSceneGenerationKeepsAllResults(t *testing.T) {
+	useTempPaths(t)
+	const sceneCount = 30
+	handlersToRun := []http.HandlerFunc{
+		GenerateSceneImageHandler,
+		GenerateSceneAudioHandler,
+	}
+	server := newFakeProviders(t, sceneCount*len(handlersToRun))
+
+	cfg := models.Config{
+		LLM:   models.LLMConfig{Model: "test-llm", BaseURL: server.URL, APIKey: "test-key"},
+		Image: models.ImageConfig{Model: "test-image", BaseURL: server.URL, APIKey: "test-key"},
+		Voice: models.VoiceConfig{Model: "test-tts", BaseURL: server.URL, APIKey: "test-key", Voice: "Cherry", Language: "Chinese"},
+	}
+	if err := config.SaveConfig(cfg); err != nil {
+		t.Fatalf("save config: %v", err)
+	}
+
+	scenes := make([]models.Scene, sceneCount)
+	for i := range scenes {
+		scenes[i] = models.Scene{
+			ID:          fmt.Sprintf("scn_%02d", i),
+			Title:       fmt.Sprintf("场景%d", i),
+			Description: fmt.Sprintf("描述%d", i),
+			Narration:   fmt.Sprintf("旁白%d", i),
+		}
+	}
+	if err := config.SaveScenesData(scenes); err != nil {
+		t.Fatalf("seed scenes: %v", err)
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan string, sceneCount*len(handlersToRun))
+	for _, scene := range scenes {
+		for _, handler := range handlersToRun {
+			wg.Add(1)
+			go func(id string, handler http.HandlerFunc) {
+				defer wg.Done()
+				body, _ := json.Marshal(map[string]string{"id": id})
+				req := httptest.NewRequest(http.MethodPost, "/api/scenes/generate", bytes.NewReader(body))
+				w := httptest.NewRecorder()
+				handler(w, req)
+				if w.Code != http.StatusOK {
+					errs <- fmt.Sprintf("%s: status %d: %s", id, w.Code, w.Body.String())
+				}
+			}(scene.ID, handler)
+		}
+	}
+	wg.Wait()
+	close(errs)
+	for msg := range errs {
+		t.Error(msg)
+	}
+
+	saved, err := config.LoadScenesData()
+	if err != nil {
+		t.Fatalf("load scenes: %v", err)
+	}
+	if len(saved) != sceneCount {
+		t.Fatalf("Expected %d scenes, got %d", sceneCount, len(saved))
+	}
+	for _, scene := range saved {
+		if scene.ImagePath == "" {
+			t.Errorf("Scene %s lost its image path", scene.ID)
+		}
+		if scene.AudioPath == "" {
+			t.Errorf("Scene %s lost its audio path", scene.ID)
+		}
+	}
+}
+
+func TestConcurrentSceneEditsDoNotOverwriteEachOther(t *testing.T) {
+	useTempPaths(t)
+
+	const sceneCount = 50
+	scenes := make([]models.Scene, sceneCount)
+	for i := range scenes {
+		scenes[i] = models.Scene{ID: fmt.Sprintf("scn_%02d", i), Title: "原标题"}
+	}
+	config.SaveScenesData(scenes)
+
+	var wg sync.WaitGroup
+	for i := 0; i < sceneCount; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			body := []byte(fmt.Sprintf(`{"title":"标题%d"}`, i))
+			req := httptest.NewRequest(http.MethodPatch, fmt.Sprintf("/api/scenes/scn_%02d", i), bytes.NewReader(body))
+			SceneItemHandler(httptest.NewRecorder(), req)
+		}(i)
+	}
+	wg.Wait()
+
+	saved, _ := config.LoadScenesData()
+	for i, scene := range saved {
+		if want := fmt.Sprintf("标题%d", i); scene.Title != want {
+			t.Errorf("Scene %s: expected title %s, got %s", scene.ID, want, scene.Title)
+		}
+	}
+}
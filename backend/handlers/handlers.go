@@ -3,6 +3,7 @@ package handlers
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
@@ -17,21 +18,27 @@ import (
 	"taco/backend/services/audio"
 	"taco/backend/services/image"
 	"taco/backend/services/llm"
+	"taco/backend/services/novel"
 	"taco/backend/utils"
 )
 
 func ConfigHandler(w http.ResponseWriter, r *http.Request) {
 	log.Printf("[HTTP] %s %s - 来自 %s", r.Method, r.URL.Path, r.RemoteAddr)
+	project, ok := resolveProject(w, r)
+	if !ok {
+		return
+	}
+
 	switch r.Method {
 	case http.MethodGet:
-		cfg, err := config.LoadConfig()
+		cfg, err := project.LoadConfig()
 		if err != nil {
 			log.Printf("[ERROR] 读取配置失败: %v", err)
 			http.Error(w, fmt.Sprintf("读取配置失败: %v", err), http.StatusInternalServerError)
 			return
 		}
 		log.Printf("[SUCCESS] 成功读取配置")
-		utils.WriteJSON(w, cfg)
+		utils.WriteJSON(w, config.RedactConfig(cfg))
 
 	case http.MethodPost:
 		var cfg models.Config
@@ -40,18 +47,27 @@ func ConfigHandler(w http.ResponseWriter, r *http.Request) {
 			http.Error(w, "请求数据无效", http.StatusBadRequest)
 			return
 		}
+		current, err := project.LoadConfig()
+		if err != nil {
+			log.Printf("[ERROR] 读取配置失败: %v", err)
+			http.Error(w, fmt.Sprintf("读取配置失败: %v", err), http.StatusInternalServerError)
+			return
+		}
+		cfg = config.MergeSecrets(cfg, current)
+		// 提示词模板只通过 /api/prompts 修改，这里沿用已保存的模板，避免旧页面或脚本覆盖或清空它们。
+		cfg.Prompts = current.Prompts
 		if err := config.ValidateConfig(cfg); err != nil {
 			log.Printf("[ERROR] 配置验证失败: %v", err)
 			http.Error(w, err.Error(), http.StatusBadRequest)
 			return
 		}
-		if err := config.SaveConfig(cfg); err != nil {
+		if err := project.SaveConfig(cfg); err != nil {
 			log.Printf("[ERROR] 保存配置失败: %v", err)
 			http.Error(w, fmt.Sprintf("保存配置失败: %v", err), http.StatusInternalServerError)
 			return
 		}
 		log.Printf("[SUCCESS] 成功保存配置")
-		utils.WriteJSON(w, cfg)
+		utils.WriteJSON(w, config.RedactConfig(cfg))
 
 	default:
 		http.Error(w, "不支持的请求方法", http.StatusMethodNotAllowed)
@@ -64,6 +80,10 @@ func UploadHandler(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "不支持的请求方法", http.StatusMethodNotAllowed)
 		return
 	}
+	project, ok := resolveProject(w, r)
+	if !ok {
+		return
+	}
 
 	if err := r.ParseMultipartForm(utils.MaxFileSize); err != nil {
 		log.Printf("[ERROR] 无法解析上传文件: %v", err)
@@ -80,46 +100,85 @@ func UploadHandler(w http.ResponseWriter, r *http.Request) {
 	defer file.Close()
 	log.Printf("[INFO] 接收到文件上传: %s, 大小: %d 字节", header.Filename, header.Size)
 
-	if err := os.MkdirAll(utils.UploadDir, 0o755); err != nil {
+	encoding := r.FormValue("encoding")
+	if _, err := novel.CanonicalEncoding(encoding); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := os.MkdirAll(project.UploadDir, 0o755); err != nil {
 		http.Error(w, "创建上传目录失败", http.StatusInternalServerError)
 		return
 	}
 
+	data, err := io.ReadAll(file)
+	if err != nil {
+		log.Printf("[ERROR] 读取上传文件失败: %v", err)
+		http.Error(w, "读取上传文件失败", http.StatusBadRequest)
+		return
+	}
+
 	filename := filepath.Base(header.Filename)
-	if filename == "" {
+	if filename == "" || filename == "." || filename == string(filepath.Separator) {
 		filename = "novel.txt"
 	}
+	title := strings.TrimSuffix(filename, filepath.Ext(filename))
+	if format := novel.DetectFormat(data); format != novel.FormatText {
+		filename = title + ".txt"
+	}
 	targetName := fmt.Sprintf("%d_%s", time.Now().UnixNano(), filename)
-	targetPath := filepath.Join(utils.UploadDir, targetName)
+	targetPath := filepath.Join(project.UploadDir, targetName)
 
-	dst, err := os.Create(targetPath)
+	// 内容完全相同的文件只保留一份，检查和预留在同一把锁内完成，同时上传的副本同样会被拒绝。
+	library := novel.OpenLibrary(project.UploadDir)
+	existing, release, err := library.Reserve(targetName, novel.HashContent(data))
+	if errors.Is(err, novel.ErrDuplicateNovel) {
+		log.Printf("[WARN] 重复上传的小说: %s（已有 %s）", header.Filename, existing.ID)
+		http.Error(w, fmt.Sprintf("该小说已上传过：%s", existing.Title), http.StatusConflict)
+		return
+	}
 	if err != nil {
-		http.Error(w, "保存文件失败", http.StatusInternalServerError)
+		log.Printf("[ERROR] 读取小说库失败: %v", err)
+		http.Error(w, fmt.Sprintf("读取小说库失败: %v", err), http.StatusInternalServerError)
 		return
 	}
-	defer dst.Close()
+	defer release()
 
-	if _, err := io.Copy(dst, file); err != nil {
+	// 原始文件保存为 .orig，转换为 UTF-8 的文本保存在 targetPath。
+	if err := os.WriteFile(targetPath+novel.OriginalSuffix, data, 0o644); err != nil {
 		log.Printf("[ERROR] 写入文件失败: %v", err)
 		http.Error(w, "写入文件失败", http.StatusInternalServerError)
 		return
 	}
 
-	log.Printf("[SUCCESS] 文件上传成功: %s", targetPath)
-	utils.WriteJSON(w, map[string]string{
-		"filePath": targetPath,
-	})
+	upload, err := convertNovel(library, targetName, encoding, title)
+	if err != nil {
+		log.Printf("[ERROR] 转换小说失败: %v", err)
+		os.Remove(targetPath + novel.OriginalSuffix)
+		os.Remove(targetPath)
+		http.Error(w, fmt.Sprintf("转换小说失败: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	log.Printf("[SUCCESS] 文件上传成功: %s（格式 %s，编码 %s，无法解码 %d 处，%d 章）", targetPath, upload.Format, upload.Encoding, upload.Invalid, upload.Chapters)
+	utils.WriteJSON(w, upload)
 }
 
 func CharactersHandler(w http.ResponseWriter, r *http.Request) {
 	log.Printf("[HTTP] %s %s - 来自 %s", r.Method, r.URL.Path, r.RemoteAddr)
+	project, ok := resolveProject(w, r)
+	if !ok {
+		return
+	}
+
 	switch r.Method {
 	case http.MethodGet:
-		characters, err := config.LoadCharactersData()
+		characters, err := project.LoadCharactersData()
 		if err != nil {
 			http.Error(w, fmt.Sprintf("读取角色失败: %v", err), http.StatusInternalServerError)
 			return
 		}
+		setETag(w, config.CharactersVersion(characters))
 		utils.WriteJSON(w, characters)
 	case http.MethodPost:
 		var characters []models.CharacterProfile
@@ -130,11 +189,17 @@ func CharactersHandler(w http.ResponseWriter, r *http.Request) {
 		if characters == nil {
 			characters = []models.CharacterProfile{}
 		}
-		if err := config.SaveCharactersData(characters); err != nil {
+		saved, err := project.ReplaceCharactersData(characters, ifMatchVersion(r))
+		if errors.Is(err, config.ErrVersionMismatch) {
+			writeCharactersConflict(w, project)
+			return
+		}
+		if err != nil {
 			http.Error(w, fmt.Sprintf("保存角色失败: %v", err), http.StatusInternalServerError)
 			return
 		}
-		utils.WriteJSON(w, characters)
+		setETag(w, config.CharactersVersion(saved))
+		utils.WriteJSON(w, saved)
 	default:
 		http.Error(w, "不支持的请求方法", http.StatusMethodNotAllowed)
 	}
@@ -142,13 +207,19 @@ func CharactersHandler(w http.ResponseWriter, r *http.Request) {
 
 func ScenesHandler(w http.ResponseWriter, r *http.Request) {
 	log.Printf("[HTTP] %s %s - 来自 %s", r.Method, r.URL.Path, r.RemoteAddr)
+	project, ok := resolveProject(w, r)
+	if !ok {
+		return
+	}
+
 	switch r.Method {
 	case http.MethodGet:
-		scenes, err := config.LoadScenesData()
+		scenes, err := project.LoadScenesData()
 		if err != nil {
 			http.Error(w, fmt.Sprintf("读取场景失败: %v", err), http.StatusInternalServerError)
 			return
 		}
+		setETag(w, config.ScenesVersion(scenes))
 		utils.WriteJSON(w, scenes)
 	case http.MethodPost:
 		var scenes []models.Scene
@@ -159,11 +230,17 @@ func ScenesHandler(w http.ResponseWriter, r *http.Request) {
 		if scenes == nil {
 			scenes = []models.Scene{}
 		}
-		if err := config.SaveScenesData(scenes); err != nil {
+		saved, err := project.ReplaceScenesData(scenes, ifMatchVersion(r))
+		if errors.Is(err, config.ErrVersionMismatch) {
+			writeScenesConflict(w, project)
+			return
+		}
+		if err != nil {
 			http.Error(w, fmt.Sprintf("保存场景失败: %v", err), http.StatusInternalServerError)
 			return
 		}
-		utils.WriteJSON(w, scenes)
+		setETag(w, config.ScenesVersion(saved))
+		utils.WriteJSON(w, saved)
 	default:
 		http.Error(w, "不支持的请求方法", http.StatusMethodNotAllowed)
 	}
@@ -171,34 +248,39 @@ func ScenesHandler(w http.ResponseWriter, r *http.Request) {
 
 func ExtractCharactersHandler(w http.ResponseWriter, r *http.Request) {
 	log.Printf("[HTTP] %s %s - 来自 %s", r.Method, r.URL.Path, r.RemoteAddr)
+	if r.Method == http.MethodGet {
+		writeExtractionProgress(w, r, extractCharacters)
+		return
+	}
 	if r.Method != http.MethodPost {
 		http.Error(w, "不支持的请求方法", http.StatusMethodNotAllowed)
 		return
 	}
+	project, ok := resolveProject(w, r)
+	if !ok {
+		return
+	}
 
-	cfg, err := config.LoadConfig()
+	cfg, err := project.LoadConfigFor(config.OpCharacterExtraction)
 	if err != nil {
 		http.Error(w, fmt.Sprintf("读取配置失败: %v", err), http.StatusInternalServerError)
 		return
 	}
 
-	if cfg.NovelFile == "" {
-		http.Error(w, "尚未上传小说文件", http.StatusBadRequest)
+	scope, ok := readNovelScope(w, r, cfg)
+	if !ok {
 		return
 	}
-
-	novelData, err := os.ReadFile(cfg.NovelFile)
-	if err != nil {
-		log.Printf("[ERROR] 读取小说文件失败: %v", err)
-		http.Error(w, fmt.Sprintf("读取小说文件失败: %v", err), http.StatusInternalServerError)
-		return
+	novelText := scope.selectedText()
+	if scope.scoped() {
+		log.Printf("[INFO] 开始提取第 %d-%d 章的角色，共 %d 字节", scope.from, scope.to, len(novelText))
+	} else {
+		log.Printf("[INFO] 开始提取角色，小说文件大小: %d 字节", len(novelText))
 	}
-	log.Printf("[INFO] 开始提取角色，小说文件大小: %d 字节", len(novelData))
 
-	ctx, cancel := context.WithTimeout(r.Context(), 600*time.Second)
-	defer cancel()
-
-	characters, err := llm.CallLLMForCharacters(ctx, cfg, string(novelData))
+	progress, finish := startExtraction(project.ID, extractCharacters)
+	characters, err := llm.CallLLMForCharacters(r.Context(), cfg, novelText, progress)
+	finish(err)
 	if err != nil {
 		log.Printf("[ERROR] 分析角色失败: %v", err)
 		http.Error(w, fmt.Sprintf("分析角色失败: %v", err), http.StatusInternalServerError)
@@ -206,53 +288,57 @@ func ExtractCharactersHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	log.Printf("[SUCCESS] 成功提取 %d 个角色", len(characters))
-	if err := config.SaveCharactersData(characters); err != nil {
+	saved, err := saveExtractedCharacters(project, scope, characters)
+	if err != nil {
 		log.Printf("[ERROR] 保存角色信息失败: %v", err)
 		http.Error(w, fmt.Sprintf("保存角色信息失败: %v", err), http.StatusInternalServerError)
 		return
 	}
 
-	utils.WriteJSON(w, characters)
+	utils.WriteJSON(w, saved)
 }
 
 func ExtractScenesHandler(w http.ResponseWriter, r *http.Request) {
 	log.Printf("[HTTP] %s %s - 来自 %s", r.Method, r.URL.Path, r.RemoteAddr)
+	if r.Method == http.MethodGet {
+		writeExtractionProgress(w, r, extractScenes)
+		return
+	}
 	if r.Method != http.MethodPost {
 		http.Error(w, "不支持的请求方法", http.StatusMethodNotAllowed)
 		return
 	}
-
-	cfg, err := config.LoadConfig()
-	if err != nil {
-		http.Error(w, fmt.Sprintf("读取配置失败: %v", err), http.StatusInternalServerError)
+	project, ok := resolveProject(w, r)
+	if !ok {
 		return
 	}
 
-	if cfg.NovelFile == "" {
-		http.Error(w, "尚未上传小说文件", http.StatusBadRequest)
+	cfg, err := project.LoadConfigFor(config.OpSceneExtraction)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("读取配置失败: %v", err), http.StatusInternalServerError)
 		return
 	}
 
-	novelData, err := os.ReadFile(cfg.NovelFile)
-	if err != nil {
-		log.Printf("[ERROR] 读取小说文件失败: %v", err)
-		http.Error(w, fmt.Sprintf("读取小说文件失败: %v", err), http.StatusInternalServerError)
+	scope, ok := readNovelScope(w, r, cfg)
+	if !ok {
 		return
 	}
-
-	characters, err := config.LoadCharactersData()
+	characters, err := project.LoadCharactersData()
 	if err != nil {
 		log.Printf("[ERROR] 读取角色失败: %v", err)
 		http.Error(w, fmt.Sprintf("读取角色失败: %v", err), http.StatusInternalServerError)
 		return
 	}
 
-	log.Printf("[INFO] 开始提取场景，小说文件大小: %d 字节，角色数: %d", len(novelData), len(characters))
-
-	ctx, cancel := context.WithTimeout(r.Context(), 900*time.Second)
-	defer cancel()
+	if scope.scoped() {
+		log.Printf("[INFO] 开始提取第 %d-%d 章的场景，角色数: %d", scope.from, scope.to, len(characters))
+	} else {
+		log.Printf("[INFO] 开始提取场景，小说文件大小: %d 字节，角色数: %d", len(scope.text), len(characters))
+	}
 
-	scenes, err := llm.CallLLMForScenes(ctx, cfg, string(novelData), characters)
+	progress, finish := startExtraction(project.ID, extractScenes)
+	scenes, err := llm.CallLLMForScenes(r.Context(), cfg, scope.text, scope.chapters, characters, progress)
+	finish(err)
 	if err != nil {
 		log.Printf("[ERROR] 分析场景失败: %v", err)
 		http.Error(w, fmt.Sprintf("分析场景失败: %v", err), http.StatusInternalServerError)
@@ -260,13 +346,34 @@ func ExtractScenesHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	log.Printf("[SUCCESS] 成功提取 %d 个场景", len(scenes))
-	if err := config.SaveScenesData(scenes); err != nil {
+	saved, err := saveExtractedScenes(project, scope, scenes)
+	if err != nil {
 		log.Printf("[ERROR] 保存场景失败: %v", err)
 		http.Error(w, fmt.Sprintf("保存场景失败: %v", err), http.StatusInternalServerError)
 		return
 	}
 
-	utils.WriteJSON(w, scenes)
+	utils.WriteJSON(w, saved)
+}
+
+// saveExtractedCharacters 保存提取结果。按章节提取时只补充新出现的角色，不覆盖其他章节已有的角色。
+func saveExtractedCharacters(project *config.Project, scope novelScope, characters []models.CharacterProfile) ([]models.CharacterProfile, error) {
+	return project.MutateCharactersData(func(current []models.CharacterProfile) ([]models.CharacterProfile, error) {
+		if scope.scoped() {
+			return mergeChapterCharacters(current, characters), nil
+		}
+		return characters, nil
+	})
+}
+
+// saveExtractedScenes 保存提取结果。按章节提取时只替换这些章节原有的场景。
+func saveExtractedScenes(project *config.Project, scope novelScope, scenes []models.Scene) ([]models.Scene, error) {
+	return project.MutateScenesData(func(current []models.Scene) ([]models.Scene, error) {
+		if scope.scoped() {
+			return mergeChapterScenes(current, scenes, scope.from, scope.to), nil
+		}
+		return scenes, nil
+	})
 }
 
 func UploadCharacterImageHandler(w http.ResponseWriter, r *http.Request) {
@@ -275,6 +382,10 @@ func UploadCharacterImageHandler(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "不支持的请求方法", http.StatusMethodNotAllowed)
 		return
 	}
+	project, ok := resolveProject(w, r)
+	if !ok {
+		return
+	}
 
 	if err := r.ParseMultipartForm(utils.MaxFileSize); err != nil {
 		log.Printf("[ERROR] 无法解析上传文件: %v", err)
@@ -282,12 +393,14 @@ func UploadCharacterImageHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	indexStr := r.FormValue("index")
-	var index int
-	if _, err := fmt.Sscanf(indexStr, "%d", &index); err != nil {
+	ref, ok := itemRefFromForm(r)
+	if !ok {
 		http.Error(w, "角色索引无效", http.StatusBadRequest)
 		return
 	}
+	if !ref.valid(w, "角色") {
+		return
+	}
 
 	file, header, err := r.FormFile("image")
 	if err != nil {
@@ -304,23 +417,26 @@ func UploadCharacterImageHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	characters, err := config.LoadCharactersData()
+	characters, err := project.LoadCharactersData()
 	if err != nil {
 		http.Error(w, fmt.Sprintf("读取角色失败: %v", err), http.StatusInternalServerError)
 		return
 	}
-	if index < 0 || index >= len(characters) {
-		http.Error(w, "角色索引超出范围", http.StatusBadRequest)
+	index, ok := ref.locate(w, "角色", len(characters), func(id string) int {
+		return config.FindCharacterIndex(characters, id)
+	})
+	if !ok {
 		return
 	}
+	characterID := characters[index].ID
 
-	if err := os.MkdirAll(utils.GeneratedImagesDir, 0o755); err != nil {
+	if err := os.MkdirAll(project.Assets.ImagesDir, 0o755); err != nil {
 		http.Error(w, "创建图片目录失败", http.StatusInternalServerError)
 		return
 	}
 
-	filename := fmt.Sprintf("character_%d_%d%s", index, time.Now().UnixNano(), ext)
-	targetPath := filepath.Join(utils.GeneratedImagesDir, filename)
+	filename := fmt.Sprintf("character_%s_%d%s", characterID, time.Now().UnixNano(), ext)
+	targetPath := filepath.Join(project.Assets.ImagesDir, filename)
 
 	dst, err := os.Create(targetPath)
 	if err != nil {
@@ -336,13 +452,13 @@ func UploadCharacterImageHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	log.Printf("[SUCCESS] 文件上传成功: %s", targetPath)
-	imagePath := "/generated/images/" + filename
-	character := characters[index]
-	character.ImagePath = imagePath
-	characters[index] = character
-
-	if err := config.SaveCharactersData(characters); err != nil {
-		http.Error(w, fmt.Sprintf("保存角色失败: %v", err), http.StatusInternalServerError)
+	character, err := project.AddCharacterVariant(characterID, config.AssetImage, models.AssetVariant{
+		Path:      project.Assets.ImageURL(filename),
+		Source:    models.VariantUploaded,
+		CreatedAt: time.Now(),
+	})
+	if err != nil {
+		writeItemUpdateError(w, "角色", err)
 		return
 	}
 
@@ -355,38 +471,41 @@ func GenerateCharacterImageHandler(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "不支持的请求方法", http.StatusMethodNotAllowed)
 		return
 	}
-
-	var payload struct {
-		Index int `json:"index"`
+	project, ok := resolveProject(w, r)
+	if !ok {
+		return
 	}
+
+	var payload itemRef
 	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(&payload); err != nil {
 		http.Error(w, "请求数据无效", http.StatusBadRequest)
 		return
 	}
-	if payload.Index < 0 {
-		http.Error(w, "角色索引无效", http.StatusBadRequest)
+	if !payload.valid(w, "角色") {
 		return
 	}
 
-	characters, err := config.LoadCharactersData()
+	characters, err := project.LoadCharactersData()
 	if err != nil {
 		http.Error(w, fmt.Sprintf("读取角色失败: %v", err), http.StatusInternalServerError)
 		return
 	}
-	if payload.Index >= len(characters) {
-		http.Error(w, "角色索引超出范围", http.StatusBadRequest)
+	index, ok := payload.locate(w, "角色", len(characters), func(id string) int {
+		return config.FindCharacterIndex(characters, id)
+	})
+	if !ok {
 		return
 	}
 
-	character := characters[payload.Index]
+	character := characters[index]
 	if strings.TrimSpace(character.Description) == "" {
 		http.Error(w, "角色描述为空，无法生成图片", http.StatusBadRequest)
 		return
 	}
 
-	log.Printf("[INFO] 开始生成角色 %d 的图片，角色名称: %s", payload.Index, character.Name)
+	log.Printf("[INFO] 开始生成角色 %s 的图片，角色名称: %s", character.ID, character.Name)
 
-	cfg, err := config.LoadConfig()
+	cfg, err := project.LoadConfigFor(config.OpCharacterImage)
 	if err != nil {
 		http.Error(w, fmt.Sprintf("读取配置失败: %v", err), http.StatusInternalServerError)
 		return
@@ -395,18 +514,17 @@ func GenerateCharacterImageHandler(w http.ResponseWriter, r *http.Request) {
 	ctx, cancel := context.WithTimeout(r.Context(), 600*time.Second)
 	defer cancel()
 
-	imagePath, err := image.GenerateCharacterImage(ctx, cfg, character, payload.Index)
+	variant, err := image.GenerateCharacterImage(ctx, cfg, project.Assets, character)
 	if err != nil {
 		log.Printf("[ERROR] 生成图片失败: %v", err)
 		http.Error(w, fmt.Sprintf("生成图片失败: %v", err), http.StatusInternalServerError)
 		return
 	}
 
-	log.Printf("[SUCCESS] 成功生成角色图片: %s", imagePath)
-	character.ImagePath = imagePath
-	characters[payload.Index] = character
-	if err := config.SaveCharactersData(characters); err != nil {
-		http.Error(w, fmt.Sprintf("保存角色失败: %v", err), http.StatusInternalServerError)
+	log.Printf("[SUCCESS] 成功生成角色图片: %s", variant.Path)
+	character, err = project.AddCharacterVariant(character.ID, config.AssetImage, variant)
+	if err != nil {
+		writeItemUpdateError(w, "角色", err)
 		return
 	}
 
@@ -419,58 +537,66 @@ func GenerateSceneImageHandler(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "不支持的请求方法", http.StatusMethodNotAllowed)
 		return
 	}
-
-	var payload struct {
-		Index int `json:"index"`
+	project, ok := resolveProject(w, r)
+	if !ok {
+		return
 	}
+
+	var payload itemRef
 	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(&payload); err != nil {
 		http.Error(w, "请求数据无效", http.StatusBadRequest)
 		return
 	}
-	if payload.Index < 0 {
-		http.Error(w, "场景索引无效", http.StatusBadRequest)
+	if !payload.valid(w, "场景") {
 		return
 	}
 
-	scenes, err := config.LoadScenesData()
+	scenes, err := project.LoadScenesData()
 	if err != nil {
 		http.Error(w, fmt.Sprintf("读取场景失败: %v", err), http.StatusInternalServerError)
 		return
 	}
-	if payload.Index >= len(scenes) {
-		http.Error(w, "场景索引超出范围", http.StatusBadRequest)
+	index, ok := payload.locate(w, "场景", len(scenes), func(id string) int {
+		return config.FindSceneIndex(scenes, id)
+	})
+	if !ok {
 		return
 	}
 
-	scene := scenes[payload.Index]
+	scene := scenes[index]
 	if strings.TrimSpace(scene.Description) == "" {
 		http.Error(w, "场景描述为空，无法生成图片", http.StatusBadRequest)
 		return
 	}
 
-	log.Printf("[INFO] 开始生成场景 %d 的图片，场景标题: %s", payload.Index, scene.Title)
+	log.Printf("[INFO] 开始生成场景 %s 的图片，场景标题: %s", scene.ID, scene.Title)
 
-	cfg, err := config.LoadConfig()
+	cfg, err := project.LoadConfigFor(config.OpSceneImage)
 	if err != nil {
 		http.Error(w, fmt.Sprintf("读取配置失败: %v", err), http.StatusInternalServerError)
 		return
 	}
 
+	characters, err := project.LoadCharactersData()
+	if err != nil {
+		http.Error(w, fmt.Sprintf("读取角色失败: %v", err), http.StatusInternalServerError)
+		return
+	}
+
 	ctx, cancel := context.WithTimeout(r.Context(), 600*time.Second)
 	defer cancel()
 
-	imagePath, err := image.GenerateSceneImage(ctx, cfg, scene, payload.Index)
+	variant, err := image.GenerateSceneImage(ctx, cfg, project.Assets, scene, characters)
 	if err != nil {
 		log.Printf("[ERROR] 生成图片失败: %v", err)
 		http.Error(w, fmt.Sprintf("生成图片失败: %v", err), http.StatusInternalServerError)
 		return
 	}
 
-	log.Printf("[SUCCESS] 成功生成场景图片: %s", imagePath)
-	scene.ImagePath = imagePath
-	scenes[payload.Index] = scene
-	if err := config.SaveScenesData(scenes); err != nil {
-		http.Error(w, fmt.Sprintf("保存场景失败: %v", err), http.StatusInternalServerError)
+	log.Printf("[SUCCESS] 成功生成场景图片: %s", variant.Path)
+	scene, err = project.AddSceneVariant(scene.ID, config.AssetImage, variant)
+	if err != nil {
+		writeItemUpdateError(w, "场景", err)
 		return
 	}
 
@@ -483,44 +609,47 @@ func GenerateSceneImageWithCharactersHandler(w http.ResponseWriter, r *http.Requ
 		http.Error(w, "不支持的请求方法", http.StatusMethodNotAllowed)
 		return
 	}
-
-	var payload struct {
-		Index int `json:"index"`
+	project, ok := resolveProject(w, r)
+	if !ok {
+		return
 	}
+
+	var payload itemRef
 	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(&payload); err != nil {
 		http.Error(w, "请求数据无效", http.StatusBadRequest)
 		return
 	}
-	if payload.Index < 0 {
-		http.Error(w, "场景索引无效", http.StatusBadRequest)
+	if !payload.valid(w, "场景") {
 		return
 	}
 
-	scenes, err := config.LoadScenesData()
+	scenes, err := project.LoadScenesData()
 	if err != nil {
 		http.Error(w, fmt.Sprintf("读取场景失败: %v", err), http.StatusInternalServerError)
 		return
 	}
-	if payload.Index >= len(scenes) {
-		http.Error(w, "场景索引超出范围", http.StatusBadRequest)
+	index, ok := payload.locate(w, "场景", len(scenes), func(id string) int {
+		return config.FindSceneIndex(scenes, id)
+	})
+	if !ok {
 		return
 	}
 
-	scene := scenes[payload.Index]
+	scene := scenes[index]
 	if strings.TrimSpace(scene.Description) == "" {
 		http.Error(w, "场景描述为空，无法生成图片", http.StatusBadRequest)
 		return
 	}
 
-	log.Printf("[INFO] 开始使用人物图片生成场景 %d 的图片，场景标题: %s", payload.Index, scene.Title)
+	log.Printf("[INFO] 开始使用人物图片生成场景 %s 的图片，场景标题: %s", scene.ID, scene.Title)
 
-	cfg, err := config.LoadConfig()
+	cfg, err := project.LoadConfigFor(config.OpSceneEdit)
 	if err != nil {
 		http.Error(w, fmt.Sprintf("读取配置失败: %v", err), http.StatusInternalServerError)
 		return
 	}
 
-	characters, err := config.LoadCharactersData()
+	characters, err := project.LoadCharactersData()
 	if err != nil {
 		http.Error(w, fmt.Sprintf("读取角色失败: %v", err), http.StatusInternalServerError)
 		return
@@ -529,18 +658,17 @@ func GenerateSceneImageWithCharactersHandler(w http.ResponseWriter, r *http.Requ
 	ctx, cancel := context.WithTimeout(r.Context(), 600*time.Second)
 	defer cancel()
 
-	imagePath, err := image.GenerateSceneImageWithCharacters(ctx, cfg, scene, characters, payload.Index)
+	variant, err := image.GenerateSceneImageWithCharacters(ctx, cfg, project.Assets, scene, characters)
 	if err != nil {
 		log.Printf("[ERROR] 生成图片失败: %v", err)
 		http.Error(w, fmt.Sprintf("生成图片失败: %v", err), http.StatusInternalServerError)
 		return
 	}
 
-	log.Printf("[SUCCESS] 成功生成场景图片: %s", imagePath)
-	scene.ImagePath = imagePath
-	scenes[payload.Index] = scene
-	if err := config.SaveScenesData(scenes); err != nil {
-		http.Error(w, fmt.Sprintf("保存场景失败: %v", err), http.StatusInternalServerError)
+	log.Printf("[SUCCESS] 成功生成场景图片: %s", variant.Path)
+	scene, err = project.AddSceneVariant(scene.ID, config.AssetImage, variant)
+	if err != nil {
+		writeItemUpdateError(w, "场景", err)
 		return
 	}
 
@@ -553,36 +681,39 @@ func GenerateSceneAudioHandler(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "不支持的请求方法", http.StatusMethodNotAllowed)
 		return
 	}
-
-	var payload struct {
-		Index int `json:"index"`
+	project, ok := resolveProject(w, r)
+	if !ok {
+		return
 	}
+
+	var payload itemRef
 	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(&payload); err != nil {
 		http.Error(w, "请求数据无效", http.StatusBadRequest)
 		return
 	}
-	if payload.Index < 0 {
-		http.Error(w, "场景索引无效", http.StatusBadRequest)
+	if !payload.valid(w, "场景") {
 		return
 	}
 
-	scenes, err := config.LoadScenesData()
+	scenes, err := project.LoadScenesData()
 	if err != nil {
 		http.Error(w, fmt.Sprintf("读取场景失败: %v", err), http.StatusInternalServerError)
 		return
 	}
-	if payload.Index >= len(scenes) {
-		http.Error(w, "场景索引超出范围", http.StatusBadRequest)
+	index, ok := payload.locate(w, "场景", len(scenes), func(id string) int {
+		return config.FindSceneIndex(scenes, id)
+	})
+	if !ok {
 		return
 	}
 
-	scene := scenes[payload.Index]
+	scene := scenes[index]
 	if text := audio.BuildSceneSpeechText(scene); text == "" {
 		http.Error(w, "场景缺少可用于生成语音的文本", http.StatusBadRequest)
 		return
 	}
 
-	cfg, err := config.LoadConfig()
+	cfg, err := project.LoadConfigFor(config.OpTTS)
 	if err != nil {
 		http.Error(w, fmt.Sprintf("读取配置失败: %v", err), http.StatusInternalServerError)
 		return
@@ -595,17 +726,16 @@ func GenerateSceneAudioHandler(w http.ResponseWriter, r *http.Request) {
 	ctx, cancel := context.WithTimeout(r.Context(), 600*time.Second)
 	defer cancel()
 
-	audioPath, err := audio.GenerateSceneAudio(ctx, cfg, scene, payload.Index)
+	variant, err := audio.GenerateSceneAudio(ctx, cfg, project.Assets, scene)
 	if err != nil {
 		log.Printf("[ERROR] 生成语音失败: %v", err)
 		http.Error(w, fmt.Sprintf("生成语音失败: %v", err), http.StatusInternalServerError)
 		return
 	}
 
-	scene.AudioPath = audioPath
-	scenes[payload.Index] = scene
-	if err := config.SaveScenesData(scenes); err != nil {
-		http.Error(w, fmt.Sprintf("保存场景失败: %v", err), http.StatusInternalServerError)
+	scene, err = project.AddSceneVariant(scene.ID, config.AssetAudio, variant)
+	if err != nil {
+		writeItemUpdateError(w, "场景", err)
 		return
 	}
 
@@ -3,6 +3,7 @@ package handlers
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"mime/multipart"
 	"net/http"
 	"net/http/httptest"
@@ -16,11 +17,7 @@ import (
 )
 
 func TestConfigHandlerGet(t *testing.T) {
-	tmpDir := t.TempDir()
-	utils.ConfigPath = filepath.Join(tmpDir, "config.json")
-	defer func() {
-		utils.ConfigPath = filepath.Join(utils.ProjectRoot, "config", "config.json")
-	}()
+	useTempPaths(t)
 
 	req := httptest.NewRequest(http.MethodGet, "/api/config", nil)
 	w := httptest.NewRecorder()
@@ -38,11 +35,7 @@ func TestConfigHandlerGet(t *testing.T) {
 }
 
 func TestConfigHandlerPost(t *testing.T) {
-	tmpDir := t.TempDir()
-	utils.ConfigPath = filepath.Join(tmpDir, "config.json")
-	defer func() {
-		utils.ConfigPath = filepath.Join(utils.ProjectRoot, "config", "config.json")
-	}()
+	useTempPaths(t)
 
 	testCfg := models.Config{
 		LLM: models.LLMConfig{
@@ -112,12 +105,12 @@ func TestUploadHandler(t *testing.T) {
 		t.Errorf("Expected status 200, got %d: %s", w.Code, w.Body.String())
 	}
 
-	var response map[string]string
+	var response models.NovelUpload
 	if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
 		t.Fatalf("Failed to decode response: %v", err)
 	}
 
-	if response["filePath"] == "" {
+	if response.FilePath == "" {
 		t.Error("Expected non-empty file path")
 	}
 }
@@ -134,11 +127,7 @@ func TestUploadHandlerMethodNotAllowed(t *testing.T) {
 }
 
 func TestCharactersHandlerGet(t *testing.T) {
-	tmpDir := t.TempDir()
-	utils.CharactersPath = filepath.Join(tmpDir, "characters.json")
-	defer func() {
-		utils.CharactersPath = filepath.Join(utils.ProjectRoot, "config", "characters.json")
-	}()
+	useTempPaths(t)
 
 	req := httptest.NewRequest(http.MethodGet, "/api/characters", nil)
 	w := httptest.NewRecorder()
@@ -156,11 +145,7 @@ func TestCharactersHandlerGet(t *testing.T) {
 }
 
 func TestCharactersHandlerPost(t *testing.T) {
-	tmpDir := t.TempDir()
-	utils.CharactersPath = filepath.Join(tmpDir, "characters.json")
-	defer func() {
-		utils.CharactersPath = filepath.Join(utils.ProjectRoot, "config", "characters.json")
-	}()
+	useTempPaths(t)
 
 	characters := []models.CharacterProfile{
 		{Name: "角色1", Description: "描述1"},
@@ -180,11 +165,7 @@ func TestCharactersHandlerPost(t *testing.T) {
 }
 
 func TestScenesHandlerGet(t *testing.T) {
-	tmpDir := t.TempDir()
-	utils.ScenesPath = filepath.Join(tmpDir, "scenes.json")
-	defer func() {
-		utils.ScenesPath = filepath.Join(utils.ProjectRoot, "config", "scenes.json")
-	}()
+	useTempPaths(t)
 
 	req := httptest.NewRequest(http.MethodGet, "/api/scenes", nil)
 	w := httptest.NewRecorder()
@@ -202,11 +183,7 @@ func TestScenesHandlerGet(t *testing.T) {
 }
 
 func TestScenesHandlerPost(t *testing.T) {
-	tmpDir := t.TempDir()
-	utils.ScenesPath = filepath.Join(tmpDir, "scenes.json")
-	defer func() {
-		utils.ScenesPath = filepath.Join(utils.ProjectRoot, "config", "scenes.json")
-	}()
+	useTempPaths(t)
 
 	scenes := []models.Scene{
 		{
@@ -230,12 +207,36 @@ func TestScenesHandlerPost(t *testing.T) {
 	}
 }
 
+func TestCharactersHandlerPostRegeneratesUnsafeIDs(t *testing.T) {
+	useTempPaths(t)
+
+	body := `[{"id": "../../x", "name": "角色1"}, {"id": "chr_ok", "name": "角色2"}]`
+	req := httptest.NewRequest(http.MethodPost, "/api/characters", strings.NewReader(body))
+	w := httptest.NewRecorder()
+	CharactersHandler(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	characters, _ := config.LoadCharactersData()
+	if len(characters) != 2 || !strings.HasPrefix(characters[0].ID, "chr_") || strings.ContainsAny(characters[0].ID, "./") {
+		t.Errorf("Expected the traversal ID to be replaced, got %+v", characters)
+	}
+	if characters[1].ID != "chr_ok" {
+		t.Errorf("Expected a valid ID to be kept, got %q", characters[1].ID)
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/api/scenes", strings.NewReader(`[{"id": "scn_../x", "title": "场景1"}]`))
+	w = httptest.NewRecorder()
+	ScenesHandler(w, req)
+	scenes, _ := config.LoadScenesData()
+	if w.Code != http.StatusOK || len(scenes) != 1 || strings.ContainsAny(scenes[0].ID, "./") {
+		t.Errorf("Expected the traversal scene ID to be replaced, got %d %+v", w.Code, scenes)
+	}
+}
+
 func TestExtractCharactersHandlerNoNovel(t *testing.T) {
-	tmpDir := t.TempDir()
-	utils.ConfigPath = filepath.Join(tmpDir, "config.json")
-	defer func() {
-		utils.ConfigPath = filepath.Join(utils.ProjectRoot, "config", "config.json")
-	}()
+	useTempPaths(t)
 
 	cfg := models.Config{
 		NovelFile: "",
@@ -257,11 +258,7 @@ func TestExtractCharactersHandlerNoNovel(t *testing.T) {
 }
 
 func TestExtractScenesHandlerNoNovel(t *testing.T) {
-	tmpDir := t.TempDir()
-	utils.ConfigPath = filepath.Join(tmpDir, "config.json")
-	defer func() {
-		utils.ConfigPath = filepath.Join(utils.ProjectRoot, "config", "config.json")
-	}()
+	useTempPaths(t)
 
 	cfg := models.Config{
 		NovelFile: "",
@@ -278,14 +275,43 @@ func TestExtractScenesHandlerNoNovel(t *testing.T) {
 	}
 }
 
+func TestExtractCharactersHandlerProgress(t *testing.T) {
+	useTempPaths(t)
+	t.Cleanup(func() { extractionProgress.Delete(progressKey(config.DefaultProjectID, extractCharacters)) })
+
+	get := func() models.ExtractionProgress {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodGet, "/api/characters/extract", nil)
+		w := httptest.NewRecorder()
+		ExtractCharactersHandler(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+		}
+		var progress models.ExtractionProgress
+		if err := json.Unmarshal(w.Body.Bytes(), &progress); err != nil {
+			t.Fatalf("Failed to decode progress: %v", err)
+		}
+		return progress
+	}
+
+	if progress := get(); progress.Running || progress.Total != 0 {
+		t.Errorf("Expected empty progress before any extraction, got %+v", progress)
+	}
+
+	update, finish := startExtraction(config.DefaultProjectID, extractCharacters)
+	update(2, 5, 7)
+	if progress := get(); !progress.Running || progress.Done != 2 || progress.Total != 5 || progress.Found != 7 {
+		t.Errorf("Unexpected running progress %+v", progress)
+	}
+
+	finish(errors.New("第 3/5 段提取失败"))
+	if progress := get(); progress.Running || progress.Error == "" {
+		t.Errorf("Expected finished progress with error, got %+v", progress)
+	}
+}
+
 func TestUploadCharacterImageHandler(t *testing.T) {
-	tmpDir := t.TempDir()
-	utils.CharactersPath = filepath.Join(tmpDir, "characters.json")
-	utils.GeneratedImagesDir = tmpDir
-	defer func() {
-		utils.CharactersPath = filepath.Join(utils.ProjectRoot, "config", "characters.json")
-		utils.GeneratedImagesDir = filepath.Join(utils.ProjectRoot, "generated", "images")
-	}()
+	useTempPaths(t)
 
 	characters := []models.CharacterProfile{
 		{Name: "角色1", Description: "描述1"},
@@ -310,6 +336,57 @@ func TestUploadCharacterImageHandler(t *testing.T) {
 	}
 }
 
+func TestUploadCharacterImageHandlerByID(t *testing.T) {
+	useTempPaths(t)
+
+	characters := []models.CharacterProfile{
+		{ID: "chr_a", Name: "角色1", Description: "描述1"},
+		{ID: "chr_b", Name: "角色2", Description: "描述2"},
+	}
+	config.SaveCharactersData(characters)
+
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	writer.WriteField("id", "chr_b")
+	part, _ := writer.CreateFormFile("image", "test.png")
+	part.Write([]byte("fake image data"))
+	writer.Close()
+
+	req := httptest.NewRequest(http.MethodPost, "/api/characters/upload-image", body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	w := httptest.NewRecorder()
+
+	UploadCharacterImageHandler(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	saved, _ := config.LoadCharactersData()
+	if saved[0].ImagePath != "" {
+		t.Errorf("Expected first character untouched, got %s", saved[0].ImagePath)
+	}
+	if !strings.Contains(saved[1].ImagePath, "chr_b") {
+		t.Errorf("Expected image named after character ID, got %s", saved[1].ImagePath)
+	}
+}
+
+func TestGenerateSceneImageHandlerUnknownID(t *testing.T) {
+	useTempPaths(t)
+	config.SaveScenesData([]models.Scene{{ID: "scn_a", Title: "场景1", Description: "描述1"}})
+
+	body, _ := json.Marshal(map[string]string{"id": "scn_missing"})
+	req := httptest.NewRequest(http.MethodPost, "/api/scenes/generate-image", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+
+	GenerateSceneImageHandler(w, req)
+
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404, got %d", w.Code)
+	}
+}
+
 func TestGenerateCharacterImageHandlerInvalidIndex(t *testing.T) {
 	payload := map[string]int{"index": -1}
 	body, _ := json.Marshal(payload)
@@ -354,3 +431,71 @@ func TestGenerateSceneAudioHandlerInvalidIndex(t *testing.T) {
 		t.Errorf("Expected status 400, got %d", w.Code)
 	}
 }
+
+func TestConfigHandlerRedactsKeys(t *testing.T) {
+	useTempPaths(t)
+	config.SaveConfig(models.Config{
+		LLM:   models.LLMConfig{Model: "m", BaseURL: "https://llm", APIKey: "sk-llm-0000-1111"},
+		Image: models.ImageConfig{Model: "i", BaseURL: "https://img", APIKey: "sk-img-2222-3333"},
+		Voice: models.VoiceConfig{Model: "v", BaseURL: "https://tts", APIKey: "sk-tts-4444-5555", Voice: "Cherry", Language: "Chinese"},
+	})
+
+	w := httptest.NewRecorder()
+	ConfigHandler(w, httptest.NewRequest(http.MethodGet, "/api/config", nil))
+	if strings.Contains(w.Body.String(), "sk-") {
+		t.Fatalf("GET /api/config leaked a key: %s", w.Body.String())
+	}
+	var cfg models.Config
+	json.NewDecoder(w.Body).Decode(&cfg)
+
+	// 前端原样回传脱敏值并只修改了语音 Key。
+	cfg.Voice.APIKey = "sk-tts-new"
+	cfg.SceneCount = 7
+	body, _ := json.Marshal(cfg)
+	w = httptest.NewRecorder()
+	ConfigHandler(w, httptest.NewRequest(http.MethodPost, "/api/config", bytes.NewReader(body)))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if strings.Contains(w.Body.String(), "sk-") {
+		t.Errorf("POST /api/config leaked a key: %s", w.Body.String())
+	}
+
+	saved, _ := config.LoadConfig()
+	if saved.LLM.APIKey != "sk-llm-0000-1111" || saved.Image.APIKey != "sk-img-2222-3333" {
+		t.Errorf("Masked keys should be unchanged, got %+v", saved)
+	}
+	if saved.Voice.APIKey != "sk-tts-new" || saved.SceneCount != 7 {
+		t.Errorf("Expected updated fields to be saved, got %+v", saved)
+	}
+}
+
+func TestConfigHandlerOperationProfiles(t *testing.T) {
+	useTempPaths(t)
+	config.SaveConfig(models.Config{
+		LLM:        models.LLMConfig{Model: "m", BaseURL: "https://llm", APIKey: "sk-llm-0000-1111"},
+		Image:      models.ImageConfig{Model: "i", BaseURL: "https://img"},
+		Voice:      models.VoiceConfig{Model: "v", BaseURL: "https://tts", Voice: "Cherry", Language: "Chinese"},
+		Profiles:   []models.ProviderProfile{{Name: "final", Image: models.ImageConfig{Model: "hq", APIKey: "sk-final-9999-0000"}}},
+		Operations: models.OperationProfiles{SceneImage: "final"},
+	})
+
+	w := httptest.NewRecorder()
+	ConfigHandler(w, httptest.NewRequest(http.MethodGet, "/api/config", nil))
+	if strings.Contains(w.Body.String(), "sk-final") {
+		t.Fatalf("GET /api/config leaked a profile key: %s", w.Body.String())
+	}
+	var cfg models.Config
+	json.NewDecoder(w.Body).Decode(&cfg)
+	if cfg.Operations.SceneImage != "final" || len(cfg.Profiles) != 1 || cfg.Profiles[0].Image.Model != "hq" {
+		t.Fatalf("Expected profiles and operation choices in response, got %+v", cfg)
+	}
+
+	cfg.Operations.TTS = "missing"
+	body, _ := json.Marshal(cfg)
+	w = httptest.NewRecorder()
+	ConfigHandler(w, httptest.NewRequest(http.MethodPost, "/api/config", bytes.NewReader(body)))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for unknown profile, got %d", w.Code)
+	}
+}
@@ -0,0 +1,115 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"taco/backend/config"
+	"taco/backend/utils"
+)
+
+// CharacterHistoryHandler 处理 /api/characters/history 下的历史版本接口。
+func CharacterHistoryHandler(w http.ResponseWriter, r *http.Request) {
+	historyHandler(w, r, config.HistoryCharacters, "/api/characters/history")
+}
+
+// SceneHistoryHandler 处理 /api/scenes/history 下的历史版本接口。
+func SceneHistoryHandler(w http.ResponseWriter, r *http.Request) {
+	historyHandler(w, r, config.HistoryScenes, "/api/scenes/history")
+}
+
+// historyHandler 提供以下路由：
+//
+//	GET  {prefix}                     历史版本列表（最新在前）
+//	GET  {prefix}/diff?from=&to=      比较两个版本，to 缺省为最新版本
+//	GET  {prefix}/{rev}               查看某个版本的完整数据
+//	POST {prefix}/{rev}/restore       恢复到该版本
+func historyHandler(w http.ResponseWriter, r *http.Request, kind, prefix string) {
+	log.Printf("[HTTP] %s %s - 来自 %s", r.Method, r.URL.Path, r.RemoteAddr)
+	project, ok := resolveProject(w, r)
+	if !ok {
+		return
+	}
+
+	rest := strings.Trim(strings.TrimPrefix(r.URL.Path, prefix), "/")
+	revisionID, action, _ := strings.Cut(rest, "/")
+
+	switch {
+	case rest == "" && r.Method == http.MethodGet:
+		revisions, err := project.ListRevisions(kind)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("读取历史版本失败: %v", err), http.StatusInternalServerError)
+			return
+		}
+		utils.WriteJSON(w, revisions)
+
+	case rest == "diff" && r.Method == http.MethodGet:
+		from := strings.TrimSpace(r.URL.Query().Get("from"))
+		to := strings.TrimSpace(r.URL.Query().Get("to"))
+		if from == "" {
+			http.Error(w, "缺少 from 参数", http.StatusBadRequest)
+			return
+		}
+		if to == "" {
+			revisions, err := project.ListRevisions(kind)
+			if err != nil || len(revisions) == 0 {
+				http.Error(w, config.ErrRevisionNotFound.Error(), http.StatusNotFound)
+				return
+			}
+			to = revisions[0].ID
+		}
+		diff, err := project.DiffRevisions(kind, from, to)
+		if err != nil {
+			writeHistoryError(w, err)
+			return
+		}
+		utils.WriteJSON(w, diff)
+
+	case action == "" && r.Method == http.MethodGet:
+		revision, err := project.LoadRevision(kind, revisionID)
+		if err != nil {
+			writeHistoryError(w, err)
+			return
+		}
+		utils.WriteJSON(w, revision)
+
+	case action == "restore" && r.Method == http.MethodPost:
+		var (
+			data    any
+			version string
+			err     error
+		)
+		if kind == config.HistoryCharacters {
+			characters, restoreErr := project.RestoreCharactersRevision(revisionID)
+			data, version, err = characters, config.CharactersVersion(characters), restoreErr
+		} else {
+			scenes, restoreErr := project.RestoreScenesRevision(revisionID)
+			data, version, err = scenes, config.ScenesVersion(scenes), restoreErr
+		}
+		if err != nil {
+			writeHistoryError(w, err)
+			return
+		}
+		log.Printf("[SUCCESS] 已恢复 %s 历史版本: %s", kind, revisionID)
+		setETag(w, version)
+		utils.WriteJSON(w, data)
+
+	case action == "" || action == "restore" || rest == "diff":
+		http.Error(w, "不支持的请求方法", http.StatusMethodNotAllowed)
+
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func writeHistoryError(w http.ResponseWriter, err error) {
+	if errors.Is(err, config.ErrRevisionNotFound) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	log.Printf("[ERROR] 历史版本操作失败: %v", err)
+	http.Error(w, fmt.Sprintf("历史版本操作失败: %v", err), http.StatusInternalServerError)
+}
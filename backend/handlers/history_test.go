@@ -0,0 +1,67 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"taco/backend/config"
+	"taco/backend/models"
+)
+
+func TestSceneHistoryHandlerListDiffRestore(t *testing.T) {
+	useTempPaths(t)
+	config.SaveScenesData([]models.Scene{{ID: "scn_a", Title: "手工修改"}})
+	config.SaveScenesData([]models.Scene{{ID: "scn_a", Title: "被覆盖"}})
+
+	req := httptest.NewRequest(http.MethodGet, "/api/scenes/history", nil)
+	w := httptest.NewRecorder()
+	SceneHistoryHandler(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+	var revisions []models.RevisionInfo
+	json.Unmarshal(w.Body.Bytes(), &revisions)
+	if len(revisions) != 2 {
+		t.Fatalf("Expected 2 revisions, got %d", len(revisions))
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/api/scenes/history/diff?from="+revisions[1].ID, nil)
+	w = httptest.NewRecorder()
+	SceneHistoryHandler(w, req)
+	var diff models.RevisionDiff
+	json.Unmarshal(w.Body.Bytes(), &diff)
+	if w.Code != http.StatusOK || len(diff.Changes) != 1 || diff.Changes[0].Type != "modified" {
+		t.Fatalf("Unexpected diff response %d: %s", w.Code, w.Body.String())
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/api/scenes/history/"+revisions[1].ID+"/restore", nil)
+	w = httptest.NewRecorder()
+	SceneHistoryHandler(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	scenes, _ := config.LoadScenesData()
+	if scenes[0].Title != "手工修改" {
+		t.Errorf("Expected restored title, got %s", scenes[0].Title)
+	}
+}
+
+func TestCharacterHistoryHandlerNotFound(t *testing.T) {
+	useTempPaths(t)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/characters/history/123", nil)
+	w := httptest.NewRecorder()
+	CharacterHistoryHandler(w, req)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404, got %d", w.Code)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/api/characters/history/123/restore", nil)
+	w = httptest.NewRecorder()
+	CharacterHistoryHandler(w, req)
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Expected status 405, got %d", w.Code)
+	}
+}
@@ -0,0 +1,406 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"taco/backend/config"
+	"taco/backend/models"
+	"taco/backend/utils"
+)
+
+// itemRef 定位单个角色或场景：优先使用稳定的 id，旧客户端仍可只传 index。
+type itemRef struct {
+	ID    string `json:"id"`
+	Index *int   `json:"index"`
+}
+
+// itemRefFromForm 从表单字段 id / index 中读取定位信息。
+func itemRefFromForm(r *http.Request) (itemRef, bool) {
+	ref := itemRef{ID: strings.TrimSpace(r.FormValue("id"))}
+	if indexStr := strings.TrimSpace(r.FormValue("index")); indexStr != "" {
+		index, err := strconv.Atoi(indexStr)
+		if err != nil {
+			return ref, false
+		}
+		ref.Index = &index
+	}
+	return ref, true
+}
+
+// valid 在读取数据前做基本校验，缺少 id 时 index 必须为非负数。
+func (ref itemRef) valid(w http.ResponseWriter, kind string) bool {
+	if strings.TrimSpace(ref.ID) == "" && (ref.Index == nil || *ref.Index < 0) {
+		http.Error(w, kind+"索引无效", http.StatusBadRequest)
+		return false
+	}
+	return true
+}
+
+// locate 返回条目在列表中的下标；失败时写入错误响应并返回 false。
+// kind 为"角色"或"场景"，用于拼接错误信息。
+func (ref itemRef) locate(w http.ResponseWriter, kind string, count int, indexOf func(id string) int) (int, bool) {
+	if id := strings.TrimSpace(ref.ID); id != "" {
+		idx := indexOf(id)
+		if idx < 0 {
+			http.Error(w, kind+"不存在", http.StatusNotFound)
+			return -1, false
+		}
+		return idx, true
+	}
+	if !ref.valid(w, kind) {
+		return -1, false
+	}
+	if *ref.Index >= count {
+		http.Error(w, kind+"索引超出范围", http.StatusBadRequest)
+		return -1, false
+	}
+	return *ref.Index, true
+}
+
+// writeItemUpdateError 处理生成结果回写失败的情况。
+func writeItemUpdateError(w http.ResponseWriter, kind string, err error) {
+	if errors.Is(err, config.ErrCharacterNotFound) || errors.Is(err, config.ErrSceneNotFound) {
+		log.Printf("[WARN] %s在生成期间已被删除", kind)
+		http.Error(w, kind+"已被删除", http.StatusConflict)
+		return
+	}
+	http.Error(w, fmt.Sprintf("保存%s失败: %v", kind, err), http.StatusInternalServerError)
+}
+
+// itemIDFromPath 取出 /api/scenes/{id} 形式路径中的 id。
+func itemIDFromPath(r *http.Request, prefix string) (string, bool) {
+	id := strings.Trim(strings.TrimPrefix(r.URL.Path, prefix), "/")
+	if id == "" || strings.Contains(id, "/") {
+		return "", false
+	}
+	return id, true
+}
+
+// writeItemError 将 config 包的条目错误映射为 HTTP 状态码。
+func writeItemError(w http.ResponseWriter, action string, err error) {
+	switch {
+	case errors.Is(err, config.ErrCharacterNotFound), errors.Is(err, config.ErrSceneNotFound),
+		errors.Is(err, config.ErrVariantNotFound), errors.Is(err, config.ErrUnknownAsset):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, config.ErrOrderMismatch):
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		log.Printf("[ERROR] %s失败: %v", action, err)
+		http.Error(w, fmt.Sprintf("%s失败: %v", action, err), http.StatusInternalServerError)
+	}
+}
+
+// CharacterItemHandler 处理 /api/characters/{id} 的单个角色读写。
+// PUT 整体替换，PATCH 只覆盖请求中出现的字段，均只改动目标角色。
+func CharacterItemHandler(w http.ResponseWriter, r *http.Request) {
+	log.Printf("[HTTP] %s %s - 来自 %s", r.Method, r.URL.Path, r.RemoteAddr)
+	if route, ok := parseVariantRoute(r, "/api/characters/"); ok {
+		characterVariantsHandler(w, r, route)
+		return
+	}
+	id, ok := itemIDFromPath(r, "/api/characters/")
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	project, ok := resolveProject(w, r)
+	if !ok {
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		characters, err := project.LoadCharactersData()
+		if err != nil {
+			http.Error(w, fmt.Sprintf("读取角色失败: %v", err), http.StatusInternalServerError)
+			return
+		}
+		idx := config.FindCharacterIndex(characters, id)
+		if idx < 0 {
+			http.Error(w, config.ErrCharacterNotFound.Error(), http.StatusNotFound)
+			return
+		}
+		setETag(w, config.CharacterVersion(characters[idx]))
+		utils.WriteJSON(w, characters[idx])
+	case http.MethodPut, http.MethodPatch:
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, 1<<20))
+		if err != nil {
+			http.Error(w, "请求数据无效", http.StatusBadRequest)
+			return
+		}
+		var incoming models.CharacterProfile
+		if err := json.Unmarshal(body, &incoming); err != nil {
+			http.Error(w, "请求数据无效", http.StatusBadRequest)
+			return
+		}
+		expected := ifMatchVersion(r)
+		character, err := project.UpdateCharacter(id, func(c *models.CharacterProfile) error {
+			if !config.VersionMatches(expected, config.CharacterVersion(*c)) {
+				return config.ErrVersionMismatch
+			}
+			imageVariants := c.ImageVariants
+			if r.Method == http.MethodPut {
+				*c = incoming
+			} else if err := json.Unmarshal(body, c); err != nil {
+				return err
+			}
+			c.ImageVariants = imageVariants
+			return nil
+		})
+		if errors.Is(err, config.ErrVersionMismatch) {
+			writeCharacterConflict(w, project, id)
+			return
+		}
+		if err != nil {
+			writeItemError(w, "保存角色", err)
+			return
+		}
+		setETag(w, config.CharacterVersion(character))
+		utils.WriteJSON(w, character)
+	case http.MethodDelete:
+		err := project.DeleteCharacter(id, ifMatchVersion(r))
+		if errors.Is(err, config.ErrVersionMismatch) {
+			writeCharacterConflict(w, project, id)
+			return
+		}
+		if err != nil {
+			writeItemError(w, "删除角色", err)
+			return
+		}
+		log.Printf("[SUCCESS] 已删除角色: %s", id)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.Error(w, "不支持的请求方法", http.StatusMethodNotAllowed)
+	}
+}
+
+// InsertCharacterHandler 在指定位置插入一个角色，position 缺省时追加到末尾。
+func InsertCharacterHandler(w http.ResponseWriter, r *http.Request) {
+	log.Printf("[HTTP] %s %s - 来自 %s", r.Method, r.URL.Path, r.RemoteAddr)
+	if r.Method != http.MethodPost {
+		http.Error(w, "不支持的请求方法", http.StatusMethodNotAllowed)
+		return
+	}
+	project, ok := resolveProject(w, r)
+	if !ok {
+		return
+	}
+
+	var payload struct {
+		Position  *int                    `json:"position"`
+		Character models.CharacterProfile `json:"character"`
+	}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(&payload); err != nil {
+		http.Error(w, "请求数据无效", http.StatusBadRequest)
+		return
+	}
+	position := -1
+	if payload.Position != nil {
+		position = *payload.Position
+	}
+
+	character, err := project.InsertCharacter(position, payload.Character, ifMatchVersion(r))
+	if errors.Is(err, config.ErrVersionMismatch) {
+		writeCharactersConflict(w, project)
+		return
+	}
+	if err != nil {
+		writeItemError(w, "保存角色", err)
+		return
+	}
+	utils.WriteJSONStatus(w, http.StatusCreated, character)
+}
+
+// ReorderCharactersHandler 按请求给出的 ID 顺序重排角色。
+func ReorderCharactersHandler(w http.ResponseWriter, r *http.Request) {
+	log.Printf("[HTTP] %s %s - 来自 %s", r.Method, r.URL.Path, r.RemoteAddr)
+	if r.Method != http.MethodPost {
+		http.Error(w, "不支持的请求方法", http.StatusMethodNotAllowed)
+		return
+	}
+	project, ok := resolveProject(w, r)
+	if !ok {
+		return
+	}
+
+	var payload struct {
+		IDs []string `json:"ids"`
+	}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(&payload); err != nil {
+		http.Error(w, "请求数据无效", http.StatusBadRequest)
+		return
+	}
+
+	characters, err := project.ReorderCharacters(payload.IDs, ifMatchVersion(r))
+	if errors.Is(err, config.ErrVersionMismatch) {
+		writeCharactersConflict(w, project)
+		return
+	}
+	if err != nil {
+		writeItemError(w, "调整角色顺序", err)
+		return
+	}
+	setETag(w, config.CharactersVersion(characters))
+	utils.WriteJSON(w, characters)
+}
+
+// SceneItemHandler 处理 /api/scenes/{id} 的单个场景读写，语义同 CharacterItemHandler。
+func SceneItemHandler(w http.ResponseWriter, r *http.Request) {
+	log.Printf("[HTTP] %s %s - 来自 %s", r.Method, r.URL.Path, r.RemoteAddr)
+	if route, ok := parseVariantRoute(r, "/api/scenes/"); ok {
+		sceneVariantsHandler(w, r, route)
+		return
+	}
+	id, ok := itemIDFromPath(r, "/api/scenes/")
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	project, ok := resolveProject(w, r)
+	if !ok {
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		scenes, err := project.LoadScenesData()
+		if err != nil {
+			http.Error(w, fmt.Sprintf("读取场景失败: %v", err), http.StatusInternalServerError)
+			return
+		}
+		idx := config.FindSceneIndex(scenes, id)
+		if idx < 0 {
+			http.Error(w, config.ErrSceneNotFound.Error(), http.StatusNotFound)
+			return
+		}
+		setETag(w, config.SceneVersion(scenes[idx]))
+		utils.WriteJSON(w, scenes[idx])
+	case http.MethodPut, http.MethodPatch:
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, 1<<20))
+		if err != nil {
+			http.Error(w, "请求数据无效", http.StatusBadRequest)
+			return
+		}
+		var incoming models.Scene
+		if err := json.Unmarshal(body, &incoming); err != nil {
+			http.Error(w, "请求数据无效", http.StatusBadRequest)
+			return
+		}
+		expected := ifMatchVersion(r)
+		scene, err := project.UpdateScene(id, func(s *models.Scene) error {
+			if !config.VersionMatches(expected, config.SceneVersion(*s)) {
+				return config.ErrVersionMismatch
+			}
+			// 素材版本只由生成、上传和版本接口维护，这里保留已保存的列表。
+			imageVariants, audioVariants := s.ImageVariants, s.AudioVariants
+			if r.Method == http.MethodPut {
+				*s = incoming
+			} else if err := json.Unmarshal(body, s); err != nil {
+				return err
+			}
+			s.ImageVariants, s.AudioVariants = imageVariants, audioVariants
+			return nil
+		})
+		if errors.Is(err, config.ErrVersionMismatch) {
+			writeSceneConflict(w, project, id)
+			return
+		}
+		if err != nil {
+			writeItemError(w, "保存场景", err)
+			return
+		}
+		setETag(w, config.SceneVersion(scene))
+		utils.WriteJSON(w, scene)
+	case http.MethodDelete:
+		err := project.DeleteScene(id, ifMatchVersion(r))
+		if errors.Is(err, config.ErrVersionMismatch) {
+			writeSceneConflict(w, project, id)
+			return
+		}
+		if err != nil {
+			writeItemError(w, "删除场景", err)
+			return
+		}
+		log.Printf("[SUCCESS] 已删除场景: %s", id)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.Error(w, "不支持的请求方法", http.StatusMethodNotAllowed)
+	}
+}
+
+// InsertSceneHandler 在指定位置插入一个场景，position 缺省时追加到末尾。
+func InsertSceneHandler(w http.ResponseWriter, r *http.Request) {
+	log.Printf("[HTTP] %s %s - 来自 %s", r.Method, r.URL.Path, r.RemoteAddr)
+	if r.Method != http.MethodPost {
+		http.Error(w, "不支持的请求方法", http.StatusMethodNotAllowed)
+		return
+	}
+	project, ok := resolveProject(w, r)
+	if !ok {
+		return
+	}
+
+	var payload struct {
+		Position *int         `json:"position"`
+		Scene    models.Scene `json:"scene"`
+	}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(&payload); err != nil {
+		http.Error(w, "请求数据无效", http.StatusBadRequest)
+		return
+	}
+	position := -1
+	if payload.Position != nil {
+		position = *payload.Position
+	}
+
+	scene, err := project.InsertScene(position, payload.Scene, ifMatchVersion(r))
+	if errors.Is(err, config.ErrVersionMismatch) {
+		writeScenesConflict(w, project)
+		return
+	}
+	if err != nil {
+		writeItemError(w, "保存场景", err)
+		return
+	}
+	utils.WriteJSONStatus(w, http.StatusCreated, scene)
+}
+
+// ReorderScenesHandler 按请求给出的 ID 顺序重排场景。
+func ReorderScenesHandler(w http.ResponseWriter, r *http.Request) {
+	log.Printf("[HTTP] %s %s - 来自 %s", r.Method, r.URL.Path, r.RemoteAddr)
+	if r.Method != http.MethodPost {
+		http.Error(w, "不支持的请求方法", http.StatusMethodNotAllowed)
+		return
+	}
+	project, ok := resolveProject(w, r)
+	if !ok {
+		return
+	}
+
+	var payload struct {
+		IDs []string `json:"ids"`
+	}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(&payload); err != nil {
+		http.Error(w, "请求数据无效", http.StatusBadRequest)
+		return
+	}
+
+	scenes, err := project.ReorderScenes(payload.IDs, ifMatchVersion(r))
+	if errors.Is(err, config.ErrVersionMismatch) {
+		writeScenesConflict(w, project)
+		return
+	}
+	if err != nil {
+		writeItemError(w, "调整场景顺序", err)
+		return
+	}
+	setETag(w, config.ScenesVersion(scenes))
+	utils.WriteJSON(w, scenes)
+}
@@ -0,0 +1,219 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"taco/backend/config"
+	"taco/backend/models"
+)
+
+func seedScenes(t *testing.T) {
+	t.Helper()
+	scenes := []models.Scene{
+		{ID: "scn_a", Title: "场景1", Description: "描述1", ImagePath: "/generated/images/a.png"},
+		{ID: "scn_b", Title: "场景2", Description: "描述2"},
+		{ID: "scn_c", Title: "场景3", Description: "描述3"},
+	}
+	if err := config.SaveScenesData(scenes); err != nil {
+		t.Fatalf("seed scenes: %v", err)
+	}
+}
+
+func sceneTitles(t *testing.T) []string {
+	t.Helper()
+	scenes, err := config.LoadScenesData()
+	if err != nil {
+		t.Fatalf("load scenes: %v", err)
+	}
+	titles := make([]string, len(scenes))
+	for i, scene := range scenes {
+		titles[i] = scene.Title
+	}
+	return titles
+}
+
+func TestSceneItemHandlerGet(t *testing.T) {
+	useTempPaths(t)
+	seedScenes(t)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/scenes/scn_b", nil)
+	w := httptest.NewRecorder()
+	SceneItemHandler(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+	var scene models.Scene
+	json.Unmarshal(w.Body.Bytes(), &scene)
+	if scene.Title != "场景2" {
+		t.Errorf("Expected 场景2, got %s", scene.Title)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/api/scenes/scn_missing", nil)
+	w = httptest.NewRecorder()
+	SceneItemHandler(w, req)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404, got %d", w.Code)
+	}
+}
+
+func TestSceneItemHandlerPatchKeepsOtherFields(t *testing.T) {
+	useTempPaths(t)
+	seedScenes(t)
+
+	body := []byte(`{"title":"新标题"}`)
+	req := httptest.NewRequest(http.MethodPatch, "/api/scenes/scn_a", bytes.NewReader(body))
+	w := httptest.NewRecorder()
+	SceneItemHandler(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	scenes, _ := config.LoadScenesData()
+	if scenes[0].Title != "新标题" || scenes[0].Description != "描述1" || scenes[0].ImagePath == "" {
+		t.Errorf("Expected only title to change, got %+v", scenes[0])
+	}
+	if scenes[0].ID != "scn_a" {
+		t.Errorf("Expected ID to be kept, got %s", scenes[0].ID)
+	}
+}
+
+func TestSceneItemHandlerPutReplaces(t *testing.T) {
+	useTempPaths(t)
+	seedScenes(t)
+
+	body := []byte(`{"id":"scn_other","title":"替换","description":"新描述"}`)
+	req := httptest.NewRequest(http.MethodPut, "/api/scenes/scn_a", bytes.NewReader(body))
+	w := httptest.NewRecorder()
+	SceneItemHandler(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	scenes, _ := config.LoadScenesData()
+	if scenes[0].ID != "scn_a" || scenes[0].Title != "替换" || scenes[0].ImagePath != "" {
+		t.Errorf("Expected scene to be replaced in place, got %+v", scenes[0])
+	}
+}
+
+func TestSceneItemHandlerDelete(t *testing.T) {
+	useTempPaths(t)
+	seedScenes(t)
+
+	req := httptest.NewRequest(http.MethodDelete, "/api/scenes/scn_b", nil)
+	w := httptest.NewRecorder()
+	SceneItemHandler(w, req)
+
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("Expected status 204, got %d", w.Code)
+	}
+	titles := sceneTitles(t)
+	if len(titles) != 2 || titles[0] != "场景1" || titles[1] != "场景3" {
+		t.Errorf("Unexpected scenes after delete: %v", titles)
+	}
+}
+
+func TestInsertSceneHandler(t *testing.T) {
+	useTempPaths(t)
+	seedScenes(t)
+
+	body := []byte(`{"position":1,"scene":{"title":"插入"}}`)
+	req := httptest.NewRequest(http.MethodPost, "/api/scenes/insert", bytes.NewReader(body))
+	w := httptest.NewRecorder()
+	InsertSceneHandler(w, req)
+
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d: %s", w.Code, w.Body.String())
+	}
+	var scene models.Scene
+	json.Unmarshal(w.Body.Bytes(), &scene)
+	if scene.ID == "" {
+		t.Error("Expected inserted scene to get an ID")
+	}
+	titles := sceneTitles(t)
+	if len(titles) != 4 || titles[1] != "插入" {
+		t.Errorf("Unexpected scenes after insert: %v", titles)
+	}
+}
+
+func TestReorderScenesHandler(t *testing.T) {
+	useTempPaths(t)
+	seedScenes(t)
+
+	body := []byte(`{"ids":["scn_c","scn_a","scn_b"]}`)
+	req := httptest.NewRequest(http.MethodPost, "/api/scenes/reorder", bytes.NewReader(body))
+	w := httptest.NewRecorder()
+	ReorderScenesHandler(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	titles := sceneTitles(t)
+	if titles[0] != "场景3" || titles[1] != "场景1" || titles[2] != "场景2" {
+		t.Errorf("Unexpected order: %v", titles)
+	}
+
+	body = []byte(`{"ids":["scn_c","scn_a"]}`)
+	req = httptest.NewRequest(http.MethodPost, "/api/scenes/reorder", bytes.NewReader(body))
+	w = httptest.NewRecorder()
+	ReorderScenesHandler(w, req)
+	if w.Code != http.StatusConflict {
+		t.Errorf("Expected status 409 for incomplete order, got %d", w.Code)
+	}
+}
+
+func TestCharacterItemHandlerPatch(t *testing.T) {
+	useTempPaths(t)
+	config.SaveCharactersData([]models.CharacterProfile{
+		{ID: "chr_a", Name: "角色1", Description: "描述1"},
+		{ID: "chr_b", Name: "角色2", Description: "描述2"},
+	})
+
+	body := []byte(`{"description":"新描述"}`)
+	req := httptest.NewRequest(http.MethodPatch, "/api/characters/chr_b", bytes.NewReader(body))
+	w := httptest.NewRecorder()
+	CharacterItemHandler(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	characters, _ := config.LoadCharactersData()
+	if characters[1].Name != "角色2" || characters[1].Description != "新描述" {
+		t.Errorf("Unexpected character after patch: %+v", characters[1])
+	}
+	if characters[0].Description != "描述1" {
+		t.Errorf("Expected other character untouched, got %+v", characters[0])
+	}
+}
+
+func TestCharacterInsertAndReorder(t *testing.T) {
+	useTempPaths(t)
+	config.SaveCharactersData([]models.CharacterProfile{{ID: "chr_a", Name: "角色1"}})
+
+	body := []byte(`{"character":{"name":"角色2"}}`)
+	req := httptest.NewRequest(http.MethodPost, "/api/characters/insert", bytes.NewReader(body))
+	w := httptest.NewRecorder()
+	InsertCharacterHandler(w, req)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d: %s", w.Code, w.Body.String())
+	}
+	var inserted models.CharacterProfile
+	json.Unmarshal(w.Body.Bytes(), &inserted)
+
+	order, _ := json.Marshal(map[string][]string{"ids": {inserted.ID, "chr_a"}})
+	req = httptest.NewRequest(http.MethodPost, "/api/characters/reorder", bytes.NewReader(order))
+	w = httptest.NewRecorder()
+	ReorderCharactersHandler(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	characters, _ := config.LoadCharactersData()
+	if len(characters) != 2 || characters[0].Name != "角色2" || characters[1].ID != "chr_a" {
+		t.Errorf("Unexpected characters: %+v", characters)
+	}
+}
@@ -0,0 +1,170 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"path/filepath"
+	"strings"
+
+	"taco/backend/config"
+	"taco/backend/models"
+	"taco/backend/services/novel"
+	"taco/backend/utils"
+)
+
+// markActiveNovel 标记当前项目正在使用的小说。
+func markActiveNovel(novels []models.NovelInfo, cfg models.Config) {
+	for i := range novels {
+		novels[i].Active = cfg.NovelFile != "" && filepath.Clean(cfg.NovelFile) == filepath.Clean(novels[i].FilePath)
+	}
+}
+
+// NovelsHandler 列出当前项目上传过的小说。
+func NovelsHandler(w http.ResponseWriter, r *http.Request) {
+	log.Printf("[HTTP] %s %s - 来自 %s", r.Method, r.URL.Path, r.RemoteAddr)
+	if r.Method != http.MethodGet {
+		http.Error(w, "不支持的请求方法", http.StatusMethodNotAllowed)
+		return
+	}
+	project, ok := resolveProject(w, r)
+	if !ok {
+		return
+	}
+	cfg, err := project.LoadConfig()
+	if err != nil {
+		http.Error(w, fmt.Sprintf("读取配置失败: %v", err), http.StatusInternalServerError)
+		return
+	}
+	novels, err := novel.OpenLibrary(project.UploadDir).List()
+	if err != nil {
+		log.Printf("[ERROR] 读取小说库失败: %v", err)
+		http.Error(w, fmt.Sprintf("读取小说库失败: %v", err), http.StatusInternalServerError)
+		return
+	}
+	markActiveNovel(novels, cfg)
+	utils.WriteJSON(w, novels)
+}
+
+// NovelItemHandler 处理 /api/novels/{id} 的查询、重命名与删除，
+// 以及 POST /api/novels/{id}/activate 切换当前项目使用的小说。
+func NovelItemHandler(w http.ResponseWriter, r *http.Request) {
+	log.Printf("[HTTP] %s %s - 来自 %s", r.Method, r.URL.Path, r.RemoteAddr)
+	rest := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/novels/"), "/")
+	id, action, _ := strings.Cut(rest, "/")
+	if id == "" || (action != "" && action != "activate") {
+		http.NotFound(w, r)
+		return
+	}
+	project, ok := resolveProject(w, r)
+	if !ok {
+		return
+	}
+	library := novel.OpenLibrary(project.UploadDir)
+
+	if action == "activate" {
+		if r.Method != http.MethodPost {
+			http.Error(w, "不支持的请求方法", http.StatusMethodNotAllowed)
+			return
+		}
+		activateNovel(w, project, library, id)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		info, err := library.Get(id)
+		if err != nil {
+			writeNovelError(w, "读取小说", err)
+			return
+		}
+		cfg, err := project.LoadConfig()
+		if err != nil {
+			http.Error(w, fmt.Sprintf("读取配置失败: %v", err), http.StatusInternalServerError)
+			return
+		}
+		novels := []models.NovelInfo{info}
+		markActiveNovel(novels, cfg)
+		utils.WriteJSON(w, novels[0])
+	case http.MethodPut, http.MethodPatch:
+		var payload struct {
+			Title string `json:"title"`
+		}
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(&payload); err != nil {
+			http.Error(w, "请求数据无效", http.StatusBadRequest)
+			return
+		}
+		if strings.TrimSpace(payload.Title) == "" {
+			http.Error(w, "小说标题不能为空", http.StatusBadRequest)
+			return
+		}
+		info, err := library.Rename(id, payload.Title)
+		if err != nil {
+			writeNovelError(w, "重命名小说", err)
+			return
+		}
+		log.Printf("[SUCCESS] 小说已重命名: %s -> %s", id, info.Title)
+		utils.WriteJSON(w, info)
+	case http.MethodDelete:
+		cfg, err := project.LoadConfig()
+		if err != nil {
+			http.Error(w, fmt.Sprintf("读取配置失败: %v", err), http.StatusInternalServerError)
+			return
+		}
+		path, err := library.Path(id)
+		if err != nil {
+			writeNovelError(w, "删除小说", err)
+			return
+		}
+		if err := library.Delete(id); err != nil {
+			writeNovelError(w, "删除小说", err)
+			return
+		}
+		// 删除的是当前使用的小说时清空配置，避免之后的提取读取不存在的文件。
+		if cfg.NovelFile != "" && filepath.Clean(cfg.NovelFile) == filepath.Clean(path) {
+			cfg.NovelFile = ""
+			if err := project.SaveConfig(cfg); err != nil {
+				log.Printf("[ERROR] 保存配置失败: %v", err)
+				http.Error(w, fmt.Sprintf("保存配置失败: %v", err), http.StatusInternalServerError)
+				return
+			}
+		}
+		log.Printf("[SUCCESS] 已删除小说: %s", id)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.Error(w, "不支持的请求方法", http.StatusMethodNotAllowed)
+	}
+}
+
+func activateNovel(w http.ResponseWriter, project *config.Project, library *novel.Library, id string) {
+	info, err := library.Get(id)
+	if err != nil {
+		writeNovelError(w, "切换小说", err)
+		return
+	}
+	cfg, err := project.LoadConfig()
+	if err != nil {
+		http.Error(w, fmt.Sprintf("读取配置失败: %v", err), http.StatusInternalServerError)
+		return
+	}
+	cfg.NovelFile = info.FilePath
+	if err := project.SaveConfig(cfg); err != nil {
+		log.Printf("[ERROR] 保存配置失败: %v", err)
+		http.Error(w, fmt.Sprintf("保存配置失败: %v", err), http.StatusInternalServerError)
+		return
+	}
+	info.Active = true
+	log.Printf("[SUCCESS] 当前小说已切换为: %s", info.Title)
+	utils.WriteJSON(w, info)
+}
+
+func writeNovelError(w http.ResponseWriter, action string, err error) {
+	if errors.Is(err, novel.ErrNovelNotFound) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	log.Printf("[ERROR] %s失败: %v", action, err)
+	http.Error(w, fmt.Sprintf("%s失败: %v", action, err), http.StatusInternalServerError)
+}
@@ -0,0 +1,171 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"taco/backend/config"
+	"taco/backend/models"
+	"taco/backend/utils"
+)
+
+func listNovels(t *testing.T) []models.NovelInfo {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodGet, "/api/novels", nil)
+	w := httptest.NewRecorder()
+	NovelsHandler(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var novels []models.NovelInfo
+	json.Unmarshal(w.Body.Bytes(), &novels)
+	return novels
+}
+
+func TestUploadHandlerRejectsDuplicate(t *testing.T) {
+	useTempPaths(t)
+	uploadNovel(t, []byte(chapterNovel), "")
+
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	part, _ := writer.CreateFormFile("novel", "copy.txt")
+	part.Write([]byte(chapterNovel))
+	writer.Close()
+	req := httptest.NewRequest(http.MethodPost, "/api/upload", body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	w := httptest.NewRecorder()
+	UploadHandler(w, req)
+
+	if w.Code != http.StatusConflict {
+		t.Fatalf("Expected status 409, got %d: %s", w.Code, w.Body.String())
+	}
+	if novels := listNovels(t); len(novels) != 1 {
+		t.Errorf("Expected the duplicate not to be stored, got %+v", novels)
+	}
+}
+
+func TestUploadHandlerRejectsConcurrentDuplicates(t *testing.T) {
+	useTempPaths(t)
+
+	const uploads = 8
+	codes := make([]int, uploads)
+	var wg sync.WaitGroup
+	for i := 0; i < uploads; i++ {
+		body := &bytes.Buffer{}
+		writer := multipart.NewWriter(body)
+		part, _ := writer.CreateFormFile("novel", fmt.Sprintf("copy%d.txt", i))
+		part.Write([]byte(chapterNovel))
+		writer.Close()
+		req := httptest.NewRequest(http.MethodPost, "/api/upload", body)
+		req.Header.Set("Content-Type", writer.FormDataContentType())
+
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			w := httptest.NewRecorder()
+			UploadHandler(w, req)
+			codes[i] = w.Code
+		}(i)
+	}
+	wg.Wait()
+
+	stored := 0
+	for _, code := range codes {
+		switch code {
+		case http.StatusOK:
+			stored++
+		case http.StatusConflict:
+		default:
+			t.Errorf("Unexpected status %d", code)
+		}
+	}
+	if novels := listNovels(t); stored != 1 || len(novels) != 1 {
+		t.Errorf("Expected exactly one copy to be stored, got %d accepted and %+v", stored, novels)
+	}
+}
+
+func TestNovelLibraryActivateRenameDelete(t *testing.T) {
+	useTempPaths(t)
+	first := uploadNovel(t, []byte(chapterNovel), "")
+	second := uploadNovel(t, gbkNovel, "")
+
+	// 上传前就在目录中的文件同样会列出。
+	legacy := filepath.Join(utils.UploadDir, "1700000000000000000_旧书.txt")
+	os.WriteFile(legacy, []byte("第一章 开始\n正文"), 0o644)
+
+	novels := listNovels(t)
+	if len(novels) != 3 {
+		t.Fatalf("Expected 3 novels, got %+v", novels)
+	}
+	for _, info := range novels {
+		switch info.ID {
+		case first.ID:
+			if info.Title != "gbk" || info.Chapters != 3 || info.Encoding != "utf-8" || info.Size != int64(len(chapterNovel)) {
+				t.Errorf("Unexpected metadata %+v", info)
+			}
+		case filepath.Base(legacy):
+			if info.Title != "旧书" || info.Chapters != 1 {
+				t.Errorf("Unexpected legacy metadata %+v", info)
+			}
+		}
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/api/novels/"+second.ID+"/activate", nil)
+	w := httptest.NewRecorder()
+	NovelItemHandler(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if cfg, _ := config.LoadConfig(); cfg.NovelFile != second.FilePath {
+		t.Errorf("Expected active novel %s, got %s", second.FilePath, cfg.NovelFile)
+	}
+
+	req = httptest.NewRequest(http.MethodPatch, "/api/novels/"+second.ID, strings.NewReader(`{"title": "红楼梦"}`))
+	w = httptest.NewRecorder()
+	NovelItemHandler(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	for _, info := range listNovels(t) {
+		if info.ID == second.ID && (info.Title != "红楼梦" || !info.Active) {
+			t.Errorf("Expected renamed active novel, got %+v", info)
+		}
+	}
+
+	req = httptest.NewRequest(http.MethodDelete, "/api/novels/"+second.ID, nil)
+	w = httptest.NewRecorder()
+	NovelItemHandler(w, req)
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("Expected status 204, got %d: %s", w.Code, w.Body.String())
+	}
+	if _, err := os.Stat(second.FilePath + ".orig"); !os.IsNotExist(err) {
+		t.Error("Expected the original file to be removed")
+	}
+	if cfg, _ := config.LoadConfig(); cfg.NovelFile != "" {
+		t.Errorf("Expected active novel to be cleared, got %s", cfg.NovelFile)
+	}
+	if novels := listNovels(t); len(novels) != 2 {
+		t.Errorf("Expected 2 novels after delete, got %+v", novels)
+	}
+}
+
+func TestNovelItemHandlerNotFound(t *testing.T) {
+	useTempPaths(t)
+	for _, path := range []string{"/api/novels/missing.txt", "/api/novels/..", "/api/novels/novels.json"} {
+		req := httptest.NewRequest(http.MethodDelete, path, nil)
+		w := httptest.NewRecorder()
+		NovelItemHandler(w, req)
+		if w.Code != http.StatusNotFound {
+			t.Errorf("%s: expected status 404, got %d", path, w.Code)
+		}
+	}
+}
@@ -0,0 +1,45 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+
+	"taco/backend/utils"
+)
+
+// GCHandler 清理当前项目中未被引用的生成文件。GET 只返回清理报告；
+// POST 执行删除，带 dryRun=true 时同样只返回报告。
+func GCHandler(w http.ResponseWriter, r *http.Request) {
+	log.Printf("[HTTP] %s %s - 来自 %s", r.Method, r.URL.Path, r.RemoteAddr)
+	if r.Method != http.MethodGet && r.Method != http.MethodPost {
+		http.Error(w, "不支持的请求方法", http.StatusMethodNotAllowed)
+		return
+	}
+	project, ok := resolveProject(w, r)
+	if !ok {
+		return
+	}
+
+	dryRun := r.Method == http.MethodGet
+	if value := r.URL.Query().Get("dryRun"); value != "" {
+		parsed, err := strconv.ParseBool(value)
+		if err != nil {
+			http.Error(w, "dryRun 参数无效", http.StatusBadRequest)
+			return
+		}
+		dryRun = dryRun || parsed
+	}
+
+	report, err := project.CollectGarbage(dryRun)
+	if err != nil {
+		log.Printf("[ERROR] 清理生成文件失败: %v", err)
+		http.Error(w, fmt.Sprintf("清理生成文件失败: %v", err), http.StatusInternalServerError)
+		return
+	}
+	if !dryRun {
+		log.Printf("[SUCCESS] 已清理项目 %s 的 %d 个未引用文件，释放 %d 字节", project.ID, len(report.Orphans), report.FreedBytes)
+	}
+	utils.WriteJSON(w, report)
+}
@@ -0,0 +1,67 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"taco/backend/config"
+	"taco/backend/models"
+	"taco/backend/utils"
+)
+
+func TestGCHandler(t *testing.T) {
+	useTempPaths(t)
+	os.MkdirAll(utils.GeneratedImagesDir, 0o755)
+	old := time.Now().Add(-time.Hour)
+	for _, name := range []string{"used.png", "orphan.png"} {
+		path := filepath.Join(utils.GeneratedImagesDir, name)
+		os.WriteFile(path, []byte("png"), 0o644)
+		os.Chtimes(path, old, old)
+	}
+	config.SaveScenesData([]models.Scene{{ID: "scn_a", ImagePath: "/generated/images/used.png"}})
+	orphan := filepath.Join(utils.GeneratedImagesDir, "orphan.png")
+
+	for _, req := range []*http.Request{
+		httptest.NewRequest(http.MethodGet, "/api/maintenance/gc", nil),
+		httptest.NewRequest(http.MethodPost, "/api/maintenance/gc?dryRun=true", nil),
+	} {
+		w := httptest.NewRecorder()
+		GCHandler(w, req)
+		var report models.GCReport
+		json.NewDecoder(w.Body).Decode(&report)
+		if w.Code != http.StatusOK || !report.DryRun || len(report.Orphans) != 1 {
+			t.Fatalf("Unexpected dry-run response %d: %+v", w.Code, report)
+		}
+		if report.Orphans[0].Path != "/generated/images/orphan.png" {
+			t.Errorf("Unexpected orphan path %s", report.Orphans[0].Path)
+		}
+		if _, err := os.Stat(orphan); err != nil {
+			t.Fatal("Dry run should not delete files")
+		}
+	}
+
+	w := httptest.NewRecorder()
+	GCHandler(w, httptest.NewRequest(http.MethodPost, "/api/maintenance/gc", nil))
+	var report models.GCReport
+	json.NewDecoder(w.Body).Decode(&report)
+	if w.Code != http.StatusOK || report.DryRun || !report.Orphans[0].Removed {
+		t.Fatalf("Unexpected response %d: %+v", w.Code, report)
+	}
+	if _, err := os.Stat(orphan); !os.IsNotExist(err) {
+		t.Error("Expected orphan to be removed")
+	}
+	if _, err := os.Stat(filepath.Join(utils.GeneratedImagesDir, "used.png")); err != nil {
+		t.Error("Referenced file should be kept")
+	}
+
+	w = httptest.NewRecorder()
+	GCHandler(w, httptest.NewRequest(http.MethodPost, "/api/maintenance/gc?dryRun=maybe", nil))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400, got %d", w.Code)
+	}
+}
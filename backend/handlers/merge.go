@@ -0,0 +1,132 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"taco/backend/config"
+	"taco/backend/models"
+	"taco/backend/services/cast"
+	"taco/backend/services/llm"
+	"taco/backend/utils"
+)
+
+// mergeResult 是合并角色的响应：合并后的目标角色、全部角色，以及出场人物被改写的场景数。
+type mergeResult struct {
+	Character     models.CharacterProfile   `json:"character"`
+	Characters    []models.CharacterProfile `json:"characters"`
+	ScenesUpdated int                       `json:"scenesUpdated"`
+}
+
+// MergeCharactersHandler 把 sourceIds 对应的角色并入 targetId（{"targetId": "...", "sourceIds": [...]}）：
+// 被合并角色的名称和别名成为目标的别名，场景出场人物中的这些名称改为目标的名称。
+// If-Match 与整体保存角色列表时相同。
+//
+// 场景在持有角色文件锁期间、写入角色之前改写：改写失败时角色保持不变，客户端可以原样重试。
+// 场景写入后保存角色仍失败的，场景中只是把别名换成了仍然存在的目标角色名称，重试同样能完成合并。
+func MergeCharactersHandler(w http.ResponseWriter, r *http.Request) {
+	log.Printf("[HTTP] %s %s - 来自 %s", r.Method, r.URL.Path, r.RemoteAddr)
+	if r.Method != http.MethodPost {
+		http.Error(w, "不支持的请求方法", http.StatusMethodNotAllowed)
+		return
+	}
+	project, ok := resolveProject(w, r)
+	if !ok {
+		return
+	}
+
+	var payload struct {
+		TargetID  string   `json:"targetId"`
+		SourceIDs []string `json:"sourceIds"`
+	}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(&payload); err != nil {
+		http.Error(w, "请求数据无效", http.StatusBadRequest)
+		return
+	}
+	if payload.TargetID == "" {
+		http.Error(w, "缺少合并目标 targetId", http.StatusBadRequest)
+		return
+	}
+
+	ifMatch := ifMatchVersion(r)
+	var target models.CharacterProfile
+	var mergedNames []string
+	updated := 0
+	characters, err := project.MutateCharactersData(func(characters []models.CharacterProfile) ([]models.CharacterProfile, error) {
+		if !config.VersionMatches(ifMatch, config.CharactersVersion(characters)) {
+			return nil, config.ErrVersionMismatch
+		}
+		for _, id := range append([]string{payload.TargetID}, payload.SourceIDs...) {
+			if config.FindCharacterIndex(characters, id) < 0 {
+				return nil, config.ErrCharacterNotFound
+			}
+		}
+		merged, mergedTarget, names, err := cast.Merge(characters, payload.TargetID, payload.SourceIDs)
+		if err != nil {
+			return nil, err
+		}
+		target, mergedNames = mergedTarget, names
+		if _, err := project.MutateScenesData(func(scenes []models.Scene) ([]models.Scene, error) {
+			updated = cast.RenameInScenes(scenes, mergedNames, target.Name)
+			return scenes, nil
+		}); err != nil {
+			return nil, fmt.Errorf("改写场景出场人物失败: %w", err)
+		}
+		return merged, nil
+	})
+	switch {
+	case errors.Is(err, config.ErrVersionMismatch):
+		writeCharactersConflict(w, project)
+		return
+	case errors.Is(err, cast.ErrMergeTarget), errors.Is(err, cast.ErrMergeSources):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	case err != nil:
+		writeItemError(w, "合并角色", err)
+		return
+	}
+
+	log.Printf("[SUCCESS] 已将 %v 合并到角色 %s，改写 %d 个场景", mergedNames, target.Name, updated)
+	setETag(w, config.CharactersVersion(characters))
+	utils.WriteJSON(w, mergeResult{Character: target, Characters: characters, ScenesUpdated: updated})
+}
+
+// SuggestMergesHandler 请 LLM 找出可能是同一人物的角色，只返回建议，不做修改。
+func SuggestMergesHandler(w http.ResponseWriter, r *http.Request) {
+	log.Printf("[HTTP] %s %s - 来自 %s", r.Method, r.URL.Path, r.RemoteAddr)
+	if r.Method != http.MethodPost {
+		http.Error(w, "不支持的请求方法", http.StatusMethodNotAllowed)
+		return
+	}
+	project, ok := resolveProject(w, r)
+	if !ok {
+		return
+	}
+	cfg, err := project.LoadConfigFor(config.OpCharacterExtraction)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("读取配置失败: %v", err), http.StatusInternalServerError)
+		return
+	}
+	characters, err := project.LoadCharactersData()
+	if err != nil {
+		http.Error(w, fmt.Sprintf("读取角色失败: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 300*time.Second)
+	defer cancel()
+
+	suggestions, err := llm.SuggestCharacterMerges(ctx, cfg, characters)
+	if err != nil {
+		log.Printf("[ERROR] 查找重复角色失败: %v", err)
+		http.Error(w, fmt.Sprintf("查找重复角色失败: %v", err), http.StatusInternalServerError)
+		return
+	}
+	log.Printf("[SUCCESS] 找到 %d 组可能重复的角色", len(suggestions))
+	utils.WriteJSON(w, suggestions)
+}
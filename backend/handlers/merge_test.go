@@ -0,0 +1,84 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"taco/backend/config"
+	"taco/backend/models"
+	"taco/backend/utils"
+)
+
+func mergeCharacters(t *testing.T, body, ifMatch string) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPost, "/api/characters/merge", strings.NewReader(body))
+	if ifMatch != "" {
+		req.Header.Set("If-Match", ifMatch)
+	}
+	w := httptest.NewRecorder()
+	MergeCharactersHandler(w, req)
+	return w
+}
+
+func TestMergeCharactersHandlerRewritesScenes(t *testing.T) {
+	useTempPaths(t)
+	config.SaveCharactersData([]models.CharacterProfile{
+		{ID: "chr_a", Name: "刘姥姥"},
+		{ID: "chr_b", Name: "姥姥", Aliases: []string{"刘氏"}},
+		{ID: "chr_c", Name: "王熙凤"},
+	})
+	config.SaveScenesData([]models.Scene{
+		{ID: "scn_1", Title: "进府", Characters: []string{"刘氏", "王熙凤"}},
+		{ID: "scn_2", Title: "宴席", Characters: []string{"王熙凤"}},
+	})
+
+	if w := mergeCharacters(t, `{"targetId": "chr_a", "sourceIds": ["x"]}`, ""); w.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 for an unknown character, got %d", w.Code)
+	}
+	if w := mergeCharacters(t, `{"targetId": "chr_a", "sourceIds": ["chr_a"]}`, ""); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 when merging a character into itself, got %d", w.Code)
+	}
+	if w := mergeCharacters(t, `{"targetId": "chr_a", "sourceIds": ["chr_b"]}`, `"stale"`); w.Code != http.StatusPreconditionFailed {
+		t.Errorf("Expected 412 for a stale version, got %d", w.Code)
+	}
+
+	w := mergeCharacters(t, `{"targetId": "chr_a", "sourceIds": ["chr_b"]}`, "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var result mergeResult
+	json.Unmarshal(w.Body.Bytes(), &result)
+	if result.ScenesUpdated != 1 || len(result.Characters) != 2 || strings.Join(result.Character.Aliases, ",") != "姥姥,刘氏" {
+		t.Errorf("Unexpected result %+v", result)
+	}
+	if w.Header().Get("ETag") == "" {
+		t.Error("Expected an ETag for the merged characters")
+	}
+
+	scenes, _ := config.LoadScenesData()
+	if strings.Join(scenes[0].Characters, ",") != "刘姥姥,王熙凤" || strings.Join(scenes[1].Characters, ",") != "王熙凤" {
+		t.Errorf("Expected scene characters to be rewritten, got %+v", scenes)
+	}
+	characters, _ := config.LoadCharactersData()
+	if len(characters) != 2 || characters[0].ID != "chr_a" || characters[1].ID != "chr_c" {
+		t.Errorf("Expected the merged character to be removed, got %+v", characters)
+	}
+}
+
+func TestMergeCharactersHandlerKeepsCharactersWhenScenesFail(t *testing.T) {
+	useTempPaths(t)
+	config.SaveCharactersData([]models.CharacterProfile{{ID: "chr_a", Name: "刘姥姥"}, {ID: "chr_b", Name: "姥姥"}})
+	os.WriteFile(utils.ScenesPath, []byte("{"), 0o644)
+
+	if w := mergeCharacters(t, `{"targetId": "chr_a", "sourceIds": ["chr_b"]}`, ""); w.Code != http.StatusInternalServerError {
+		t.Errorf("Expected status 500 when the scenes cannot be rewritten, got %d", w.Code)
+	}
+	characters, _ := config.LoadCharactersData()
+	if len(characters) != 2 {
+		t.Errorf("Expected the characters to stay unmerged so the request can be retried, got %+v", characters)
+	}
+}
@@ -0,0 +1,302 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"taco/backend/models"
+	"taco/backend/services/cast"
+	"taco/backend/services/novel"
+	"taco/backend/utils"
+)
+
+// previewLength 是上传后返回的预览字数。
+const previewLength = 300
+
+// convertNovel 把上传目录中 id 对应的原始文件（<文本文件名>.orig）转换为 UTF-8 文本写入正文文件，
+// EPUB 和 DOCX 的章节结构另存为章节文件，并更新小说库中的元数据。encoding 只对纯文本有效，
+// 为空时自动识别；title 为空时保留原标题。
+func convertNovel(library *novel.Library, id, encoding, title string) (models.NovelUpload, error) {
+	path, err := library.Path(id)
+	if err != nil {
+		return models.NovelUpload{}, err
+	}
+	data, err := os.ReadFile(path + novel.OriginalSuffix)
+	if err != nil {
+		return models.NovelUpload{}, err
+	}
+	requested, err := novel.CanonicalEncoding(encoding)
+	if err != nil {
+		return models.NovelUpload{}, err
+	}
+	doc, err := novel.Load(data, requested)
+	if err != nil {
+		return models.NovelUpload{}, err
+	}
+
+	tmpPath := path + ".tmp"
+	if err := os.WriteFile(tmpPath, []byte(doc.Text), 0o644); err != nil {
+		return models.NovelUpload{}, err
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		return models.NovelUpload{}, err
+	}
+	if err := novel.SaveChapters(path, doc.Chapters); err != nil {
+		return models.NovelUpload{}, err
+	}
+	chapters := len(novel.ReadChapters(path, doc.Text))
+	if _, err := library.Record(models.NovelInfo{
+		ID:       id,
+		Title:    title,
+		Size:     int64(len(doc.Text)),
+		Format:   doc.Format,
+		Encoding: doc.Encoding,
+		Chapters: chapters,
+		Hash:     novel.HashContent(data),
+	}); err != nil {
+		return models.NovelUpload{}, err
+	}
+	return models.NovelUpload{
+		ID:       id,
+		FilePath: path,
+		Format:   doc.Format,
+		Encoding: doc.Encoding,
+		Detected: requested == "" || doc.Format != novel.FormatText,
+		Invalid:  doc.Invalid,
+		Chapters: chapters,
+		Preview:  novel.Preview(doc.Text, previewLength),
+	}, nil
+}
+
+// NovelEncodingHandler 按指定的编码重新转换已上传的小说，filePath 为空时转换当前项目的小说。
+func NovelEncodingHandler(w http.ResponseWriter, r *http.Request) {
+	log.Printf("[HTTP] %s %s - 来自 %s", r.Method, r.URL.Path, r.RemoteAddr)
+	if r.Method != http.MethodPost {
+		http.Error(w, "不支持的请求方法", http.StatusMethodNotAllowed)
+		return
+	}
+	project, ok := resolveProject(w, r)
+	if !ok {
+		return
+	}
+
+	var payload struct {
+		FilePath string `json:"filePath"`
+		Encoding string `json:"encoding"`
+	}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(&payload); err != nil {
+		http.Error(w, "请求数据格式错误", http.StatusBadRequest)
+		return
+	}
+	if _, err := novel.CanonicalEncoding(payload.Encoding); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	path := strings.TrimSpace(payload.FilePath)
+	if path == "" {
+		cfg, err := project.LoadConfig()
+		if err != nil {
+			http.Error(w, fmt.Sprintf("读取配置失败: %v", err), http.StatusInternalServerError)
+			return
+		}
+		path = cfg.NovelFile
+	}
+	if path == "" {
+		http.Error(w, "尚未上传小说文件", http.StatusBadRequest)
+		return
+	}
+	// 只允许转换本项目上传目录中的文件。
+	rel, err := filepath.Rel(project.UploadDir, path)
+	if err != nil || rel != filepath.Base(rel) || rel == "." || rel == ".." {
+		http.Error(w, "文件不在上传目录中", http.StatusBadRequest)
+		return
+	}
+
+	upload, err := convertNovel(novel.OpenLibrary(project.UploadDir), rel, payload.Encoding, "")
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) || errors.Is(err, novel.ErrNovelNotFound) {
+			http.Error(w, "找不到原始文件，请重新上传小说", http.StatusNotFound)
+			return
+		}
+		log.Printf("[ERROR] 转换小说编码失败: %v", err)
+		http.Error(w, fmt.Sprintf("转换小说编码失败: %v", err), http.StatusInternalServerError)
+		return
+	}
+	log.Printf("[SUCCESS] 小说已按 %s 重新转换: %s", upload.Encoding, path)
+	utils.WriteJSON(w, upload)
+}
+
+// novelScope 是一次提取要处理的小说内容。chapters 为空表示整本小说。
+type novelScope struct {
+	text     string
+	chapters []models.Chapter
+	from, to int
+}
+
+func (s novelScope) scoped() bool {
+	return len(s.chapters) > 0
+}
+
+// selectedText 返回选中章节的原文，未限定章节时返回整本小说。
+func (s novelScope) selectedText() string {
+	if !s.scoped() {
+		return s.text
+	}
+	return s.text[s.chapters[0].Start:s.chapters[len(s.chapters)-1].End]
+}
+
+// readNovel 读取配置中的小说文件，失败时写入错误响应并返回 false。
+func readNovel(w http.ResponseWriter, cfg models.Config) (string, bool) {
+	if cfg.NovelFile == "" {
+		http.Error(w, "尚未上传小说文件", http.StatusBadRequest)
+		return "", false
+	}
+	data, err := os.ReadFile(cfg.NovelFile)
+	if err != nil {
+		log.Printf("[ERROR] 读取小说文件失败: %v", err)
+		http.Error(w, fmt.Sprintf("读取小说文件失败: %v", err), http.StatusInternalServerError)
+		return "", false
+	}
+	return string(data), true
+}
+
+// readNovelScope 读取小说并按查询参数 from、to 选取章节，序号与 /api/novel/chapters 返回的 index 一致。
+// 只给出 from 时只处理这一章，只给出 to 时从第一章开始；两者都没有时处理整本小说。
+func readNovelScope(w http.ResponseWriter, r *http.Request, cfg models.Config) (novelScope, bool) {
+	text, ok := readNovel(w, cfg)
+	if !ok {
+		return novelScope{}, false
+	}
+	scope := novelScope{text: text}
+
+	query := r.URL.Query()
+	fromRaw, toRaw := strings.TrimSpace(query.Get("from")), strings.TrimSpace(query.Get("to"))
+	if fromRaw == "" && toRaw == "" {
+		return scope, true
+	}
+	chapters := novel.ReadChapters(cfg.NovelFile, text)
+	from, to, err := parseChapterRange(fromRaw, toRaw, chapters)
+	if err == nil {
+		scope.chapters, err = novel.SelectChapters(chapters, from, to)
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return novelScope{}, false
+	}
+	scope.from, scope.to = from, to
+	return scope, true
+}
+
+func parseChapterRange(fromRaw, toRaw string, chapters []models.Chapter) (int, int, error) {
+	parse := func(raw, name string) (int, error) {
+		value, err := strconv.Atoi(raw)
+		if err != nil || value < 0 {
+			return 0, fmt.Errorf("%w: %s 必须是非负整数", novel.ErrChapterRange, name)
+		}
+		return value, nil
+	}
+	var from, to int
+	var err error
+	switch {
+	case toRaw == "":
+		if from, err = parse(fromRaw, "from"); err != nil {
+			return 0, 0, err
+		}
+		return from, from, nil
+	case fromRaw == "":
+		if len(chapters) > 0 {
+			from = chapters[0].Index
+		}
+	default:
+		if from, err = parse(fromRaw, "from"); err != nil {
+			return 0, 0, err
+		}
+	}
+	if to, err = parse(toRaw, "to"); err != nil {
+		return 0, 0, err
+	}
+	return from, to, nil
+}
+
+// mergeChapterScenes 用新提取的场景替换 current 中属于 [from, to] 章的场景，其他场景保持不变。
+// 新场景放在被替换场景原来的位置；没有可替换的场景时插在后续章节的第一个场景之前，否则追加到末尾。
+// 没有章节标记的场景（手动添加或整本提取的旧数据）不会被替换。
+func mergeChapterScenes(current, extracted []models.Scene, from, to int) []models.Scene {
+	position := -1
+	kept := make([]models.Scene, 0, len(current))
+	for _, scene := range current {
+		inRange := scene.Chapter > 0 && scene.Chapter >= from && scene.Chapter <= to
+		if inRange && position < 0 {
+			position = len(kept)
+		}
+		if !inRange {
+			kept = append(kept, scene)
+		}
+	}
+	if position < 0 {
+		position = len(kept)
+		for i, scene := range kept {
+			if scene.Chapter > to {
+				position = i
+				break
+			}
+		}
+	}
+
+	merged := make([]models.Scene, 0, len(kept)+len(extracted))
+	merged = append(merged, kept[:position]...)
+	merged = append(merged, extracted...)
+	return append(merged, kept[position:]...)
+}
+
+// mergeChapterCharacters 把按章节提取到的角色追加到现有角色之后。名称或别名与已有角色相同的
+// 视为同一角色，只为已有角色补充新出现的别名和空缺的设定。
+func mergeChapterCharacters(current, extracted []models.CharacterProfile) []models.CharacterProfile {
+	for _, character := range extracted {
+		index := cast.NewIndex(current)
+		matched := -1
+		for _, name := range cast.Names(character) {
+			if idx, ok := index.Find(name); ok {
+				matched = idx
+				break
+			}
+		}
+		if matched < 0 {
+			current = append(current, character)
+			continue
+		}
+		cast.AddAliases(&current[matched], cast.Names(character)...)
+		cast.FillSheet(&current[matched].CharacterSheet, character.CharacterSheet)
+	}
+	return current
+}
+
+// NovelChaptersHandler 返回当前小说识别出的章节列表。
+func NovelChaptersHandler(w http.ResponseWriter, r *http.Request) {
+	log.Printf("[HTTP] %s %s - 来自 %s", r.Method, r.URL.Path, r.RemoteAddr)
+	if r.Method != http.MethodGet {
+		http.Error(w, "不支持的请求方法", http.StatusMethodNotAllowed)
+		return
+	}
+	project, ok := resolveProject(w, r)
+	if !ok {
+		return
+	}
+	cfg, err := project.LoadConfig()
+	if err != nil {
+		http.Error(w, fmt.Sprintf("读取配置失败: %v", err), http.StatusInternalServerError)
+		return
+	}
+	text, ok := readNovel(w, cfg)
+	if !ok {
+		return
+	}
+	utils.WriteJSON(w, novel.ReadChapters(cfg.NovelFile, text))
+}
@@ -0,0 +1,245 @@
+package handlers
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"taco/backend/config"
+	"taco/backend/models"
+)
+
+const chapterNovel = "第一章 初遇\n甲甲甲甲。\n第二章 重逢\n乙乙乙乙。\n第三章 离别\n丙丙丙丙。\n"
+
+// useChapterNovel 写入三章的测试小说，LLM 指向一个按收到的章节内容返回场景的测试服务。
+func useChapterNovel(t *testing.T) *[]string {
+	t.Helper()
+	tmpDir := useTempPaths(t)
+	prompts := []string{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Messages []map[string]string `json:"messages"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		prompt := body.Messages[len(body.Messages)-1]["content"]
+		prompts = append(prompts, prompt)
+		content := `[{"name": "新角色", "description": "第二章出场"}, {"name": "旧角色", "description": "新描述"}]`
+		if strings.Contains(prompt, `"title"`) {
+			content = `[{"title": "新场景"}]`
+		}
+		json.NewEncoder(w).Encode(map[string]any{
+			"choices": []map[string]any{{"message": map[string]string{"content": content}}},
+		})
+	}))
+	t.Cleanup(server.Close)
+
+	novelPath := filepath.Join(tmpDir, "novel.txt")
+	if err := os.WriteFile(novelPath, []byte(chapterNovel), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := config.SaveConfig(models.Config{
+		NovelFile: novelPath,
+		LLM:       models.LLMConfig{Model: "m", BaseURL: server.URL, APIKey: "k"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	return &prompts
+}
+
+func TestNovelChaptersHandler(t *testing.T) {
+	useChapterNovel(t)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/novel/chapters", nil)
+	w := httptest.NewRecorder()
+	NovelChaptersHandler(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var chapters []models.Chapter
+	json.Unmarshal(w.Body.Bytes(), &chapters)
+	if len(chapters) != 3 || chapters[1].Index != 2 || chapters[1].Title != "第二章 重逢" {
+		t.Errorf("Unexpected chapters %+v", chapters)
+	}
+}
+
+func TestExtractScenesHandlerChapterRange(t *testing.T) {
+	prompts := useChapterNovel(t)
+	config.SaveScenesData([]models.Scene{
+		{ID: "scn_1", Title: "第一章旧场景", Chapter: 1},
+		{ID: "scn_2", Title: "第二章旧场景", Chapter: 2},
+		{ID: "scn_manual", Title: "手动场景"},
+		{ID: "scn_3", Title: "第三章旧场景", Chapter: 3},
+	})
+
+	req := httptest.NewRequest(http.MethodPost, "/api/scenes/extract?from=2", nil)
+	w := httptest.NewRecorder()
+	ExtractScenesHandler(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if len(*prompts) != 1 || !strings.Contains((*prompts)[0], "乙乙乙乙") || strings.Contains((*prompts)[0], "甲") {
+		t.Errorf("Expected only chapter 2 to be sent, got %q", *prompts)
+	}
+
+	scenes, _ := config.LoadScenesData()
+	titles := []string{}
+	for _, scene := range scenes {
+		titles = append(titles, scene.Title)
+	}
+	if strings.Join(titles, ",") != "第一章旧场景,新场景,手动场景,第三章旧场景" {
+		t.Errorf("Expected chapter 2 scenes replaced in place, got %v", titles)
+	}
+	if scenes[1].Chapter != 2 || scenes[1].ID == "" {
+		t.Errorf("Expected new scene tagged with chapter 2, got %+v", scenes[1])
+	}
+}
+
+func TestExtractCharactersHandlerChapterRangeKeepsExisting(t *testing.T) {
+	prompts := useChapterNovel(t)
+	config.SaveCharactersData([]models.CharacterProfile{{ID: "chr_old", Name: "旧角色", Description: "原描述"}})
+
+	req := httptest.NewRequest(http.MethodPost, "/api/characters/extract?from=2&to=3", nil)
+	w := httptest.NewRecorder()
+	ExtractCharactersHandler(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if strings.Contains((*prompts)[0], "甲") || !strings.Contains((*prompts)[0], "丙丙丙丙") {
+		t.Errorf("Expected only chapters 2-3 to be sent, got %q", (*prompts)[0])
+	}
+	characters, _ := config.LoadCharactersData()
+	if len(characters) != 2 || characters[0].Description != "原描述" || characters[1].Name != "新角色" {
+		t.Errorf("Expected new characters appended without touching existing ones, got %+v", characters)
+	}
+}
+
+func TestExtractScenesHandlerInvalidChapterRange(t *testing.T) {
+	useChapterNovel(t)
+
+	for _, query := range []string{"from=5", "from=3&to=1", "from=abc"} {
+		req := httptest.NewRequest(http.MethodPost, "/api/scenes/extract?"+query, nil)
+		w := httptest.NewRecorder()
+		ExtractScenesHandler(w, req)
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected status 400, got %d", query, w.Code)
+		}
+	}
+}
+
+func uploadNovel(t *testing.T, content []byte, encoding string) models.NovelUpload {
+	t.Helper()
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	part, _ := writer.CreateFormFile("novel", "gbk.txt")
+	part.Write(content)
+	if encoding != "" {
+		writer.WriteField("encoding", encoding)
+	}
+	writer.Close()
+
+	req := httptest.NewRequest(http.MethodPost, "/api/upload", body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	w := httptest.NewRecorder()
+	UploadHandler(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var upload models.NovelUpload
+	json.Unmarshal(w.Body.Bytes(), &upload)
+	return upload
+}
+
+// gbkNovel 是 "第一回　刘姥姥\r\n进荣国府" 的 GBK 编码。
+var gbkNovel = []byte{0xB5, 0xDA, 0xD2, 0xBB, 0xBB, 0xD8, 0xA1, 0xA1, 0xC1, 0xF5, 0xC0, 0xD1, 0xC0, 0xD1, 0x0D, 0x0A, 0xBD, 0xF8, 0xC8, 0xD9, 0xB9, 0xFA, 0xB8, 0xAE}
+
+func TestUploadHandlerConvertsGBK(t *testing.T) {
+	useTempPaths(t)
+
+	upload := uploadNovel(t, gbkNovel, "")
+	if upload.Encoding != "gb18030" || !upload.Detected || upload.Invalid != 0 {
+		t.Errorf("Expected detected gb18030, got %+v", upload)
+	}
+	data, _ := os.ReadFile(upload.FilePath)
+	if string(data) != "第一回　刘姥姥\n进荣国府" || upload.Preview != string(data) {
+		t.Errorf("Expected normalized UTF-8 text, got %q (preview %q)", data, upload.Preview)
+	}
+	if original, _ := os.ReadFile(upload.FilePath + ".orig"); !bytes.Equal(original, gbkNovel) {
+		t.Error("Expected the original bytes to be kept")
+	}
+}
+
+func TestNovelEncodingHandlerOverride(t *testing.T) {
+	useTempPaths(t)
+	upload := uploadNovel(t, gbkNovel, "utf-8")
+	if upload.Encoding != "utf-8" || upload.Detected || upload.Invalid == 0 {
+		t.Fatalf("Expected forced UTF-8 with invalid bytes, got %+v", upload)
+	}
+
+	body, _ := json.Marshal(map[string]string{"filePath": upload.FilePath, "encoding": "gbk"})
+	req := httptest.NewRequest(http.MethodPost, "/api/novel/encoding", bytes.NewReader(body))
+	w := httptest.NewRecorder()
+	NovelEncodingHandler(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	data, _ := os.ReadFile(upload.FilePath)
+	if string(data) != "第一回　刘姥姥\n进荣国府" {
+		t.Errorf("Expected file reconverted as GBK, got %q", data)
+	}
+
+	for _, payload := range []map[string]string{
+		{"filePath": upload.FilePath, "encoding": "ebcdic"},
+		{"filePath": filepath.Join(t.TempDir(), "other.txt"), "encoding": "gbk"},
+	} {
+		body, _ := json.Marshal(payload)
+		req := httptest.NewRequest(http.MethodPost, "/api/novel/encoding", bytes.NewReader(body))
+		w := httptest.NewRecorder()
+		NovelEncodingHandler(w, req)
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%v: expected status 400, got %d", payload, w.Code)
+		}
+	}
+}
+
+func TestUploadHandlerDOCXChapters(t *testing.T) {
+	useTempPaths(t)
+
+	var docx bytes.Buffer
+	archive := zip.NewWriter(&docx)
+	entry, _ := archive.Create("word/document.xml")
+	entry.Write([]byte(`<w:document xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main"><w:body>` +
+		`<w:p><w:pPr><w:outlineLvl w:val="0"/></w:pPr><w:r><w:t>楔子</w:t></w:r></w:p>` +
+		`<w:p><w:r><w:t>甲甲甲。</w:t></w:r></w:p>` +
+		`<w:p><w:pPr><w:outlineLvl w:val="0"/></w:pPr><w:r><w:t>重逢</w:t></w:r></w:p>` +
+		`<w:p><w:r><w:t>乙乙乙。</w:t></w:r></w:p>` +
+		`</w:body></w:document>`))
+	archive.Close()
+
+	upload := uploadNovel(t, docx.Bytes(), "")
+	if upload.Format != "docx" || upload.Chapters != 2 || !strings.HasSuffix(upload.FilePath, ".txt") {
+		t.Fatalf("Expected docx with 2 chapters, got %+v", upload)
+	}
+	if err := config.SaveConfig(models.Config{NovelFile: upload.FilePath}); err != nil {
+		t.Fatal(err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/novel/chapters", nil)
+	w := httptest.NewRecorder()
+	NovelChaptersHandler(w, req)
+	var chapters []models.Chapter
+	json.Unmarshal(w.Body.Bytes(), &chapters)
+	// 标题不是“第X章”的形式，只能来自文件本身的标题样式。
+	if len(chapters) != 2 || chapters[0].Title != "楔子" || chapters[1].Title != "重逢" {
+		t.Errorf("Unexpected chapters %+v", chapters)
+	}
+}
@@ -0,0 +1,64 @@
+package handlers
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"taco/backend/models"
+	"taco/backend/services/llm"
+	"taco/backend/utils"
+)
+
+const (
+	extractCharacters = "characters"
+	extractScenes     = "scenes"
+)
+
+// extractionProgress 保存每个项目最近一次角色或场景提取的进度，键为 "<项目 ID>/<kind>"。
+// 进度只保存在内存中，服务重启后清空。
+var extractionProgress sync.Map
+
+func progressKey(projectID, kind string) string {
+	return projectID + "/" + kind
+}
+
+// startExtraction 记录一次新的提取，返回每段完成时更新进度的回调和结束时调用的 finish。
+func startExtraction(projectID, kind string) (llm.ProgressFunc, func(error)) {
+	key := progressKey(projectID, kind)
+	var mu sync.Mutex
+	current := models.ExtractionProgress{Kind: kind, Running: true, UpdatedAt: time.Now()}
+	extractionProgress.Store(key, current)
+
+	update := func(done, total, found int) {
+		mu.Lock()
+		defer mu.Unlock()
+		current.Done, current.Total, current.Found = done, total, found
+		current.UpdatedAt = time.Now()
+		extractionProgress.Store(key, current)
+	}
+	finish := func(err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		current.Running = false
+		if err != nil {
+			current.Error = err.Error()
+		}
+		current.UpdatedAt = time.Now()
+		extractionProgress.Store(key, current)
+	}
+	return update, finish
+}
+
+// writeExtractionProgress 返回项目最近一次提取的进度，没有记录时 running 为 false 且 total 为 0。
+func writeExtractionProgress(w http.ResponseWriter, r *http.Request, kind string) {
+	project, ok := resolveProject(w, r)
+	if !ok {
+		return
+	}
+	progress := models.ExtractionProgress{Kind: kind}
+	if value, ok := extractionProgress.Load(progressKey(project.ID, kind)); ok {
+		progress = value.(models.ExtractionProgress)
+	}
+	utils.WriteJSON(w, progress)
+}
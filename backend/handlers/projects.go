@@ -0,0 +1,151 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"taco/backend/config"
+	"taco/backend/utils"
+)
+
+const projectHeader = "X-Project-ID"
+
+// projectIDFromRequest 读取请求指定的项目，优先使用查询参数 project，其次是 X-Project-ID 请求头。
+func projectIDFromRequest(r *http.Request) string {
+	if id := strings.TrimSpace(r.URL.Query().Get("project")); id != "" {
+		return id
+	}
+	return strings.TrimSpace(r.Header.Get(projectHeader))
+}
+
+func resolveProject(w http.ResponseWriter, r *http.Request) (*config.Project, bool) {
+	project, err := config.OpenProject(projectIDFromRequest(r))
+	if err != nil {
+		if errors.Is(err, config.ErrProjectNotFound) {
+			http.Error(w, "项目不存在", http.StatusNotFound)
+			return nil, false
+		}
+		log.Printf("[ERROR] 打开项目失败: %v", err)
+		http.Error(w, fmt.Sprintf("打开项目失败: %v", err), http.StatusInternalServerError)
+		return nil, false
+	}
+	return project, true
+}
+
+func ProjectsHandler(w http.ResponseWriter, r *http.Request) {
+	log.Printf("[HTTP] %s %s - 来自 %s", r.Method, r.URL.Path, r.RemoteAddr)
+	switch r.Method {
+	case http.MethodGet:
+		projects, err := config.ListProjects()
+		if err != nil {
+			http.Error(w, fmt.Sprintf("读取项目列表失败: %v", err), http.StatusInternalServerError)
+			return
+		}
+		utils.WriteJSON(w, projects)
+	case http.MethodPost:
+		var payload struct {
+			Name string `json:"name"`
+		}
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(&payload); err != nil {
+			http.Error(w, "请求数据无效", http.StatusBadRequest)
+			return
+		}
+		if strings.TrimSpace(payload.Name) == "" {
+			http.Error(w, "项目名称不能为空", http.StatusBadRequest)
+			return
+		}
+		info, err := config.CreateProject(payload.Name)
+		if err != nil {
+			log.Printf("[ERROR] 创建项目失败: %v", err)
+			http.Error(w, fmt.Sprintf("创建项目失败: %v", err), http.StatusInternalServerError)
+			return
+		}
+		log.Printf("[SUCCESS] 成功创建项目: %s (%s)", info.Name, info.ID)
+		utils.WriteJSONStatus(w, http.StatusCreated, info)
+	default:
+		http.Error(w, "不支持的请求方法", http.StatusMethodNotAllowed)
+	}
+}
+
+// ProjectHandler 处理 /api/projects/{id} 的查询、重命名与删除。
+func ProjectHandler(w http.ResponseWriter, r *http.Request) {
+	log.Printf("[HTTP] %s %s - 来自 %s", r.Method, r.URL.Path, r.RemoteAddr)
+	id := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/projects/"), "/")
+	if id == "" || strings.Contains(id, "/") {
+		http.NotFound(w, r)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		project, err := config.OpenProject(id)
+		if err != nil {
+			writeProjectError(w, "读取项目", err)
+			return
+		}
+		info, err := project.Info()
+		if err != nil {
+			writeProjectError(w, "读取项目", err)
+			return
+		}
+		utils.WriteJSON(w, info)
+	case http.MethodPut, http.MethodPatch:
+		var payload struct {
+			Name string `json:"name"`
+		}
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(&payload); err != nil {
+			http.Error(w, "请求数据无效", http.StatusBadRequest)
+			return
+		}
+		info, err := config.RenameProject(id, payload.Name)
+		if err != nil {
+			writeProjectError(w, "重命名项目", err)
+			return
+		}
+		utils.WriteJSON(w, info)
+	case http.MethodDelete:
+		if err := config.DeleteProject(id); err != nil {
+			writeProjectError(w, "删除项目", err)
+			return
+		}
+		log.Printf("[SUCCESS] 已删除项目: %s", id)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.Error(w, "不支持的请求方法", http.StatusMethodNotAllowed)
+	}
+}
+
+// writeProjectError 只把请求本身无效的错误映射为 400，读写项目文件失败等返回 500。
+func writeProjectError(w http.ResponseWriter, action string, err error) {
+	switch {
+	case errors.Is(err, config.ErrProjectNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, config.ErrProjectName), errors.Is(err, config.ErrDefaultProject):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	default:
+		log.Printf("[ERROR] %s失败: %v", action, err)
+		http.Error(w, fmt.Sprintf("%s失败: %v", action, err), http.StatusInternalServerError)
+	}
+}
+
+// ProjectAssetsHandler 提供 /projects/{id}/generated/... 下的生成文件，
+// 只开放 generated 目录，项目配置（含 API Key）不会被暴露。
+func ProjectAssetsHandler(w http.ResponseWriter, r *http.Request) {
+	rest := strings.TrimPrefix(r.URL.Path, utils.ProjectsURLPrefix)
+	id, assetPath, found := strings.Cut(rest, "/")
+	if !found || !strings.HasPrefix(assetPath, "generated/") {
+		http.NotFound(w, r)
+		return
+	}
+	project, err := config.OpenProject(id)
+	if err != nil || config.IsDefaultProject(id) {
+		http.NotFound(w, r)
+		return
+	}
+	prefix := utils.ProjectsURLPrefix + id + "/generated"
+	http.StripPrefix(prefix, http.FileServer(http.Dir(project.GeneratedDir))).ServeHTTP(w, r)
+}
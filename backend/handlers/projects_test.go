@@ -0,0 +1,195 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"taco/backend/config"
+	"taco/backend/models"
+	"taco/backend/utils"
+)
+
+// useTempPaths 将默认项目和项目目录的全部路径指向临时目录，测试结束后恢复。
+func useTempPaths(t *testing.T) string {
+	t.Helper()
+	tmpDir := t.TempDir()
+	original := []string{utils.ProjectRoot, utils.ConfigPath, utils.CharactersPath, utils.ScenesPath, utils.UploadDir, utils.GeneratedDir, utils.GeneratedImagesDir, utils.GeneratedAudioDir, utils.ProjectsDir}
+	utils.SetDataDir(tmpDir)
+	t.Cleanup(func() {
+		utils.ProjectRoot, utils.ConfigPath, utils.CharactersPath, utils.ScenesPath = original[0], original[1], original[2], original[3]
+		utils.UploadDir, utils.GeneratedDir = original[4], original[5]
+		utils.GeneratedImagesDir, utils.GeneratedAudioDir = original[6], original[7]
+		utils.ProjectsDir = original[8]
+	})
+	return tmpDir
+}
+
+func createTestProject(t *testing.T, name string) models.ProjectInfo {
+	t.Helper()
+	body, _ := json.Marshal(map[string]string{"name": name})
+	req := httptest.NewRequest(http.MethodPost, "/api/projects", bytes.NewReader(body))
+	w := httptest.NewRecorder()
+
+	ProjectsHandler(w, req)
+
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Expected status 201, got %d: %s", w.Code, w.Body.String())
+	}
+	var info models.ProjectInfo
+	if err := json.NewDecoder(w.Body).Decode(&info); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	return info
+}
+
+func TestProjectsHandlerCreateAndList(t *testing.T) {
+	useTempPaths(t)
+
+	info := createTestProject(t, "测试项目")
+
+	req := httptest.NewRequest(http.MethodGet, "/api/projects", nil)
+	w := httptest.NewRecorder()
+	ProjectsHandler(w, req)
+
+	var projects []models.ProjectInfo
+	if err := json.NewDecoder(w.Body).Decode(&projects); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if len(projects) != 2 || projects[1].ID != info.ID {
+		t.Fatalf("Unexpected project list: %+v", projects)
+	}
+}
+
+func TestProjectsHandlerEmptyName(t *testing.T) {
+	useTempPaths(t)
+
+	req := httptest.NewRequest(http.MethodPost, "/api/projects", bytes.NewReader([]byte(`{"name":"  "}`)))
+	w := httptest.NewRecorder()
+	ProjectsHandler(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400, got %d", w.Code)
+	}
+}
+
+func TestProjectHandlerRenameAndDelete(t *testing.T) {
+	useTempPaths(t)
+	info := createTestProject(t, "旧名称")
+
+	req := httptest.NewRequest(http.MethodPatch, "/api/projects/"+info.ID, bytes.NewReader([]byte(`{"name":"新名称"}`)))
+	w := httptest.NewRecorder()
+	ProjectHandler(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	req = httptest.NewRequest(http.MethodDelete, "/api/projects/"+info.ID, nil)
+	w = httptest.NewRecorder()
+	ProjectHandler(w, req)
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("Expected status 204, got %d", w.Code)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/api/projects/"+info.ID, nil)
+	w = httptest.NewRecorder()
+	ProjectHandler(w, req)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404, got %d", w.Code)
+	}
+}
+
+func TestProjectHandlerErrorStatus(t *testing.T) {
+	useTempPaths(t)
+
+	req := httptest.NewRequest(http.MethodDelete, "/api/projects/"+config.DefaultProjectID, nil)
+	w := httptest.NewRecorder()
+	ProjectHandler(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 when deleting the default project, got %d", w.Code)
+	}
+
+	info := createTestProject(t, "损坏的项目")
+	os.WriteFile(filepath.Join(utils.ProjectsDir, info.ID, "project.json"), []byte("{"), 0o644)
+	req = httptest.NewRequest(http.MethodGet, "/api/projects/"+info.ID, nil)
+	w = httptest.NewRecorder()
+	ProjectHandler(w, req)
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("Expected status 500 for an unreadable project, got %d", w.Code)
+	}
+}
+
+func TestScenesHandlerScopedToProject(t *testing.T) {
+	useTempPaths(t)
+	info := createTestProject(t, "场景隔离")
+
+	body, _ := json.Marshal([]models.Scene{{Title: "项目场景", Description: "描述"}})
+	req := httptest.NewRequest(http.MethodPost, "/api/scenes?project="+info.ID, bytes.NewReader(body))
+	w := httptest.NewRecorder()
+	ScenesHandler(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	defaultScenes, err := config.LoadScenesData()
+	if err != nil {
+		t.Fatalf("LoadScenesData failed: %v", err)
+	}
+	if len(defaultScenes) != 0 {
+		t.Errorf("Default project should be untouched, got %d scenes", len(defaultScenes))
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/api/scenes", nil)
+	req.Header.Set(projectHeader, info.ID)
+	w = httptest.NewRecorder()
+	ScenesHandler(w, req)
+	var scenes []models.Scene
+	if err := json.NewDecoder(w.Body).Decode(&scenes); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if len(scenes) != 1 || scenes[0].Title != "项目场景" {
+		t.Errorf("Unexpected project scenes: %+v", scenes)
+	}
+}
+
+func TestScenesHandlerUnknownProject(t *testing.T) {
+	useTempPaths(t)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/scenes?project=nope", nil)
+	w := httptest.NewRecorder()
+	ScenesHandler(w, req)
+
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404, got %d", w.Code)
+	}
+}
+
+func TestProjectAssetsHandler(t *testing.T) {
+	useTempPaths(t)
+	info := createTestProject(t, "素材")
+	project, err := config.OpenProject(info.ID)
+	if err != nil {
+		t.Fatalf("OpenProject failed: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(project.Assets.ImagesDir, "a.png"), []byte("png"), 0o644); err != nil {
+		t.Fatalf("Failed to write asset: %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, project.Assets.ImageURL("a.png"), nil)
+	w := httptest.NewRecorder()
+	ProjectAssetsHandler(w, req)
+	if w.Code != http.StatusOK || w.Body.String() != "png" {
+		t.Errorf("Expected asset to be served, got %d: %s", w.Code, w.Body.String())
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/projects/"+info.ID+"/config/config.json", nil)
+	w = httptest.NewRecorder()
+	ProjectAssetsHandler(w, req)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected project config to be hidden, got %d", w.Code)
+	}
+}
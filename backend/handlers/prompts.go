@@ -0,0 +1,246 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"taco/backend/config"
+	"taco/backend/models"
+	"taco/backend/services/image"
+	"taco/backend/services/llm"
+	"taco/backend/services/prompts"
+	"taco/backend/utils"
+)
+
+func promptTemplate(definition prompts.Definition, cfg models.Config) models.PromptTemplate {
+	text, _ := prompts.Text(cfg.Prompts, definition.Name)
+	return models.PromptTemplate{
+		Name:        definition.Name,
+		Title:       definition.Title,
+		Description: definition.Description,
+		Default:     definition.Default,
+		Template:    text,
+		Overridden:  text != definition.Default,
+	}
+}
+
+// PromptsHandler 列出所有提示词模板及当前项目实际使用的内容。
+func PromptsHandler(w http.ResponseWriter, r *http.Request) {
+	log.Printf("[HTTP] %s %s - 来自 %s", r.Method, r.URL.Path, r.RemoteAddr)
+	if r.Method != http.MethodGet {
+		http.Error(w, "不支持的请求方法", http.StatusMethodNotAllowed)
+		return
+	}
+	project, ok := resolveProject(w, r)
+	if !ok {
+		return
+	}
+	cfg, err := project.LoadConfig()
+	if err != nil {
+		http.Error(w, fmt.Sprintf("读取配置失败: %v", err), http.StatusInternalServerError)
+		return
+	}
+	templates := []models.PromptTemplate{}
+	for _, definition := range prompts.Definitions() {
+		templates = append(templates, promptTemplate(definition, cfg))
+	}
+	utils.WriteJSON(w, templates)
+}
+
+// PromptItemHandler 处理 /api/prompts/{name}：GET 查看，PUT 覆盖（{"template": "..."}），
+// DELETE 恢复默认；POST /api/prompts/{name}/preview 用真实的角色或场景试渲染。
+func PromptItemHandler(w http.ResponseWriter, r *http.Request) {
+	log.Printf("[HTTP] %s %s - 来自 %s", r.Method, r.URL.Path, r.RemoteAddr)
+	rest := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/prompts/"), "/")
+	name, action, _ := strings.Cut(rest, "/")
+	definition, found := prompts.Lookup(name)
+	if !found || (action != "" && action != "preview") {
+		http.NotFound(w, r)
+		return
+	}
+	project, ok := resolveProject(w, r)
+	if !ok {
+		return
+	}
+
+	if action == "preview" {
+		if r.Method != http.MethodPost {
+			http.Error(w, "不支持的请求方法", http.StatusMethodNotAllowed)
+			return
+		}
+		previewPrompt(w, r, project, name)
+		return
+	}
+
+	cfg, err := project.LoadConfig()
+	if err != nil {
+		http.Error(w, fmt.Sprintf("读取配置失败: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		utils.WriteJSON(w, promptTemplate(definition, cfg))
+	case http.MethodPut:
+		var req struct {
+			Template string `json:"template"`
+		}
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(&req); err != nil {
+			http.Error(w, "请求数据无效", http.StatusBadRequest)
+			return
+		}
+		if err := prompts.Validate(name, req.Template); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		overrides := map[string]string{}
+		for key, value := range cfg.Prompts {
+			overrides[key] = value
+		}
+		overrides[name] = req.Template
+		cfg.Prompts = overrides
+		if err := project.SaveConfig(cfg); err != nil {
+			log.Printf("[ERROR] 保存提示词模板失败: %v", err)
+			http.Error(w, fmt.Sprintf("保存提示词模板失败: %v", err), http.StatusInternalServerError)
+			return
+		}
+		log.Printf("[SUCCESS] 已覆盖提示词模板 %s", name)
+		utils.WriteJSON(w, promptTemplate(definition, cfg))
+	case http.MethodDelete:
+		if _, overridden := cfg.Prompts[name]; overridden {
+			overrides := map[string]string{}
+			for key, value := range cfg.Prompts {
+				if key != name {
+					overrides[key] = value
+				}
+			}
+			cfg.Prompts = overrides
+			if err := project.SaveConfig(cfg); err != nil {
+				log.Printf("[ERROR] 保存提示词模板失败: %v", err)
+				http.Error(w, fmt.Sprintf("保存提示词模板失败: %v", err), http.StatusInternalServerError)
+				return
+			}
+			log.Printf("[SUCCESS] 已恢复默认提示词模板 %s", name)
+		}
+		utils.WriteJSON(w, promptTemplate(definition, cfg))
+	default:
+		http.Error(w, "不支持的请求方法", http.StatusMethodNotAllowed)
+	}
+}
+
+// previewPrompt 渲染模板但不保存。请求体可选：template 为尚未保存的模板内容，
+// characterId/sceneId 指定用于渲染的角色或场景，未指定时使用第一个；提取模板使用小说的第一段（场景拆分为第一个分到场景的段）。
+func previewPrompt(w http.ResponseWriter, r *http.Request, project *config.Project, name string) {
+	var req struct {
+		Template    string `json:"template"`
+		CharacterID string `json:"characterId"`
+		SceneID     string `json:"sceneId"`
+	}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(&req); err != nil {
+			http.Error(w, "请求数据无效", http.StatusBadRequest)
+			return
+		}
+	}
+
+	operation := map[string]string{
+		prompts.CharacterSystem:          config.OpCharacterExtraction,
+		prompts.CharacterExtraction:      config.OpCharacterExtraction,
+		prompts.CharacterMerge:           config.OpCharacterExtraction,
+		prompts.SceneSystem:              config.OpSceneExtraction,
+		prompts.SceneExtraction:          config.OpSceneExtraction,
+		prompts.CharacterImage:           config.OpCharacterImage,
+		prompts.SceneImage:               config.OpSceneImage,
+		prompts.SceneImageWithCharacters: config.OpSceneEdit,
+	}[name]
+	cfg, err := project.LoadConfigFor(operation)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("读取配置失败: %v", err), http.StatusInternalServerError)
+		return
+	}
+	if strings.TrimSpace(req.Template) != "" {
+		overrides := map[string]string{name: req.Template}
+		for key, value := range cfg.Prompts {
+			if key != name {
+				overrides[key] = value
+			}
+		}
+		cfg.Prompts = overrides
+	}
+
+	characters, err := project.LoadCharactersData()
+	if err != nil {
+		http.Error(w, fmt.Sprintf("读取角色失败: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	var prompt string
+	switch name {
+	case prompts.CharacterSystem, prompts.CharacterExtraction:
+		text, ok := readNovel(w, cfg)
+		if !ok {
+			return
+		}
+		prompt, err = llm.PreviewCharacterPrompt(cfg, name, text)
+	case prompts.CharacterMerge:
+		_, prompt, err = llm.CharacterMergePrompt(cfg, characters)
+	case prompts.SceneSystem, prompts.SceneExtraction:
+		text, ok := readNovel(w, cfg)
+		if !ok {
+			return
+		}
+		prompt, err = llm.PreviewScenePrompt(cfg, name, text, characters)
+	case prompts.CharacterImage:
+		character, ok := pickPreviewItem(w, characters, req.CharacterID, func(c models.CharacterProfile) string { return c.ID }, "角色")
+		if !ok {
+			return
+		}
+		prompt, err = image.CharacterImagePrompt(cfg, character)
+	default:
+		scenes, loadErr := project.LoadScenesData()
+		if loadErr != nil {
+			http.Error(w, fmt.Sprintf("读取场景失败: %v", loadErr), http.StatusInternalServerError)
+			return
+		}
+		scene, ok := pickPreviewItem(w, scenes, req.SceneID, func(s models.Scene) string { return s.ID }, "场景")
+		if !ok {
+			return
+		}
+		if name == prompts.SceneImage {
+			prompt, err = image.SceneImagePrompt(cfg, scene, characters)
+		} else {
+			references := []string{}
+			for _, character := range image.ReferenceCharacters(scene, characters) {
+				references = append(references, character.Name)
+			}
+			prompt, err = image.SceneImageWithCharactersPrompt(cfg, scene, characters, references)
+		}
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	utils.WriteJSON(w, map[string]string{"name": name, "prompt": prompt})
+}
+
+// pickPreviewItem 按 ID 选取预览用的角色或场景，id 为空时选第一个。
+func pickPreviewItem[T any](w http.ResponseWriter, items []T, id string, idOf func(T) string, label string) (T, bool) {
+	var zero T
+	if len(items) == 0 {
+		http.Error(w, fmt.Sprintf("还没有%s，无法预览", label), http.StatusBadRequest)
+		return zero, false
+	}
+	if id == "" {
+		return items[0], true
+	}
+	for _, item := range items {
+		if idOf(item) == id {
+			return item, true
+		}
+	}
+	http.Error(w, fmt.Sprintf("%s不存在: %s", label, id), http.StatusNotFound)
+	return zero, false
+}
@@ -0,0 +1,110 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"taco/backend/config"
+	"taco/backend/models"
+	"taco/backend/services/prompts"
+)
+
+func promptRequest(t *testing.T, method, path, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	w := httptest.NewRecorder()
+	PromptItemHandler(w, req)
+	return w
+}
+
+func TestPromptOverrideAndReset(t *testing.T) {
+	useTempPaths(t)
+	config.SaveConfig(models.Config{AnimeStyle: "水彩风格"})
+
+	w := promptRequest(t, http.MethodPut, "/api/prompts/characterImage", `{"template": "{{.Character.Nickname}}"}`)
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("Expected an invalid template to be rejected, got %d", w.Code)
+	}
+
+	w = promptRequest(t, http.MethodPut, "/api/prompts/characterImage", `{"template": "{{.Style}}：{{.Character.Name}}"}`)
+	var template models.PromptTemplate
+	json.Unmarshal(w.Body.Bytes(), &template)
+	if w.Code != http.StatusOK || !template.Overridden {
+		t.Fatalf("Expected the override to be saved, got %d: %s", w.Code, w.Body.String())
+	}
+	cfg, _ := config.LoadConfig()
+	if cfg.Prompts[prompts.CharacterImage] != "{{.Style}}：{{.Character.Name}}" || cfg.AnimeStyle != "水彩风格" {
+		t.Errorf("Expected the override alongside the existing config, got %+v", cfg)
+	}
+
+	config.SaveCharactersData([]models.CharacterProfile{{ID: "chr_a", Name: "甲"}, {ID: "chr_b", Name: "乙"}})
+	w = promptRequest(t, http.MethodPost, "/api/prompts/characterImage/preview", `{"characterId": "chr_b"}`)
+	var preview map[string]string
+	json.Unmarshal(w.Body.Bytes(), &preview)
+	if w.Code != http.StatusOK || preview["prompt"] != "水彩风格：乙" {
+		t.Errorf("Expected the saved template to render against the character, got %d: %s", w.Code, w.Body.String())
+	}
+	w = promptRequest(t, http.MethodPost, "/api/prompts/characterImage/preview", `{"template": "画{{.Character.Name}}"}`)
+	json.Unmarshal(w.Body.Bytes(), &preview)
+	if preview["prompt"] != "画甲" {
+		t.Errorf("Expected an unsaved template to be previewed, got %s", w.Body.String())
+	}
+
+	w = promptRequest(t, http.MethodDelete, "/api/prompts/characterImage", "")
+	json.Unmarshal(w.Body.Bytes(), &template)
+	cfg, _ = config.LoadConfig()
+	if w.Code != http.StatusOK || template.Overridden || len(cfg.Prompts) != 0 {
+		t.Errorf("Expected the default to be restored, got %s and %+v", w.Body.String(), cfg.Prompts)
+	}
+
+	if w := promptRequest(t, http.MethodGet, "/api/prompts/unknown", ""); w.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 for an unknown template, got %d", w.Code)
+	}
+}
+
+func TestConfigPostKeepsPromptOverrides(t *testing.T) {
+	useTempPaths(t)
+	config.SaveConfig(models.Config{Prompts: map[string]string{prompts.CharacterImage: "画{{.Character.Name}}"}})
+
+	posted := models.Config{
+		LLM:   models.LLMConfig{Model: "m", BaseURL: "https://test.com", APIKey: "k"},
+		Image: models.ImageConfig{Model: "m", BaseURL: "https://test.com", APIKey: "k"},
+		Voice: models.VoiceConfig{Model: "m", BaseURL: "https://test.com", APIKey: "k", Voice: "v", Language: "zh"},
+	}
+	withoutPrompts, _ := json.Marshal(posted)
+	posted.Prompts = map[string]string{prompts.CharacterImage: "{{.Character.Nickname}}"}
+	withBrokenPrompt, _ := json.Marshal(posted)
+
+	for _, body := range []string{string(withoutPrompts), string(withBrokenPrompt)} {
+		w := httptest.NewRecorder()
+		ConfigHandler(w, httptest.NewRequest(http.MethodPost, "/api/config", strings.NewReader(body)))
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected status 200 for %s, got %d: %s", body, w.Code, w.Body.String())
+		}
+		cfg, _ := config.LoadConfig()
+		if len(cfg.Prompts) != 1 || cfg.Prompts[prompts.CharacterImage] != "画{{.Character.Name}}" {
+			t.Errorf("Expected the stored override to survive %s, got %+v", body, cfg.Prompts)
+		}
+	}
+}
+
+func TestPromptPreviewScene(t *testing.T) {
+	useTempPaths(t)
+	config.SaveConfig(models.Config{})
+	w := promptRequest(t, http.MethodPost, "/api/prompts/sceneImageWithCharacters/preview", "")
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 without scenes, got %d", w.Code)
+	}
+
+	config.SaveCharactersData([]models.CharacterProfile{{ID: "chr_a", Name: "甲", ImagePath: "a.png"}})
+	config.SaveScenesData([]models.Scene{{ID: "scn_s", Description: "雨夜", Characters: []string{"甲"}}})
+	w = promptRequest(t, http.MethodPost, "/api/prompts/sceneImageWithCharacters/preview", "")
+	var preview map[string]string
+	json.Unmarshal(w.Body.Bytes(), &preview)
+	if !strings.HasPrefix(preview["prompt"], "图1中的人物是甲。") {
+		t.Errorf("Expected the reference characters in the preview, got %d: %s", w.Code, w.Body.String())
+	}
+}
@@ -0,0 +1,254 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"taco/backend/config"
+	"taco/backend/services/llm"
+)
+
+const (
+	// sseKeepAlive 是没有事件时发送注释行的间隔，避免代理因连接空闲而断开。
+	sseKeepAlive = 15 * time.Second
+	// tokenEventInterval 限制输出 token 进度的发送频率。
+	tokenEventInterval = 250 * time.Millisecond
+)
+
+// sseStream 以 Server-Sent Events 格式向浏览器写事件。
+type sseStream struct {
+	mu      sync.Mutex
+	w       http.ResponseWriter
+	flusher http.Flusher
+	closed  bool
+	stop    chan struct{}
+}
+
+// startSSE 写出事件流的响应头，之后只能通过 send 写响应。
+func startSSE(w http.ResponseWriter) (*sseStream, bool) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "当前连接不支持流式响应", http.StatusInternalServerError)
+		return nil, false
+	}
+	header := w.Header()
+	header.Set("Content-Type", "text/event-stream; charset=utf-8")
+	header.Set("Cache-Control", "no-cache")
+	header.Set("Connection", "keep-alive")
+	header.Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	stream := &sseStream{w: w, flusher: flusher, stop: make(chan struct{})}
+	go stream.keepAlive()
+	return stream, true
+}
+
+func (s *sseStream) keepAlive() {
+	ticker := time.NewTicker(sseKeepAlive)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-ticker.C:
+			s.write(": keep-alive\n\n")
+		}
+	}
+}
+
+func (s *sseStream) write(text string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return
+	}
+	fmt.Fprint(s.w, text)
+	s.flusher.Flush()
+}
+
+// send 发送一个事件，data 序列化为 JSON；json.RawMessage 原样发送。
+func (s *sseStream) send(event string, data any) {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		log.Printf("[ERROR] 序列化事件 %s 失败: %v", event, err)
+		return
+	}
+	s.write(fmt.Sprintf("event: %s\ndata: %s\n\n", event, payload))
+}
+
+// close 停止发送，处理函数返回前必须调用。
+func (s *sseStream) close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.closed {
+		s.closed = true
+		close(s.stop)
+	}
+}
+
+// extractionEvent 是 progress 事件的内容：Done/Total 为已完成和总的段数，Found 为累计结果数，
+// Tokens 为模型已输出的 token 数（估算）。
+type extractionEvent struct {
+	Done   int `json:"done"`
+	Total  int `json:"total"`
+	Found  int `json:"found"`
+	Tokens int `json:"tokens"`
+}
+
+// extractionStream 把一次提取的进度和模型输出转换为事件：
+//   - progress：每段开始和完成时，以及输出 token 时（最多每 250ms 一次）
+//   - itemEvent（character 或 scene）：每个对象在模型输出中完整时，内容尚未去重和校验
+//   - done：保存后的全部结果；error：提取或保存失败的原因
+type extractionStream struct {
+	stream    *sseStream
+	itemEvent string
+	record    llm.ProgressFunc
+
+	mu       sync.Mutex
+	current  extractionEvent
+	lastSent time.Time
+}
+
+func newExtractionStream(stream *sseStream, itemEvent string, record llm.ProgressFunc) *extractionStream {
+	return &extractionStream{stream: stream, itemEvent: itemEvent, record: record}
+}
+
+func (e *extractionStream) progress(done, total, found int) {
+	e.record(done, total, found)
+	e.mu.Lock()
+	e.current.Done, e.current.Total, e.current.Found = done, total, found
+	event := e.current
+	e.lastSent = time.Now()
+	e.mu.Unlock()
+	e.stream.send("progress", event)
+}
+
+func (e *extractionStream) context(ctx context.Context) context.Context {
+	return llm.WithStream(ctx, llm.StreamHandler{
+		Delta: func(text string) {
+			e.mu.Lock()
+			e.current.Tokens += llm.EstimateTokens(text)
+			if time.Since(e.lastSent) < tokenEventInterval {
+				e.mu.Unlock()
+				return
+			}
+			event := e.current
+			e.lastSent = time.Now()
+			e.mu.Unlock()
+			e.stream.send("progress", event)
+		},
+		Item: func(item json.RawMessage) {
+			e.stream.send(e.itemEvent, item)
+		},
+	})
+}
+
+func (e *extractionStream) fail(message string, err error) {
+	log.Printf("[ERROR] %s: %v", message, err)
+	e.stream.send("error", map[string]string{"error": fmt.Sprintf("%s: %v", message, err)})
+}
+
+// ExtractCharactersStreamHandler 与 ExtractCharactersHandler 相同，但以事件流返回进度和逐个解析出的角色。
+func ExtractCharactersStreamHandler(w http.ResponseWriter, r *http.Request) {
+	log.Printf("[HTTP] %s %s - 来自 %s", r.Method, r.URL.Path, r.RemoteAddr)
+	if r.Method != http.MethodPost {
+		http.Error(w, "不支持的请求方法", http.StatusMethodNotAllowed)
+		return
+	}
+	project, ok := resolveProject(w, r)
+	if !ok {
+		return
+	}
+	cfg, err := project.LoadConfigFor(config.OpCharacterExtraction)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("读取配置失败: %v", err), http.StatusInternalServerError)
+		return
+	}
+	scope, ok := readNovelScope(w, r, cfg)
+	if !ok {
+		return
+	}
+	novelText := scope.selectedText()
+	log.Printf("[INFO] 开始流式提取角色，共 %d 字节", len(novelText))
+
+	stream, ok := startSSE(w)
+	if !ok {
+		return
+	}
+	defer stream.close()
+
+	record, finish := startExtraction(project.ID, extractCharacters)
+	events := newExtractionStream(stream, "character", record)
+	characters, err := llm.CallLLMForCharacters(events.context(r.Context()), cfg, novelText, events.progress)
+	finish(err)
+	if err != nil {
+		events.fail("分析角色失败", err)
+		return
+	}
+
+	log.Printf("[SUCCESS] 成功提取 %d 个角色", len(characters))
+	saved, err := saveExtractedCharacters(project, scope, characters)
+	if err != nil {
+		events.fail("保存角色信息失败", err)
+		return
+	}
+	stream.send("done", saved)
+}
+
+// ExtractScenesStreamHandler 与 ExtractScenesHandler 相同，但以事件流返回进度和逐个解析出的场景。
+func ExtractScenesStreamHandler(w http.ResponseWriter, r *http.Request) {
+	log.Printf("[HTTP] %s %s - 来自 %s", r.Method, r.URL.Path, r.RemoteAddr)
+	if r.Method != http.MethodPost {
+		http.Error(w, "不支持的请求方法", http.StatusMethodNotAllowed)
+		return
+	}
+	project, ok := resolveProject(w, r)
+	if !ok {
+		return
+	}
+	cfg, err := project.LoadConfigFor(config.OpSceneExtraction)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("读取配置失败: %v", err), http.StatusInternalServerError)
+		return
+	}
+	scope, ok := readNovelScope(w, r, cfg)
+	if !ok {
+		return
+	}
+	characters, err := project.LoadCharactersData()
+	if err != nil {
+		log.Printf("[ERROR] 读取角色失败: %v", err)
+		http.Error(w, fmt.Sprintf("读取角色失败: %v", err), http.StatusInternalServerError)
+		return
+	}
+	log.Printf("[INFO] 开始流式提取场景，角色数: %d", len(characters))
+
+	stream, ok := startSSE(w)
+	if !ok {
+		return
+	}
+	defer stream.close()
+
+	record, finish := startExtraction(project.ID, extractScenes)
+	events := newExtractionStream(stream, "scene", record)
+	scenes, err := llm.CallLLMForScenes(events.context(r.Context()), cfg, scope.text, scope.chapters, characters, events.progress)
+	finish(err)
+	if err != nil {
+		events.fail("分析场景失败", err)
+		return
+	}
+
+	log.Printf("[SUCCESS] 成功提取 %d 个场景", len(scenes))
+	saved, err := saveExtractedScenes(project, scope, scenes)
+	if err != nil {
+		events.fail("保存场景失败", err)
+		return
+	}
+	stream.send("done", saved)
+}
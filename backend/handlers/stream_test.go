@@ -0,0 +1,119 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"taco/backend/config"
+	"taco/backend/models"
+)
+
+// sseEvents 解析响应中的事件，返回按顺序排列的事件名和 data。
+func sseEvents(t *testing.T, body string) ([]string, []string) {
+	t.Helper()
+	var names, data []string
+	for _, block := range strings.Split(body, "\n\n") {
+		var name, payload string
+		for _, line := range strings.Split(block, "\n") {
+			if value, ok := strings.CutPrefix(line, "event: "); ok {
+				name = value
+			}
+			if value, ok := strings.CutPrefix(line, "data: "); ok {
+				payload = value
+			}
+		}
+		if name != "" {
+			names = append(names, name)
+			data = append(data, payload)
+		}
+	}
+	return names, data
+}
+
+func TestExtractScenesStreamHandler(t *testing.T) {
+	tmpDir := useTempPaths(t)
+	t.Cleanup(func() { extractionProgress.Delete(progressKey(config.DefaultProjectID, extractScenes)) })
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]any
+		json.NewDecoder(r.Body).Decode(&body)
+		if body["stream"] != true {
+			t.Errorf("Expected a streaming LLM request, got %v", body)
+		}
+		for _, delta := range []string{`{"scenes": [{"title": "初`, `遇"}, {"title": "重逢", `, `"characters": ["甲"]}]}`} {
+			chunk, _ := json.Marshal(map[string]any{"choices": []map[string]any{{"delta": map[string]string{"content": delta}}}})
+			fmt.Fprintf(w, "data: %s\n\n", chunk)
+		}
+		fmt.Fprint(w, "data: [DONE]\n\n")
+	}))
+	defer server.Close()
+
+	novelPath := filepath.Join(tmpDir, "novel.txt")
+	os.WriteFile(novelPath, []byte(chapterNovel), 0o644)
+	config.SaveConfig(models.Config{
+		NovelFile: novelPath,
+		LLM:       models.LLMConfig{Model: "m", BaseURL: server.URL, APIKey: "k"},
+	})
+
+	req := httptest.NewRequest(http.MethodPost, "/api/scenes/extract/stream", nil)
+	w := httptest.NewRecorder()
+	ExtractScenesStreamHandler(w, req)
+
+	if w.Code != http.StatusOK || !strings.HasPrefix(w.Header().Get("Content-Type"), "text/event-stream") {
+		t.Fatalf("Expected an event stream, got %d %q", w.Code, w.Header().Get("Content-Type"))
+	}
+	names, data := sseEvents(t, w.Body.String())
+	if len(names) < 5 || names[0] != "progress" || names[len(names)-1] != "done" {
+		t.Fatalf("Unexpected events %v", names)
+	}
+	var scenes []string
+	for i, name := range names {
+		if name == "scene" {
+			var scene models.Scene
+			json.Unmarshal([]byte(data[i]), &scene)
+			scenes = append(scenes, scene.Title)
+		}
+	}
+	if strings.Join(scenes, ",") != "初遇,重逢" {
+		t.Errorf("Expected each scene as soon as it was complete, got %v", scenes)
+	}
+
+	var last struct {
+		Done, Total, Tokens int
+	}
+	json.Unmarshal([]byte(data[len(names)-2]), &last)
+	if last.Done != last.Total || last.Tokens == 0 {
+		t.Errorf("Expected final progress with token count, got %s", data[len(names)-2])
+	}
+	var saved []models.Scene
+	json.Unmarshal([]byte(data[len(names)-1]), &saved)
+	stored, _ := config.LoadScenesData()
+	if len(saved) != 2 || len(stored) != 2 || stored[1].Title != "重逢" || stored[1].ID == "" {
+		t.Errorf("Expected the scenes to be saved, got %+v and %+v", saved, stored)
+	}
+}
+
+func TestExtractCharactersStreamHandlerReportsError(t *testing.T) {
+	useTempPaths(t)
+	t.Cleanup(func() { extractionProgress.Delete(progressKey(config.DefaultProjectID, extractCharacters)) })
+	novelPath := filepath.Join(t.TempDir(), "novel.txt")
+	os.WriteFile(novelPath, []byte(chapterNovel), 0o644)
+	config.SaveConfig(models.Config{
+		NovelFile: novelPath,
+		LLM:       models.LLMConfig{Model: "m", BaseURL: "http://127.0.0.1:1", APIKey: ""},
+	})
+
+	req := httptest.NewRequest(http.MethodPost, "/api/characters/extract/stream", nil)
+	w := httptest.NewRecorder()
+	ExtractCharactersStreamHandler(w, req)
+
+	names, data := sseEvents(t, w.Body.String())
+	if len(names) == 0 || names[len(names)-1] != "error" || !strings.Contains(data[len(data)-1], "API Key") {
+		t.Errorf("Expected an error event, got %v %v", names, data)
+	}
+}
@@ -0,0 +1,121 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"strings"
+
+	"taco/backend/config"
+	"taco/backend/utils"
+)
+
+// variantRoute 对应以下路径，asset 段为 images 或 audio（角色只有 images）：
+//
+//	GET    {prefix}{id}/{asset}                    版本列表及当前版本
+//	POST   {prefix}{id}/{asset}/{variant}/activate 设为当前版本
+//	DELETE {prefix}{id}/{asset}/{variant}          删除版本记录，文件由 GC 回收
+type variantRoute struct {
+	ItemID    string
+	Asset     string
+	VariantID string
+	Action    string
+}
+
+func parseVariantRoute(r *http.Request, prefix string) (variantRoute, bool) {
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, prefix), "/"), "/")
+	if len(parts) < 2 || len(parts) > 4 || parts[0] == "" {
+		return variantRoute{}, false
+	}
+	route := variantRoute{ItemID: parts[0]}
+	switch parts[1] {
+	case "images":
+		route.Asset = config.AssetImage
+	case "audio":
+		route.Asset = config.AssetAudio
+	default:
+		return variantRoute{}, false
+	}
+	if len(parts) > 2 {
+		route.VariantID = parts[2]
+	}
+	if len(parts) > 3 {
+		route.Action = parts[3]
+	}
+	return route, true
+}
+
+func characterVariantsHandler(w http.ResponseWriter, r *http.Request, route variantRoute) {
+	project, ok := resolveProject(w, r)
+	if !ok {
+		return
+	}
+
+	switch {
+	case route.VariantID == "" && r.Method == http.MethodGet:
+		list, err := project.CharacterVariants(route.ItemID, route.Asset)
+		if err != nil {
+			writeItemError(w, "读取图片版本", err)
+			return
+		}
+		utils.WriteJSON(w, list)
+	case route.Action == "activate" && r.Method == http.MethodPost:
+		character, err := project.ActivateCharacterVariant(route.ItemID, route.Asset, route.VariantID)
+		if err != nil {
+			writeItemError(w, "切换图片版本", err)
+			return
+		}
+		log.Printf("[SUCCESS] 角色 %s 已切换到版本 %s", route.ItemID, route.VariantID)
+		setETag(w, config.CharacterVersion(character))
+		utils.WriteJSON(w, character)
+	case route.VariantID != "" && route.Action == "" && r.Method == http.MethodDelete:
+		character, err := project.DeleteCharacterVariant(route.ItemID, route.Asset, route.VariantID)
+		if err != nil {
+			writeItemError(w, "删除图片版本", err)
+			return
+		}
+		setETag(w, config.CharacterVersion(character))
+		utils.WriteJSON(w, character)
+	case route.Action == "" || route.Action == "activate":
+		http.Error(w, "不支持的请求方法", http.StatusMethodNotAllowed)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func sceneVariantsHandler(w http.ResponseWriter, r *http.Request, route variantRoute) {
+	project, ok := resolveProject(w, r)
+	if !ok {
+		return
+	}
+
+	switch {
+	case route.VariantID == "" && r.Method == http.MethodGet:
+		list, err := project.SceneVariants(route.ItemID, route.Asset)
+		if err != nil {
+			writeItemError(w, "读取素材版本", err)
+			return
+		}
+		utils.WriteJSON(w, list)
+	case route.Action == "activate" && r.Method == http.MethodPost:
+		scene, err := project.ActivateSceneVariant(route.ItemID, route.Asset, route.VariantID)
+		if err != nil {
+			writeItemError(w, "切换素材版本", err)
+			return
+		}
+		log.Printf("[SUCCESS] 场景 %s 已切换到版本 %s", route.ItemID, route.VariantID)
+		setETag(w, config.SceneVersion(scene))
+		utils.WriteJSON(w, scene)
+	case route.VariantID != "" && route.Action == "" && r.Method == http.MethodDelete:
+		scene, err := project.DeleteSceneVariant(route.ItemID, route.Asset, route.VariantID)
+		if err != nil {
+			writeItemError(w, "删除素材版本", err)
+			return
+		}
+		setETag(w, config.SceneVersion(scene))
+		utils.WriteJSON(w, scene)
+	case route.Action == "" || route.Action == "activate":
+		http.Error(w, "不支持的请求方法", http.StatusMethodNotAllowed)
+	default:
+		http.NotFound(w, r)
+	}
+}
@@ -0,0 +1,130 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"taco/backend/config"
+	"taco/backend/models"
+)
+
+func TestRegenerateSceneImageKeepsVariants(t *testing.T) {
+	useTempPaths(t)
+	server := newFakeProviders(t, 1)
+	config.SaveConfig(models.Config{
+		Image: models.ImageConfig{Model: "test-image", BaseURL: server.URL, APIKey: "test-key"},
+	})
+	config.SaveScenesData([]models.Scene{{ID: "scn_a", Title: "场景", Description: "夜晚的街道"}})
+
+	for i := 0; i < 2; i++ {
+		req := httptest.NewRequest(http.MethodPost, "/api/scenes/generate-image", bytes.NewReader([]byte(`{"id":"scn_a"}`)))
+		w := httptest.NewRecorder()
+		GenerateSceneImageHandler(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+		}
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/scenes/scn_a/images", nil)
+	w := httptest.NewRecorder()
+	SceneItemHandler(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var list models.AssetVariantList
+	json.NewDecoder(w.Body).Decode(&list)
+	if len(list.Variants) != 2 {
+		t.Fatalf("Expected 2 image variants, got %+v", list.Variants)
+	}
+	first, second := list.Variants[0], list.Variants[1]
+	if list.ActiveID != second.ID {
+		t.Errorf("Expected latest variant to be active, got %s", list.ActiveID)
+	}
+	if first.Model != "test-image" || first.Prompt == "" || first.CreatedAt.IsZero() {
+		t.Errorf("Expected variant metadata, got %+v", first)
+	}
+	project := config.DefaultProject()
+	for _, v := range list.Variants {
+		file, _ := project.Assets.ImageFile(v.Path)
+		if _, err := os.Stat(file); err != nil {
+			t.Errorf("Expected variant file %s to be kept: %v", v.Path, err)
+		}
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/api/scenes/scn_a/images/"+first.ID+"/activate", nil)
+	w = httptest.NewRecorder()
+	SceneItemHandler(w, req)
+	var scene models.Scene
+	json.NewDecoder(w.Body).Decode(&scene)
+	if w.Code != http.StatusOK || scene.ImagePath != first.Path {
+		t.Fatalf("Expected first variant to become active, got %d %+v", w.Code, scene)
+	}
+
+	req = httptest.NewRequest(http.MethodDelete, "/api/scenes/scn_a/images/"+second.ID, nil)
+	w = httptest.NewRecorder()
+	SceneItemHandler(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	file, _ := project.Assets.ImageFile(second.Path)
+	if _, err := os.Stat(file); err != nil {
+		t.Error("Expected deleted variant file to be left for GC")
+	}
+
+	req = httptest.NewRequest(http.MethodDelete, "/api/scenes/scn_a/images/"+second.ID, nil)
+	w = httptest.NewRecorder()
+	SceneItemHandler(w, req)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 for missing variant, got %d", w.Code)
+	}
+}
+
+func characterImageUploadRequest(t *testing.T, id string) *http.Request {
+	t.Helper()
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	writer.WriteField("id", id)
+	part, _ := writer.CreateFormFile("image", "test.png")
+	part.Write([]byte("fake image data"))
+	writer.Close()
+	req := httptest.NewRequest(http.MethodPost, "/api/characters/upload-image", body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	return req
+}
+
+func TestUploadCharacterImageAddsVariant(t *testing.T) {
+	useTempPaths(t)
+	config.SaveCharactersData([]models.CharacterProfile{{ID: "chr_a", Name: "角色", ImagePath: "/generated/images/old.png"}})
+
+	for i := 0; i < 2; i++ {
+		w := httptest.NewRecorder()
+		UploadCharacterImageHandler(w, characterImageUploadRequest(t, "chr_a"))
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+		}
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/characters/chr_a/images", nil)
+	w := httptest.NewRecorder()
+	CharacterItemHandler(w, req)
+	var list models.AssetVariantList
+	json.NewDecoder(w.Body).Decode(&list)
+	if len(list.Variants) != 3 {
+		t.Fatalf("Expected legacy image plus 2 uploads, got %+v", list.Variants)
+	}
+	if list.Variants[0].Source != models.VariantLegacy || list.Variants[2].Source != models.VariantUploaded {
+		t.Errorf("Unexpected variant sources: %+v", list.Variants)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/api/characters/chr_a/audio", nil)
+	w = httptest.NewRecorder()
+	CharacterItemHandler(w, req)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Characters have no audio variants, expected 404, got %d", w.Code)
+	}
+}
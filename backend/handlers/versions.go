@@ -0,0 +1,92 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"taco/backend/config"
+	"taco/backend/utils"
+)
+
+// 角色和场景接口使用 ETag / If-Match 做乐观并发控制：
+// 列表接口的 ETag 对应整个列表，/api/scenes/{id} 等单条目接口的 ETag 对应该条目。
+// 写请求携带的 If-Match 与当前版本不一致时返回 412，并附上当前版本和数据供前端合并。
+
+func etag(version string) string {
+	return `"` + version + `"`
+}
+
+func setETag(w http.ResponseWriter, version string) {
+	w.Header().Set("ETag", etag(version))
+}
+
+// ifMatchVersion 读取 If-Match 请求头中的版本号，去掉弱校验前缀和引号。
+func ifMatchVersion(r *http.Request) string {
+	value := strings.TrimSpace(r.Header.Get("If-Match"))
+	value = strings.TrimPrefix(value, "W/")
+	return strings.Trim(value, `"`)
+}
+
+type versionConflict struct {
+	Message string `json:"message"`
+	ETag    string `json:"etag"`
+	Current any    `json:"current"`
+}
+
+func writeVersionConflict(w http.ResponseWriter, version string, current any) {
+	log.Printf("[WARN] 版本冲突，当前版本: %s", version)
+	setETag(w, version)
+	utils.WriteJSONStatus(w, http.StatusPreconditionFailed, versionConflict{
+		Message: config.ErrVersionMismatch.Error(),
+		ETag:    etag(version),
+		Current: current,
+	})
+}
+
+func writeCharactersConflict(w http.ResponseWriter, project *config.Project) {
+	characters, err := project.LoadCharactersData()
+	if err != nil {
+		http.Error(w, fmt.Sprintf("读取角色失败: %v", err), http.StatusInternalServerError)
+		return
+	}
+	writeVersionConflict(w, config.CharactersVersion(characters), characters)
+}
+
+func writeCharacterConflict(w http.ResponseWriter, project *config.Project, id string) {
+	characters, err := project.LoadCharactersData()
+	if err != nil {
+		http.Error(w, fmt.Sprintf("读取角色失败: %v", err), http.StatusInternalServerError)
+		return
+	}
+	idx := config.FindCharacterIndex(characters, id)
+	if idx < 0 {
+		http.Error(w, config.ErrCharacterNotFound.Error(), http.StatusNotFound)
+		return
+	}
+	writeVersionConflict(w, config.CharacterVersion(characters[idx]), characters[idx])
+}
+
+func writeScenesConflict(w http.ResponseWriter, project *config.Project) {
+	scenes, err := project.LoadScenesData()
+	if err != nil {
+		http.Error(w, fmt.Sprintf("读取场景失败: %v", err), http.StatusInternalServerError)
+		return
+	}
+	writeVersionConflict(w, config.ScenesVersion(scenes), scenes)
+}
+
+func writeSceneConflict(w http.ResponseWriter, project *config.Project, id string) {
+	scenes, err := project.LoadScenesData()
+	if err != nil {
+		http.Error(w, fmt.Sprintf("读取场景失败: %v", err), http.StatusInternalServerError)
+		return
+	}
+	idx := config.FindSceneIndex(scenes, id)
+	if idx < 0 {
+		http.Error(w, config.ErrSceneNotFound.Error(), http.StatusNotFound)
+		return
+	}
+	writeVersionConflict(w, config.SceneVersion(scenes[idx]), scenes[idx])
+}
@@ -0,0 +1,136 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"taco/backend/config"
+	"taco/backend/models"
+)
+
+func TestScenesHandlerETagAndIfMatch(t *testing.T) {
+	useTempPaths(t)
+	seedScenes(t)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/scenes", nil)
+	w := httptest.NewRecorder()
+	ScenesHandler(w, req)
+	original := w.Header().Get("ETag")
+	if original == "" {
+		t.Fatal("Expected ETag header on GET")
+	}
+
+	var scenes []models.Scene
+	json.Unmarshal(w.Body.Bytes(), &scenes)
+	scenes[0].Title = "第一个标签页"
+	body, _ := json.Marshal(scenes)
+	req = httptest.NewRequest(http.MethodPost, "/api/scenes", bytes.NewReader(body))
+	req.Header.Set("If-Match", original)
+	w = httptest.NewRecorder()
+	ScenesHandler(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	updated := w.Header().Get("ETag")
+	if updated == "" || updated == original {
+		t.Errorf("Expected a new ETag after write, got %q", updated)
+	}
+
+	scenes[0].Title = "第二个标签页"
+	body, _ = json.Marshal(scenes)
+	req = httptest.NewRequest(http.MethodPost, "/api/scenes", bytes.NewReader(body))
+	req.Header.Set("If-Match", original)
+	w = httptest.NewRecorder()
+	ScenesHandler(w, req)
+	if w.Code != http.StatusPreconditionFailed {
+		t.Fatalf("Expected status 412, got %d", w.Code)
+	}
+	var conflict struct {
+		ETag    string         `json:"etag"`
+		Current []models.Scene `json:"current"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &conflict)
+	if conflict.ETag != updated || w.Header().Get("ETag") != updated {
+		t.Errorf("Expected current ETag %s, got %s", updated, conflict.ETag)
+	}
+	if len(conflict.Current) == 0 || conflict.Current[0].Title != "第一个标签页" {
+		t.Errorf("Expected current data in conflict response, got %+v", conflict.Current)
+	}
+
+	saved, _ := config.LoadScenesData()
+	if saved[0].Title != "第一个标签页" {
+		t.Errorf("Stale write should not be saved, got %s", saved[0].Title)
+	}
+}
+
+func TestScenesHandlerWithoutIfMatchStillWrites(t *testing.T) {
+	useTempPaths(t)
+	seedScenes(t)
+
+	body, _ := json.Marshal([]models.Scene{{Title: "覆盖"}})
+	req := httptest.NewRequest(http.MethodPost, "/api/scenes", bytes.NewReader(body))
+	w := httptest.NewRecorder()
+	ScenesHandler(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+}
+
+func TestSceneItemHandlerIfMatch(t *testing.T) {
+	useTempPaths(t)
+	seedScenes(t)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/scenes/scn_a", nil)
+	w := httptest.NewRecorder()
+	SceneItemHandler(w, req)
+	original := w.Header().Get("ETag")
+
+	// 修改其他场景不影响 scn_a 的版本。
+	req = httptest.NewRequest(http.MethodPatch, "/api/scenes/scn_b", bytes.NewReader([]byte(`{"title":"别处的修改"}`)))
+	SceneItemHandler(httptest.NewRecorder(), req)
+
+	req = httptest.NewRequest(http.MethodPatch, "/api/scenes/scn_a", bytes.NewReader([]byte(`{"title":"A1"}`)))
+	req.Header.Set("If-Match", original)
+	w = httptest.NewRecorder()
+	SceneItemHandler(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	req = httptest.NewRequest(http.MethodPatch, "/api/scenes/scn_a", bytes.NewReader([]byte(`{"title":"A2"}`)))
+	req.Header.Set("If-Match", original)
+	w = httptest.NewRecorder()
+	SceneItemHandler(w, req)
+	if w.Code != http.StatusPreconditionFailed {
+		t.Fatalf("Expected status 412, got %d", w.Code)
+	}
+
+	req = httptest.NewRequest(http.MethodDelete, "/api/scenes/scn_a", nil)
+	req.Header.Set("If-Match", original)
+	w = httptest.NewRecorder()
+	SceneItemHandler(w, req)
+	if w.Code != http.StatusPreconditionFailed {
+		t.Fatalf("Expected status 412 for stale delete, got %d", w.Code)
+	}
+}
+
+func TestCharactersHandlerIfMatchConflict(t *testing.T) {
+	useTempPaths(t)
+	config.SaveCharactersData([]models.CharacterProfile{{ID: "chr_a", Name: "角色1"}})
+
+	body, _ := json.Marshal([]models.CharacterProfile{{ID: "chr_a", Name: "新名字"}})
+	req := httptest.NewRequest(http.MethodPost, "/api/characters", bytes.NewReader(body))
+	req.Header.Set("If-Match", `"stale"`)
+	w := httptest.NewRecorder()
+	CharactersHandler(w, req)
+
+	if w.Code != http.StatusPreconditionFailed {
+		t.Fatalf("Expected status 412, got %d", w.Code)
+	}
+	if w.Header().Get("ETag") == "" {
+		t.Error("Expected current ETag in conflict response")
+	}
+}
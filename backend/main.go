@@ -1,15 +1,39 @@
 package main
 
 import (
+	"flag"
 	"log"
 	"net/http"
+	"os"
+	"path/filepath"
 
 	"taco/backend/config"
 	"taco/backend/handlers"
 	"taco/backend/utils"
 )
 
+// 服务器设置的环境变量，命令行参数优先于环境变量。
+const (
+	envListenAddr = "TACO_LISTEN_ADDR"
+	envDataDir    = "TACO_DATA_DIR"
+	envWebDir     = "TACO_WEB_DIR"
+	envConfigPath = "TACO_CONFIG_PATH"
+)
+
 func main() {
+	addr := flag.String("addr", envOr(envListenAddr, utils.ListenAddr), "HTTP 监听地址")
+	dataDir := flag.String("data-dir", os.Getenv(envDataDir), "数据目录，存放 config/、uploads/、generated/ 和 projects/，默认为仓库根目录")
+	webDir := flag.String("web-dir", os.Getenv(envWebDir), "前端静态文件目录，默认为仓库根目录下的 web/")
+	configPath := flag.String("config", os.Getenv(envConfigPath), "默认项目的配置文件路径，默认为数据目录下的 config/config.json")
+	gc := flag.Bool("gc", false, "启动时清理所有项目中未被引用的生成文件")
+	gcDryRun := flag.Bool("gc-dry-run", false, "启动时只列出未被引用的生成文件，不删除")
+	flag.Parse()
+
+	if err := applyServerPaths(*dataDir, *webDir, *configPath); err != nil {
+		log.Fatalf("resolve server paths: %v", err)
+	}
+	log.Printf("数据目录: %s，前端目录: %s，配置文件: %s", utils.ProjectRoot, utils.WebDir, utils.ConfigPath)
+
 	if _, err := config.LoadConfig(); err != nil {
 		log.Fatalf("initialise config: %v", err)
 	}
@@ -23,24 +47,109 @@ func main() {
 	if err := utils.EnsureDir(utils.GeneratedAudioDir); err != nil {
 		log.Fatalf("ensure audio dir: %v", err)
 	}
+	if err := utils.EnsureDir(utils.ProjectsDir); err != nil {
+		log.Fatalf("ensure projects dir: %v", err)
+	}
+
+	if *gc || *gcDryRun {
+		runStartupGC(*gcDryRun)
+	}
 
 	mux := http.NewServeMux()
 	mux.Handle("/", http.FileServer(http.Dir(utils.WebDir)))
 	mux.Handle("/generated/", http.StripPrefix("/generated/", http.FileServer(http.Dir(utils.GeneratedDir))))
+	mux.HandleFunc("/projects/", handlers.ProjectAssetsHandler)
+	mux.HandleFunc("/api/projects", handlers.ProjectsHandler)
+	mux.HandleFunc("/api/projects/", handlers.ProjectHandler)
+	mux.HandleFunc("/api/export/project", handlers.ExportProjectHandler)
+	mux.HandleFunc("/api/import/project", handlers.ImportProjectHandler)
+	mux.HandleFunc("/api/maintenance/gc", handlers.GCHandler)
 	mux.HandleFunc("/api/config", handlers.ConfigHandler)
+	mux.HandleFunc("/api/prompts", handlers.PromptsHandler)
+	mux.HandleFunc("/api/prompts/", handlers.PromptItemHandler)
 	mux.HandleFunc("/api/upload", handlers.UploadHandler)
+	mux.HandleFunc("/api/novel/chapters", handlers.NovelChaptersHandler)
+	mux.HandleFunc("/api/novel/encoding", handlers.NovelEncodingHandler)
+	mux.HandleFunc("/api/novels", handlers.NovelsHandler)
+	mux.HandleFunc("/api/novels/", handlers.NovelItemHandler)
 	mux.HandleFunc("/api/characters", handlers.CharactersHandler)
 	mux.HandleFunc("/api/characters/extract", handlers.ExtractCharactersHandler)
+	mux.HandleFunc("/api/characters/extract/stream", handlers.ExtractCharactersStreamHandler)
 	mux.HandleFunc("/api/characters/upload-image", handlers.UploadCharacterImageHandler)
 	mux.HandleFunc("/api/characters/generate-image", handlers.GenerateCharacterImageHandler)
+	mux.HandleFunc("/api/characters/insert", handlers.InsertCharacterHandler)
+	mux.HandleFunc("/api/characters/reorder", handlers.ReorderCharactersHandler)
+	mux.HandleFunc("/api/characters/merge", handlers.MergeCharactersHandler)
+	mux.HandleFunc("/api/characters/merge/suggest", handlers.SuggestMergesHandler)
+	mux.HandleFunc("/api/characters/history", handlers.CharacterHistoryHandler)
+	mux.HandleFunc("/api/characters/history/", handlers.CharacterHistoryHandler)
+	mux.HandleFunc("/api/characters/", handlers.CharacterItemHandler)
 	mux.HandleFunc("/api/scenes", handlers.ScenesHandler)
 	mux.HandleFunc("/api/scenes/extract", handlers.ExtractScenesHandler)
+	mux.HandleFunc("/api/scenes/extract/stream", handlers.ExtractScenesStreamHandler)
 	mux.HandleFunc("/api/scenes/generate-image", handlers.GenerateSceneImageHandler)
 	mux.HandleFunc("/api/scenes/generate-image-with-characters", handlers.GenerateSceneImageWithCharactersHandler)
 	mux.HandleFunc("/api/scenes/generate-audio", handlers.GenerateSceneAudioHandler)
+	mux.HandleFunc("/api/scenes/insert", handlers.InsertSceneHandler)
+	mux.HandleFunc("/api/scenes/reorder", handlers.ReorderScenesHandler)
+	mux.HandleFunc("/api/scenes/history", handlers.SceneHistoryHandler)
+	mux.HandleFunc("/api/scenes/history/", handlers.SceneHistoryHandler)
+	mux.HandleFunc("/api/scenes/", handlers.SceneItemHandler)
 
-	log.Printf("Server listening on %s", utils.ListenAddr)
-	if err := http.ListenAndServe(utils.ListenAddr, mux); err != nil {
+	log.Printf("Server listening on %s", *addr)
+	if err := http.ListenAndServe(*addr, mux); err != nil {
 		log.Fatal(err)
 	}
 }
+
+func envOr(name, fallback string) string {
+	if value := os.Getenv(name); value != "" {
+		return value
+	}
+	return fallback
+}
+
+// applyServerPaths 按参数切换数据目录、前端目录和配置文件，空值表示沿用默认位置。
+// 配置文件需在数据目录之后设置，否则会被 SetDataDir 覆盖。
+func applyServerPaths(dataDir, webDir, configPath string) error {
+	if dataDir != "" {
+		abs, err := filepath.Abs(dataDir)
+		if err != nil {
+			return err
+		}
+		utils.SetDataDir(abs)
+	}
+	if webDir != "" {
+		abs, err := filepath.Abs(webDir)
+		if err != nil {
+			return err
+		}
+		utils.WebDir = abs
+	}
+	if configPath != "" {
+		abs, err := filepath.Abs(configPath)
+		if err != nil {
+			return err
+		}
+		utils.ConfigPath = abs
+	}
+	return nil
+}
+
+func runStartupGC(dryRun bool) {
+	reports, err := config.CollectAllGarbage(dryRun)
+	if err != nil {
+		log.Printf("[WARN] 启动清理失败: %v", err)
+		return
+	}
+	for _, report := range reports {
+		for _, orphan := range report.Orphans {
+			if dryRun {
+				log.Printf("[GC] 未引用: %s (%d 字节)", orphan.Path, orphan.Size)
+			} else if orphan.Removed {
+				log.Printf("[GC] 已删除: %s", orphan.Path)
+			}
+		}
+		log.Printf("[GC] 项目 %s: 扫描 %d 个文件，未引用 %d 个，释放 %d 字节", report.Project, report.Scanned, len(report.Orphans), report.FreedBytes)
+	}
+}
@@ -49,3 +49,42 @@ func TestListenAddr(t *testing.T) {
 		t.Errorf("Expected listen address ':8080', got '%s'", utils.ListenAddr)
 	}
 }
+
+func TestApplyServerPaths(t *testing.T) {
+	original := []string{utils.ProjectRoot, utils.ConfigPath, utils.CharactersPath, utils.ScenesPath, utils.UploadDir, utils.GeneratedDir, utils.GeneratedImagesDir, utils.GeneratedAudioDir, utils.ProjectsDir, utils.WebDir}
+	t.Cleanup(func() {
+		utils.ProjectRoot, utils.ConfigPath, utils.CharactersPath, utils.ScenesPath = original[0], original[1], original[2], original[3]
+		utils.UploadDir, utils.GeneratedDir, utils.GeneratedImagesDir, utils.GeneratedAudioDir = original[4], original[5], original[6], original[7]
+		utils.ProjectsDir, utils.WebDir = original[8], original[9]
+	})
+
+	tmpDir := t.TempDir()
+	configPath := filepath.Join(tmpDir, "etc", "taco.json")
+	if err := applyServerPaths(filepath.Join(tmpDir, "data"), filepath.Join(tmpDir, "public"), configPath); err != nil {
+		t.Fatalf("applyServerPaths failed: %v", err)
+	}
+	if utils.UploadDir != filepath.Join(tmpDir, "data", "uploads") {
+		t.Errorf("Unexpected upload dir %s", utils.UploadDir)
+	}
+	if utils.WebDir != filepath.Join(tmpDir, "public") {
+		t.Errorf("Unexpected web dir %s", utils.WebDir)
+	}
+	if utils.ConfigPath != configPath {
+		t.Errorf("Config path should override data dir, got %s", utils.ConfigPath)
+	}
+
+	if err := applyServerPaths("", "", ""); err != nil || utils.ConfigPath != configPath {
+		t.Errorf("Empty values should keep current paths, got %s (%v)", utils.ConfigPath, err)
+	}
+}
+
+func TestEnvOr(t *testing.T) {
+	t.Setenv(envListenAddr, "")
+	if got := envOr(envListenAddr, utils.ListenAddr); got != ":8080" {
+		t.Errorf("Expected fallback listen address, got %q", got)
+	}
+	t.Setenv(envListenAddr, "127.0.0.1:9000")
+	if got := envOr(envListenAddr, utils.ListenAddr); got != "127.0.0.1:9000" {
+		t.Errorf("Expected environment listen address, got %q", got)
+	}
+}
@@ -1,5 +1,10 @@
 package models
 
+import (
+	"encoding/json"
+	"time"
+)
+
 type Config struct {
 	NovelFile      string      `json:"novelFile"`
 	LLM            LLMConfig   `json:"llm"`
@@ -10,12 +15,57 @@ type Config struct {
 	CharacterCount int         `json:"characterCount"`
 	SceneCount     int         `json:"sceneCount"`
 	AnimeStyle     string      `json:"animeStyle,omitempty"`
+	// Profiles 是按名称保存的其他服务配置，Operations 指定每个操作使用哪一个。
+	Profiles   []ProviderProfile `json:"profiles,omitempty"`
+	Operations OperationProfiles `json:"operations"`
+	// Retry 是调用 LLM、图像、图像编辑和语音接口时共用的重试策略。
+	Retry RetryConfig `json:"retry"`
+	// Prompts 按模板名称覆盖内置的提示词模板，未列出的模板使用默认内容。
+	Prompts map[string]string `json:"prompts,omitempty"`
+}
+
+// RetryConfig 控制失败请求的重试，0 表示使用默认值：最多 3 次，初始等待 1000 毫秒，单次等待上限 30000 毫秒。
+// 每次重试的等待时间翻倍并加入随机抖动，服务端返回 Retry-After 时按其等待。
+type RetryConfig struct {
+	MaxAttempts int `json:"maxAttempts,omitempty"`
+	BaseDelayMs int `json:"baseDelayMs,omitempty"`
+	MaxDelayMs  int `json:"maxDelayMs,omitempty"`
+}
+
+// ProviderProfile 是一组可按名称选择的服务配置，例如便宜的草稿模型和效果更好的定稿模型。
+// 留空的字段沿用顶层配置。
+type ProviderProfile struct {
+	Name      string      `json:"name"`
+	LLM       LLMConfig   `json:"llm"`
+	Image     ImageConfig `json:"image"`
+	ImageEdit ImageConfig `json:"imageEdit"`
+	Voice     VoiceConfig `json:"voice"`
+}
+
+// OperationProfiles 记录每个操作选用的配置档案名称，留空表示使用顶层配置。
+type OperationProfiles struct {
+	CharacterExtraction string `json:"characterExtraction"`
+	SceneExtraction     string `json:"sceneExtraction"`
+	CharacterImage      string `json:"characterImage"`
+	SceneImage          string `json:"sceneImage"`
+	SceneEdit           string `json:"sceneEdit"`
+	TTS                 string `json:"tts"`
 }
 
 type LLMConfig struct {
 	Model   string `json:"model"`
 	BaseURL string `json:"baseUrl"`
 	APIKey  string `json:"apiKey"`
+	// Provider 是接口类型：openai（默认，OpenAI 兼容接口）、anthropic、ollama 或 dashscope。
+	Provider string `json:"provider,omitempty"`
+	// ChunkTokens 是提取角色和场景时每段小说的 token 上限，0 表示使用默认值。
+	ChunkTokens int `json:"chunkTokens,omitempty"`
+	// JSONMode 控制是否要求接口只输出 JSON：auto（默认）、on 或 off。
+	JSONMode string `json:"jsonMode,omitempty"`
+	// RepairAttempts 是输出不符合格式时要求模型修正的次数，0 表示默认 2 次，小于 0 表示不修正。
+	RepairAttempts int `json:"repairAttempts,omitempty"`
+	// MaxTokens 是单次回复的 token 上限，0 表示由接口决定（Anthropic 必须指定，默认 8192）。
+	MaxTokens int `json:"maxTokens,omitempty"`
 }
 
 type ImageConfig struct {
@@ -35,20 +85,81 @@ type VoiceConfig struct {
 	OutputDir string `json:"outputDir"`
 }
 
+const (
+	CharacterIDPrefix = "chr"
+	SceneIDPrefix     = "scn"
+	VariantIDPrefix   = "var"
+)
+
 type CharacterProfile struct {
-	Name        string `json:"name"`
-	Description string `json:"description"`
-	ImagePath   string `json:"imagePath,omitempty"`
+	ID          string   `json:"id"`
+	Name        string   `json:"name"`
+	Aliases     []string `json:"aliases,omitempty"`
+	Description string   `json:"description"`
+	CharacterSheet
+	ImagePath     string         `json:"imagePath,omitempty"`
+	ImageVariants []AssetVariant `json:"imageVariants,omitempty"`
+}
+
+// CharacterSheet 是角色的结构化设定，字段在 JSON 中与角色的其他字段平铺。
+// VisualPrompt 是简短的英文外观提示词，生成角色图和场景图时原样使用，以保持角色外观一致。
+type CharacterSheet struct {
+	Gender       string   `json:"gender,omitempty"`
+	AgeRange     string   `json:"ageRange,omitempty"`
+	Build        string   `json:"build,omitempty"`
+	Hair         string   `json:"hair,omitempty"`
+	Eyes         string   `json:"eyes,omitempty"`
+	Clothing     string   `json:"clothing,omitempty"`
+	Colors       []string `json:"colors,omitempty"`
+	Personality  string   `json:"personality,omitempty"`
+	SpeechStyle  string   `json:"speechStyle,omitempty"`
+	Role         string   `json:"role,omitempty"`
+	VisualPrompt string   `json:"visualPrompt,omitempty"`
+}
+
+// MergeSuggestion 是模型建议合并的一组角色：Sources 与 Target 为同一人物。
+type MergeSuggestion struct {
+	TargetID  string   `json:"targetId"`
+	Target    string   `json:"target"`
+	SourceIDs []string `json:"sourceIds"`
+	Sources   []string `json:"sources"`
+	Reason    string   `json:"reason,omitempty"`
 }
 
 type Scene struct {
-	Title       string   `json:"title"`
-	Characters  []string `json:"characters"`
-	Description string   `json:"description"`
-	Dialogues   []string `json:"dialogues"`
-	Narration   string   `json:"narration"`
-	ImagePath   string   `json:"imagePath"`
-	AudioPath   string   `json:"audioPath"`
+	ID            string         `json:"id"`
+	Title         string         `json:"title"`
+	Characters    []string       `json:"characters"`
+	Description   string         `json:"description"`
+	Dialogues     []string       `json:"dialogues"`
+	Narration     string         `json:"narration"`
+	Chapter       int            `json:"chapter,omitempty"`
+	ImagePath     string         `json:"imagePath"`
+	AudioPath     string         `json:"audioPath"`
+	ImageVariants []AssetVariant `json:"imageVariants,omitempty"`
+	AudioVariants []AssetVariant `json:"audioVariants,omitempty"`
+}
+
+const (
+	VariantGenerated = "generated"
+	VariantUploaded  = "uploaded"
+	// VariantLegacy 表示在引入素材版本之前就已存在的文件。
+	VariantLegacy = "legacy"
+)
+
+// AssetVariant 是图片或音频的一个历史版本。Path 与条目的 ImagePath/AudioPath 相同时即为当前使用的版本。
+type AssetVariant struct {
+	ID        string    `json:"id"`
+	Path      string    `json:"path"`
+	Source    string    `json:"source"`
+	Prompt    string    `json:"prompt,omitempty"`
+	Model     string    `json:"model,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+type AssetVariantList struct {
+	ActiveID string         `json:"activeId"`
+	Variants []AssetVariant `json:"variants"`
 }
 
 type AudioResult struct {
@@ -56,3 +167,117 @@ type AudioResult struct {
 	IsURL     bool
 	Extension string
 }
+
+type ProjectInfo struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// RevisionInfo 描述角色或场景列表的一次保存记录，Kind 为 "characters" 或 "scenes"。
+type RevisionInfo struct {
+	ID        string    `json:"id"`
+	Kind      string    `json:"kind"`
+	CreatedAt time.Time `json:"createdAt"`
+	Version   string    `json:"version"`
+	Count     int       `json:"count"`
+}
+
+type Revision struct {
+	RevisionInfo
+	Data json.RawMessage `json:"data"`
+}
+
+// ItemChange 是两个版本之间单个条目的差异，Type 为 added、removed、modified 或 moved。
+type ItemChange struct {
+	ID     string         `json:"id"`
+	Label  string         `json:"label"`
+	Type   string         `json:"type"`
+	Fields []string       `json:"fields,omitempty"`
+	Before map[string]any `json:"before,omitempty"`
+	After  map[string]any `json:"after,omitempty"`
+}
+
+type RevisionDiff struct {
+	Kind    string       `json:"kind"`
+	From    string       `json:"from"`
+	To      string       `json:"to"`
+	Changes []ItemChange `json:"changes"`
+}
+
+// GCReport 是一次生成文件清理的结果，DryRun 为 true 时只列出未被引用的文件而不删除。
+type GCReport struct {
+	Project    string   `json:"project"`
+	DryRun     bool     `json:"dryRun"`
+	Scanned    int      `json:"scanned"`
+	Referenced int      `json:"referenced"`
+	Skipped    int      `json:"skipped"`
+	Orphans    []GCFile `json:"orphans"`
+	FreedBytes int64    `json:"freedBytes"`
+}
+
+type GCFile struct {
+	Path    string `json:"path"`
+	Size    int64  `json:"size"`
+	Removed bool   `json:"removed"`
+}
+
+// Chapter 是小说中的一章。Start、End 是章节（含标题行）在小说文本中的字节偏移，左闭右开；
+// Length 为章节的字数。Index 从 1 开始，第一个标题之前的内容（如书名、序言）作为第 0 章。
+type Chapter struct {
+	Index  int    `json:"index"`
+	Title  string `json:"title"`
+	Start  int    `json:"start"`
+	End    int    `json:"end"`
+	Length int    `json:"length"`
+}
+
+// NovelUpload 描述上传后转换为 UTF-8 的小说文件，ID 为它在小说库中的标识。Format 为原始文件的格式（text、epub、docx），
+// Encoding 为解码使用的编码，Detected 表示编码是自动识别的；Invalid 为无法解码而被替换的字节数，
+// 不为 0 时通常说明编码选错了。Chapters 为识别出的章节数。
+type NovelUpload struct {
+	ID       string `json:"id"`
+	FilePath string `json:"filePath"`
+	Format   string `json:"format"`
+	Encoding string `json:"encoding"`
+	Detected bool   `json:"detected"`
+	Invalid  int    `json:"invalid"`
+	Chapters int    `json:"chapters"`
+	Preview  string `json:"preview"`
+}
+
+// NovelInfo 描述上传目录中的一部小说。ID 为上传目录中的文件名，Title 可以修改，不影响文件名；
+// Hash 为原始文件内容的 SHA-256，用于拒绝重复上传。Active 表示是当前项目正在使用的小说。
+type NovelInfo struct {
+	ID         string    `json:"id"`
+	Title      string    `json:"title"`
+	FilePath   string    `json:"filePath"`
+	Size       int64     `json:"size"`
+	Format     string    `json:"format"`
+	Encoding   string    `json:"encoding"`
+	Chapters   int       `json:"chapters"`
+	Hash       string    `json:"hash"`
+	UploadedAt time.Time `json:"uploadedAt"`
+	Active     bool      `json:"active"`
+}
+
+// PromptTemplate 是 /api/prompts 返回的提示词模板，Template 为当前项目实际使用的内容。
+type PromptTemplate struct {
+	Name        string `json:"name"`
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	Default     string `json:"default"`
+	Template    string `json:"template"`
+	Overridden  bool   `json:"overridden"`
+}
+
+// ExtractionProgress 是一次分段提取的进度，Done 为已完成的段数，Found 为累计提取到的条目数。
+type ExtractionProgress struct {
+	Kind      string    `json:"kind"`
+	Running   bool      `json:"running"`
+	Done      int       `json:"done"`
+	Total     int       `json:"total"`
+	Found     int       `json:"found"`
+	Error     string    `json:"error,omitempty"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
@@ -6,7 +6,6 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
-	"io"
 	"net"
 	"net/http"
 	"path/filepath"
@@ -15,43 +14,51 @@ import (
 	"time"
 
 	"taco/backend/models"
+	"taco/backend/services/retry"
 	"taco/backend/utils"
 )
 
 var audioDataURLPattern = regexp.MustCompile(`^data:audio/([^;]+);base64,`)
 
-func GenerateSceneAudio(ctx context.Context, cfg models.Config, scene models.Scene, index int) (string, error) {
-	if err := utils.EnsureDir(utils.GeneratedAudioDir); err != nil {
-		return "", err
+// GenerateSceneAudio 生成场景配音并保存为新文件，返回对应的素材版本，已有的音频不会被删除。
+func GenerateSceneAudio(ctx context.Context, cfg models.Config, assets utils.AssetDirs, scene models.Scene) (models.AssetVariant, error) {
+	if err := utils.EnsureDir(assets.AudioDir); err != nil {
+		return models.AssetVariant{}, err
 	}
 
 	result, err := requestSceneAudio(ctx, cfg, scene)
 	if err != nil {
-		return "", err
+		return models.AssetVariant{}, err
 	}
 
 	ext := ".mp3"
 	if result.Extension != "" {
 		ext = "." + strings.TrimPrefix(result.Extension, ".")
 	}
-	filename := fmt.Sprintf("scene_%02d_%d%s", index+1, time.Now().Unix(), ext)
-	absPath := filepath.Join(utils.GeneratedAudioDir, filename)
-
-	if scene.AudioPath != "" {
-		RemoveGeneratedAudio(scene.AudioPath)
+	filename := fmt.Sprintf("scene_%d%s", time.Now().UnixNano(), ext)
+	if scene.ID != "" {
+		filename = fmt.Sprintf("scene_%s_%d%s", scene.ID, time.Now().UnixNano(), ext)
 	}
+	absPath := filepath.Join(assets.AudioDir, filename)
 
 	if result.IsURL {
 		if err := utils.DownloadToFile(ctx, result.Source, absPath); err != nil {
-			return "", err
+			return models.AssetVariant{}, err
 		}
 	} else {
 		if err := utils.SaveBase64ToFile(result.Source, absPath); err != nil {
-			return "", err
+			return models.AssetVariant{}, err
 		}
 	}
 
-	return utils.GeneratedAudioURLPrefix + filename, nil
+	return models.AssetVariant{
+		ID:        utils.NewID(models.VariantIDPrefix),
+		Path:      assets.AudioURL(filename),
+		Source:    models.VariantGenerated,
+		Prompt:    BuildSceneSpeechText(scene),
+		Model:     cfg.Voice.Model,
+		CreatedAt: time.Now(),
+	}, nil
 }
 
 func requestSceneAudio(ctx context.Context, cfg models.Config, scene models.Scene) (models.AudioResult, error) {
@@ -98,16 +105,6 @@ func requestSceneAudio(ctx context.Context, cfg models.Config, scene models.Scen
 
 	apiURL := base + "/api/v1/services/aigc/multimodal-generation/generation"
 
-	// 创建 HTTP 请求
-	request, err := http.NewRequestWithContext(ctx, http.MethodPost, apiURL, bytes.NewBuffer(bodyBytes))
-	if err != nil {
-		return models.AudioResult{}, err
-	}
-	request.Header.Set("Authorization", "Bearer "+voiceCfg.APIKey)
-	request.Header.Set("Content-Type", "application/json")
-	request.Header.Set("Connection", "keep-alive")
-	request.ContentLength = int64(len(bodyBytes))
-
 	// 配置 HTTP Transport 以处理大请求体
 	transport := &http.Transport{
 		DisableKeepAlives:   false,
@@ -131,6 +128,21 @@ func requestSceneAudio(ctx context.Context, cfg models.Config, scene models.Scen
 		Transport: transport,
 	}
 
+	return retry.Do(ctx, retry.FromConfig(cfg.Retry), "语音 API", func() (models.AudioResult, error) {
+		return doAudioRequest(ctx, client, apiURL, voiceCfg.APIKey, bodyBytes)
+	})
+}
+
+func doAudioRequest(ctx context.Context, client *http.Client, apiURL, apiKey string, bodyBytes []byte) (models.AudioResult, error) {
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, apiURL, bytes.NewBuffer(bodyBytes))
+	if err != nil {
+		return models.AudioResult{}, retry.Permanent(err)
+	}
+	request.Header.Set("Authorization", "Bearer "+apiKey)
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("Connection", "keep-alive")
+	request.ContentLength = int64(len(bodyBytes))
+
 	resp, err := client.Do(request)
 	if err != nil {
 		return models.AudioResult{}, fmt.Errorf("发送请求失败: %w", err)
@@ -138,12 +150,7 @@ func requestSceneAudio(ctx context.Context, cfg models.Config, scene models.Scen
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		errBody, _ := io.ReadAll(resp.Body)
-		errMsg := strings.TrimSpace(string(errBody))
-		if errMsg == "" {
-			errMsg = fmt.Sprintf("HTTP %d", resp.StatusCode)
-		}
-		return models.AudioResult{}, fmt.Errorf("语音服务请求失败 (状态码: %d): %s", resp.StatusCode, errMsg)
+		return models.AudioResult{}, retry.NewHTTPError(resp, "语音服务请求失败")
 	}
 
 	var payload map[string]any
@@ -289,7 +296,3 @@ func inferAudioExtensionFromURL(url string, fallback string) string {
 	}
 	return "mp3"
 }
-
-func RemoveGeneratedAudio(relPath string) {
-	utils.RemoveGeneratedFile(relPath, utils.GeneratedAudioURLPrefix, utils.GeneratedAudioDir)
-}
@@ -5,7 +5,6 @@ import (
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
-	"os"
 	"path/filepath"
 	"testing"
 
@@ -112,17 +111,21 @@ func TestGenerateSceneAudioSuccess(t *testing.T) {
 	}
 
 	ctx := context.Background()
-	audioPath, err := GenerateSceneAudio(ctx, cfg, scene, 0)
+	variant, err := GenerateSceneAudio(ctx, cfg, utils.DefaultAssetDirs(), scene)
 	if err != nil {
 		t.Fatalf("GenerateSceneAudio failed: %v", err)
 	}
 
-	if audioPath == "" {
+	if variant.Path == "" {
 		t.Error("Expected non-empty audio path")
 	}
 
-	if filepath.Ext(audioPath) != ".mp3" {
-		t.Errorf("Expected .mp3 extension, got '%s'", filepath.Ext(audioPath))
+	if filepath.Ext(variant.Path) != ".mp3" {
+		t.Errorf("Expected .mp3 extension, got '%s'", filepath.Ext(variant.Path))
+	}
+
+	if variant.Model != "test-tts" || variant.Prompt != "测试旁白" || variant.CreatedAt.IsZero() {
+		t.Errorf("Expected variant metadata, got %+v", variant)
 	}
 }
 
@@ -140,7 +143,7 @@ func TestGenerateSceneAudioMissingConfig(t *testing.T) {
 	}
 
 	ctx := context.Background()
-	_, err := GenerateSceneAudio(ctx, cfg, scene, 0)
+	_, err := GenerateSceneAudio(ctx, cfg, utils.AssetDirs{AudioDir: t.TempDir()}, scene)
 	if err == nil {
 		t.Error("Expected error for missing voice config")
 	}
@@ -160,7 +163,7 @@ func TestGenerateSceneAudioEmptyText(t *testing.T) {
 	scene := models.Scene{}
 
 	ctx := context.Background()
-	_, err := GenerateSceneAudio(ctx, cfg, scene, 0)
+	_, err := GenerateSceneAudio(ctx, cfg, utils.AssetDirs{AudioDir: t.TempDir()}, scene)
 	if err == nil {
 		t.Error("Expected error for empty scene text")
 	}
@@ -275,22 +278,3 @@ func TestInferAudioExtensionFromData(t *testing.T) {
 		}
 	}
 }
-
-func TestRemoveGeneratedAudio(t *testing.T) {
-	tmpDir := t.TempDir()
-	originalAudioDir := utils.GeneratedAudioDir
-	utils.GeneratedAudioDir = tmpDir
-	defer func() { utils.GeneratedAudioDir = originalAudioDir }()
-
-	testFile := filepath.Join(tmpDir, "test.mp3")
-	err := os.WriteFile(testFile, []byte("test"), 0o644)
-	if err != nil {
-		t.Fatalf("Failed to create test file: %v", err)
-	}
-
-	RemoveGeneratedAudio("/generated/audio/test.mp3")
-
-	if _, err := os.Stat(testFile); !os.IsNotExist(err) {
-		t.Error("File should have been deleted")
-	}
-}
@@ -0,0 +1,223 @@
+// Package cast 按名称和别名匹配角色，并把同一人物的多个角色条目合并为一个。
+package cast
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"taco/backend/models"
+)
+
+var (
+	ErrMergeTarget  = errors.New("合并目标不能同时作为被合并的角色")
+	ErrMergeSources = errors.New("至少需要一个被合并的角色")
+)
+
+// Key 返回用于判断是否同一人物的名称键，忽略空白和大小写，
+// 使“林 黛玉”“林黛玉”以及“Alice”“alice”视为同一人。
+func Key(name string) string {
+	return strings.ToLower(strings.Join(strings.Fields(name), ""))
+}
+
+// Names 返回角色的名称和全部别名，去掉空白和重复项，名称在前。
+func Names(character models.CharacterProfile) []string {
+	names := []string{}
+	seen := map[string]bool{}
+	for _, name := range append([]string{character.Name}, character.Aliases...) {
+		name = strings.TrimSpace(name)
+		key := Key(name)
+		if key == "" || seen[key] {
+			continue
+		}
+		seen[key] = true
+		names = append(names, name)
+	}
+	return names
+}
+
+// Index 把名称和别名的键映射到角色下标。多个角色共用一个名称时，
+// 以名称匹配的优先于别名匹配的，其次是排在前面的。
+type Index map[string]int
+
+// NewIndex 为 characters 建立索引。
+func NewIndex(characters []models.CharacterProfile) Index {
+	index := Index{}
+	for i, character := range characters {
+		if key := Key(character.Name); key != "" {
+			if _, ok := index[key]; !ok {
+				index[key] = i
+			}
+		}
+	}
+	for i, character := range characters {
+		for _, alias := range character.Aliases {
+			if key := Key(alias); key != "" {
+				if _, ok := index[key]; !ok {
+					index[key] = i
+				}
+			}
+		}
+	}
+	return index
+}
+
+// Find 返回名称或别名为 name 的角色下标。
+func (idx Index) Find(name string) (int, bool) {
+	i, ok := idx[Key(name)]
+	return i, ok
+}
+
+// Find 在 characters 中查找名称或别名为 name 的角色。
+func Find(characters []models.CharacterProfile, name string) (models.CharacterProfile, bool) {
+	if i, ok := NewIndex(characters).Find(name); ok {
+		return characters[i], true
+	}
+	return models.CharacterProfile{}, false
+}
+
+// AddAliases 把 names 中与角色现有名称和别名都不同的加入别名。
+func AddAliases(character *models.CharacterProfile, names ...string) {
+	seen := map[string]bool{}
+	for _, name := range Names(*character) {
+		seen[Key(name)] = true
+	}
+	for _, name := range names {
+		name = strings.TrimSpace(name)
+		key := Key(name)
+		if key == "" || seen[key] {
+			continue
+		}
+		seen[key] = true
+		character.Aliases = append(character.Aliases, name)
+	}
+}
+
+// FillSheet 用 src 补全 dst 中为空的设定字段，代表色取两者的并集。已有的内容不会被覆盖，
+// 因此同一角色的 VisualPrompt 一经确定便保持不变。
+func FillSheet(dst *models.CharacterSheet, src models.CharacterSheet) {
+	fields := []struct {
+		dst *string
+		src string
+	}{
+		{&dst.Gender, src.Gender},
+		{&dst.AgeRange, src.AgeRange},
+		{&dst.Build, src.Build},
+		{&dst.Hair, src.Hair},
+		{&dst.Eyes, src.Eyes},
+		{&dst.Clothing, src.Clothing},
+		{&dst.Personality, src.Personality},
+		{&dst.SpeechStyle, src.SpeechStyle},
+		{&dst.Role, src.Role},
+		{&dst.VisualPrompt, src.VisualPrompt},
+	}
+	for _, field := range fields {
+		if strings.TrimSpace(*field.dst) == "" {
+			*field.dst = strings.TrimSpace(field.src)
+		}
+	}
+	seen := map[string]bool{}
+	colors := []string{}
+	for _, color := range append(append([]string(nil), dst.Colors...), src.Colors...) {
+		color = strings.TrimSpace(color)
+		if color != "" && !seen[Key(color)] {
+			seen[Key(color)] = true
+			colors = append(colors, color)
+		}
+	}
+	if len(colors) > 0 {
+		dst.Colors = colors
+	}
+}
+
+// Merge 把 sourceIDs 对应的角色并入 targetID：被合并角色的名称和别名成为目标的别名，
+// 目标没有描述、设定字段或图片时沿用第一个有的被合并角色的。调用方需确认这些 ID 都存在。
+// 返回合并后的角色列表、目标角色，以及被合并掉的名称（用于改写场景）。
+func Merge(characters []models.CharacterProfile, targetID string, sourceIDs []string) ([]models.CharacterProfile, models.CharacterProfile, []string, error) {
+	sources := map[string]bool{}
+	for _, id := range sourceIDs {
+		if id == targetID {
+			return nil, models.CharacterProfile{}, nil, ErrMergeTarget
+		}
+		sources[id] = true
+	}
+	if len(sources) == 0 {
+		return nil, models.CharacterProfile{}, nil, ErrMergeSources
+	}
+	targetIdx := -1
+	for i, character := range characters {
+		if character.ID == targetID {
+			targetIdx = i
+			break
+		}
+	}
+	if targetIdx < 0 {
+		return nil, models.CharacterProfile{}, nil, fmt.Errorf("角色不存在: %s", targetID)
+	}
+
+	target := characters[targetIdx]
+	target.Aliases = append([]string(nil), target.Aliases...)
+	var merged []string
+	for _, character := range characters {
+		if !sources[character.ID] {
+			continue
+		}
+		names := Names(character)
+		AddAliases(&target, names...)
+		merged = append(merged, names...)
+		if strings.TrimSpace(target.Description) == "" {
+			target.Description = character.Description
+		}
+		FillSheet(&target.CharacterSheet, character.CharacterSheet)
+		if target.ImagePath == "" && character.ImagePath != "" {
+			target.ImagePath = character.ImagePath
+			target.ImageVariants = character.ImageVariants
+		}
+	}
+
+	result := make([]models.CharacterProfile, 0, len(characters)-len(sources))
+	for i, character := range characters {
+		switch {
+		case i == targetIdx:
+			result = append(result, target)
+		case !sources[character.ID]:
+			result = append(result, character)
+		}
+	}
+	return result, target, merged, nil
+}
+
+// RenameInScenes 把场景出场人物中属于 names 的名称改为 name，并去掉因此重复的人物，返回被修改的场景数。
+func RenameInScenes(scenes []models.Scene, names []string, name string) int {
+	renamed := map[string]bool{}
+	for _, alias := range names {
+		renamed[Key(alias)] = true
+	}
+	changed := 0
+	for i := range scenes {
+		matched := false
+		for _, character := range scenes[i].Characters {
+			if renamed[Key(character)] {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			continue
+		}
+		rewritten := make([]string, 0, len(scenes[i].Characters))
+		seen := map[string]bool{}
+		for _, character := range scenes[i].Characters {
+			if renamed[Key(character)] {
+				character = name
+			}
+			if key := Key(character); !seen[key] {
+				seen[key] = true
+				rewritten = append(rewritten, character)
+			}
+		}
+		scenes[i].Characters = rewritten
+		changed++
+	}
+	return changed
+}
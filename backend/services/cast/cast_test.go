@@ -0,0 +1,71 @@
+package cast
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"taco/backend/models"
+)
+
+func TestIndexMatchesNamesAndAliases(t *testing.T) {
+	characters := []models.CharacterProfile{
+		{ID: "a", Name: "刘姥姥", Aliases: []string{"姥姥", "刘氏"}},
+		{ID: "b", Name: "Harry Potter", Aliases: []string{"刘姥姥"}},
+		{ID: "c", Name: "姥姥"},
+	}
+	index := NewIndex(characters)
+	for name, want := range map[string]int{"刘姥姥": 0, " 刘氏 ": 0, "harrypotter": 1, "姥姥": 2} {
+		if got, ok := index.Find(name); !ok || got != want {
+			t.Errorf("Find(%q) = %d, %v; want %d", name, got, ok, want)
+		}
+	}
+	if _, ok := index.Find("王熙凤"); ok {
+		t.Error("Expected no match for an unknown name")
+	}
+}
+
+func TestMergeAndRenameInScenes(t *testing.T) {
+	characters := []models.CharacterProfile{
+		{ID: "a", Name: "刘姥姥", CharacterSheet: models.CharacterSheet{Colors: []string{"蓝"}}},
+		{ID: "b", Name: "姥姥", Description: "乡下老妇", ImagePath: "b.png", Aliases: []string{"老刘"},
+			CharacterSheet: models.CharacterSheet{VisualPrompt: "old woman, grey bun", Colors: []string{"灰", "蓝"}}},
+		{ID: "c", Name: "刘氏"},
+		{ID: "d", Name: "王熙凤"},
+	}
+	merged, target, names, err := Merge(characters, "a", []string{"b", "c"})
+	if err != nil {
+		t.Fatalf("Merge failed: %v", err)
+	}
+	if len(merged) != 2 || merged[0].ID != "a" || merged[1].ID != "d" {
+		t.Fatalf("Unexpected characters %+v", merged)
+	}
+	if fmt.Sprint(target.Aliases) != "[姥姥 老刘 刘氏]" || target.Description != "乡下老妇" || target.ImagePath != "b.png" {
+		t.Errorf("Unexpected target %+v", target)
+	}
+	if target.VisualPrompt != "old woman, grey bun" || fmt.Sprint(target.Colors) != "[蓝 灰]" {
+		t.Errorf("Expected empty sheet fields to be filled from the merged character, got %+v", target.CharacterSheet)
+	}
+	if characters[0].Aliases != nil {
+		t.Errorf("Merge should not modify its input, got %+v", characters[0])
+	}
+
+	scenes := []models.Scene{
+		{Characters: []string{"姥姥", "王熙凤", "刘氏"}},
+		{Characters: []string{"王熙凤"}},
+		{Characters: []string{"刘姥姥", "老刘"}},
+	}
+	if changed := RenameInScenes(scenes, names, target.Name); changed != 2 {
+		t.Errorf("Expected 2 scenes to change, got %d", changed)
+	}
+	if fmt.Sprint(scenes[0].Characters) != "[刘姥姥 王熙凤]" || fmt.Sprint(scenes[2].Characters) != "[刘姥姥]" {
+		t.Errorf("Unexpected scenes %+v", scenes)
+	}
+
+	if _, _, _, err := Merge(characters, "a", []string{"a"}); !errors.Is(err, ErrMergeTarget) {
+		t.Errorf("Expected ErrMergeTarget, got %v", err)
+	}
+	if _, _, _, err := Merge(characters, "a", nil); !errors.Is(err, ErrMergeSources) {
+		t.Errorf("Expected ErrMergeSources, got %v", err)
+	}
+}
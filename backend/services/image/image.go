@@ -7,7 +7,6 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
-	"io"
 	"log"
 	"net"
 	"net/http"
@@ -18,42 +17,57 @@ import (
 	"time"
 
 	"taco/backend/models"
+	"taco/backend/services/retry"
 	"taco/backend/utils"
 )
 
 var imageURLPattern = regexp.MustCompile(`https?://[^\s)]+`)
 
-func GenerateCharacterImage(ctx context.Context, cfg models.Config, character models.CharacterProfile, index int) (string, error) {
-	if err := utils.EnsureDir(utils.GeneratedImagesDir); err != nil {
-		return "", err
-	}
+// imageResult 是接口返回的图片（URL 或 base64），以及本次请求使用的提示词和模型。
+type imageResult struct {
+	ref    string
+	prompt string
+	model  string
+}
 
-	imageRef, err := requestCharacterImage(ctx, cfg, character)
-	if err != nil {
-		return "", err
+// saveImageVariant 把生成结果保存为新文件并返回对应的素材版本，已有的图片不会被删除。
+func saveImageVariant(ctx context.Context, assets utils.AssetDirs, filename string, result imageResult) (models.AssetVariant, error) {
+	absPath := filepath.Join(assets.ImagesDir, filename)
+	lower := strings.ToLower(result.ref)
+	if strings.HasPrefix(lower, "http://") || strings.HasPrefix(lower, "https://") {
+		if err := utils.DownloadToFile(ctx, result.ref, absPath); err != nil {
+			return models.AssetVariant{}, err
+		}
+	} else {
+		if err := utils.SaveBase64ToFile(result.ref, absPath); err != nil {
+			return models.AssetVariant{}, err
+		}
 	}
 
-	filename := fmt.Sprintf("character_%02d_%d.png", index+1, time.Now().Unix())
-	absPath := filepath.Join(utils.GeneratedImagesDir, filename)
+	return models.AssetVariant{
+		ID:        utils.NewID(models.VariantIDPrefix),
+		Path:      assets.ImageURL(filename),
+		Source:    models.VariantGenerated,
+		Prompt:    result.prompt,
+		Model:     result.model,
+		CreatedAt: time.Now(),
+	}, nil
+}
 
-	if character.ImagePath != "" {
-		RemoveGeneratedImage(character.ImagePath)
+func GenerateCharacterImage(ctx context.Context, cfg models.Config, assets utils.AssetDirs, character models.CharacterProfile) (models.AssetVariant, error) {
+	if err := utils.EnsureDir(assets.ImagesDir); err != nil {
+		return models.AssetVariant{}, err
 	}
 
-	if strings.HasPrefix(strings.ToLower(imageRef), "http://") || strings.HasPrefix(strings.ToLower(imageRef), "https://") {
-		if err := utils.DownloadToFile(ctx, imageRef, absPath); err != nil {
-			return "", err
-		}
-	} else {
-		if err := utils.SaveBase64ToFile(imageRef, absPath); err != nil {
-			return "", err
-		}
+	result, err := requestCharacterImage(ctx, cfg, character)
+	if err != nil {
+		return models.AssetVariant{}, err
 	}
 
-	return utils.GeneratedImagesURLPrefix + filename, nil
+	return saveImageVariant(ctx, assets, assetFilename("character", character.ID, ".png"), result)
 }
 
-func requestCharacterImage(ctx context.Context, cfg models.Config, character models.CharacterProfile) (string, error) {
+func requestCharacterImage(ctx context.Context, cfg models.Config, character models.CharacterProfile) (imageResult, error) {
 	imageCfg := cfg.Image
 	if strings.TrimSpace(imageCfg.Model) == "" {
 		imageCfg.Model = "gpt-4o-image"
@@ -66,38 +80,29 @@ func requestCharacterImage(ctx context.Context, cfg models.Config, character mod
 	}
 
 	if strings.TrimSpace(imageCfg.Model) == "" {
-		return "", errors.New("未配置图像模型")
+		return imageResult{}, errors.New("未配置图像模型")
 	}
 	if strings.TrimSpace(imageCfg.BaseURL) == "" {
-		return "", errors.New("未配置图像接口地址")
+		return imageResult{}, errors.New("未配置图像接口地址")
 	}
 	if strings.TrimSpace(imageCfg.APIKey) == "" {
-		return "", errors.New("未配置图像 API Key")
+		return imageResult{}, errors.New("未配置图像 API Key")
 	}
 
 	base := strings.TrimRight(imageCfg.BaseURL, "/")
 	if base == "" {
-		return "", errors.New("图像接口地址无效")
+		return imageResult{}, errors.New("图像接口地址无效")
 	}
 
-	promptBuilder := strings.Builder{}
-	if styleDesc := strings.TrimSpace(cfg.AnimeStyle); styleDesc != "" {
-		promptBuilder.WriteString("以")
-		promptBuilder.WriteString(styleDesc)
-		promptBuilder.WriteString("绘制角色立绘，要求：")
-	} else {
-		promptBuilder.WriteString("以高质量动漫风格绘制角色立绘，要求：")
+	prompt, err := CharacterImagePrompt(cfg, character)
+	if err != nil {
+		return imageResult{}, err
 	}
-	promptBuilder.WriteString("角色名称：")
-	promptBuilder.WriteString(character.Name)
-	promptBuilder.WriteString("。角色特征描述：")
-	promptBuilder.WriteString(character.Description)
-	promptBuilder.WriteString("。画面需呈现明显的动漫风格、清晰的角色特征、柔和光效与细腻线条，适合作为角色头像或立绘使用。")
 
 	messages := []map[string]any{
 		{
 			"role":    "user",
-			"content": promptBuilder.String(),
+			"content": prompt,
 		},
 	}
 
@@ -116,69 +121,35 @@ func requestCharacterImage(ctx context.Context, cfg models.Config, character mod
 
 	bodyBytes, err := json.Marshal(reqBody)
 	if err != nil {
-		return "", err
+		return imageResult{}, err
 	}
 
 	log.Printf("[图像 API] 请求体大小: %d 字节", len(bodyBytes))
 
-	maxRetries := 3
-	var lastErr error
-
-	for attempt := 1; attempt <= maxRetries; attempt++ {
-		if attempt > 1 {
-			waitTime := time.Duration(attempt-1) * 2 * time.Second
-			log.Printf("[图像 API] 第 %d 次重试，等待 %v...", attempt, waitTime)
-			time.Sleep(waitTime)
-		}
-
-		log.Printf("[图像 API] 发起请求 (尝试 %d/%d): %s/v1/chat/completions", attempt, maxRetries, base)
-
-		result, err := doImageRequest(ctx, base, imageCfg.APIKey, bodyBytes)
-		if err == nil {
-			if attempt > 1 {
-				log.Printf("[图像 API] 重试成功！")
-			}
-			return result, nil
-		}
-
-		lastErr = err
-		log.Printf("[图像 API] 尝试 %d/%d 失败: %v", attempt, maxRetries, err)
+	result, err := retry.Do(ctx, retry.FromConfig(cfg.Retry), "图像 API", func() (string, error) {
+		log.Printf("[图像 API] 发起请求: %s/v1/chat/completions", base)
+		return doImageRequest(ctx, base, imageCfg.APIKey, bodyBytes)
+	})
+	if err != nil {
+		return imageResult{}, err
 	}
-
-	return "", fmt.Errorf("重试 %d 次后仍然失败: %w", maxRetries, lastErr)
+	return imageResult{ref: result, prompt: prompt, model: imageCfg.Model}, nil
 }
 
-func GenerateSceneImage(ctx context.Context, cfg models.Config, scene models.Scene, index int) (string, error) {
-	if err := utils.EnsureDir(utils.GeneratedImagesDir); err != nil {
-		return "", err
+func GenerateSceneImage(ctx context.Context, cfg models.Config, assets utils.AssetDirs, scene models.Scene, characters []models.CharacterProfile) (models.AssetVariant, error) {
+	if err := utils.EnsureDir(assets.ImagesDir); err != nil {
+		return models.AssetVariant{}, err
 	}
 
-	imageRef, err := requestSceneImage(ctx, cfg, scene)
+	result, err := requestSceneImage(ctx, cfg, scene, characters)
 	if err != nil {
-		return "", err
-	}
-
-	filename := fmt.Sprintf("scene_%02d_%d.png", index+1, time.Now().Unix())
-	absPath := filepath.Join(utils.GeneratedImagesDir, filename)
-
-	if scene.ImagePath != "" {
-		RemoveGeneratedImage(scene.ImagePath)
-	}
-
-	if strings.HasPrefix(strings.ToLower(imageRef), "http://") || strings.HasPrefix(strings.ToLower(imageRef), "https://") {
-		if err := utils.DownloadToFile(ctx, imageRef, absPath); err != nil {
-			return "", err
-		}
-	} else {
-		if err := utils.SaveBase64ToFile(imageRef, absPath); err != nil {
-			return "", err
-		}
+		return models.AssetVariant{}, err
 	}
 
-	return utils.GeneratedImagesURLPrefix + filename, nil
+	return saveImageVariant(ctx, assets, assetFilename("scene", scene.ID, ".png"), result)
 }
 
-func requestSceneImage(ctx context.Context, cfg models.Config, scene models.Scene) (string, error) {
+func requestSceneImage(ctx context.Context, cfg models.Config, scene models.Scene, characters []models.CharacterProfile) (imageResult, error) {
 	imageCfg := cfg.Image
 	if strings.TrimSpace(imageCfg.Model) == "" {
 		imageCfg.Model = "gpt-4o-image"
@@ -191,50 +162,29 @@ func requestSceneImage(ctx context.Context, cfg models.Config, scene models.Scen
 	}
 
 	if strings.TrimSpace(imageCfg.Model) == "" {
-		return "", errors.New("未配置图像模型")
+		return imageResult{}, errors.New("未配置图像模型")
 	}
 	if strings.TrimSpace(imageCfg.BaseURL) == "" {
-		return "", errors.New("未配置图像接口地址")
+		return imageResult{}, errors.New("未配置图像接口地址")
 	}
 	if strings.TrimSpace(imageCfg.APIKey) == "" {
-		return "", errors.New("未配置图像 API Key")
+		return imageResult{}, errors.New("未配置图像 API Key")
 	}
 
 	base := strings.TrimRight(imageCfg.BaseURL, "/")
 	if base == "" {
-		return "", errors.New("图像接口地址无效")
+		return imageResult{}, errors.New("图像接口地址无效")
 	}
 
-	characterLine := strings.Join(scene.Characters, "、")
-	dialogueSnippet := ""
-	if len(scene.Dialogues) > 0 {
-		dialogueSnippet = strings.Join(scene.Dialogues, " ")
-	}
-
-	promptBuilder := strings.Builder{}
-	if styleDesc := strings.TrimSpace(cfg.AnimeStyle); styleDesc != "" {
-		promptBuilder.WriteString("以")
-		promptBuilder.WriteString(styleDesc)
-		promptBuilder.WriteString("绘制以下场景，强调电影级光影、鲜明色彩与角色表情。")
-	} else {
-		promptBuilder.WriteString("以高质量动漫风格绘制以下场景，强调电影级光影、鲜明色彩与角色表情。")
-	}
-	promptBuilder.WriteString("场景描述：")
-	promptBuilder.WriteString(scene.Description)
-	if characterLine != "" {
-		promptBuilder.WriteString("。出场角色：")
-		promptBuilder.WriteString(characterLine)
-	}
-	if dialogueSnippet != "" {
-		promptBuilder.WriteString("。对话氛围参考：")
-		promptBuilder.WriteString(dialogueSnippet)
+	prompt, err := SceneImagePrompt(cfg, scene, characters)
+	if err != nil {
+		return imageResult{}, err
 	}
-	promptBuilder.WriteString("。画面需呈现明显的动漫风格、柔和光效与细腻线条。")
 
 	messages := []map[string]any{
 		{
 			"role":    "user",
-			"content": promptBuilder.String(),
+			"content": prompt,
 		},
 	}
 
@@ -253,69 +203,35 @@ func requestSceneImage(ctx context.Context, cfg models.Config, scene models.Scen
 
 	bodyBytes, err := json.Marshal(reqBody)
 	if err != nil {
-		return "", err
+		return imageResult{}, err
 	}
 
 	log.Printf("[图像 API] 请求体大小: %d 字节", len(bodyBytes))
 
-	maxRetries := 3
-	var lastErr error
-
-	for attempt := 1; attempt <= maxRetries; attempt++ {
-		if attempt > 1 {
-			waitTime := time.Duration(attempt-1) * 2 * time.Second
-			log.Printf("[图像 API] 第 %d 次重试，等待 %v...", attempt, waitTime)
-			time.Sleep(waitTime)
-		}
-
-		log.Printf("[图像 API] 发起请求 (尝试 %d/%d): %s/v1/chat/completions", attempt, maxRetries, base)
-
-		result, err := doImageRequest(ctx, base, imageCfg.APIKey, bodyBytes)
-		if err == nil {
-			if attempt > 1 {
-				log.Printf("[图像 API] 重试成功！")
-			}
-			return result, nil
-		}
-
-		lastErr = err
-		log.Printf("[图像 API] 尝试 %d/%d 失败: %v", attempt, maxRetries, err)
+	result, err := retry.Do(ctx, retry.FromConfig(cfg.Retry), "图像 API", func() (string, error) {
+		log.Printf("[图像 API] 发起请求: %s/v1/chat/completions", base)
+		return doImageRequest(ctx, base, imageCfg.APIKey, bodyBytes)
+	})
+	if err != nil {
+		return imageResult{}, err
 	}
-
-	return "", fmt.Errorf("重试 %d 次后仍然失败: %w", maxRetries, lastErr)
+	return imageResult{ref: result, prompt: prompt, model: imageCfg.Model}, nil
 }
 
-func GenerateSceneImageWithCharacters(ctx context.Context, cfg models.Config, scene models.Scene, characters []models.CharacterProfile, index int) (string, error) {
-	if err := utils.EnsureDir(utils.GeneratedImagesDir); err != nil {
-		return "", err
+func GenerateSceneImageWithCharacters(ctx context.Context, cfg models.Config, assets utils.AssetDirs, scene models.Scene, characters []models.CharacterProfile) (models.AssetVariant, error) {
+	if err := utils.EnsureDir(assets.ImagesDir); err != nil {
+		return models.AssetVariant{}, err
 	}
 
-	imageRef, err := requestSceneImageWithCharacters(ctx, cfg, scene, characters)
+	result, err := requestSceneImageWithCharacters(ctx, cfg, assets, scene, characters)
 	if err != nil {
-		return "", err
-	}
-
-	filename := fmt.Sprintf("scene_%02d_%d.png", index+1, time.Now().Unix())
-	absPath := filepath.Join(utils.GeneratedImagesDir, filename)
-
-	if scene.ImagePath != "" {
-		RemoveGeneratedImage(scene.ImagePath)
-	}
-
-	if strings.HasPrefix(strings.ToLower(imageRef), "http://") || strings.HasPrefix(strings.ToLower(imageRef), "https://") {
-		if err := utils.DownloadToFile(ctx, imageRef, absPath); err != nil {
-			return "", err
-		}
-	} else {
-		if err := utils.SaveBase64ToFile(imageRef, absPath); err != nil {
-			return "", err
-		}
+		return models.AssetVariant{}, err
 	}
 
-	return utils.GeneratedImagesURLPrefix + filename, nil
+	return saveImageVariant(ctx, assets, assetFilename("scene", scene.ID, ".png"), result)
 }
 
-func requestSceneImageWithCharacters(ctx context.Context, cfg models.Config, scene models.Scene, allCharacters []models.CharacterProfile) (string, error) {
+func requestSceneImageWithCharacters(ctx context.Context, cfg models.Config, assets utils.AssetDirs, scene models.Scene, allCharacters []models.CharacterProfile) (imageResult, error) {
 	imageEditCfg := cfg.ImageEdit
 	if strings.TrimSpace(imageEditCfg.Model) == "" {
 		imageEditCfg.Model = "qwen-image-edit"
@@ -328,104 +244,58 @@ func requestSceneImageWithCharacters(ctx context.Context, cfg models.Config, sce
 	}
 
 	if strings.TrimSpace(imageEditCfg.Model) == "" {
-		return "", errors.New("未配置图像编辑模型")
+		return imageResult{}, errors.New("未配置图像编辑模型")
 	}
 	if strings.TrimSpace(imageEditCfg.BaseURL) == "" {
-		return "", errors.New("未配置图像编辑接口地址")
+		return imageResult{}, errors.New("未配置图像编辑接口地址")
 	}
 	if strings.TrimSpace(imageEditCfg.APIKey) == "" {
-		return "", errors.New("未配置图像编辑 API Key")
+		return imageResult{}, errors.New("未配置图像编辑 API Key")
 	}
 
 	base := strings.TrimRight(imageEditCfg.BaseURL, "/")
 	if base == "" {
-		return "", errors.New("图像编辑接口地址无效")
-	}
-
-	characterImages := make(map[string]string)
-	characterNames := []string{}
-	for _, charName := range scene.Characters {
-		for _, char := range allCharacters {
-			if char.Name == charName && char.ImagePath != "" {
-				var imagePath string
-				if strings.HasPrefix(char.ImagePath, utils.GeneratedImagesURLPrefix) {
-					filename := strings.TrimPrefix(char.ImagePath, utils.GeneratedImagesURLPrefix)
-					imagePath = filepath.Join(utils.GeneratedImagesDir, filename)
-				} else if strings.HasPrefix(char.ImagePath, "/") {
-					imagePath = char.ImagePath
-				} else {
-					imagePath = filepath.Join(utils.GeneratedImagesDir, filepath.Base(char.ImagePath))
-				}
-
-				log.Printf("[INFO] 读取角色 %s 的图片: %s", charName, imagePath)
-				imageData, err := os.ReadFile(imagePath)
-				if err != nil {
-					log.Printf("[WARNING] 无法读取角色 %s 的图片: %v (路径: %s)", charName, err, imagePath)
-					continue
-				}
-				log.Printf("[INFO] 成功读取角色 %s 的图片，大小: %d 字节", charName, len(imageData))
-				base64Image := base64.StdEncoding.EncodeToString(imageData)
-
-				// 检测图片格式
-				ext := strings.ToLower(filepath.Ext(imagePath))
-				mimeType := "image/png"
-				if ext == ".jpg" || ext == ".jpeg" {
-					mimeType = "image/jpeg"
-				} else if ext == ".webp" {
-					mimeType = "image/webp"
-				}
-
-				characterImages[charName] = fmt.Sprintf("data:%s;base64,%s", mimeType, base64Image)
-				characterNames = append(characterNames, charName)
-				break
-			}
-		}
+		return imageResult{}, errors.New("图像编辑接口地址无效")
 	}
 
-	log.Printf("[INFO] 成功加载 %d 个角色的图片", len(characterImages))
-
-	// 构建千问API格式的content数组
 	contentArray := []map[string]any{}
-	textBuilder := strings.Builder{}
-
-	// 添加角色图片到content数组（按顺序）
-	if len(characterImages) > 0 {
-		for imageIndex, charName := range characterNames {
-			contentArray = append(contentArray, map[string]any{
-				"image": characterImages[charName],
-			})
-			textBuilder.WriteString(fmt.Sprintf("图%d中的人物是%s。", imageIndex+1, charName))
+	references := []string{}
+	for _, char := range ReferenceCharacters(scene, allCharacters) {
+		imagePath := assets.ResolveImage(char.ImagePath)
+
+		log.Printf("[INFO] 读取角色 %s 的图片: %s", char.Name, imagePath)
+		imageData, err := os.ReadFile(imagePath)
+		if err != nil {
+			log.Printf("[WARNING] 无法读取角色 %s 的图片: %v (路径: %s)", char.Name, err, imagePath)
+			continue
+		}
+		log.Printf("[INFO] 成功读取角色 %s 的图片，大小: %d 字节", char.Name, len(imageData))
+		base64Image := base64.StdEncoding.EncodeToString(imageData)
+
+		// 检测图片格式
+		ext := strings.ToLower(filepath.Ext(imagePath))
+		mimeType := "image/png"
+		if ext == ".jpg" || ext == ".jpeg" {
+			mimeType = "image/jpeg"
+		} else if ext == ".webp" {
+			mimeType = "image/webp"
 		}
-	}
 
-	// 构建编辑指令文本
-	textBuilder.WriteString("请")
-	if styleDesc := strings.TrimSpace(cfg.AnimeStyle); styleDesc != "" {
-		textBuilder.WriteString("以")
-		textBuilder.WriteString(styleDesc)
-	} else {
-		textBuilder.WriteString("以高质量动漫风格")
+		// 千问API格式的content数组，角色图片按顺序排在编辑指令之前
+		contentArray = append(contentArray, map[string]any{
+			"image": fmt.Sprintf("data:%s;base64,%s", mimeType, base64Image),
+		})
+		references = append(references, char.Name)
 	}
-	textBuilder.WriteString("绘制以下场景。场景描述：")
-	textBuilder.WriteString(scene.Description)
 
-	characterLine := strings.Join(scene.Characters, "、")
-	if characterLine != "" {
-		textBuilder.WriteString("。出场角色：")
-		textBuilder.WriteString(characterLine)
-	}
+	log.Printf("[INFO] 成功加载 %d 个角色的图片", len(references))
 
-	if len(scene.Dialogues) > 0 {
-		dialogueSnippet := strings.Join(scene.Dialogues, " ")
-		textBuilder.WriteString("。对话氛围参考：")
-		textBuilder.WriteString(dialogueSnippet)
+	prompt, err := SceneImageWithCharactersPrompt(cfg, scene, allCharacters, references)
+	if err != nil {
+		return imageResult{}, err
 	}
-
-	textBuilder.WriteString("。要求画面呈现明显的动漫风格、电影级光影、鲜明色彩、角色表情生动、柔和光效与细腻线条。")
-
-	// 添加文本指令到content数组
 	contentArray = append(contentArray, map[string]any{
-		"text": textBuilder.String(),
+		"text": prompt,
 	})
 
 	// 构建千问API格式的请求体
@@ -446,7 +316,7 @@ func requestSceneImageWithCharacters(ctx context.Context, cfg models.Config, sce
 
 	bodyBytes, err := json.Marshal(reqBody)
 	if err != nil {
-		return "", err
+		return imageResult{}, err
 	}
 
 	log.Printf("[图像编辑 API] 请求体大小: %d 字节", len(bodyBytes))
@@ -456,31 +326,14 @@ func requestSceneImageWithCharacters(ctx context.Context, cfg models.Config, sce
 		log.Printf("[图像编辑 API] 请求体（前1000字符）: %s...", string(bodyBytes[:1000]))
 	}
 
-	maxRetries := 3
-	var lastErr error
-
-	for attempt := 1; attempt <= maxRetries; attempt++ {
-		if attempt > 1 {
-			waitTime := time.Duration(attempt-1) * 2 * time.Second
-			log.Printf("[图像编辑 API] 第 %d 次重试，等待 %v...", attempt, waitTime)
-			time.Sleep(waitTime)
-		}
-
-		log.Printf("[图像编辑 API] 发起请求 (尝试 %d/%d): %s/v1/chat/completions", attempt, maxRetries, base)
-
-		result, err := doImageEditRequest(ctx, base, imageEditCfg.APIKey, bodyBytes)
-		if err == nil {
-			if attempt > 1 {
-				log.Printf("[图像编辑 API] 重试成功！")
-			}
-			return result, nil
-		}
-
-		lastErr = err
-		log.Printf("[图像编辑 API] 尝试 %d/%d 失败: %v", attempt, maxRetries, err)
+	result, err := retry.Do(ctx, retry.FromConfig(cfg.Retry), "图像编辑 API", func() (string, error) {
+		log.Printf("[图像编辑 API] 发起请求: %s/api/v1/services/aigc/multimodal-generation/generation", base)
+		return doImageEditRequest(ctx, base, imageEditCfg.APIKey, bodyBytes)
+	})
+	if err != nil {
+		return imageResult{}, err
 	}
-
-	return "", fmt.Errorf("重试 %d 次后仍然失败: %w", maxRetries, lastErr)
+	return imageResult{ref: result, prompt: prompt, model: imageEditCfg.Model}, nil
 }
 
 func doImageRequest(ctx context.Context, baseURL, apiKey string, bodyBytes []byte) (string, error) {
@@ -523,9 +376,9 @@ func doImageRequest(ctx context.Context, baseURL, apiKey string, bodyBytes []byt
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		errBody, _ := io.ReadAll(resp.Body)
-		log.Printf("[图像 API] 错误响应 (状态码 %d): %s", resp.StatusCode, strings.TrimSpace(string(errBody)))
-		return "", fmt.Errorf("图像服务请求失败 (状态码 %d): %s", resp.StatusCode, strings.TrimSpace(string(errBody)))
+		httpErr := retry.NewHTTPError(resp, "图像服务请求失败")
+		log.Printf("[图像 API] 错误响应 (状态码 %d): %s", resp.StatusCode, httpErr.Body)
+		return "", httpErr
 	}
 
 	var completion struct {
@@ -593,9 +446,9 @@ func doImageEditRequest(ctx context.Context, baseURL, apiKey string, bodyBytes [
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		errBody, _ := io.ReadAll(resp.Body)
-		log.Printf("[图像编辑 API] 错误响应 (状态码 %d): %s", resp.StatusCode, strings.TrimSpace(string(errBody)))
-		return "", fmt.Errorf("图像编辑服务请求失败 (状态码 %d): %s", resp.StatusCode, strings.TrimSpace(string(errBody)))
+		httpErr := retry.NewHTTPError(resp, "图像编辑服务请求失败")
+		log.Printf("[图像编辑 API] 错误响应 (状态码 %d): %s", resp.StatusCode, httpErr.Body)
+		return "", httpErr
 	}
 
 	var response map[string]any
@@ -646,6 +499,14 @@ func doImageEditRequest(ctx context.Context, baseURL, apiKey string, bodyBytes [
 	return "", errors.New("图像编辑服务未返回有效的图片URL")
 }
 
+// assetFilename 以角色或场景 ID 命名生成文件，保证重排或删除其他条目后文件仍能对应到原条目。
+func assetFilename(kind, id, ext string) string {
+	if id == "" {
+		return fmt.Sprintf("%s_%d%s", kind, time.Now().UnixNano(), ext)
+	}
+	return fmt.Sprintf("%s_%s_%d%s", kind, id, time.Now().UnixNano(), ext)
+}
+
 func extractImageURL(content string) (string, error) {
 	matches := imageURLPattern.FindAllString(content, -1)
 	if len(matches) == 0 {
@@ -661,7 +522,3 @@ func extractImageURL(content string) (string, error) {
 	clean := strings.Trim(matches[len(matches)-1], "[]()<>\"'`.,")
 	return clean, nil
 }
-
-func RemoveGeneratedImage(relPath string) {
-	utils.RemoveGeneratedFile(relPath, utils.GeneratedImagesURLPrefix, utils.GeneratedImagesDir)
-}
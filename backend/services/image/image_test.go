@@ -5,8 +5,7 @@ import (
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
-	"os"
-	"path/filepath"
+	"strings"
 	"testing"
 
 	"taco/backend/models"
@@ -55,14 +54,18 @@ func TestGenerateCharacterImageSuccess(t *testing.T) {
 	}
 
 	ctx := context.Background()
-	imagePath, err := GenerateCharacterImage(ctx, cfg, character, 0)
+	variant, err := GenerateCharacterImage(ctx, cfg, utils.DefaultAssetDirs(), character)
 	if err != nil {
 		t.Fatalf("GenerateCharacterImage failed: %v", err)
 	}
 
-	if imagePath == "" {
+	if variant.Path == "" {
 		t.Error("Expected non-empty image path")
 	}
+
+	if variant.ID == "" || !strings.Contains(variant.Prompt, "角色描述") || variant.Model == "" {
+		t.Errorf("Expected variant metadata, got %+v", variant)
+	}
 }
 
 func TestGenerateCharacterImageMissingConfig(t *testing.T) {
@@ -127,15 +130,22 @@ func TestGenerateSceneImageSuccess(t *testing.T) {
 		Dialogues:   []string{"对话1"},
 	}
 
+	characters := []models.CharacterProfile{
+		{Name: "角色一", Aliases: []string{"角色1"}, CharacterSheet: models.CharacterSheet{VisualPrompt: "young man, short silver hair, red scarf"}},
+	}
+
 	ctx := context.Background()
-	imagePath, err := GenerateSceneImage(ctx, cfg, scene, 0)
+	variant, err := GenerateSceneImage(ctx, cfg, utils.DefaultAssetDirs(), scene, characters)
 	if err != nil {
 		t.Fatalf("GenerateSceneImage failed: %v", err)
 	}
 
-	if imagePath == "" {
+	if variant.Path == "" {
 		t.Error("Expected non-empty image path")
 	}
+	if !strings.Contains(variant.Prompt, "角色一的外观：young man, short silver hair, red scarf") {
+		t.Errorf("Expected the visual prompt verbatim, got %q", variant.Prompt)
+	}
 }
 
 func TestExtractImageURL(t *testing.T) {
@@ -251,25 +261,6 @@ func TestDoImageRequestNoChoices(t *testing.T) {
 	}
 }
 
-func TestRemoveGeneratedImage(t *testing.T) {
-	tmpDir := t.TempDir()
-	originalImagesDir := utils.GeneratedImagesDir
-	utils.GeneratedImagesDir = tmpDir
-	defer func() { utils.GeneratedImagesDir = originalImagesDir }()
-
-	testFile := filepath.Join(tmpDir, "test.png")
-	err := os.WriteFile(testFile, []byte("test"), 0o644)
-	if err != nil {
-		t.Fatalf("Failed to create test file: %v", err)
-	}
-
-	RemoveGeneratedImage("/generated/images/test.png")
-
-	if _, err := os.Stat(testFile); !os.IsNotExist(err) {
-		t.Error("File should have been deleted")
-	}
-}
-
 func TestDoImageEditRequestSuccess(t *testing.T) {
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		response := map[string]any{
@@ -321,3 +312,16 @@ func TestDoImageEditRequestNoImage(t *testing.T) {
 		t.Error("Expected error for missing image in response")
 	}
 }
+
+func TestReferenceCharactersMatchesAliases(t *testing.T) {
+	all := []models.CharacterProfile{
+		{Name: "刘姥姥", Aliases: []string{"姥姥"}, ImagePath: "a.png"},
+		{Name: "王熙凤"},
+		{Name: "贾宝玉", ImagePath: "b.png"},
+	}
+	scene := models.Scene{Characters: []string{"贾宝玉", "姥姥", "王熙凤", "刘姥姥"}}
+	references := ReferenceCharacters(scene, all)
+	if len(references) != 2 || references[0].Name != "贾宝玉" || references[1].Name != "刘姥姥" {
+		t.Errorf("Expected alias-matched references without duplicates, got %+v", references)
+	}
+}
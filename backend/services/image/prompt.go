@@ -0,0 +1,61 @@
+package image
+
+import (
+	"strings"
+
+	"taco/backend/models"
+	"taco/backend/services/cast"
+	"taco/backend/services/prompts"
+)
+
+// CharacterImagePrompt 渲染生成角色立绘的提示词。
+func CharacterImagePrompt(cfg models.Config, character models.CharacterProfile) (string, error) {
+	return prompts.Render(cfg.Prompts, prompts.CharacterImage, prompts.CharacterImageData{
+		Style:     strings.TrimSpace(cfg.AnimeStyle),
+		Character: character,
+	})
+}
+
+// SceneImagePrompt 渲染生成场景图的提示词，characters 为项目的全部角色。
+func SceneImagePrompt(cfg models.Config, scene models.Scene, characters []models.CharacterProfile) (string, error) {
+	return prompts.Render(cfg.Prompts, prompts.SceneImage, prompts.SceneImageData{
+		Style:      strings.TrimSpace(cfg.AnimeStyle),
+		Scene:      scene,
+		Characters: SceneCharacters(scene, characters),
+	})
+}
+
+// SceneImageWithCharactersPrompt 渲染参考角色立绘编辑场景图的提示词，references 为参考图对应的角色名称。
+func SceneImageWithCharactersPrompt(cfg models.Config, scene models.Scene, characters []models.CharacterProfile, references []string) (string, error) {
+	return prompts.Render(cfg.Prompts, prompts.SceneImageWithCharacters, prompts.SceneImageWithCharactersData{
+		Style:      strings.TrimSpace(cfg.AnimeStyle),
+		Scene:      scene,
+		Characters: SceneCharacters(scene, characters),
+		References: references,
+	})
+}
+
+// SceneCharacters 按场景中的出场顺序返回匹配到的角色。出场人物按名称或别名匹配，同一角色只返回一次。
+func SceneCharacters(scene models.Scene, all []models.CharacterProfile) []models.CharacterProfile {
+	matched := []models.CharacterProfile{}
+	index := cast.NewIndex(all)
+	used := map[int]bool{}
+	for _, name := range scene.Characters {
+		if idx, ok := index.Find(name); ok && !used[idx] {
+			used[idx] = true
+			matched = append(matched, all[idx])
+		}
+	}
+	return matched
+}
+
+// ReferenceCharacters 返回 SceneCharacters 中有立绘的角色，作为编辑场景图时的参考图。
+func ReferenceCharacters(scene models.Scene, all []models.CharacterProfile) []models.CharacterProfile {
+	references := []models.CharacterProfile{}
+	for _, character := range SceneCharacters(scene, all) {
+		if character.ImagePath != "" {
+			references = append(references, character)
+		}
+	}
+	return references
+}
@@ -0,0 +1,132 @@
+package llm
+
+import (
+	"strings"
+	"unicode"
+
+	"taco/backend/models"
+)
+
+// DefaultChunkTokens 是未配置 llm.chunkTokens 时每段小说的 token 上限，
+// 为提示词和模型输出预留了足够的上下文空间。
+const DefaultChunkTokens = 6000
+
+// EstimateTokens 粗略估算文本的 token 数：汉字、假名、谚文按每字 1 个计算，
+// 其他字符按每 4 个 1 个计算。只用于切分，不需要与具体模型的分词器完全一致。
+func EstimateTokens(text string) int {
+	cjk, other := 0, 0
+	for _, r := range text {
+		if isCJK(r) {
+			cjk++
+		} else {
+			other++
+		}
+	}
+	return cjk + (other+3)/4
+}
+
+func isCJK(r rune) bool {
+	return unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul)
+}
+
+// SplitNovel 按段落把小说切成不超过 maxTokens 的若干段，保持原文顺序。
+// 超长的段落再按句子切分，超长的句子最后按字符硬切。
+func SplitNovel(text string, maxTokens int) []string {
+	if maxTokens <= 0 {
+		maxTokens = DefaultChunkTokens
+	}
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+
+	var chunks []string
+	var current strings.Builder
+	currentTokens := 0
+	flush := func() {
+		if chunk := strings.TrimSpace(current.String()); chunk != "" {
+			chunks = append(chunks, chunk)
+		}
+		current.Reset()
+		currentTokens = 0
+	}
+	add := func(piece string) {
+		tokens := EstimateTokens(piece)
+		if currentTokens > 0 && currentTokens+tokens > maxTokens {
+			flush()
+		}
+		current.WriteString(piece)
+		currentTokens += tokens
+	}
+
+	for _, paragraph := range strings.SplitAfter(text, "\n") {
+		if EstimateTokens(paragraph) <= maxTokens {
+			add(paragraph)
+			continue
+		}
+		for _, sentence := range splitSentences(paragraph) {
+			if EstimateTokens(sentence) <= maxTokens {
+				add(sentence)
+				continue
+			}
+			for _, piece := range splitRunes(sentence, maxTokens) {
+				add(piece)
+			}
+		}
+	}
+	flush()
+	return chunks
+}
+
+// splitSentences 在句末标点之后切分，标点保留在前一句中。
+func splitSentences(text string) []string {
+	var sentences []string
+	start := 0
+	for i, r := range text {
+		if strings.ContainsRune("。！？!?；;…", r) || (r == '.' && i+1 < len(text) && text[i+1] == ' ') {
+			end := i + len(string(r))
+			sentences = append(sentences, text[start:end])
+			start = end
+		}
+	}
+	if start < len(text) {
+		sentences = append(sentences, text[start:])
+	}
+	return sentences
+}
+
+func splitRunes(text string, maxTokens int) []string {
+	var pieces []string
+	var current strings.Builder
+	cjk, other := 0, 0
+	for _, r := range text {
+		current.WriteRune(r)
+		if isCJK(r) {
+			cjk++
+		} else {
+			other++
+		}
+		if cjk+(other+3)/4 >= maxTokens {
+			pieces = append(pieces, current.String())
+			current.Reset()
+			cjk, other = 0, 0
+		}
+	}
+	if current.Len() > 0 {
+		pieces = append(pieces, current.String())
+	}
+	return pieces
+}
+
+// splitByChapters 在每个章节内部分段，段落不会跨越章节；chapters 为空时整本小说一起分段。
+// owners[i] 是第 i 段所属章节的序号，未分章时为 0。
+func splitByChapters(novel string, chapters []models.Chapter, maxTokens int) (chunks []string, owners []int) {
+	if len(chapters) == 0 {
+		chunks = SplitNovel(novel, maxTokens)
+		return chunks, make([]int, len(chunks))
+	}
+	for _, chapter := range chapters {
+		for _, chunk := range SplitNovel(novel[chapter.Start:chapter.End], maxTokens) {
+			chunks = append(chunks, chunk)
+			owners = append(owners, chapter.Index)
+		}
+	}
+	return chunks, owners
+}
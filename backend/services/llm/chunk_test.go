@@ -0,0 +1,81 @@
+package llm
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestEstimateTokens(t *testing.T) {
+	if got := EstimateTokens("你好世界"); got != 4 {
+		t.Errorf("Expected 4 tokens for four Han characters, got %d", got)
+	}
+	if got := EstimateTokens("abcdefgh"); got != 2 {
+		t.Errorf("Expected 2 tokens for eight ASCII characters, got %d", got)
+	}
+	if got := EstimateTokens(""); got != 0 {
+		t.Errorf("Expected 0 tokens for empty text, got %d", got)
+	}
+}
+
+func TestSplitNovelKeepsParagraphsAndOrder(t *testing.T) {
+	paragraphs := []string{
+		strings.Repeat("甲", 40),
+		strings.Repeat("乙", 40),
+		strings.Repeat("丙", 40),
+	}
+	chunks := SplitNovel(strings.Join(paragraphs, "\n"), 90)
+	if len(chunks) != 2 {
+		t.Fatalf("Expected 2 chunks, got %d: %q", len(chunks), chunks)
+	}
+	if chunks[0] != paragraphs[0]+"\n"+paragraphs[1] || chunks[1] != paragraphs[2] {
+		t.Errorf("Chunks should follow paragraph boundaries in order: %q", chunks)
+	}
+}
+
+func TestSplitNovelSplitsLongParagraphs(t *testing.T) {
+	sentence := strings.Repeat("字", 30) + "。"
+	chunks := SplitNovel(strings.Repeat(sentence, 10)+strings.Repeat("长", 250), 100)
+	for i, chunk := range chunks {
+		if tokens := EstimateTokens(chunk); tokens > 100 {
+			t.Errorf("Chunk %d has %d tokens, exceeding the limit", i, tokens)
+		}
+	}
+	if !strings.HasSuffix(chunks[0], "。") {
+		t.Errorf("Expected chunks to end at sentence boundaries when possible: %q", chunks[0])
+	}
+	if joined := strings.Join(chunks, ""); joined != strings.Repeat(sentence, 10)+strings.Repeat("长", 250) {
+		t.Error("Splitting should not lose or reorder text")
+	}
+}
+
+func TestSceneQuotas(t *testing.T) {
+	chunks := []string{strings.Repeat("a", 400), strings.Repeat("b", 400), strings.Repeat("c", 200)}
+	quotas := sceneQuotas(chunks, 5)
+	if quotas[0]+quotas[1]+quotas[2] != 5 || quotas[0] != 2 || quotas[1] != 2 || quotas[2] != 1 {
+		t.Errorf("Unexpected quotas %v", quotas)
+	}
+	if sceneQuotas(chunks, 0) != nil {
+		t.Error("Expected no quotas when the scene count is unlimited")
+	}
+}
+
+func TestSceneQuotasSpreadAcrossNovel(t *testing.T) {
+	chunks := make([]string, 40)
+	for i := range chunks {
+		chunks[i] = strings.Repeat("a", 400)
+	}
+	quotas := sceneQuotas(chunks, 5)
+	picked := []int{}
+	for i, quota := range quotas {
+		if quota > 1 {
+			t.Fatalf("Expected at most one scene per chunk, got %v", quotas)
+		}
+		if quota == 1 {
+			picked = append(picked, i)
+		}
+	}
+	if fmt.Sprint(picked) != "[3 11 19 27 35]" {
+		t.Errorf("Expected scenes spread evenly across the novel, got chunks %v", picked)
+	}
+}
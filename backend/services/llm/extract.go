@@ -0,0 +1,355 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+
+	"taco/backend/models"
+	"taco/backend/services/cast"
+	"taco/backend/services/prompts"
+	"taco/backend/utils"
+)
+
+// chunkTimeout 是提取单段的时限。整个提取过程只受调用方的 ctx 约束，
+// 长篇小说的段数再多也不会因为总时长超时而中途失败。
+var chunkTimeout = 600 * time.Second
+
+// ProgressFunc 在每段处理完成后被调用，done 为已完成的段数，found 为目前累计的结果数。
+// 开始处理前会以 done=0 调用一次，便于调用方得知总段数。
+type ProgressFunc func(done, total, found int)
+
+func reportProgress(progress ProgressFunc, done, total, found int) {
+	if progress != nil {
+		progress(done, total, found)
+	}
+}
+
+// CallLLMForCharacters 把小说按 cfg.LLM.ChunkTokens 分段，逐段提取人物后合并：
+// 同名或互为别名的人物只保留一个并合并描述和设定，超过 CharacterCount 时优先保留出现段数多的人物。
+func CallLLMForCharacters(ctx context.Context, cfg models.Config, novel string, progress ProgressFunc) ([]models.CharacterProfile, error) {
+	chunks := SplitNovel(novel, cfg.LLM.ChunkTokens)
+	if len(chunks) == 0 {
+		return []models.CharacterProfile{}, nil
+	}
+	log.Printf("[INFO] 小说分为 %d 段提取角色", len(chunks))
+	reportProgress(progress, 0, len(chunks), 0)
+
+	merger := newCharacterMerger()
+	for i, chunk := range chunks {
+		characters, err := extractChunkCharacters(ctx, cfg, chunk, i, len(chunks), merger.names())
+		if err != nil {
+			return nil, fmt.Errorf("第 %d/%d 段: %w", i+1, len(chunks), err)
+		}
+		merger.add(characters)
+		log.Printf("[INFO] 第 %d/%d 段提取到 %d 个角色，累计 %d 个", i+1, len(chunks), len(characters), len(merger.order))
+		reportProgress(progress, i+1, len(chunks), len(merger.order))
+	}
+
+	characters := merger.result(cfg.CharacterCount)
+	for i := range characters {
+		characters[i].ID = utils.NewID(models.CharacterIDPrefix)
+	}
+	return characters, nil
+}
+
+func extractChunkCharacters(ctx context.Context, cfg models.Config, chunk string, index, total int, known []string) ([]models.CharacterProfile, error) {
+	system, prompt, err := characterPrompts(cfg, chunk, index, total, known)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(ctx, chunkTimeout)
+	defer cancel()
+	var characters []models.CharacterProfile
+	if err := invokeStructured(ctx, cfg, system, prompt, 0.3, CharacterSchema, &characters); err != nil {
+		return nil, err
+	}
+	return characters, nil
+}
+
+// characterPrompts 渲染提取第 index 段（从 0 开始）人物时的系统提示词和提示词。
+func characterPrompts(cfg models.Config, chunk string, index, total int, known []string) (string, string, error) {
+	system, err := prompts.Render(cfg.Prompts, prompts.CharacterSystem, nil)
+	if err != nil {
+		return "", "", err
+	}
+	prompt, err := prompts.Render(cfg.Prompts, prompts.CharacterExtraction, prompts.CharacterExtractionData{
+		Index: index + 1,
+		Total: total,
+		Known: known,
+		Chunk: chunk,
+	})
+	return system, prompt, err
+}
+
+// characterMerger 按名称和别名合并各段提取到的人物，保留首次出现的顺序。
+type characterMerger struct {
+	order  []string
+	byKey  map[string]*models.CharacterProfile
+	chunks map[string]int
+	// keys 把名称和别名的键映射到 order 中的键。
+	keys map[string]string
+}
+
+func newCharacterMerger() *characterMerger {
+	return &characterMerger{byKey: map[string]*models.CharacterProfile{}, chunks: map[string]int{}, keys: map[string]string{}}
+}
+
+func (m *characterMerger) add(characters []models.CharacterProfile) {
+	seen := map[string]bool{}
+	for _, character := range characters {
+		names := cast.Names(character)
+		if len(names) == 0 || cast.Key(character.Name) == "" {
+			continue
+		}
+		key := ""
+		for _, name := range names {
+			if existing, ok := m.keys[cast.Key(name)]; ok {
+				key = existing
+				break
+			}
+		}
+		if key == "" {
+			key = cast.Key(names[0])
+			m.order = append(m.order, key)
+			m.byKey[key] = &models.CharacterProfile{Name: names[0], Description: strings.TrimSpace(character.Description)}
+		} else {
+			existing := m.byKey[key]
+			existing.Description = combineDescriptions(existing.Description, character.Description)
+		}
+		cast.AddAliases(m.byKey[key], names...)
+		cast.FillSheet(&m.byKey[key].CharacterSheet, character.CharacterSheet)
+		for _, name := range names {
+			if _, ok := m.keys[cast.Key(name)]; !ok {
+				m.keys[cast.Key(name)] = key
+			}
+		}
+		if !seen[key] {
+			seen[key] = true
+			m.chunks[key]++
+		}
+	}
+}
+
+func (m *characterMerger) names() []string {
+	names := make([]string, 0, len(m.order))
+	for _, key := range m.order {
+		names = append(names, m.byKey[key].Name)
+	}
+	return names
+}
+
+// result 返回合并后的人物。超过 limit 时按出现的段数保留，结果仍按首次出现的顺序排列。
+func (m *characterMerger) result(limit int) []models.CharacterProfile {
+	keys := append([]string(nil), m.order...)
+	if limit > 0 && len(keys) > limit {
+		sort.SliceStable(keys, func(i, j int) bool { return m.chunks[keys[i]] > m.chunks[keys[j]] })
+		kept := map[string]bool{}
+		for _, key := range keys[:limit] {
+			kept[key] = true
+		}
+		keys = keys[:0]
+		for _, key := range m.order {
+			if kept[key] {
+				keys = append(keys, key)
+			}
+		}
+	}
+	characters := make([]models.CharacterProfile, 0, len(keys))
+	for _, key := range keys {
+		characters = append(characters, *m.byKey[key])
+	}
+	return characters
+}
+
+// combineDescriptions 合并同一人物在不同段落中的描述，跳过已经包含的内容。
+func combineDescriptions(existing, addition string) string {
+	existing = strings.TrimSpace(existing)
+	addition = strings.TrimSpace(addition)
+	switch {
+	case addition == "" || strings.Contains(existing, addition):
+		return existing
+	case existing == "" || strings.Contains(addition, existing):
+		return addition
+	}
+	last := []rune(existing)[len([]rune(existing))-1]
+	if !unicode.IsPunct(last) {
+		existing += "。"
+	}
+	return existing + " " + addition
+}
+
+// CallLLMForScenes 把小说分段后逐段拆分场景，按小说顺序拼接结果。
+// SceneCount 大于 0 时按各段长度分配场景数，避免场景都集中在小说开头。
+// chapters 非空时只处理这些章节，分段不跨越章节，每个场景记录所属的章节序号。
+func CallLLMForScenes(ctx context.Context, cfg models.Config, novel string, chapters []models.Chapter, characters []models.CharacterProfile, progress ProgressFunc) ([]models.Scene, error) {
+	charactersJSON, err := sceneCharactersJSON(characters)
+	if err != nil {
+		return nil, err
+	}
+
+	chunks, owners := splitByChapters(novel, chapters, cfg.LLM.ChunkTokens)
+	if len(chunks) == 0 {
+		return []models.Scene{}, nil
+	}
+	quotas := sceneQuotas(chunks, cfg.SceneCount)
+	log.Printf("[INFO] 小说分为 %d 段提取场景", len(chunks))
+	reportProgress(progress, 0, len(chunks), 0)
+
+	scenes := []models.Scene{}
+	for i, chunk := range chunks {
+		if quotas != nil && quotas[i] == 0 {
+			reportProgress(progress, i+1, len(chunks), len(scenes))
+			continue
+		}
+		quota := 0
+		if quotas != nil {
+			quota = quotas[i]
+		}
+		chunkScenes, err := extractChunkScenes(ctx, cfg, chunk, i, len(chunks), quota, charactersJSON)
+		if err != nil {
+			return nil, fmt.Errorf("第 %d/%d 段: %w", i+1, len(chunks), err)
+		}
+		if quota > 0 && len(chunkScenes) > quota {
+			chunkScenes = chunkScenes[:quota]
+		}
+		for j := range chunkScenes {
+			chunkScenes[j].Chapter = owners[i]
+		}
+		scenes = append(scenes, chunkScenes...)
+		log.Printf("[INFO] 第 %d/%d 段拆分出 %d 个场景，累计 %d 个", i+1, len(chunks), len(chunkScenes), len(scenes))
+		reportProgress(progress, i+1, len(chunks), len(scenes))
+	}
+
+	for i := range scenes {
+		scenes[i].ID = utils.NewID(models.SceneIDPrefix)
+	}
+	return scenes, nil
+}
+
+// sceneCharactersJSON 只保留拆分场景需要的名称、别名、描述和外观提示词，
+// 避免把图片版本、文件路径等内部信息随每段提示词发给模型。
+func sceneCharactersJSON(characters []models.CharacterProfile) (string, error) {
+	type entry struct {
+		Name         string   `json:"name"`
+		Aliases      []string `json:"aliases,omitempty"`
+		Description  string   `json:"description,omitempty"`
+		VisualPrompt string   `json:"visualPrompt,omitempty"`
+	}
+	entries := make([]entry, 0, len(characters))
+	for _, character := range characters {
+		entries = append(entries, entry{
+			Name:         character.Name,
+			Aliases:      character.Aliases,
+			Description:  character.Description,
+			VisualPrompt: character.VisualPrompt,
+		})
+	}
+	charactersJSON, err := json.Marshal(entries)
+	if err != nil {
+		return "", fmt.Errorf("序列化角色信息失败: %w", err)
+	}
+	return string(charactersJSON), nil
+}
+
+func extractChunkScenes(ctx context.Context, cfg models.Config, chunk string, index, total, quota int, charactersJSON string) ([]models.Scene, error) {
+	system, prompt, err := scenePrompts(cfg, chunk, index, total, quota, charactersJSON)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(ctx, chunkTimeout)
+	defer cancel()
+	var scenes []models.Scene
+	if err := invokeStructured(ctx, cfg, system, prompt, 0.2, SceneSchema, &scenes); err != nil {
+		return nil, err
+	}
+	return NormalizeScenes(scenes), nil
+}
+
+// scenePrompts 渲染拆分第 index 段（从 0 开始）场景时的系统提示词和提示词。
+func scenePrompts(cfg models.Config, chunk string, index, total, quota int, charactersJSON string) (string, string, error) {
+	system, err := prompts.Render(cfg.Prompts, prompts.SceneSystem, nil)
+	if err != nil {
+		return "", "", err
+	}
+	prompt, err := prompts.Render(cfg.Prompts, prompts.SceneExtraction, prompts.SceneExtractionData{
+		Index:          index + 1,
+		Total:          total,
+		Quota:          quota,
+		Chunk:          chunk,
+		CharactersJSON: charactersJSON,
+	})
+	return system, prompt, err
+}
+
+// PreviewCharacterPrompt 返回提取人物时第一段使用的模板渲染结果，name 为 CharacterSystem 或 CharacterExtraction。
+func PreviewCharacterPrompt(cfg models.Config, name, novel string) (string, error) {
+	chunks := SplitNovel(novel, cfg.LLM.ChunkTokens)
+	if len(chunks) == 0 {
+		chunks = []string{""}
+	}
+	system, prompt, err := characterPrompts(cfg, chunks[0], 0, len(chunks), nil)
+	if name == prompts.CharacterSystem {
+		return system, err
+	}
+	return prompt, err
+}
+
+// PreviewScenePrompt 返回拆分场景时第一个分到场景的段使用的模板渲染结果，name 为 SceneSystem 或 SceneExtraction。
+func PreviewScenePrompt(cfg models.Config, name, novel string, characters []models.CharacterProfile) (string, error) {
+	charactersJSON, err := sceneCharactersJSON(characters)
+	if err != nil {
+		return "", err
+	}
+	chunks := SplitNovel(novel, cfg.LLM.ChunkTokens)
+	if len(chunks) == 0 {
+		chunks = []string{""}
+	}
+	// 分不到场景的段不会发给模型，预览第一个实际会发送的段。
+	index, quota := 0, 0
+	if quotas := sceneQuotas(chunks, cfg.SceneCount); quotas != nil {
+		for quotas[index] == 0 {
+			index++
+		}
+		quota = quotas[index]
+	}
+	system, prompt, err := scenePrompts(cfg, chunks[index], index, len(chunks), quota, charactersJSON)
+	if name == prompts.SceneSystem {
+		return system, err
+	}
+	return prompt, err
+}
+
+// sceneQuotas 按各段的 token 数分配 total 个场景，total 不大于 0 时返回 nil 表示不限制。
+// 第 j 个场景落在小说 (j+0.5)/total 处所在的段，每段分到的数量与其长度成比例（相差不超过 1），
+// 场景数少于段数时分到场景的段也均匀分布在全书，而不是集中在开头几段。
+func sceneQuotas(chunks []string, total int) []int {
+	if total <= 0 {
+		return nil
+	}
+	weights := make([]int, len(chunks))
+	sum := 0
+	for i, chunk := range chunks {
+		weights[i] = EstimateTokens(chunk)
+		sum += weights[i]
+	}
+	quotas := make([]int, len(chunks))
+	if sum == 0 {
+		quotas[0] = total
+		return quotas
+	}
+	cumulative, assigned := 0, 0
+	for i, weight := range weights {
+		cumulative += weight
+		// 前 i+1 段覆盖的场景数：四舍五入 total*cumulative/sum。
+		covered := (2*total*cumulative + sum) / (2 * sum)
+		quotas[i] = covered - assigned
+		assigned = covered
+	}
+	return quotas
+}
@@ -0,0 +1,269 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"taco/backend/models"
+	"taco/backend/services/prompts"
+)
+
+// replyByMarker 按提示词中包含的标记返回对应内容的 JSON，没有匹配时返回空数组。
+func replyByMarker(replies map[string]any) func(fakeRequest) any {
+	return func(req fakeRequest) any {
+		content := "[]"
+		for marker, reply := range replies {
+			if strings.Contains(req.Prompt(), marker) {
+				data, _ := json.Marshal(reply)
+				content = string(data)
+			}
+		}
+		return chatReply(content)
+	}
+}
+
+func chunkedNovel() string {
+	return strings.Repeat("甲", 50) + "\n" + strings.Repeat("乙", 50) + "\n" + strings.Repeat("丙", 50)
+}
+
+func TestCallLLMForCharactersMergesChunks(t *testing.T) {
+	server, requests := fakeLLMServer(t, "", replyByMarker(map[string]any{
+		"甲甲甲": []models.CharacterProfile{{Name: "林黛玉", Description: "体弱多病"}, {Name: "贾宝玉", Description: "衔玉而生"}},
+		"乙乙乙": []models.CharacterProfile{{Name: "林黛玉", Description: "才思敏捷"}},
+		"丙丙丙": []models.CharacterProfile{{Name: " 林黛玉 ", Description: "体弱多病"}, {Name: "王熙凤", Description: "精明强干"}},
+	}))
+	cfg := models.Config{
+		LLM:            models.LLMConfig{Model: "m", BaseURL: server.URL, APIKey: "k", ChunkTokens: 60},
+		CharacterCount: 2,
+	}
+
+	var calls [][3]int
+	characters, err := CallLLMForCharacters(context.Background(), cfg, chunkedNovel(), func(done, total, found int) {
+		calls = append(calls, [3]int{done, total, found})
+	})
+	if err != nil {
+		t.Fatalf("CallLLMForCharacters failed: %v", err)
+	}
+
+	if len(*requests) != 3 {
+		t.Fatalf("Expected one request per chunk, got %d", len(*requests))
+	}
+	if !strings.Contains((*requests)[1].Prompt(), "林黛玉、贾宝玉") {
+		t.Errorf("Later chunks should list known characters: %s", (*requests)[1].Prompt())
+	}
+
+	// 林黛玉出现在三段中，贾宝玉和王熙凤各一段，按首次出现保留贾宝玉。
+	if len(characters) != 2 || characters[0].Name != "林黛玉" || characters[1].Name != "贾宝玉" {
+		t.Fatalf("Unexpected merged characters: %+v", characters)
+	}
+	if characters[0].Description != "体弱多病。 才思敏捷" {
+		t.Errorf("Expected combined description without duplicates, got %q", characters[0].Description)
+	}
+	if characters[0].ID == "" || characters[0].ID == characters[1].ID {
+		t.Errorf("Expected unique IDs, got %+v", characters)
+	}
+
+	want := [][3]int{{0, 3, 0}, {1, 3, 2}, {2, 3, 2}, {3, 3, 3}}
+	if len(calls) != len(want) {
+		t.Fatalf("Expected progress %v, got %v", want, calls)
+	}
+	for i := range want {
+		if calls[i] != want[i] {
+			t.Errorf("Expected progress %v, got %v", want, calls)
+			break
+		}
+	}
+}
+
+func TestCallLLMForScenesKeepsNovelOrder(t *testing.T) {
+	server, requests := fakeLLMServer(t, "", replyByMarker(map[string]any{
+		"甲甲甲": []models.Scene{{Title: "开端"}, {Title: "多余"}},
+		"乙乙乙": []models.Scene{{Title: "发展"}},
+		"丙丙丙": []models.Scene{{Title: "高潮"}},
+	}))
+	cfg := models.Config{
+		LLM:        models.LLMConfig{Model: "m", BaseURL: server.URL, APIKey: "k", ChunkTokens: 60},
+		SceneCount: 3,
+	}
+
+	scenes, err := CallLLMForScenes(context.Background(), cfg, chunkedNovel(), nil, nil, nil)
+	if err != nil {
+		t.Fatalf("CallLLMForScenes failed: %v", err)
+	}
+	titles := []string{}
+	for _, scene := range scenes {
+		titles = append(titles, scene.Title)
+	}
+	if strings.Join(titles, ",") != "开端,发展,高潮" {
+		t.Errorf("Expected one scene per chunk in novel order, got %v", titles)
+	}
+	if !strings.Contains((*requests)[0].Prompt(), "不超过 1 个") {
+		t.Errorf("Expected the per-chunk quota in the prompt: %s", (*requests)[0].Prompt())
+	}
+}
+
+func TestCallLLMForScenesReadsLateChunks(t *testing.T) {
+	server, requests := fakeLLMServer(t, "", replyByMarker(map[string]any{
+		"甲甲甲": []models.Scene{{Title: "开端"}},
+		"乙乙乙": []models.Scene{{Title: "发展"}},
+		"丙丙丙": []models.Scene{{Title: "结局"}},
+	}))
+	cfg := models.Config{
+		LLM:        models.LLMConfig{Model: "m", BaseURL: server.URL, APIKey: "k", ChunkTokens: 60},
+		SceneCount: 2,
+	}
+
+	scenes, err := CallLLMForScenes(context.Background(), cfg, chunkedNovel(), nil, nil, nil)
+	if err != nil {
+		t.Fatalf("CallLLMForScenes failed: %v", err)
+	}
+	titles := []string{}
+	for _, scene := range scenes {
+		titles = append(titles, scene.Title)
+	}
+	// 场景数少于段数时，分到场景的段分布在全书，最后一段同样会被读取。
+	if strings.Join(titles, ",") != "开端,结局" || len(*requests) != 2 {
+		t.Errorf("Expected scenes from the first and last chunks, got %v after %d requests", titles, len(*requests))
+	}
+}
+
+func TestCallLLMForScenesReportsFailingChunk(t *testing.T) {
+	server, _ := fakeLLMServer(t, "", func(req fakeRequest) any {
+		if req.N == 2 {
+			return errorReply{http.StatusInternalServerError, "boom"}
+		}
+		return chatReply("[]")
+	})
+	// 不重试，第二段直接失败。
+	cfg := models.Config{
+		LLM:   models.LLMConfig{Model: "m", BaseURL: server.URL, APIKey: "k", ChunkTokens: 60},
+		Retry: models.RetryConfig{MaxAttempts: 1},
+	}
+
+	_, err := CallLLMForScenes(context.Background(), cfg, chunkedNovel(), nil, nil, nil)
+	if err == nil || !strings.Contains(err.Error(), "第 2/3 段") {
+		t.Errorf("Expected error naming the failing chunk, got %v", err)
+	}
+}
+
+func TestCallLLMForScenesTimesOutPerChunk(t *testing.T) {
+	defer func(timeout time.Duration) { chunkTimeout = timeout }(chunkTimeout)
+	chunkTimeout = 100 * time.Millisecond
+
+	server, _ := fakeLLMServer(t, "", func(req fakeRequest) any {
+		// 前两段各用去大半个时限，合计超过时限；第三段单独就超过时限。
+		delay := 60 * time.Millisecond
+		if req.N == 3 {
+			delay = 500 * time.Millisecond
+		}
+		time.Sleep(delay)
+		return chatReply("[]")
+	})
+	cfg := models.Config{
+		LLM:   models.LLMConfig{Model: "m", BaseURL: server.URL, APIKey: "k", ChunkTokens: 60},
+		Retry: models.RetryConfig{MaxAttempts: 1},
+	}
+
+	_, err := CallLLMForScenes(context.Background(), cfg, chunkedNovel(), nil, nil, nil)
+	if err == nil || !strings.Contains(err.Error(), "第 3/3 段") {
+		t.Errorf("Expected only the stalled third chunk to time out, got %v", err)
+	}
+}
+
+func TestCallLLMForScenesTagsChapters(t *testing.T) {
+	server, requests := fakeLLMServer(t, "", replyByMarker(map[string]any{
+		"乙乙乙": []models.Scene{{Title: "第二章场景"}},
+		"丙丙丙": []models.Scene{{Title: "第三章场景"}},
+	}))
+	cfg := models.Config{LLM: models.LLMConfig{Model: "m", BaseURL: server.URL, APIKey: "k"}}
+	novel := chunkedNovel()
+	second, third := strings.Index(novel, "乙"), strings.Index(novel, "丙")
+	chapters := []models.Chapter{
+		{Index: 2, Start: second, End: third},
+		{Index: 3, Start: third, End: len(novel)},
+	}
+
+	scenes, err := CallLLMForScenes(context.Background(), cfg, novel, chapters, nil, nil)
+	if err != nil {
+		t.Fatalf("CallLLMForScenes failed: %v", err)
+	}
+	if len(*requests) != 2 {
+		t.Fatalf("Expected one request per selected chapter, got %d", len(*requests))
+	}
+	for _, req := range *requests {
+		prompt := req.Prompt()
+		if strings.Contains(prompt, "甲") {
+			t.Errorf("Unselected chapters should not be sent: %s", prompt)
+		}
+	}
+	if len(scenes) != 2 || scenes[0].Chapter != 2 || scenes[1].Chapter != 3 {
+		t.Errorf("Expected scenes tagged with their chapters, got %+v", scenes)
+	}
+}
+
+func TestCallLLMForCharactersMergesAliases(t *testing.T) {
+	server, _ := fakeLLMServer(t, "", replyByMarker(map[string]any{
+		"甲甲甲": []models.CharacterProfile{{Name: "刘姥姥", Aliases: []string{"姥姥"}, Description: "乡下老妇"}},
+		"乙乙乙": []models.CharacterProfile{{Name: "姥姥", Aliases: []string{"刘氏"}, Description: "风趣",
+			CharacterSheet: models.CharacterSheet{Gender: "女", VisualPrompt: "old woman, grey hair in a bun"}}},
+		"丙丙丙": []models.CharacterProfile{{Name: "刘氏", CharacterSheet: models.CharacterSheet{VisualPrompt: "elderly lady"}}},
+	}))
+	cfg := models.Config{LLM: models.LLMConfig{Model: "m", BaseURL: server.URL, APIKey: "k", ChunkTokens: 60}}
+
+	characters, err := CallLLMForCharacters(context.Background(), cfg, chunkedNovel(), nil)
+	if err != nil {
+		t.Fatalf("CallLLMForCharacters failed: %v", err)
+	}
+	if len(characters) != 1 || characters[0].Name != "刘姥姥" || strings.Join(characters[0].Aliases, ",") != "姥姥,刘氏" {
+		t.Fatalf("Expected aliases to be merged into one character, got %+v", characters)
+	}
+	if characters[0].Description != "乡下老妇。 风趣" {
+		t.Errorf("Unexpected description %q", characters[0].Description)
+	}
+	// 设定字段取首次出现的内容，外观提示词不会被后面的段落改写。
+	if characters[0].Gender != "女" || characters[0].VisualPrompt != "old woman, grey hair in a bun" {
+		t.Errorf("Unexpected character sheet %+v", characters[0].CharacterSheet)
+	}
+}
+
+func TestPreviewScenePromptSendsOnlyCharacterSummaries(t *testing.T) {
+	characters := []models.CharacterProfile{{
+		ID:             "char-1",
+		Name:           "刘姥姥",
+		Aliases:        []string{"姥姥"},
+		Description:    "乡下老妇",
+		CharacterSheet: models.CharacterSheet{Gender: "女", VisualPrompt: "old woman"},
+		ImagePath:      "assets/characters/char-1.png",
+		ImageVariants:  []models.AssetVariant{{Path: "assets/characters/char-1-old.png", Prompt: "旧提示词"}},
+	}}
+
+	prompt, err := PreviewScenePrompt(models.Config{}, prompts.SceneExtraction, "小说内容", characters)
+	if err != nil {
+		t.Fatalf("PreviewScenePrompt failed: %v", err)
+	}
+	if !strings.Contains(prompt, `{"name":"刘姥姥","aliases":["姥姥"],"description":"乡下老妇","visualPrompt":"old woman"}`) {
+		t.Errorf("Expected the character summary in the prompt: %s", prompt)
+	}
+	for _, leaked := range []string{"char-1", "assets/", "旧提示词", `"gender"`} {
+		if strings.Contains(prompt, leaked) {
+			t.Errorf("Expected %q to be left out of the prompt: %s", leaked, prompt)
+		}
+	}
+}
+
+func TestPreviewScenePromptSkipsChunksWithoutQuota(t *testing.T) {
+	cfg := models.Config{LLM: models.LLMConfig{ChunkTokens: 60}, SceneCount: 1}
+
+	prompt, err := PreviewScenePrompt(cfg, prompts.SceneExtraction, chunkedNovel(), nil)
+	if err != nil {
+		t.Fatalf("PreviewScenePrompt failed: %v", err)
+	}
+	// 唯一的场景分给中间一段，第一段不会发给模型。
+	if !strings.Contains(prompt, "第 2/3 段") || !strings.Contains(prompt, "乙乙乙") || !strings.Contains(prompt, "不超过 1 个") {
+		t.Errorf("Expected the preview of the chunk that is actually sent: %s", prompt)
+	}
+}
@@ -1,211 +1,105 @@
 package llm
 
 import (
-	"bytes"
 	"context"
-	"encoding/json"
 	"errors"
 	"fmt"
-	"io"
 	"log"
-	"net"
 	"net/http"
 	"strings"
-	"time"
+	"sync"
 
 	"taco/backend/models"
+	"taco/backend/services/retry"
 )
 
-func InvokeLLM(ctx context.Context, cfg models.Config, messages []map[string]string, temperature float64) (string, error) {
-	if strings.TrimSpace(cfg.LLM.APIKey) == "" {
-		return "", errors.New("请先在配置中填写 LLM API Key")
-	}
-
-	base := strings.TrimRight(cfg.LLM.BaseURL, "/")
-	if base == "" {
-		return "", errors.New("LLM Base URL 未设置")
-	}
-
-	reqBody := map[string]any{
-		"model":       cfg.LLM.Model,
-		"messages":    messages,
-		"temperature": temperature,
-	}
+// JSON 模式的取值：auto（默认）先请求 response_format，接口不支持时自动退回普通模式；
+// on 总是请求；off 从不请求。
+const (
+	JSONModeAuto = "auto"
+	JSONModeOn   = "on"
+	JSONModeOff  = "off"
+)
 
-	bodyBytes, err := json.Marshal(reqBody)
-	if err != nil {
-		return "", err
-	}
+// jsonModeUnsupported 记录拒绝过 response_format 的接口（地址 + 模型），之后不再请求 JSON 模式。
+var jsonModeUnsupported sync.Map
 
-	apiURL := base + "/v1/chat/completions"
-	log.Printf("[LLM API] 发起请求: %s, 模型: %s, 消息数: %d", apiURL, cfg.LLM.Model, len(messages))
+func jsonModeKey(cfg models.Config) string {
+	return strings.TrimRight(cfg.LLM.BaseURL, "/") + "|" + cfg.LLM.Model
+}
 
-	request, err := http.NewRequestWithContext(ctx, http.MethodPost, apiURL, bytes.NewBuffer(bodyBytes))
-	if err != nil {
-		return "", err
+func jsonModeEnabled(cfg models.Config) bool {
+	if provider, err := ProviderFor(cfg.LLM); err == nil && !provider.SupportsJSONMode() {
+		return false
 	}
-	request.Header.Set("Authorization", "Bearer "+cfg.LLM.APIKey)
-	request.Header.Set("Content-Type", "application/json")
-	request.Header.Set("Connection", "keep-alive")
-	request.ContentLength = int64(len(bodyBytes))
-
-	// 配置 HTTP Transport 以处理大请求体
-	transport := &http.Transport{
-		DisableKeepAlives:   false,
-		MaxIdleConns:        100,
-		MaxIdleConnsPerHost: 10,
-		IdleConnTimeout:     90 * time.Second,
-		TLSHandshakeTimeout: 30 * time.Second,
-		// 关键：设置足够长的响应头超时和期望继续超时
-		ExpectContinueTimeout: 10 * time.Second,
-		ResponseHeaderTimeout: 600 * time.Second,
-		// 添加 DialContext 以设置连接超时
-		DialContext: (&net.Dialer{
-			Timeout:   120 * time.Second, // 连接超时
-			KeepAlive: 30 * time.Second,  // Keep-Alive 探测间隔
-		}).DialContext,
+	switch strings.ToLower(strings.TrimSpace(cfg.LLM.JSONMode)) {
+	case JSONModeOn:
+		return true
+	case JSONModeOff:
+		return false
 	}
+	_, unsupported := jsonModeUnsupported.Load(jsonModeKey(cfg))
+	return !unsupported
+}
 
-	client := &http.Client{
-		Timeout:   600 * time.Second,
-		Transport: transport,
-	}
+func InvokeLLM(ctx context.Context, cfg models.Config, messages []map[string]string, temperature float64) (string, error) {
+	return invokeLLM(ctx, cfg, messages, temperature, false)
+}
 
-	resp, err := client.Do(request)
+// invokeLLM 通过 cfg.LLM.Provider 选择的接口发起对话请求，按 cfg.Retry 重试；ctx 附加了 StreamHandler 时使用流式输出。jsonMode 为 true 时要求接口只输出 JSON；
+// JSON 模式为 auto 且接口以 400/422 拒绝时，去掉 JSON 模式重试一次，成功后记住该接口不支持。
+func invokeLLM(ctx context.Context, cfg models.Config, messages []map[string]string, temperature float64, jsonMode bool) (string, error) {
+	provider, err := ProviderFor(cfg.LLM)
 	if err != nil {
-		log.Printf("[LLM API] 请求失败: %v", err)
 		return "", err
 	}
-	defer resp.Body.Close()
-
-	log.Printf("[LLM API] 收到响应: HTTP %d", resp.StatusCode)
-
-	if resp.StatusCode != http.StatusOK {
-		errBody, _ := io.ReadAll(resp.Body)
-		log.Printf("[LLM API] 错误响应: %s", strings.TrimSpace(string(errBody)))
-		return "", fmt.Errorf("LLM 请求失败: %s", strings.TrimSpace(string(errBody)))
+	if strings.TrimRight(cfg.LLM.BaseURL, "/") == "" {
+		return "", errors.New("LLM Base URL 未设置")
 	}
+	jsonMode = jsonMode && provider.SupportsJSONMode()
 
-	var completion struct {
-		Choices []struct {
-			Message struct {
-				Content string `json:"content"`
-			} `json:"message"`
-		} `json:"choices"`
+	policy := retry.FromConfig(cfg.Retry)
+	request := func(jsonMode bool) (string, error) {
+		return retry.Do(ctx, policy, "LLM API", func() (string, error) {
+			req := ChatRequest{Messages: messages, Temperature: temperature, JSONMode: jsonMode}
+			if stream, ok := streamFrom(ctx); ok {
+				if streamer, ok := provider.(Streamer); ok {
+					return streamer.Stream(ctx, cfg.LLM, req, stream.begin())
+				}
+			}
+			return provider.Complete(ctx, cfg.LLM, req)
+		})
+	}
+	content, err := request(jsonMode)
+	var httpErr *retry.HTTPError
+	if err != nil && jsonMode && errors.As(err, &httpErr) &&
+		(httpErr.StatusCode == http.StatusBadRequest || httpErr.StatusCode == http.StatusUnprocessableEntity) &&
+		!strings.EqualFold(strings.TrimSpace(cfg.LLM.JSONMode), JSONModeOn) {
+		log.Printf("[LLM API] 接口可能不支持 JSON 模式，改用普通模式重试")
+		var retryErr error
+		content, retryErr = request(false)
+		if retryErr != nil {
+			return "", err
+		}
+		jsonModeUnsupported.Store(jsonModeKey(cfg), true)
+		err = nil
 	}
-	if err := json.NewDecoder(resp.Body).Decode(&completion); err != nil {
+	if err != nil {
 		return "", err
 	}
-	if len(completion.Choices) == 0 {
-		return "", errors.New("LLM 未返回结果")
-	}
 
-	content := strings.TrimSpace(completion.Choices[0].Message.Content)
+	content = strings.TrimSpace(content)
 	log.Printf("[LLM API] 成功获取响应，内容长度: %d 字节", len(content))
 	return content, nil
 }
 
-func CallLLMForCharacters(ctx context.Context, cfg models.Config, novel string) ([]models.CharacterProfile, error) {
-	prompt := fmt.Sprintf(`请阅读以下小说内容，从中提取主要人物及其关键特征。请输出 JSON 数组，每个元素包含字段 "name" (人物名) 和 "description" (特征描述)。仅返回有效的 JSON，不要附加额外文字。
-
-小说内容：
-%s`, novel)
-
-	content, err := InvokeLLM(ctx, cfg, []map[string]string{
-		{
-			"role":    "system",
-			"content": "你是一名擅长从小说中抽取人物信息的助手。",
-		},
-		{
-			"role":    "user",
-			"content": prompt,
-		},
-	}, 0.3)
-	if err != nil {
-		return nil, err
-	}
-
-	var characters []models.CharacterProfile
-	if err := json.Unmarshal([]byte(content), &characters); err != nil {
-		return nil, fmt.Errorf("解析 LLM 响应失败: %w", err)
-	}
-
-	if characters == nil {
-		characters = []models.CharacterProfile{}
-	}
-
-	if limit := cfg.CharacterCount; limit > 0 && len(characters) > limit {
-		characters = characters[:limit]
-	}
-	return characters, nil
-}
-
-func CallLLMForScenes(ctx context.Context, cfg models.Config, novel string, characters []models.CharacterProfile) ([]models.Scene, error) {
-	charactersJSON, err := json.Marshal(characters)
-	if err != nil {
-		return nil, fmt.Errorf("序列化角色信息失败: %w", err)
-	}
-
-	prompt := fmt.Sprintf(`请基于以下小说内容和现有的角色信息拆分出适合制作动漫的关键场景。请输出 JSON 数组，每个元素为一个场景对象，包含字段:
-- "title": 场景名称
-- "characters": 出场人物名称数组
-- "description": 场景的视觉/剧情描述
-- "dialogues": 关键对话数组，每个元素是一句话
-- "narration": 旁白或解说词
-
-仅返回可被 JSON 解析的数组，不要添加额外说明。
-
-小说内容：
-%s
-
-角色信息 (JSON):
-%s`, novel, string(charactersJSON))
-
-	content, err := InvokeLLM(ctx, cfg, []map[string]string{
-		{
-			"role":    "system",
-			"content": "你是一名资深的分镜师，擅长把小说拆分成动漫场景。",
-		},
-		{
-			"role":    "user",
-			"content": prompt,
-		},
-	}, 0.2)
-	if err != nil {
-		return nil, err
-	}
-
-	scenes, err := ParseScenesJSON(content)
-	if err != nil {
-		return nil, err
-	}
-
-	if limit := cfg.SceneCount; limit > 0 && len(scenes) > limit {
-		scenes = scenes[:limit]
-	}
-	return scenes, nil
-}
-
+// ParseScenesJSON 解析模型返回的场景，允许 Markdown 代码块、前后的说明文字和 {"scenes": [...]} 包装。
 func ParseScenesJSON(content string) ([]models.Scene, error) {
-	content = strings.TrimSpace(content)
-	if content == "" {
-		return []models.Scene{}, nil
-	}
-
 	var scenes []models.Scene
-	err := json.Unmarshal([]byte(content), &scenes)
-	if err == nil {
-		return NormalizeScenes(scenes), nil
-	}
-
-	var wrapper struct {
-		Scenes []models.Scene `json:"scenes"`
-	}
-	if errWrapper := json.Unmarshal([]byte(content), &wrapper); errWrapper == nil {
-		return NormalizeScenes(wrapper.Scenes), nil
+	if err := SceneSchema.Decode(content, &scenes); err != nil {
+		return nil, fmt.Errorf("解析 LLM 场景响应失败: %w", err)
 	}
-
-	return nil, fmt.Errorf("解析 LLM 场景响应失败: %w", err)
+	return NormalizeScenes(scenes), nil
 }
 
 func NormalizeScenes(scenes []models.Scene) []models.Scene {
@@ -215,6 +109,7 @@ func NormalizeScenes(scenes []models.Scene) []models.Scene {
 
 	normalized := make([]models.Scene, len(scenes))
 	for i, scene := range scenes {
+		scene.ID = strings.TrimSpace(scene.ID)
 		scene.Title = strings.TrimSpace(scene.Title)
 		scene.Description = strings.TrimSpace(scene.Description)
 		scene.Narration = strings.TrimSpace(scene.Narration)
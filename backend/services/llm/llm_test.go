@@ -123,7 +123,7 @@ func TestCallLLMForCharacters(t *testing.T) {
 	}
 
 	ctx := context.Background()
-	characters, err := CallLLMForCharacters(ctx, cfg, "小说内容")
+	characters, err := CallLLMForCharacters(ctx, cfg, "小说内容", nil)
 	if err != nil {
 		t.Fatalf("CallLLMForCharacters failed: %v", err)
 	}
@@ -169,7 +169,7 @@ func TestCallLLMForCharactersLimit(t *testing.T) {
 	}
 
 	ctx := context.Background()
-	characters, err := CallLLMForCharacters(ctx, cfg, "小说内容")
+	characters, err := CallLLMForCharacters(ctx, cfg, "小说内容", nil)
 	if err != nil {
 		t.Fatalf("CallLLMForCharacters failed: %v", err)
 	}
@@ -219,7 +219,7 @@ func TestCallLLMForScenes(t *testing.T) {
 	}
 
 	ctx := context.Background()
-	scenes, err := CallLLMForScenes(ctx, cfg, "小说内容", characters)
+	scenes, err := CallLLMForScenes(ctx, cfg, "小说内容", nil, characters, nil)
 	if err != nil {
 		t.Fatalf("CallLLMForScenes failed: %v", err)
 	}
@@ -325,3 +325,35 @@ func TestNormalizeScenesNil(t *testing.T) {
 		t.Errorf("Expected 0 scenes, got %d", len(normalized))
 	}
 }
+
+func TestInvokeLLMRetriesRateLimit(t *testing.T) {
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls == 1 {
+			http.Error(w, "rate limited", http.StatusTooManyRequests)
+			return
+		}
+		if calls == 3 {
+			http.Error(w, "invalid model", http.StatusBadRequest)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]any{
+			"choices": []map[string]any{{"message": map[string]string{"content": "ok"}}},
+		})
+	}))
+	defer server.Close()
+	cfg := models.Config{
+		LLM:   models.LLMConfig{Model: "m", BaseURL: server.URL, APIKey: "k"},
+		Retry: models.RetryConfig{BaseDelayMs: 1},
+	}
+	messages := []map[string]string{{"role": "user", "content": "test"}}
+
+	if result, err := InvokeLLM(context.Background(), cfg, messages, 0.7); err != nil || result != "ok" || calls != 2 {
+		t.Fatalf("Expected success after one retry, got %q, %v after %d calls", result, err, calls)
+	}
+	// 400 不会重试。
+	if _, err := InvokeLLM(context.Background(), cfg, messages, 0.7); err == nil || calls != 3 {
+		t.Errorf("Expected the 400 to fail without retrying, got %v after %d calls", err, calls)
+	}
+}
@@ -0,0 +1,90 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"taco/backend/models"
+	"taco/backend/services/cast"
+	"taco/backend/services/prompts"
+)
+
+type mergeOutput struct {
+	Name       string   `json:"name"`
+	Duplicates []string `json:"duplicates"`
+	Reason     string   `json:"reason"`
+}
+
+// CharacterMergePrompt 渲染查找重复人物时使用的系统提示词和提示词。
+func CharacterMergePrompt(cfg models.Config, characters []models.CharacterProfile) (string, string, error) {
+	type entry struct {
+		Name        string   `json:"name"`
+		Aliases     []string `json:"aliases,omitempty"`
+		Description string   `json:"description,omitempty"`
+	}
+	entries := make([]entry, 0, len(characters))
+	for _, character := range characters {
+		entries = append(entries, entry{Name: character.Name, Aliases: character.Aliases, Description: character.Description})
+	}
+	charactersJSON, err := json.Marshal(entries)
+	if err != nil {
+		return "", "", fmt.Errorf("序列化角色信息失败: %w", err)
+	}
+	system, err := prompts.Render(cfg.Prompts, prompts.CharacterSystem, nil)
+	if err != nil {
+		return "", "", err
+	}
+	prompt, err := prompts.Render(cfg.Prompts, prompts.CharacterMerge, prompts.CharacterMergeData{CharactersJSON: string(charactersJSON)})
+	return system, prompt, err
+}
+
+// SuggestCharacterMerges 请模型找出 characters 中实际为同一人物的条目。模型给出的名称按名称和别名
+// 对应到角色，对应不上的名称会被忽略；每个角色最多出现在一条建议中。
+func SuggestCharacterMerges(ctx context.Context, cfg models.Config, characters []models.CharacterProfile) ([]models.MergeSuggestion, error) {
+	suggestions := []models.MergeSuggestion{}
+	if len(characters) < 2 {
+		return suggestions, nil
+	}
+	system, prompt, err := CharacterMergePrompt(cfg, characters)
+	if err != nil {
+		return nil, err
+	}
+	var outputs []mergeOutput
+	if err := invokeStructured(ctx, cfg, system, prompt, 0.2, MergeSchema, &outputs); err != nil {
+		return nil, err
+	}
+
+	index := cast.NewIndex(characters)
+	used := map[int]bool{}
+	for _, output := range outputs {
+		target, ok := index.Find(output.Name)
+		if !ok || used[target] {
+			continue
+		}
+		suggestion := models.MergeSuggestion{
+			TargetID: characters[target].ID,
+			Target:   characters[target].Name,
+			Reason:   strings.TrimSpace(output.Reason),
+		}
+		claimed := map[int]bool{target: true}
+		for _, name := range output.Duplicates {
+			source, ok := index.Find(name)
+			if !ok || claimed[source] || used[source] {
+				continue
+			}
+			claimed[source] = true
+			suggestion.SourceIDs = append(suggestion.SourceIDs, characters[source].ID)
+			suggestion.Sources = append(suggestion.Sources, characters[source].Name)
+		}
+		if len(suggestion.SourceIDs) == 0 {
+			continue
+		}
+		for idx := range claimed {
+			used[idx] = true
+		}
+		suggestions = append(suggestions, suggestion)
+	}
+	return suggestions, nil
+}
@@ -0,0 +1,37 @@
+package llm
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"taco/backend/models"
+)
+
+func TestSuggestCharacterMerges(t *testing.T) {
+	server, requests := fakeLLMServer(t, "", replySequence(`[
+		{"name": "刘姥姥", "duplicates": ["姥姥", "老刘", "刘姥姥"], "reason": "同一位乡下老妇"},
+		{"name": "姥姥", "duplicates": ["王熙凤"]},
+		{"name": "贾宝玉", "duplicates": ["宝二爷"]}
+	]`))
+	cfg := models.Config{LLM: models.LLMConfig{Model: "m", BaseURL: server.URL, APIKey: "k"}}
+	characters := []models.CharacterProfile{
+		{ID: "a", Name: "刘姥姥"},
+		{ID: "b", Name: "姥姥"},
+		{ID: "c", Name: "王熙凤", Aliases: []string{"凤姐"}},
+		{ID: "d", Name: "贾宝玉"},
+	}
+
+	suggestions, err := SuggestCharacterMerges(context.Background(), cfg, characters)
+	if err != nil {
+		t.Fatalf("SuggestCharacterMerges failed: %v", err)
+	}
+	// 未知名称被忽略，已出现在前一条建议中的角色不会再被建议。
+	if len(suggestions) != 1 || suggestions[0].TargetID != "a" || strings.Join(suggestions[0].SourceIDs, ",") != "b" || suggestions[0].Reason == "" {
+		t.Fatalf("Unexpected suggestions %+v", suggestions)
+	}
+	prompt := (*requests)[0].Prompt()
+	if !strings.Contains(prompt, `"aliases":["凤姐"]`) || strings.Contains(prompt, `"id"`) {
+		t.Errorf("Expected names and aliases without IDs in the prompt: %s", prompt)
+	}
+}
@@ -0,0 +1,538 @@
+package llm
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"taco/backend/models"
+	"taco/backend/services/retry"
+)
+
+// LLMConfig.Provider 的取值，为空时使用 OpenAI 兼容接口。
+const (
+	ProviderOpenAI    = "openai"
+	ProviderAnthropic = "anthropic"
+	ProviderOllama    = "ollama"
+	ProviderDashScope = "dashscope"
+)
+
+// defaultMaxTokens 是必须指定输出上限的接口（Anthropic）在未配置 maxTokens 时使用的值。
+const defaultMaxTokens = 8192
+
+var ErrUnknownProvider = errors.New("不支持的 LLM 接口类型")
+
+// ChatRequest 是一次对话请求，与具体接口的格式无关。
+type ChatRequest struct {
+	Messages    []map[string]string
+	Temperature float64
+	// JSONMode 要求接口只输出 JSON 对象，只在 SupportsJSONMode 为 true 时生效。
+	JSONMode bool
+}
+
+// Provider 把对话请求转换为某一类接口的请求格式并解析回复。Complete 只发起一次请求，
+// 重试由调用方负责；非 200 的响应应返回 *retry.HTTPError，配置错误应以 retry.Permanent 包装。
+type Provider interface {
+	Name() string
+	SupportsJSONMode() bool
+	Complete(ctx context.Context, cfg models.LLMConfig, req ChatRequest) (string, error)
+}
+
+var providers = map[string]Provider{
+	ProviderOpenAI:    openAIProvider{},
+	ProviderAnthropic: anthropicProvider{},
+	ProviderOllama:    ollamaProvider{},
+	ProviderDashScope: dashScopeProvider{},
+}
+
+// ProviderFor 返回配置选择的接口实现。
+func ProviderFor(cfg models.LLMConfig) (Provider, error) {
+	name := strings.ToLower(strings.TrimSpace(cfg.Provider))
+	if name == "" {
+		name = ProviderOpenAI
+	}
+	provider, ok := providers[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownProvider, cfg.Provider)
+	}
+	return provider, nil
+}
+
+// httpClient 为所有接口共用，允许很长的响应时间以适应长篇小说的提取。
+var httpClient = &http.Client{
+	Timeout: 600 * time.Second,
+	Transport: &http.Transport{
+		DisableKeepAlives:   false,
+		MaxIdleConns:        100,
+		MaxIdleConnsPerHost: 10,
+		IdleConnTimeout:     90 * time.Second,
+		TLSHandshakeTimeout: 30 * time.Second,
+		// 关键：设置足够长的响应头超时和期望继续超时
+		ExpectContinueTimeout: 10 * time.Second,
+		ResponseHeaderTimeout: 600 * time.Second,
+		DialContext: (&net.Dialer{
+			Timeout:   120 * time.Second, // 连接超时
+			KeepAlive: 30 * time.Second,  // Keep-Alive 探测间隔
+		}).DialContext,
+	},
+}
+
+func requireAPIKey(cfg models.LLMConfig) error {
+	if strings.TrimSpace(cfg.APIKey) == "" {
+		return retry.Permanent(errors.New("请先在配置中填写 LLM API Key"))
+	}
+	return nil
+}
+
+func maxTokens(cfg models.LLMConfig) int {
+	if cfg.MaxTokens > 0 {
+		return cfg.MaxTokens
+	}
+	return defaultMaxTokens
+}
+
+// post 以 JSON 发送 body，返回 200 的响应，调用方负责关闭响应体。
+func post(ctx context.Context, apiURL string, headers map[string]string, body any) (*http.Response, error) {
+	bodyBytes, err := json.Marshal(body)
+	if err != nil {
+		return nil, retry.Permanent(err)
+	}
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, apiURL, bytes.NewReader(bodyBytes))
+	if err != nil {
+		return nil, retry.Permanent(err)
+	}
+	request.Header.Set("Content-Type", "application/json")
+	for key, value := range headers {
+		request.Header.Set(key, value)
+	}
+	request.ContentLength = int64(len(bodyBytes))
+
+	resp, err := httpClient.Do(request)
+	if err != nil {
+		log.Printf("[LLM API] 请求失败: %v", err)
+		return nil, err
+	}
+	log.Printf("[LLM API] 收到响应: HTTP %d", resp.StatusCode)
+
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		httpErr := retry.NewHTTPError(resp, "LLM 请求失败")
+		log.Printf("[LLM API] 错误响应: %s", httpErr.Body)
+		return nil, httpErr
+	}
+	return resp, nil
+}
+
+// postJSON 发送请求并把响应解码到 out。
+func postJSON(ctx context.Context, apiURL string, headers map[string]string, body, out any) error {
+	resp, err := post(ctx, apiURL, headers, body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("解析 LLM 响应失败: %w", err)
+	}
+	return nil
+}
+
+// postSSE 发送流式请求，把每个 Server-Sent Event 交给 onEvent。
+func postSSE(ctx context.Context, apiURL string, headers map[string]string, body any, onEvent func(event, data string) error) error {
+	headers["Accept"] = "text/event-stream"
+	resp, err := post(ctx, apiURL, headers, body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if err := readSSE(resp.Body, onEvent); err != nil {
+		return fmt.Errorf("读取 LLM 流式响应失败: %w", err)
+	}
+	return nil
+}
+
+// openAIProvider 对接 OpenAI 兼容的 /v1/chat/completions，大多数第三方接口都采用这种格式。
+type openAIProvider struct{}
+
+func (openAIProvider) Name() string           { return ProviderOpenAI }
+func (openAIProvider) SupportsJSONMode() bool { return true }
+
+func (openAIProvider) body(cfg models.LLMConfig, req ChatRequest) map[string]any {
+	body := map[string]any{
+		"model":       cfg.Model,
+		"messages":    req.Messages,
+		"temperature": req.Temperature,
+	}
+	if cfg.MaxTokens > 0 {
+		body["max_tokens"] = cfg.MaxTokens
+	}
+	if req.JSONMode {
+		body["response_format"] = map[string]string{"type": "json_object"}
+	}
+	return body
+}
+
+func (p openAIProvider) Complete(ctx context.Context, cfg models.LLMConfig, req ChatRequest) (string, error) {
+	if err := requireAPIKey(cfg); err != nil {
+		return "", err
+	}
+	apiURL := strings.TrimRight(cfg.BaseURL, "/") + "/v1/chat/completions"
+	log.Printf("[LLM API] 发起请求: %s, 模型: %s, 消息数: %d", apiURL, cfg.Model, len(req.Messages))
+	var completion struct {
+		Choices []struct {
+			Message struct {
+				Content string `json:"content"`
+			} `json:"message"`
+		} `json:"choices"`
+	}
+	if err := postJSON(ctx, apiURL, map[string]string{"Authorization": "Bearer " + cfg.APIKey}, p.body(cfg, req), &completion); err != nil {
+		return "", err
+	}
+	if len(completion.Choices) == 0 {
+		return "", errors.New("LLM 未返回结果")
+	}
+	return completion.Choices[0].Message.Content, nil
+}
+
+// Stream 以 stream: true 请求，每个 data 事件是一个 chunk，以 data: [DONE] 结束。
+func (p openAIProvider) Stream(ctx context.Context, cfg models.LLMConfig, req ChatRequest, onDelta func(string)) (string, error) {
+	if err := requireAPIKey(cfg); err != nil {
+		return "", err
+	}
+	body := p.body(cfg, req)
+	body["stream"] = true
+	apiURL := strings.TrimRight(cfg.BaseURL, "/") + "/v1/chat/completions"
+	log.Printf("[LLM API] 发起流式请求: %s, 模型: %s, 消息数: %d", apiURL, cfg.Model, len(req.Messages))
+
+	var content strings.Builder
+	err := postSSE(ctx, apiURL, map[string]string{"Authorization": "Bearer " + cfg.APIKey}, body, func(_, data string) error {
+		if strings.TrimSpace(data) == "[DONE]" {
+			return io.EOF
+		}
+		var chunk struct {
+			Choices []struct {
+				Delta struct {
+					Content string `json:"content"`
+				} `json:"delta"`
+			} `json:"choices"`
+			Error *struct {
+				Message string `json:"message"`
+			} `json:"error"`
+		}
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			return fmt.Errorf("解析 LLM 流式响应失败: %w", err)
+		}
+		if chunk.Error != nil {
+			return fmt.Errorf("LLM 请求失败: %s", chunk.Error.Message)
+		}
+		for _, choice := range chunk.Choices {
+			content.WriteString(choice.Delta.Content)
+			onDelta(choice.Delta.Content)
+		}
+		return nil
+	})
+	return content.String(), err
+}
+
+// anthropicProvider 对接 Anthropic Messages 接口（/v1/messages）。system 消息单独放在 system 字段，
+// 该接口没有 JSON 模式，依靠提示词和结果校验保证格式。
+type anthropicProvider struct{}
+
+const anthropicVersion = "2023-06-01"
+
+func (anthropicProvider) Name() string           { return ProviderAnthropic }
+func (anthropicProvider) SupportsJSONMode() bool { return false }
+
+func (anthropicProvider) body(cfg models.LLMConfig, req ChatRequest) map[string]any {
+	var system []string
+	messages := []map[string]string{}
+	for _, message := range req.Messages {
+		if message["role"] == "system" {
+			system = append(system, message["content"])
+			continue
+		}
+		messages = append(messages, map[string]string{"role": message["role"], "content": message["content"]})
+	}
+	body := map[string]any{
+		"model":       cfg.Model,
+		"max_tokens":  maxTokens(cfg),
+		"messages":    messages,
+		"temperature": req.Temperature,
+	}
+	if len(system) > 0 {
+		body["system"] = strings.Join(system, "\n\n")
+	}
+	return body
+}
+
+func (anthropicProvider) headers(cfg models.LLMConfig) map[string]string {
+	return map[string]string{"x-api-key": cfg.APIKey, "anthropic-version": anthropicVersion}
+}
+
+func (p anthropicProvider) Complete(ctx context.Context, cfg models.LLMConfig, req ChatRequest) (string, error) {
+	if err := requireAPIKey(cfg); err != nil {
+		return "", err
+	}
+	apiURL := strings.TrimRight(cfg.BaseURL, "/") + "/v1/messages"
+	log.Printf("[LLM API] 发起请求: %s, 模型: %s, 消息数: %d", apiURL, cfg.Model, len(req.Messages))
+	var response struct {
+		Content []struct {
+			Type string `json:"type"`
+			Text string `json:"text"`
+		} `json:"content"`
+		StopReason string `json:"stop_reason"`
+	}
+	if err := postJSON(ctx, apiURL, p.headers(cfg), p.body(cfg, req), &response); err != nil {
+		return "", err
+	}
+	var text strings.Builder
+	for _, block := range response.Content {
+		if block.Type == "text" {
+			text.WriteString(block.Text)
+		}
+	}
+	if text.Len() == 0 {
+		return "", errors.New("LLM 未返回结果")
+	}
+	warnTruncated(response.StopReason)
+	return text.String(), nil
+}
+
+// Stream 以 stream: true 请求，文本在 content_block_delta 事件中，以 message_stop 结束。
+func (p anthropicProvider) Stream(ctx context.Context, cfg models.LLMConfig, req ChatRequest, onDelta func(string)) (string, error) {
+	if err := requireAPIKey(cfg); err != nil {
+		return "", err
+	}
+	body := p.body(cfg, req)
+	body["stream"] = true
+	apiURL := strings.TrimRight(cfg.BaseURL, "/") + "/v1/messages"
+	log.Printf("[LLM API] 发起流式请求: %s, 模型: %s, 消息数: %d", apiURL, cfg.Model, len(req.Messages))
+
+	var content strings.Builder
+	err := postSSE(ctx, apiURL, p.headers(cfg), body, func(event, data string) error {
+		var payload struct {
+			Type  string `json:"type"`
+			Delta struct {
+				Type       string `json:"type"`
+				Text       string `json:"text"`
+				StopReason string `json:"stop_reason"`
+			} `json:"delta"`
+			Error struct {
+				Type    string `json:"type"`
+				Message string `json:"message"`
+			} `json:"error"`
+		}
+		if err := json.Unmarshal([]byte(data), &payload); err != nil {
+			return fmt.Errorf("解析 LLM 流式响应失败: %w", err)
+		}
+		switch payload.Type {
+		case "content_block_delta":
+			if payload.Delta.Type == "text_delta" {
+				content.WriteString(payload.Delta.Text)
+				onDelta(payload.Delta.Text)
+			}
+		case "message_delta":
+			warnTruncated(payload.Delta.StopReason)
+		case "message_stop":
+			return io.EOF
+		case "error":
+			return fmt.Errorf("LLM 请求失败: %s %s", payload.Error.Type, payload.Error.Message)
+		}
+		return nil
+	})
+	return content.String(), err
+}
+
+func warnTruncated(stopReason string) {
+	if stopReason == "max_tokens" {
+		log.Printf("[WARN] LLM 输出达到 max_tokens 上限被截断，可以调大 llm.maxTokens")
+	}
+}
+
+// ollamaProvider 对接本地 Ollama 的 /api/chat，不需要 API Key；填写了 Key 时按 Bearer 发送，
+// 以便使用加了鉴权的反向代理。
+type ollamaProvider struct{}
+
+func (ollamaProvider) Name() string           { return ProviderOllama }
+func (ollamaProvider) SupportsJSONMode() bool { return true }
+
+func (ollamaProvider) body(cfg models.LLMConfig, req ChatRequest, stream bool) map[string]any {
+	options := map[string]any{"temperature": req.Temperature}
+	if cfg.MaxTokens > 0 {
+		options["num_predict"] = cfg.MaxTokens
+	}
+	body := map[string]any{
+		"model":    cfg.Model,
+		"messages": req.Messages,
+		"stream":   stream,
+		"options":  options,
+	}
+	if req.JSONMode {
+		body["format"] = "json"
+	}
+	return body
+}
+
+func (ollamaProvider) headers(cfg models.LLMConfig) map[string]string {
+	headers := map[string]string{}
+	if key := strings.TrimSpace(cfg.APIKey); key != "" {
+		headers["Authorization"] = "Bearer " + key
+	}
+	return headers
+}
+
+// ollamaMessage 是 /api/chat 的响应，流式请求时每行一个。
+type ollamaMessage struct {
+	Message struct {
+		Content string `json:"content"`
+	} `json:"message"`
+	Done  bool   `json:"done"`
+	Error string `json:"error"`
+}
+
+func (p ollamaProvider) Complete(ctx context.Context, cfg models.LLMConfig, req ChatRequest) (string, error) {
+	apiURL := strings.TrimRight(cfg.BaseURL, "/") + "/api/chat"
+	log.Printf("[LLM API] 发起请求: %s, 模型: %s, 消息数: %d", apiURL, cfg.Model, len(req.Messages))
+	var response ollamaMessage
+	if err := postJSON(ctx, apiURL, p.headers(cfg), p.body(cfg, req, false), &response); err != nil {
+		return "", err
+	}
+	if response.Error != "" {
+		return "", fmt.Errorf("LLM 请求失败: %s", response.Error)
+	}
+	return response.Message.Content, nil
+}
+
+// Stream 以 stream: true 请求。Ollama 的流式响应不是 SSE，而是每行一个 JSON 对象，最后一行 done 为 true。
+func (p ollamaProvider) Stream(ctx context.Context, cfg models.LLMConfig, req ChatRequest, onDelta func(string)) (string, error) {
+	apiURL := strings.TrimRight(cfg.BaseURL, "/") + "/api/chat"
+	log.Printf("[LLM API] 发起流式请求: %s, 模型: %s, 消息数: %d", apiURL, cfg.Model, len(req.Messages))
+	resp, err := post(ctx, apiURL, p.headers(cfg), p.body(cfg, req, true))
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	var content strings.Builder
+	decoder := json.NewDecoder(resp.Body)
+	for {
+		var line ollamaMessage
+		if err := decoder.Decode(&line); err != nil {
+			if err == io.EOF {
+				return content.String(), nil
+			}
+			return content.String(), fmt.Errorf("读取 LLM 流式响应失败: %w", err)
+		}
+		if line.Error != "" {
+			return content.String(), fmt.Errorf("LLM 请求失败: %s", line.Error)
+		}
+		content.WriteString(line.Message.Content)
+		onDelta(line.Message.Content)
+		if line.Done {
+			return content.String(), nil
+		}
+	}
+}
+
+// dashScopeProvider 对接阿里云 DashScope 原生的文本生成接口，与语音、图像编辑使用同一个接口地址。
+type dashScopeProvider struct{}
+
+func (dashScopeProvider) Name() string           { return ProviderDashScope }
+func (dashScopeProvider) SupportsJSONMode() bool { return true }
+
+func (dashScopeProvider) body(cfg models.LLMConfig, req ChatRequest) (map[string]any, map[string]any) {
+	parameters := map[string]any{
+		"result_format": "message",
+		"temperature":   req.Temperature,
+	}
+	if cfg.MaxTokens > 0 {
+		parameters["max_tokens"] = cfg.MaxTokens
+	}
+	if req.JSONMode {
+		parameters["response_format"] = map[string]string{"type": "json_object"}
+	}
+	body := map[string]any{
+		"model":      cfg.Model,
+		"input":      map[string]any{"messages": req.Messages},
+		"parameters": parameters,
+	}
+	return body, parameters
+}
+
+// dashScopeResponse 是文本生成接口的响应，流式请求时每个 SSE 事件的 data 也是这个格式。
+type dashScopeResponse struct {
+	Output struct {
+		Text    string `json:"text"`
+		Choices []struct {
+			Message struct {
+				Content string `json:"content"`
+			} `json:"message"`
+		} `json:"choices"`
+	} `json:"output"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+func (r dashScopeResponse) content() string {
+	if len(r.Output.Choices) > 0 {
+		return r.Output.Choices[0].Message.Content
+	}
+	return r.Output.Text
+}
+
+const dashScopePath = "/api/v1/services/aigc/text-generation/generation"
+
+func (p dashScopeProvider) Complete(ctx context.Context, cfg models.LLMConfig, req ChatRequest) (string, error) {
+	if err := requireAPIKey(cfg); err != nil {
+		return "", err
+	}
+	body, _ := p.body(cfg, req)
+	apiURL := strings.TrimRight(cfg.BaseURL, "/") + dashScopePath
+	log.Printf("[LLM API] 发起请求: %s, 模型: %s, 消息数: %d", apiURL, cfg.Model, len(req.Messages))
+	var response dashScopeResponse
+	if err := postJSON(ctx, apiURL, map[string]string{"Authorization": "Bearer " + cfg.APIKey}, body, &response); err != nil {
+		return "", err
+	}
+	if response.Code != "" {
+		return "", fmt.Errorf("LLM 请求失败: %s %s", response.Code, response.Message)
+	}
+	if content := response.content(); content != "" {
+		return content, nil
+	}
+	return "", errors.New("LLM 未返回结果")
+}
+
+// Stream 通过 X-DashScope-SSE 请求 SSE 响应，incremental_output 使每个事件只包含新增的文本。
+func (p dashScopeProvider) Stream(ctx context.Context, cfg models.LLMConfig, req ChatRequest, onDelta func(string)) (string, error) {
+	if err := requireAPIKey(cfg); err != nil {
+		return "", err
+	}
+	body, parameters := p.body(cfg, req)
+	parameters["incremental_output"] = true
+	apiURL := strings.TrimRight(cfg.BaseURL, "/") + dashScopePath
+	log.Printf("[LLM API] 发起流式请求: %s, 模型: %s, 消息数: %d", apiURL, cfg.Model, len(req.Messages))
+
+	headers := map[string]string{"Authorization": "Bearer " + cfg.APIKey, "X-DashScope-SSE": "enable"}
+	var content strings.Builder
+	err := postSSE(ctx, apiURL, headers, body, func(event, data string) error {
+		var chunk dashScopeResponse
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			return fmt.Errorf("解析 LLM 流式响应失败: %w", err)
+		}
+		if event == "error" || chunk.Code != "" {
+			return fmt.Errorf("LLM 请求失败: %s %s", chunk.Code, chunk.Message)
+		}
+		delta := chunk.content()
+		content.WriteString(delta)
+		onDelta(delta)
+		return nil
+	})
+	return content.String(), err
+}
@@ -0,0 +1,216 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"taco/backend/models"
+)
+
+// fakeRequest 是 fakeLLMServer 收到的一次请求，N 为从 1 开始的请求序号。
+type fakeRequest struct {
+	N      int
+	Header http.Header
+	Body   map[string]any
+}
+
+// Prompt 返回请求中最后一条消息的内容。
+func (r fakeRequest) Prompt() string {
+	messages, _ := r.Body["messages"].([]any)
+	if len(messages) == 0 {
+		return ""
+	}
+	message, _ := messages[len(messages)-1].(map[string]any)
+	content, _ := message["content"].(string)
+	return content
+}
+
+// sseReply 让 fakeLLMServer 按顺序写出每一行并立即刷新，模拟流式输出。
+type sseReply []string
+
+// errorReply 让 fakeLLMServer 以 Status 状态码返回 Message。
+type errorReply struct {
+	Status  int
+	Message string
+}
+
+// fakeLLMServer 启动模拟的模型接口：path 非空时检查请求路径，记录每次请求，并写回 reply 的返回值。
+// 返回值为 sseReply 或 errorReply 时按上面的说明处理，其他值编码为 JSON。
+func fakeLLMServer(t *testing.T, path string, reply func(req fakeRequest) any) (*httptest.Server, *[]fakeRequest) {
+	t.Helper()
+	var mu sync.Mutex
+	requests := []fakeRequest{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if path != "" && r.URL.Path != path {
+			t.Errorf("Expected path %q, got %q", path, r.URL.Path)
+		}
+		req := fakeRequest{Header: r.Header.Clone(), Body: map[string]any{}}
+		json.NewDecoder(r.Body).Decode(&req.Body)
+		mu.Lock()
+		req.N = len(requests) + 1
+		requests = append(requests, req)
+		mu.Unlock()
+
+		switch v := reply(req).(type) {
+		case sseReply:
+			for _, line := range v {
+				fmt.Fprint(w, line)
+				w.(http.Flusher).Flush()
+			}
+		case errorReply:
+			http.Error(w, v.Message, v.Status)
+		default:
+			json.NewEncoder(w).Encode(v)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server, &requests
+}
+
+// chatReply 是 OpenAI 兼容接口返回 content 的回复体。
+func chatReply(content string) map[string]any {
+	return map[string]any{
+		"choices": []map[string]any{{"message": map[string]string{"content": content}}},
+	}
+}
+
+// replyWith 对每次请求都返回 v。
+func replyWith(v any) func(fakeRequest) any {
+	return func(fakeRequest) any { return v }
+}
+
+// replySequence 依次返回 contents 中的内容，用完后重复最后一条。
+func replySequence(contents ...string) func(fakeRequest) any {
+	return func(req fakeRequest) any {
+		return chatReply(contents[min(req.N, len(contents))-1])
+	}
+}
+
+var providerMessages = []map[string]string{
+	{"role": "system", "content": "你是助手"},
+	{"role": "user", "content": "你好"},
+}
+
+func TestOpenAIProvider(t *testing.T) {
+	server, requests := fakeLLMServer(t, "/v1/chat/completions", replyWith(chatReply("回复")))
+	cfg := models.LLMConfig{Model: "gpt", BaseURL: server.URL + "/", APIKey: "k"}
+
+	content, err := openAIProvider{}.Complete(context.Background(), cfg, ChatRequest{Messages: providerMessages, Temperature: 0.3, JSONMode: true})
+	if err != nil || content != "回复" {
+		t.Fatalf("Unexpected result %q, %v", content, err)
+	}
+	req := (*requests)[0]
+	if req.Header.Get("Authorization") != "Bearer k" {
+		t.Errorf("Unexpected Authorization %q", req.Header.Get("Authorization"))
+	}
+	if req.Body["model"] != "gpt" || req.Body["temperature"] != 0.3 || req.Body["response_format"] == nil || req.Body["max_tokens"] != nil {
+		t.Errorf("Unexpected body %v", req.Body)
+	}
+}
+
+func TestAnthropicProvider(t *testing.T) {
+	server, requests := fakeLLMServer(t, "/v1/messages", replyWith(map[string]any{
+		"content": []map[string]string{
+			{"type": "text", "text": "第一段"},
+			{"type": "tool_use"},
+			{"type": "text", "text": "第二段"},
+		},
+		"stop_reason": "end_turn",
+	}))
+	cfg := models.LLMConfig{Provider: ProviderAnthropic, Model: "claude", BaseURL: server.URL, APIKey: "k"}
+
+	content, err := anthropicProvider{}.Complete(context.Background(), cfg, ChatRequest{Messages: providerMessages, Temperature: 0.5, JSONMode: true})
+	if err != nil || content != "第一段第二段" {
+		t.Fatalf("Unexpected result %q, %v", content, err)
+	}
+	req := (*requests)[0]
+	if req.Header.Get("x-api-key") != "k" || req.Header.Get("anthropic-version") != anthropicVersion || req.Header.Get("Authorization") != "" {
+		t.Errorf("Unexpected headers %v", req.Header)
+	}
+	messages := req.Body["messages"].([]any)
+	if req.Body["system"] != "你是助手" || len(messages) != 1 || messages[0].(map[string]any)["role"] != "user" {
+		t.Errorf("Expected the system prompt to be moved out of messages, got %v", req.Body)
+	}
+	if req.Body["max_tokens"] != float64(defaultMaxTokens) || req.Body["response_format"] != nil {
+		t.Errorf("Unexpected body %v", req.Body)
+	}
+}
+
+func TestOllamaProvider(t *testing.T) {
+	server, requests := fakeLLMServer(t, "/api/chat", replyWith(map[string]any{
+		"message": map[string]string{"role": "assistant", "content": "回复"},
+		"done":    true,
+	}))
+	cfg := models.LLMConfig{Provider: ProviderOllama, Model: "qwen2.5", BaseURL: server.URL, MaxTokens: 512}
+
+	content, err := ollamaProvider{}.Complete(context.Background(), cfg, ChatRequest{Messages: providerMessages, Temperature: 0.2, JSONMode: true})
+	if err != nil || content != "回复" {
+		t.Fatalf("Unexpected result %q, %v", content, err)
+	}
+	req := (*requests)[0]
+	if req.Header.Get("Authorization") != "" {
+		t.Errorf("Expected no Authorization without an API key, got %q", req.Header.Get("Authorization"))
+	}
+	options := req.Body["options"].(map[string]any)
+	if req.Body["stream"] != false || req.Body["format"] != "json" || options["temperature"] != 0.2 || options["num_predict"] != float64(512) {
+		t.Errorf("Unexpected body %v", req.Body)
+	}
+}
+
+func TestDashScopeProvider(t *testing.T) {
+	server, requests := fakeLLMServer(t, "/api/v1/services/aigc/text-generation/generation", replyWith(map[string]any{
+		"output": map[string]any{
+			"choices": []map[string]any{{"message": map[string]string{"role": "assistant", "content": "回复"}}},
+		},
+		"request_id": "r",
+	}))
+	cfg := models.LLMConfig{Provider: ProviderDashScope, Model: "qwen-plus", BaseURL: server.URL, APIKey: "k"}
+
+	content, err := dashScopeProvider{}.Complete(context.Background(), cfg, ChatRequest{Messages: providerMessages, Temperature: 0.7})
+	if err != nil || content != "回复" {
+		t.Fatalf("Unexpected result %q, %v", content, err)
+	}
+	req := (*requests)[0]
+	if req.Header.Get("Authorization") != "Bearer k" {
+		t.Errorf("Unexpected Authorization %q", req.Header.Get("Authorization"))
+	}
+	input := req.Body["input"].(map[string]any)
+	parameters := req.Body["parameters"].(map[string]any)
+	if len(input["messages"].([]any)) != 2 || parameters["result_format"] != "message" || parameters["response_format"] != nil {
+		t.Errorf("Unexpected body %v", req.Body)
+	}
+}
+
+func TestProviderFor(t *testing.T) {
+	for name, want := range map[string]string{"": ProviderOpenAI, " Anthropic ": ProviderAnthropic, "ollama": ProviderOllama} {
+		provider, err := ProviderFor(models.LLMConfig{Provider: name})
+		if err != nil || provider.Name() != want {
+			t.Errorf("%q: got %v, %v; want %s", name, provider, err, want)
+		}
+	}
+	if _, err := ProviderFor(models.LLMConfig{Provider: "gemini"}); !errors.Is(err, ErrUnknownProvider) {
+		t.Errorf("Expected ErrUnknownProvider, got %v", err)
+	}
+}
+
+func TestInvokeLLMOllamaWithoutAPIKey(t *testing.T) {
+	server, requests := fakeLLMServer(t, "/api/chat", replyWith(map[string]any{
+		"message": map[string]string{"content": "  回复\n"},
+	}))
+	cfg := models.Config{LLM: models.LLMConfig{Provider: ProviderOllama, Model: "llama3", BaseURL: server.URL}}
+
+	content, err := InvokeLLM(context.Background(), cfg, providerMessages, 0.7)
+	if err != nil || content != "回复" {
+		t.Fatalf("Unexpected result %q, %v", content, err)
+	}
+	req := (*requests)[0]
+	if req.Body["format"] != nil {
+		t.Errorf("InvokeLLM should not request JSON mode, got %v", req.Body)
+	}
+}
@@ -0,0 +1,175 @@
+package llm
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"strings"
+
+	"taco/backend/models"
+)
+
+// StreamHandler 接收流式请求的实时输出，通过 WithStream 附加到 context 上。
+// 附加后支持流式的接口会以 stream: true 请求，其余接口照常一次性返回。
+type StreamHandler struct {
+	// Delta 在收到模型输出的增量文本时调用。
+	Delta func(text string)
+	// Item 在输出中 JSON 数组（或 {"characters": [...]} 这类包装对象中的数组）的一个对象元素完整后调用。
+	// 元素还没有经过 Schema 校验，输出不合格时修正请求可能再次输出同一元素。
+	Item func(item json.RawMessage)
+}
+
+type streamKey struct{}
+
+// WithStream 返回附加了 handler 的 context，之后经过该 context 的 LLM 请求都会使用流式输出。
+func WithStream(ctx context.Context, handler StreamHandler) context.Context {
+	return context.WithValue(ctx, streamKey{}, &handler)
+}
+
+func streamFrom(ctx context.Context) (*StreamHandler, bool) {
+	handler, ok := ctx.Value(streamKey{}).(*StreamHandler)
+	return handler, ok && handler != nil
+}
+
+// begin 返回一次请求的增量回调。每次请求（包括重试）都从头解析 JSON 元素。
+func (h *StreamHandler) begin() func(string) {
+	scanner := &itemScanner{emit: h.Item, itemStart: -1}
+	return func(delta string) {
+		if delta == "" {
+			return
+		}
+		if h.Delta != nil {
+			h.Delta(delta)
+		}
+		if h.Item != nil {
+			scanner.write(delta)
+		}
+	}
+}
+
+// Streamer 是支持流式输出的 Provider。Stream 只发起一次请求，每收到一段文本调用 onDelta，
+// 结束后返回完整的输出。
+type Streamer interface {
+	Stream(ctx context.Context, cfg models.LLMConfig, req ChatRequest, onDelta func(string)) (string, error)
+}
+
+// itemScanner 在流式输出中找到第一个 JSON 数组（顶层数组或顶层对象中的数组），
+// 每当其中一个对象元素的括号闭合时调用 emit。数组之前的说明文字和代码块标记会被跳过。
+type itemScanner struct {
+	emit func(json.RawMessage)
+
+	buf        strings.Builder
+	pos        int
+	depth      int
+	inString   bool
+	escaped    bool
+	arrayDepth int
+	itemStart  int
+	emitted    int
+	done       bool
+}
+
+func (s *itemScanner) write(delta string) {
+	if s.done {
+		return
+	}
+	s.buf.WriteString(delta)
+	text := s.buf.String()
+	for ; s.pos < len(text) && !s.done; s.pos++ {
+		c := text[s.pos]
+		if s.inString {
+			switch {
+			case s.escaped:
+				s.escaped = false
+			case c == '\\':
+				s.escaped = true
+			case c == '"':
+				s.inString = false
+			}
+			continue
+		}
+		switch c {
+		case '"':
+			// 找到数组之前的引号多半是说明文字里的，不进入字符串状态。
+			if s.depth > 0 {
+				s.inString = true
+			}
+		case '[', '{':
+			s.depth++
+			switch {
+			case s.arrayDepth == 0 && c == '[' && s.depth <= 2:
+				s.arrayDepth = s.depth
+			case s.arrayDepth > 0 && s.depth == s.arrayDepth+1 && c == '{':
+				s.itemStart = s.pos
+			}
+		case ']', '}':
+			if s.depth == 0 {
+				continue
+			}
+			if s.arrayDepth > 0 && s.depth == s.arrayDepth+1 && c == '}' && s.itemStart >= 0 {
+				item := text[s.itemStart : s.pos+1]
+				s.itemStart = -1
+				if json.Valid([]byte(item)) {
+					s.emitted++
+					s.emit(json.RawMessage(item))
+				}
+			}
+			if s.depth == s.arrayDepth && c == ']' {
+				// 没有对象元素的数组可能是说明文字中的方括号，继续寻找后面的数组。
+				s.done = s.emitted > 0
+				s.arrayDepth = 0
+			}
+			s.depth--
+		}
+	}
+}
+
+// readSSE 逐个读取 Server-Sent Events，把事件名和 data（多行时以换行连接）交给 onEvent，
+// onEvent 返回 io.EOF 表示正常结束。
+func readSSE(body io.Reader, onEvent func(event, data string) error) error {
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 0, 64<<10), 4<<20)
+	var event string
+	var data []string
+	dispatch := func() error {
+		if len(data) == 0 {
+			event = ""
+			return nil
+		}
+		err := onEvent(event, strings.Join(data, "\n"))
+		event, data = "", nil
+		return err
+	}
+	for scanner.Scan() {
+		line := strings.TrimSuffix(scanner.Text(), "\r")
+		if line == "" {
+			if err := dispatch(); err != nil {
+				return ignoreEOF(err)
+			}
+			continue
+		}
+		if strings.HasPrefix(line, ":") {
+			continue
+		}
+		field, value, _ := strings.Cut(line, ":")
+		value = strings.TrimPrefix(value, " ")
+		switch field {
+		case "event":
+			event = value
+		case "data":
+			data = append(data, value)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	return ignoreEOF(dispatch())
+}
+
+func ignoreEOF(err error) error {
+	if err == io.EOF {
+		return nil
+	}
+	return err
+}
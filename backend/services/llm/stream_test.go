@@ -0,0 +1,129 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+
+	"taco/backend/models"
+)
+
+func TestItemScannerEmitsCompletedObjects(t *testing.T) {
+	output := "好的 [注]：\n```json\n{\"characters\": [{\"name\": \"甲}\", \"tags\": [\"a\"]}, {\"name\": \"乙\"}, \"x\"]}\n```\n[{\"name\": \"丙\"}]"
+	var items []string
+	scanner := &itemScanner{emit: func(item json.RawMessage) { items = append(items, string(item)) }, itemStart: -1}
+	// 逐字节写入，模拟任意切分的流式输出。
+	for i := 0; i < len(output); i++ {
+		scanner.write(output[i : i+1])
+		if i == strings.Index(output, `, {"name": "乙"}`) && len(items) != 1 {
+			t.Fatalf("Expected the first item as soon as it closed, got %v", items)
+		}
+	}
+	want := []string{`{"name": "甲}", "tags": ["a"]}`, `{"name": "乙"}`}
+	if fmt.Sprint(items) != fmt.Sprint(want) {
+		t.Errorf("got %v, want %v", items, want)
+	}
+}
+
+func TestReadSSE(t *testing.T) {
+	body := ": keep-alive\n\nevent: result\ndata: {\"a\":\ndata: 1}\n\ndata: [DONE]\n\ndata: ignored\n\n"
+	var events []string
+	err := readSSE(strings.NewReader(body), func(event, data string) error {
+		if data == "[DONE]" {
+			return io.EOF
+		}
+		events = append(events, event+"|"+data)
+		return nil
+	})
+	if err != nil || len(events) != 1 || events[0] != "result|{\"a\":\n1}" {
+		t.Errorf("Unexpected events %q, %v", events, err)
+	}
+}
+
+func TestCallLLMForCharactersStreamsItems(t *testing.T) {
+	server, requests := fakeLLMServer(t, "/v1/chat/completions", replyWith(sseReply{
+		"data: {\"choices\":[{\"delta\":{\"content\":\"{\\\"characters\\\": [{\\\"name\\\": \\\"林\"}}]}\n\n",
+		"data: {\"choices\":[{\"delta\":{\"content\":\"黛玉\\\"}, {\\\"name\\\"\"}}]}\n\n",
+		"data: {\"choices\":[{\"delta\":{\"content\":\": \\\"贾宝玉\\\"}]}\"}}]}\n\n",
+		"data: [DONE]\n\n",
+	}))
+	cfg := models.Config{LLM: models.LLMConfig{Model: "m", BaseURL: server.URL, APIKey: "k"}}
+
+	var deltas, items []string
+	ctx := WithStream(context.Background(), StreamHandler{
+		Delta: func(text string) { deltas = append(deltas, text) },
+		Item:  func(item json.RawMessage) { items = append(items, string(item)) },
+	})
+	characters, err := CallLLMForCharacters(ctx, cfg, "小说内容", nil)
+	if err != nil {
+		t.Fatalf("CallLLMForCharacters failed: %v", err)
+	}
+	if body := (*requests)[0].Body; body["stream"] != true {
+		t.Errorf("Expected a streaming request, got %v", body)
+	}
+	if len(deltas) != 3 || len(items) != 2 || items[0] != `{"name": "林黛玉"}` {
+		t.Errorf("Unexpected deltas %q and items %q", deltas, items)
+	}
+	if len(characters) != 2 || characters[1].Name != "贾宝玉" {
+		t.Errorf("Unexpected characters %+v", characters)
+	}
+}
+
+func TestProviderStreams(t *testing.T) {
+	tests := []struct {
+		name     string
+		provider Provider
+		path     string
+		lines    []string
+	}{
+		{"anthropic", anthropicProvider{}, "/v1/messages", []string{
+			"event: message_start\ndata: {\"type\":\"message_start\"}\n\n",
+			"event: content_block_delta\ndata: {\"type\":\"content_block_delta\",\"delta\":{\"type\":\"text_delta\",\"text\":\"你\"}}\n\n",
+			"event: ping\ndata: {\"type\":\"ping\"}\n\n",
+			"event: content_block_delta\ndata: {\"type\":\"content_block_delta\",\"delta\":{\"type\":\"text_delta\",\"text\":\"好\"}}\n\n",
+			"event: message_stop\ndata: {\"type\":\"message_stop\"}\n\n",
+		}},
+		{"ollama", ollamaProvider{}, "/api/chat", []string{
+			"{\"message\":{\"content\":\"你\"},\"done\":false}\n",
+			"{\"message\":{\"content\":\"好\"},\"done\":false}\n",
+			"{\"message\":{\"content\":\"\"},\"done\":true}\n",
+		}},
+		{"dashscope", dashScopeProvider{}, dashScopePath, []string{
+			"id:1\nevent:result\ndata:{\"output\":{\"choices\":[{\"message\":{\"content\":\"你\"}}]}}\n\n",
+			"id:2\nevent:result\ndata:{\"output\":{\"choices\":[{\"message\":{\"content\":\"好\"}}]}}\n\n",
+		}},
+	}
+	for _, tt := range tests {
+		server, requests := fakeLLMServer(t, tt.path, replyWith(sseReply(tt.lines)))
+		cfg := models.LLMConfig{Model: "m", BaseURL: server.URL, APIKey: "k"}
+		var deltas []string
+		content, err := tt.provider.(Streamer).Stream(context.Background(), cfg, ChatRequest{Messages: providerMessages}, func(text string) {
+			deltas = append(deltas, text)
+		})
+		if err != nil || content != "你好" || strings.Join(deltas, "") != "你好" {
+			t.Errorf("%s: got %q (%q), %v", tt.name, content, deltas, err)
+		}
+		body := (*requests)[0].Body
+		streaming := body["stream"] == true
+		if tt.name == "dashscope" {
+			streaming = body["parameters"].(map[string]any)["incremental_output"] == true
+		}
+		if !streaming {
+			t.Errorf("%s: expected a streaming request, got %v", tt.name, body)
+		}
+	}
+}
+
+func TestStreamReportsErrorEvent(t *testing.T) {
+	server, _ := fakeLLMServer(t, "/v1/messages", replyWith(sseReply{
+		"event: error\ndata: {\"type\":\"error\",\"error\":{\"type\":\"overloaded_error\",\"message\":\"Overloaded\"}}\n\n",
+	}))
+	cfg := models.LLMConfig{Model: "m", BaseURL: server.URL, APIKey: "k"}
+	_, err := anthropicProvider{}.Stream(context.Background(), cfg, ChatRequest{Messages: providerMessages}, func(string) {})
+	if err == nil || !strings.Contains(err.Error(), "overloaded_error") {
+		t.Errorf("Expected the error event to be reported, got %v", err)
+	}
+}
@@ -0,0 +1,319 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+
+	"taco/backend/models"
+)
+
+// defaultRepairAttempts 是 LLM 输出无法通过校验时，要求模型修正的默认次数。
+const defaultRepairAttempts = 2
+
+var ErrNoJSON = errors.New("LLM 响应中没有 JSON")
+
+// FieldType 是 Schema 中字段的类型。
+type FieldType string
+
+const (
+	FieldString      FieldType = "string"
+	FieldStringArray FieldType = "string[]"
+)
+
+// Field 描述数组元素中的一个字段。Required 的字符串字段不能为空。
+type Field struct {
+	Name     string
+	Type     FieldType
+	Required bool
+	Desc     string
+}
+
+// Schema 描述要求 LLM 返回的 JSON：一个对象数组，也可以包在以 Wrapper 为键的对象中。
+// JSON 模式只允许返回对象，此时要求模型使用包装形式。
+type Schema struct {
+	Name    string
+	Wrapper string
+	Fields  []Field
+}
+
+var CharacterSchema = Schema{
+	Name:    "人物",
+	Wrapper: "characters",
+	Fields: []Field{
+		{Name: "name", Type: FieldString, Required: true, Desc: "人物名"},
+		{Name: "aliases", Type: FieldStringArray, Desc: "文中对该人物的其他称呼数组（如小名、称谓、姓氏加“氏”），没有则为空数组"},
+		{Name: "description", Type: FieldString, Desc: "特征描述"},
+		{Name: "gender", Type: FieldString, Desc: "性别"},
+		{Name: "ageRange", Type: FieldString, Desc: "年龄段，如“十五六岁”“中年”"},
+		{Name: "build", Type: FieldString, Desc: "身材体型"},
+		{Name: "hair", Type: FieldString, Desc: "发型和发色"},
+		{Name: "eyes", Type: FieldString, Desc: "眼睛的形状和颜色"},
+		{Name: "clothing", Type: FieldString, Desc: "标志性的服装和配饰"},
+		{Name: "colors", Type: FieldStringArray, Desc: "代表色数组，如服装和发色的主色调"},
+		{Name: "personality", Type: FieldString, Desc: "性格"},
+		{Name: "speechStyle", Type: FieldString, Desc: "说话风格、口头禅"},
+		{Name: "role", Type: FieldString, Desc: "在故事中的身份和作用"},
+		{Name: "visualPrompt", Type: FieldString, Desc: "简短的英文外观提示词（逗号分隔的短语，不超过 40 个词），只写固定的外貌、发型、服装和配色，不写动作、表情和背景，如 \"young woman, slender, long black hair in a bun, almond-shaped brown eyes, pale green hanfu with white sash\"；原文没有提到的外观可合理补全"},
+	},
+}
+
+var SceneSchema = Schema{
+	Name:    "场景",
+	Wrapper: "scenes",
+	Fields: []Field{
+		{Name: "title", Type: FieldString, Required: true, Desc: "场景名称"},
+		{Name: "characters", Type: FieldStringArray, Desc: "出场人物名称数组"},
+		{Name: "description", Type: FieldString, Desc: "场景的视觉/剧情描述"},
+		{Name: "dialogues", Type: FieldStringArray, Desc: "关键对话数组，每个元素是一句话"},
+		{Name: "narration", Type: FieldString, Desc: "旁白或解说词"},
+	},
+}
+
+var MergeSchema = Schema{
+	Name:    "合并建议",
+	Wrapper: "merges",
+	Fields: []Field{
+		{Name: "name", Type: FieldString, Required: true, Desc: "应保留的人物名称，必须是列表中的名称"},
+		{Name: "duplicates", Type: FieldStringArray, Desc: "与其为同一人物、应并入的其他人物名称数组"},
+		{Name: "reason", Type: FieldString, Desc: "判断为同一人物的理由"},
+	},
+}
+
+// Instruction 返回要求模型按 Schema 输出的说明，jsonMode 为 true 时要求使用包装对象。
+func (s Schema) Instruction(jsonMode bool) string {
+	var b strings.Builder
+	if jsonMode {
+		fmt.Fprintf(&b, `请输出 JSON 对象 {"%s": [...]}，数组中每个元素为一个%s对象，包含字段:`, s.Wrapper, s.Name)
+	} else {
+		fmt.Fprintf(&b, "请输出 JSON 数组，每个元素为一个%s对象，包含字段:", s.Name)
+	}
+	for _, field := range s.Fields {
+		fmt.Fprintf(&b, "\n- %q: %s", field.Name, field.Desc)
+		if field.Required {
+			b.WriteString("（必填）")
+		}
+	}
+	b.WriteString("\n\n仅返回可被 JSON 解析的内容，不要添加 Markdown 代码块或额外说明。")
+	return b.String()
+}
+
+// ValidationError 列出 LLM 输出不符合 Schema 的地方，会原样写进修复提示。
+type ValidationError struct {
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	return "LLM 输出格式不正确: " + strings.Join(e.Problems, "；")
+}
+
+// ExtractJSON 从模型输出中取出 JSON：去掉 Markdown 代码块，跳过前后的说明文字，
+// 返回第一个完整的 JSON 数组或对象。
+func ExtractJSON(content string) (string, error) {
+	content = strings.TrimSpace(content)
+	if fenced, ok := stripFence(content); ok {
+		content = fenced
+	}
+	start := strings.IndexAny(content, "[{")
+	for start >= 0 {
+		if end := matchBracket(content, start); end > start {
+			candidate := content[start : end+1]
+			if json.Valid([]byte(candidate)) {
+				return candidate, nil
+			}
+		}
+		next := strings.IndexAny(content[start+1:], "[{")
+		if next < 0 {
+			break
+		}
+		start += next + 1
+	}
+	return "", ErrNoJSON
+}
+
+// stripFence 取出第一个 ``` 代码块中的内容，代码块后可以有语言标记。
+func stripFence(content string) (string, bool) {
+	open := strings.Index(content, "```")
+	if open < 0 {
+		return "", false
+	}
+	body := content[open+3:]
+	if newline := strings.IndexByte(body, '\n'); newline >= 0 && !strings.ContainsAny(body[:newline], "[{") {
+		body = body[newline+1:]
+	}
+	if end := strings.Index(body, "```"); end >= 0 {
+		body = body[:end]
+	}
+	return strings.TrimSpace(body), true
+}
+
+// matchBracket 返回与 content[start] 配对的括号位置，跳过字符串中的括号，找不到时返回 -1。
+func matchBracket(content string, start int) int {
+	depth, inString, escaped := 0, false, false
+	for i := start; i < len(content); i++ {
+		c := content[i]
+		if inString {
+			switch {
+			case escaped:
+				escaped = false
+			case c == '\\':
+				escaped = true
+			case c == '"':
+				inString = false
+			}
+			continue
+		}
+		switch c {
+		case '"':
+			inString = true
+		case '[', '{':
+			depth++
+		case ']', '}':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// Decode 从模型输出中提取 JSON，按 Schema 校验后解码到 out（对象切片的指针）。
+// 空输出视为空数组。
+func (s Schema) Decode(content string, out any) error {
+	if strings.TrimSpace(content) == "" {
+		return json.Unmarshal([]byte("[]"), out)
+	}
+	payload, err := ExtractJSON(content)
+	if err != nil {
+		return &ValidationError{Problems: []string{"没有找到 JSON 数组"}}
+	}
+	items, err := s.validate([]byte(payload))
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(items, out)
+}
+
+// validate 返回数组部分的 JSON。
+func (s Schema) validate(payload []byte) (json.RawMessage, error) {
+	var value any
+	if err := json.Unmarshal(payload, &value); err != nil {
+		return nil, &ValidationError{Problems: []string{"JSON 无法解析: " + err.Error()}}
+	}
+	items, ok := value.([]any)
+	if object, isObject := value.(map[string]any); isObject {
+		items, ok = object[s.Wrapper].([]any)
+		if !ok {
+			return nil, &ValidationError{Problems: []string{fmt.Sprintf("应为数组或包含 %q 数组的对象", s.Wrapper)}}
+		}
+	}
+	if !ok {
+		return nil, &ValidationError{Problems: []string{"应为 JSON 数组"}}
+	}
+
+	var problems []string
+	for i, item := range items {
+		object, ok := item.(map[string]any)
+		if !ok {
+			problems = append(problems, fmt.Sprintf("第 %d 个元素应为对象", i+1))
+			continue
+		}
+		for _, field := range s.Fields {
+			if problem := field.check(object[field.Name]); problem != "" {
+				problems = append(problems, fmt.Sprintf("第 %d 个元素的 %q %s", i+1, field.Name, problem))
+			}
+		}
+	}
+	if len(problems) > 0 {
+		return nil, &ValidationError{Problems: problems}
+	}
+	return json.Marshal(items)
+}
+
+func (f Field) check(value any) string {
+	if value == nil {
+		if f.Required {
+			return "缺失"
+		}
+		return ""
+	}
+	switch f.Type {
+	case FieldString:
+		text, ok := value.(string)
+		if !ok {
+			return "应为字符串"
+		}
+		if f.Required && strings.TrimSpace(text) == "" {
+			return "不能为空"
+		}
+	case FieldStringArray:
+		list, ok := value.([]any)
+		if !ok {
+			return "应为字符串数组"
+		}
+		for _, element := range list {
+			if _, ok := element.(string); !ok {
+				return "应为字符串数组"
+			}
+		}
+	}
+	return ""
+}
+
+func repairAttempts(cfg models.Config) int {
+	switch {
+	case cfg.LLM.RepairAttempts < 0:
+		return 0
+	case cfg.LLM.RepairAttempts == 0:
+		return defaultRepairAttempts
+	}
+	return cfg.LLM.RepairAttempts
+}
+
+// invokeStructured 请求 LLM 并按 schema 解码结果。prompt 中的格式说明由 schema 生成并追加在末尾；
+// 输出无法通过校验时，把错误反馈给模型要求修正，最多 cfg.LLM.RepairAttempts 次。
+func invokeStructured(ctx context.Context, cfg models.Config, system, prompt string, temperature float64, schema Schema, out any) error {
+	jsonMode := jsonModeEnabled(cfg)
+	messages := []map[string]string{
+		{"role": "system", "content": system},
+		{"role": "user", "content": prompt + "\n\n" + schema.Instruction(jsonMode)},
+	}
+
+	attempts := repairAttempts(cfg)
+	for attempt := 0; ; attempt++ {
+		content, err := invokeLLM(ctx, cfg, messages, temperature, jsonMode)
+		if err != nil {
+			return err
+		}
+		err = schema.Decode(content, out)
+		var invalid *ValidationError
+		if err == nil || !errors.As(err, &invalid) || attempt >= attempts {
+			if err != nil {
+				return fmt.Errorf("解析 LLM 响应失败: %w", err)
+			}
+			return nil
+		}
+		log.Printf("[WARN] LLM 输出未通过校验，要求修正（%d/%d）: %v", attempt+1, attempts, err)
+		messages = append(messages,
+			map[string]string{"role": "assistant", "content": content},
+			map[string]string{"role": "user", "content": repairPrompt(schema, invalid, jsonMode)},
+		)
+	}
+}
+
+func repairPrompt(schema Schema, invalid *ValidationError, jsonMode bool) string {
+	var b strings.Builder
+	b.WriteString("你上一次的输出不符合要求：\n")
+	for _, problem := range invalid.Problems {
+		b.WriteString("- ")
+		b.WriteString(problem)
+		b.WriteByte('\n')
+	}
+	b.WriteString("\n请修正后重新输出完整结果。")
+	b.WriteString(schema.Instruction(jsonMode))
+	return b.String()
+}
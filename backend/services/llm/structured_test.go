@@ -0,0 +1,114 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+
+	"taco/backend/models"
+)
+
+func TestExtractJSON(t *testing.T) {
+	tests := []struct {
+		name, content, want string
+	}{
+		{"plain", `[{"name": "甲"}]`, `[{"name": "甲"}]`},
+		{"fence", "```json\n[{\"name\": \"甲\"}]\n```", `[{"name": "甲"}]`},
+		{"fence without language", "```\n{\"scenes\": []}\n```", `{"scenes": []}`},
+		{"prose", "好的，以下是结果：\n[{\"name\": \"甲\"}]\n希望对你有帮助。", `[{"name": "甲"}]`},
+		{"brackets in strings", `结果 [注] [{"name": "甲]", "description": "{括号}"}]`, `[{"name": "甲]", "description": "{括号}"}]`},
+	}
+	for _, tt := range tests {
+		got, err := ExtractJSON(tt.content)
+		if err != nil || got != tt.want {
+			t.Errorf("%s: got %q, %v; want %q", tt.name, got, err, tt.want)
+		}
+	}
+	if _, err := ExtractJSON("没有结果"); !errors.Is(err, ErrNoJSON) {
+		t.Errorf("Expected ErrNoJSON, got %v", err)
+	}
+}
+
+func TestSchemaDecodeValidation(t *testing.T) {
+	var characters []models.CharacterProfile
+	if err := CharacterSchema.Decode(`{"characters": [{"name": "甲", "description": "乙"}]}`, &characters); err != nil || len(characters) != 1 {
+		t.Fatalf("Expected wrapped characters, got %+v, %v", characters, err)
+	}
+
+	err := SceneSchema.Decode(`[{"title": ""}, {"title": "夜", "dialogues": [{"speaker": "甲"}]}, "场景"]`, &[]models.Scene{})
+	var invalid *ValidationError
+	if !errors.As(err, &invalid) || len(invalid.Problems) != 3 {
+		t.Fatalf("Expected 3 problems, got %v", err)
+	}
+	if !strings.Contains(invalid.Problems[0], `"title"`) || !strings.Contains(invalid.Problems[1], `"dialogues"`) {
+		t.Errorf("Unexpected problems %v", invalid.Problems)
+	}
+}
+
+func TestCallLLMForCharactersRepairsInvalidOutput(t *testing.T) {
+	server, requests := fakeLLMServer(t, "", replySequence(
+		"```json\n[{\"description\": \"没有名字\"}]\n```",
+		`{"characters": [{"name": "林黛玉", "description": "体弱多病"}]}`,
+	))
+	cfg := models.Config{LLM: models.LLMConfig{Model: "m", BaseURL: server.URL, APIKey: "k"}}
+
+	characters, err := CallLLMForCharacters(context.Background(), cfg, "小说内容", nil)
+	if err != nil {
+		t.Fatalf("CallLLMForCharacters failed: %v", err)
+	}
+	if len(characters) != 1 || characters[0].Name != "林黛玉" {
+		t.Errorf("Unexpected characters %+v", characters)
+	}
+	if len(*requests) != 2 {
+		t.Fatalf("Expected 2 requests, got %d", len(*requests))
+	}
+	if (*requests)[0].Body["response_format"] == nil {
+		t.Error("Expected JSON mode to be requested")
+	}
+	messages := (*requests)[1].Body["messages"].([]any)
+	repair := messages[len(messages)-1].(map[string]any)["content"].(string)
+	if len(messages) != 4 || !strings.Contains(repair, `"name" 缺失`) {
+		t.Errorf("Expected a repair prompt listing the problem, got %d messages: %s", len(messages), repair)
+	}
+}
+
+func TestCallLLMForScenesGivesUpAfterRepairAttempts(t *testing.T) {
+	server, requests := fakeLLMServer(t, "", replySequence("抱歉，我无法完成。"))
+	cfg := models.Config{LLM: models.LLMConfig{Model: "m", BaseURL: server.URL, APIKey: "k", RepairAttempts: 1}}
+
+	_, err := CallLLMForScenes(context.Background(), cfg, "小说内容", nil, nil, nil)
+	var invalid *ValidationError
+	if !errors.As(err, &invalid) {
+		t.Fatalf("Expected a validation error, got %v", err)
+	}
+	if len(*requests) != 2 {
+		t.Errorf("Expected 1 repair attempt, got %d requests", len(*requests))
+	}
+}
+
+func TestInvokeLLMFallsBackWithoutJSONMode(t *testing.T) {
+	server, requests := fakeLLMServer(t, "", func(req fakeRequest) any {
+		if req.Body["response_format"] != nil {
+			return errorReply{http.StatusBadRequest, `{"error": "response_format is not supported"}`}
+		}
+		return chatReply(`[{"name": "甲"}]`)
+	})
+	cfg := models.Config{LLM: models.LLMConfig{Model: "fallback", BaseURL: server.URL, APIKey: "k"}}
+
+	for i := 0; i < 2; i++ {
+		if _, err := CallLLMForCharacters(context.Background(), cfg, "小说内容", nil); err != nil {
+			t.Fatalf("CallLLMForCharacters failed: %v", err)
+		}
+	}
+	// 第一次请求被拒绝后重试，之后直接使用普通模式。
+	if len(*requests) != 3 {
+		t.Errorf("Expected 3 requests, got %d", len(*requests))
+	}
+
+	cfg.LLM.JSONMode = JSONModeOn
+	if _, err := CallLLMForCharacters(context.Background(), cfg, "小说内容", nil); err == nil {
+		t.Error("Expected JSON mode on to surface the error")
+	}
+}
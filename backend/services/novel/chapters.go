@@ -0,0 +1,71 @@
+package novel
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"taco/backend/models"
+)
+
+var ErrChapterRange = errors.New("章节范围无效")
+
+// chapterHeading 匹配“第X章”“第X回”“第X节”开头的标题行，X 可以是阿拉伯数字或中文数字，
+// 也兼容英文小说的 "Chapter N"。匹配前已去掉行首的空白（包括全角空格）。
+var chapterHeading = regexp.MustCompile(`^(第[0-9０-９零〇一二两三四五六七八九十百千万]+[章回节]|(?i:chapter)\s+[0-9]+)`)
+
+// maxHeadingLength 是标题行的最大字数。更长的行或以句号结尾的行即使以“第X章”开头也视为正文。
+const maxHeadingLength = 40
+
+// SplitChapters 按标题行把小说切分为章节，没有识别到任何标题时返回空列表。
+func SplitChapters(text string) []models.Chapter {
+	chapters := []models.Chapter{}
+	offset, index := 0, 0
+	for _, line := range strings.SplitAfter(text, "\n") {
+		if isHeading(line) {
+			if index == 0 && strings.TrimSpace(text[:offset]) != "" {
+				chapters = append(chapters, models.Chapter{Index: 0, Title: "（正文前）", Start: 0})
+			}
+			index++
+			chapters = append(chapters, models.Chapter{
+				Index: index,
+				Title: strings.TrimSpace(line),
+				Start: offset,
+			})
+		}
+		offset += len(line)
+	}
+
+	return finishChapters(text, chapters)
+}
+
+func isHeading(line string) bool {
+	line = strings.TrimSpace(line)
+	if line == "" || utf8.RuneCountInString(line) > maxHeadingLength || strings.HasSuffix(line, "。") {
+		return false
+	}
+	return chapterHeading.MatchString(line)
+}
+
+// SelectChapters 返回序号在 [from, to] 之间的章节。
+func SelectChapters(chapters []models.Chapter, from, to int) ([]models.Chapter, error) {
+	if len(chapters) == 0 {
+		return nil, fmt.Errorf("%w: 小说中没有识别到章节", ErrChapterRange)
+	}
+	if from > to {
+		return nil, fmt.Errorf("%w: 起始章节 %d 大于结束章节 %d", ErrChapterRange, from, to)
+	}
+	var selected []models.Chapter
+	for _, chapter := range chapters {
+		if chapter.Index >= from && chapter.Index <= to {
+			selected = append(selected, chapter)
+		}
+	}
+	if len(selected) == 0 {
+		first, last := chapters[0].Index, chapters[len(chapters)-1].Index
+		return nil, fmt.Errorf("%w: %d-%d 不在 %d-%d 之间", ErrChapterRange, from, to, first, last)
+	}
+	return selected, nil
+}
@@ -0,0 +1,69 @@
+package novel
+
+import (
+	"errors"
+	"os"
+	"strings"
+	"testing"
+)
+
+const sampleNovel = "红楼梦\n曹雪芹 著\n\n第一回　甄士隐梦幻识通灵\n　　此开卷第一回也。\n第二回 贾夫人仙逝扬州城\n第二回中的正文，第三回才会讲到黛玉进府，这一行不是标题，因为它太长了，超过了标题允许的最大长度。\n　　第3章 林黛玉抛父进京都\n正文三。\n"
+
+func TestSplitChapters(t *testing.T) {
+	chapters := SplitChapters(sampleNovel)
+	if len(chapters) != 4 {
+		t.Fatalf("Expected preface and 3 chapters, got %d: %+v", len(chapters), chapters)
+	}
+	titles := []string{"（正文前）", "第一回　甄士隐梦幻识通灵", "第二回 贾夫人仙逝扬州城", "第3章 林黛玉抛父进京都"}
+	for i, chapter := range chapters {
+		if chapter.Index != i || chapter.Title != titles[i] {
+			t.Errorf("Chapter %d: expected index %d title %q, got %d %q", i, i, titles[i], chapter.Index, chapter.Title)
+		}
+		if i > 0 && chapter.Start != chapters[i-1].End {
+			t.Errorf("Chapter %d should start where the previous one ends", i)
+		}
+	}
+	if chapters[0].Start != 0 || chapters[3].End != len(sampleNovel) {
+		t.Error("Chapters should cover the whole text")
+	}
+	if text := sampleNovel[chapters[3].Start:chapters[3].End]; !strings.HasPrefix(text, "　　第3章") || !strings.HasSuffix(text, "正文三。\n") {
+		t.Errorf("Unexpected chapter text %q", text)
+	}
+	if chapters[3].Length != len([]rune("第3章 林黛玉抛父进京都\n正文三。")) {
+		t.Errorf("Unexpected chapter length %d", chapters[3].Length)
+	}
+}
+
+func TestSplitChaptersWithoutHeadings(t *testing.T) {
+	if chapters := SplitChapters("没有章节标题的短篇。\n第二段。"); len(chapters) != 0 {
+		t.Errorf("Expected no chapters, got %+v", chapters)
+	}
+}
+
+func TestSplitChaptersSampleNovel(t *testing.T) {
+	data, err := os.ReadFile("../../../test/hongloumeng-liulaolao.txt")
+	if err != nil {
+		t.Skipf("sample novel not available: %v", err)
+	}
+	chapters := SplitChapters(string(data))
+	if len(chapters) != 1 || chapters[0].Index != 1 || !strings.HasPrefix(chapters[0].Title, "第六回") {
+		t.Errorf("Expected the excerpt to be a single 回, got %+v", chapters)
+	}
+}
+
+func TestSelectChapters(t *testing.T) {
+	chapters := SplitChapters(sampleNovel)
+	selected, err := SelectChapters(chapters, 2, 3)
+	if err != nil || len(selected) != 2 || selected[0].Index != 2 || selected[1].Index != 3 {
+		t.Errorf("Unexpected selection %+v, %v", selected, err)
+	}
+	if _, err := SelectChapters(chapters, 3, 2); !errors.Is(err, ErrChapterRange) {
+		t.Errorf("Expected ErrChapterRange for reversed range, got %v", err)
+	}
+	if _, err := SelectChapters(chapters, 9, 9); !errors.Is(err, ErrChapterRange) {
+		t.Errorf("Expected ErrChapterRange for missing chapter, got %v", err)
+	}
+	if _, err := SelectChapters(nil, 1, 1); !errors.Is(err, ErrChapterRange) {
+		t.Errorf("Expected ErrChapterRange without chapters, got %v", err)
+	}
+}
@@ -0,0 +1,190 @@
+package novel
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"unicode/utf8"
+
+	"taco/backend/models"
+)
+
+// 上传文件的格式。EPUB 和 DOCX 都是 zip 包，按包内的文件区分，与扩展名无关。
+const (
+	FormatText = "text"
+	FormatEPUB = "epub"
+	FormatDOCX = "docx"
+)
+
+// Document 是从上传文件中提取出的小说。Chapters 为 nil 表示文件本身没有章节结构，
+// 需要按标题行识别；EPUB 的目录和 DOCX 的标题样式会直接生成章节。
+type Document struct {
+	Text     string
+	Format   string
+	Encoding string
+	Invalid  int
+	Chapters []models.Chapter
+}
+
+// DetectFormat 根据文件内容判断格式。
+func DetectFormat(data []byte) string {
+	if !bytes.HasPrefix(data, []byte("PK\x03\x04")) {
+		return FormatText
+	}
+	archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return FormatText
+	}
+	for _, file := range archive.File {
+		switch file.Name {
+		case "META-INF/container.xml":
+			return FormatEPUB
+		case "word/document.xml":
+			return FormatDOCX
+		}
+	}
+	return FormatText
+}
+
+// Load 把上传的原始文件转换为规范化的 UTF-8 文本。encoding 只对纯文本有效，为空时自动识别；
+// EPUB 和 DOCX 内部固定为 UTF-8。
+func Load(data []byte, encoding string) (Document, error) {
+	switch DetectFormat(data) {
+	case FormatEPUB:
+		return loadEPUB(data)
+	case FormatDOCX:
+		return loadDOCX(data)
+	}
+	text, used, invalid, err := Decode(data, encoding)
+	if err != nil {
+		return Document{}, err
+	}
+	return Document{Text: Normalize(text), Format: FormatText, Encoding: used, Invalid: invalid}, nil
+}
+
+var errNoContent = errors.New("文件中没有可提取的正文")
+
+// documentBuilder 逐段拼接正文并记录章节的起始位置。
+type documentBuilder struct {
+	text     strings.Builder
+	chapters []models.Chapter
+}
+
+// startChapter 以 title 作为标题行开始新的一章。第一章之前已有正文时，这些内容作为第 0 章。
+func (b *documentBuilder) startChapter(title string) {
+	title = strings.TrimSpace(strings.ReplaceAll(Normalize(title), "\n", " "))
+	if len(b.chapters) == 0 && strings.TrimSpace(b.text.String()) != "" {
+		b.chapters = append(b.chapters, models.Chapter{Index: 0, Title: "（正文前）", Start: 0})
+	}
+	index := 1
+	if n := len(b.chapters); n > 0 {
+		index = b.chapters[n-1].Index + 1
+	}
+	b.chapters = append(b.chapters, models.Chapter{Index: index, Title: title, Start: b.text.Len()})
+	b.paragraph(title)
+}
+
+func (b *documentBuilder) paragraph(text string) {
+	text = strings.TrimSpace(Normalize(text))
+	if text == "" {
+		return
+	}
+	b.text.WriteString(text)
+	b.text.WriteByte('\n')
+}
+
+func (b *documentBuilder) document(format string) (Document, error) {
+	text := b.text.String()
+	if strings.TrimSpace(text) == "" {
+		return Document{}, errNoContent
+	}
+	doc := Document{Text: text, Format: format, Encoding: EncodingUTF8}
+	if len(b.chapters) > 0 {
+		doc.Chapters = finishChapters(text, b.chapters)
+	}
+	return doc, nil
+}
+
+// finishChapters 根据相邻章节的起点补全 End 和 Length。
+func finishChapters(text string, chapters []models.Chapter) []models.Chapter {
+	for i := range chapters {
+		chapters[i].End = len(text)
+		if i+1 < len(chapters) {
+			chapters[i].End = chapters[i+1].Start
+		}
+		chapters[i].Length = utf8.RuneCountInString(strings.TrimSpace(text[chapters[i].Start:chapters[i].End]))
+	}
+	return chapters
+}
+
+// ChaptersPath 是小说文本对应的章节文件，保存从 EPUB 或 DOCX 中得到的章节结构。
+func ChaptersPath(novelPath string) string {
+	return novelPath + ".chapters.json"
+}
+
+// SaveChapters 保存文件自带的章节结构，chapters 为 nil 时删除旧的章节文件。
+func SaveChapters(novelPath string, chapters []models.Chapter) error {
+	if chapters == nil {
+		if err := os.Remove(ChaptersPath(novelPath)); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		return nil
+	}
+	data, err := json.MarshalIndent(chapters, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(ChaptersPath(novelPath), data, 0o644)
+}
+
+// ReadChapters 返回小说的章节：优先使用上传时保存的章节结构，没有时按标题行识别。
+// 章节文件与正文对不上（例如正文被替换过）时同样退回按标题行识别。
+func ReadChapters(novelPath, text string) []models.Chapter {
+	data, err := os.ReadFile(ChaptersPath(novelPath))
+	if err != nil {
+		return SplitChapters(text)
+	}
+	var chapters []models.Chapter
+	if err := json.Unmarshal(data, &chapters); err != nil || len(chapters) == 0 {
+		return SplitChapters(text)
+	}
+	for _, chapter := range chapters {
+		if chapter.Start < 0 || chapter.Start > chapter.End || chapter.End > len(text) {
+			return SplitChapters(text)
+		}
+	}
+	if chapters[len(chapters)-1].End != len(text) {
+		return SplitChapters(text)
+	}
+	return chapters
+}
+
+// maxEntrySize 限制 zip 包中单个文件解压后的大小，避免压缩炸弹。
+const maxEntrySize = 64 << 20
+
+func readZipFile(archive *zip.Reader, name string) ([]byte, error) {
+	for _, file := range archive.File {
+		if file.Name != name {
+			continue
+		}
+		rc, err := file.Open()
+		if err != nil {
+			return nil, err
+		}
+		defer rc.Close()
+		data, err := io.ReadAll(io.LimitReader(rc, maxEntrySize+1))
+		if err != nil {
+			return nil, err
+		}
+		if len(data) > maxEntrySize {
+			return nil, fmt.Errorf("%s 超过 %d MB", name, maxEntrySize>>20)
+		}
+		return data, nil
+	}
+	return nil, fmt.Errorf("%s: %w", name, os.ErrNotExist)
+}
@@ -0,0 +1,159 @@
+package novel
+
+import (
+	"archive/zip"
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func buildZip(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+	buf := &bytes.Buffer{}
+	writer := zip.NewWriter(buf)
+	for name, content := range files {
+		w, err := writer.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write([]byte(content))
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func chapterTitles(doc Document) []string {
+	titles := []string{}
+	for _, chapter := range doc.Chapters {
+		titles = append(titles, chapter.Title)
+	}
+	return titles
+}
+
+func testEPUB(t *testing.T) []byte {
+	return buildZip(t, map[string]string{
+		"mimetype":               "application/epub+zip",
+		"META-INF/container.xml": `<?xml version="1.0"?><container><rootfiles><rootfile full-path="OEBPS/content.opf" media-type="application/oebps-package+xml"/></rootfiles></container>`,
+		"OEBPS/content.opf": `<?xml version="1.0" encoding="UTF-8"?>
+<package xmlns="http://www.idpf.org/2007/opf" version="3.0">
+  <manifest>
+    <item id="nav" href="nav.xhtml" media-type="application/xhtml+xml" properties="nav"/>
+    <item id="cover" href="text/cover.xhtml" media-type="application/xhtml+xml"/>
+    <item id="c2" href="text/ch%202.xhtml" media-type="application/xhtml+xml"/>
+    <item id="c1" href="text/ch1.xhtml" media-type="application/xhtml+xml"/>
+    <item id="c1b" href="text/ch1b.xhtml" media-type="application/xhtml+xml"/>
+  </manifest>
+  <spine><itemref idref="cover"/><itemref idref="c1"/><itemref idref="c1b"/><itemref idref="c2"/></spine>
+</package>`,
+		"OEBPS/nav.xhtml": `<html xmlns="http://www.w3.org/1999/xhtml" xmlns:epub="http://www.idpf.org/2007/ops"><body>
+<nav epub:type="toc"><ol><li><a href="text/ch1.xhtml#start">楔子</a></li><li><a href="text/ch%202.xhtml">第一章　入城</a></li></ol></nav>
+<nav epub:type="landmarks"><ol><li><a href="text/cover.xhtml">封面</a></li></ol></nav></body></html>`,
+		"OEBPS/text/cover.xhtml": `<html><head><title>书名</title><style>p{}</style></head><body><p>长安十二时辰</p></body></html>`,
+		"OEBPS/text/ch1.xhtml":   `<html><body><h1>楔子</h1><p>天宝三载&nbsp;元月，<b>长安</b>。</p><p>第二段<br/>换行</p></body></html>`,
+		"OEBPS/text/ch1b.xhtml":  `<html><body><p>楔子的后半部分。</p><script>alert(1)</script></body></html>`,
+		"OEBPS/text/ch 2.xhtml":  `<html><body><h2>第一章　入城</h2><div>张小敬走进城门。</div></body></html>`,
+	})
+}
+
+func TestLoadEPUB(t *testing.T) {
+	data := testEPUB(t)
+	if DetectFormat(data) != FormatEPUB {
+		t.Fatal("Expected EPUB to be detected")
+	}
+	doc, err := Load(data, "")
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	want := "长安十二时辰\n楔子\n天宝三载\u00a0元月，长安。\n第二段\n换行\n楔子的后半部分。\n第一章　入城\n张小敬走进城门。\n"
+	if doc.Text != want {
+		t.Errorf("Expected text %q, got %q", want, doc.Text)
+	}
+	if got := strings.Join(chapterTitles(doc), "|"); got != "（正文前）|楔子|第一章　入城" {
+		t.Errorf("Unexpected chapters %s", got)
+	}
+	if doc.Text[doc.Chapters[1].Start:doc.Chapters[1].End] != "楔子\n天宝三载\u00a0元月，长安。\n第二段\n换行\n楔子的后半部分。\n" {
+		t.Errorf("Split files of one chapter should be merged, got %q", doc.Text[doc.Chapters[1].Start:doc.Chapters[1].End])
+	}
+}
+
+func testDOCX(t *testing.T, withHeadings bool) []byte {
+	style, subStyle := "", ""
+	if withHeadings {
+		style = `<w:pPr><w:pStyle w:val="1"/></w:pPr>`
+		subStyle = `<w:pPr><w:pStyle w:val="2"/></w:pPr>`
+	}
+	return buildZip(t, map[string]string{
+		"[Content_Types].xml": `<Types/>`,
+		"word/styles.xml": `<w:styles xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main">
+  <w:style w:type="paragraph" w:styleId="1"><w:name w:val="heading 1"/></w:style>
+  <w:style w:type="paragraph" w:styleId="2"><w:name w:val="标题 2"/></w:style>
+</w:styles>`,
+		"word/document.xml": `<w:document xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main"><w:body>
+<w:p><w:r><w:t>作者的话</w:t></w:r></w:p>
+<w:p>` + style + `<w:r><w:t>第一章</w:t></w:r><w:r><w:t xml:space="preserve"> 雪夜</w:t></w:r></w:p>
+<w:p>` + subStyle + `<w:r><w:t>一</w:t></w:r></w:p>
+<w:p><w:r><w:t>ＡＢＣ大雪</w:t><w:tab/><w:t>纷飞。</w:t></w:r></w:p>
+<w:p></w:p>
+<w:p>` + style + `<w:r><w:t>第二章 春来</w:t></w:r></w:p>
+<w:tbl><w:tr><w:tc><w:p><w:r><w:t>表格里的字</w:t></w:r></w:p></w:tc></w:tr></w:tbl>
+</w:body></w:document>`,
+	})
+}
+
+func TestLoadDOCX(t *testing.T) {
+	data := testDOCX(t, true)
+	if DetectFormat(data) != FormatDOCX {
+		t.Fatal("Expected DOCX to be detected")
+	}
+	doc, err := Load(data, "")
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	want := "作者的话\n第一章 雪夜\n一\nABC大雪\t纷飞。\n第二章 春来\n表格里的字\n"
+	if doc.Text != want {
+		t.Errorf("Expected text %q, got %q", want, doc.Text)
+	}
+	if got := strings.Join(chapterTitles(doc), "|"); got != "（正文前）|第一章 雪夜|第二章 春来" {
+		t.Errorf("Expected only top-level headings as chapters, got %s", got)
+	}
+
+	plain, err := Load(testDOCX(t, false), "")
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if plain.Chapters != nil {
+		t.Errorf("Expected no chapter structure without heading styles, got %+v", plain.Chapters)
+	}
+	if got := SplitChapters(plain.Text); len(got) != 3 {
+		t.Errorf("Expected heading lines to be detected from text, got %+v", got)
+	}
+}
+
+func TestReadChapters(t *testing.T) {
+	doc, err := Load(testEPUB(t), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "novel.txt")
+	os.WriteFile(path, []byte(doc.Text), 0o644)
+	if err := SaveChapters(path, doc.Chapters); err != nil {
+		t.Fatal(err)
+	}
+	if got := ReadChapters(path, doc.Text); len(got) != 3 || got[2].Title != "第一章　入城" {
+		t.Errorf("Expected saved chapters, got %+v", got)
+	}
+
+	// 正文被替换后章节文件失效，退回按标题行识别。
+	if got := ReadChapters(path, "第1章 新\n内容"); len(got) != 1 || got[0].Title != "第1章 新" {
+		t.Errorf("Expected fallback to heading detection, got %+v", got)
+	}
+
+	SaveChapters(path, nil)
+	if _, err := os.Stat(ChaptersPath(path)); !os.IsNotExist(err) {
+		t.Error("Expected chapters file to be removed")
+	}
+}
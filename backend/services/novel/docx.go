@@ -0,0 +1,138 @@
+package novel
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+type docxParagraph struct {
+	text  string
+	level int
+}
+
+// headingStyleName 匹配 Word 内置标题样式的名称，英文版为 "heading 1"，中文版为 "标题 1"。
+var headingStyleName = regexp.MustCompile(`^(?i:heading|标题)\s*([1-9])$`)
+
+// loadDOCX 读取 word/document.xml 中的段落。使用标题样式（或设置了大纲级别）的段落中，
+// 级别最高的一级作为章节标题；文档没有标题样式时不生成章节，之后按标题行识别。
+func loadDOCX(data []byte) (Document, error) {
+	archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return Document{}, fmt.Errorf("解析 DOCX 失败: %w", err)
+	}
+	documentData, err := readZipFile(archive, "word/document.xml")
+	if err != nil {
+		return Document{}, fmt.Errorf("解析 DOCX 失败: %w", err)
+	}
+	styles := map[string]int{}
+	if stylesData, err := readZipFile(archive, "word/styles.xml"); err == nil {
+		styles = docxHeadingStyles(stylesData)
+	}
+	paragraphs, err := docxParagraphs(documentData, styles)
+	if err != nil {
+		return Document{}, fmt.Errorf("解析 DOCX 失败: %w", err)
+	}
+
+	chapterLevel := 0
+	for _, p := range paragraphs {
+		if p.level > 0 && (chapterLevel == 0 || p.level < chapterLevel) {
+			chapterLevel = p.level
+		}
+	}
+	var b documentBuilder
+	for _, p := range paragraphs {
+		if chapterLevel > 0 && p.level == chapterLevel && strings.TrimSpace(p.text) != "" {
+			b.startChapter(p.text)
+			continue
+		}
+		b.paragraph(p.text)
+	}
+	return b.document(FormatDOCX)
+}
+
+// docxHeadingStyles 返回标题样式的 ID 与级别。样式 ID 因语言而异（Heading1、1 等），所以按名称和大纲级别判断。
+func docxHeadingStyles(data []byte) map[string]int {
+	var doc struct {
+		Styles []struct {
+			ID   string `xml:"styleId,attr"`
+			Name struct {
+				Val string `xml:"val,attr"`
+			} `xml:"name"`
+			Outline *struct {
+				Val string `xml:"val,attr"`
+			} `xml:"pPr>outlineLvl"`
+		} `xml:"style"`
+	}
+	levels := map[string]int{}
+	if err := xml.Unmarshal(data, &doc); err != nil {
+		return levels
+	}
+	for _, style := range doc.Styles {
+		if m := headingStyleName.FindStringSubmatch(strings.TrimSpace(style.Name.Val)); m != nil {
+			levels[style.ID], _ = strconv.Atoi(m[1])
+			continue
+		}
+		if style.Outline != nil {
+			if level, err := strconv.Atoi(style.Outline.Val); err == nil && level < 9 {
+				levels[style.ID] = level + 1
+			}
+		}
+	}
+	return levels
+}
+
+// docxParagraphs 按顺序取出所有段落的文字，表格中的段落同样按顺序输出。
+func docxParagraphs(data []byte, styles map[string]int) ([]docxParagraph, error) {
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	var paragraphs []docxParagraph
+	var current strings.Builder
+	level, inText := 0, false
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			break
+		}
+		switch t := token.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "p":
+				current.Reset()
+				level = 0
+			case "pStyle":
+				if styleLevel, ok := styles[attr(t, "val")]; ok {
+					level = styleLevel
+				}
+			case "outlineLvl":
+				if outline, err := strconv.Atoi(attr(t, "val")); err == nil && outline < 9 {
+					level = outline + 1
+				}
+			case "t":
+				inText = true
+			case "tab":
+				current.WriteByte('\t')
+			case "br", "cr":
+				current.WriteByte('\n')
+			}
+		case xml.EndElement:
+			switch t.Name.Local {
+			case "t":
+				inText = false
+			case "p":
+				paragraphs = append(paragraphs, docxParagraph{text: current.String(), level: level})
+			}
+		case xml.CharData:
+			if inText {
+				current.Write(t)
+			}
+		}
+	}
+	if len(paragraphs) == 0 {
+		return nil, errNoContent
+	}
+	return paragraphs, nil
+}
@@ -0,0 +1,254 @@
+package novel
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+//go:generate go run maketables.go
+
+// 支持的文本编码。GBK 和 GB2312 是 GB18030 的子集，统一按 GB18030 解码。
+const (
+	EncodingUTF8    = "utf-8"
+	EncodingGB18030 = "gb18030"
+	EncodingUTF16LE = "utf-16le"
+	EncodingUTF16BE = "utf-16be"
+)
+
+var ErrUnsupportedEncoding = errors.New("不支持的文本编码")
+
+var encodingAliases = map[string]string{
+	"utf-8":    EncodingUTF8,
+	"utf8":     EncodingUTF8,
+	"gb18030":  EncodingGB18030,
+	"gbk":      EncodingGB18030,
+	"gb2312":   EncodingGB18030,
+	"cp936":    EncodingGB18030,
+	"utf-16le": EncodingUTF16LE,
+	"utf16le":  EncodingUTF16LE,
+	"utf-16be": EncodingUTF16BE,
+	"utf16be":  EncodingUTF16BE,
+}
+
+// Encodings 返回可以手动指定的编码名称。
+func Encodings() []string {
+	return []string{EncodingUTF8, EncodingGB18030, EncodingUTF16LE, EncodingUTF16BE}
+}
+
+// CanonicalEncoding 把用户输入的编码名称转换为标准名称，空字符串和 "auto" 表示自动识别，返回空字符串。
+func CanonicalEncoding(name string) (string, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if name == "" || name == "auto" {
+		return "", nil
+	}
+	if canonical, ok := encodingAliases[name]; ok {
+		return canonical, nil
+	}
+	return "", fmt.Errorf("%w: %s", ErrUnsupportedEncoding, name)
+}
+
+var boms = []struct {
+	prefix   []byte
+	encoding string
+}{
+	{[]byte{0xEF, 0xBB, 0xBF}, EncodingUTF8},
+	{[]byte{0xFF, 0xFE}, EncodingUTF16LE},
+	{[]byte{0xFE, 0xFF}, EncodingUTF16BE},
+	{[]byte{0x84, 0x31, 0x95, 0x33}, EncodingGB18030},
+}
+
+// DetectEncoding 判断文本的编码：优先看 BOM，再根据零字节的位置识别不带 BOM 的 UTF-16
+// （这类数据同时也是合法的 UTF-8），然后是合法的 UTF-8，其余情况比较 UTF-8 和 GB18030
+// 解码时的非法字节数，取较少的一个。
+func DetectEncoding(data []byte) string {
+	for _, bom := range boms {
+		if bytes.HasPrefix(data, bom.prefix) {
+			return bom.encoding
+		}
+	}
+	if encoding := detectUTF16(data); encoding != "" {
+		return encoding
+	}
+	if utf8.Valid(data) {
+		return EncodingUTF8
+	}
+	if _, invalid := decodeGB18030(data); invalid < invalidUTF8(data) {
+		return EncodingGB18030
+	}
+	return EncodingUTF8
+}
+
+// detectUTF16 在前 4KB 中统计偶数位和奇数位上的零字节，ASCII 为主的 UTF-16 文本会有大量零字节集中在一侧。
+func detectUTF16(data []byte) string {
+	sample := data
+	if len(sample) > 4096 {
+		sample = sample[:4096]
+	}
+	even, odd := 0, 0
+	for i, b := range sample {
+		if b != 0 {
+			continue
+		}
+		if i%2 == 0 {
+			even++
+		} else {
+			odd++
+		}
+	}
+	pairs := len(sample) / 2
+	switch {
+	case pairs == 0:
+		return ""
+	case odd*10 > pairs*3 && even*10 < pairs:
+		return EncodingUTF16LE
+	case even*10 > pairs*3 && odd*10 < pairs:
+		return EncodingUTF16BE
+	}
+	return ""
+}
+
+func invalidUTF8(data []byte) int {
+	invalid := 0
+	for len(data) > 0 {
+		r, size := utf8.DecodeRune(data)
+		if r == utf8.RuneError && size == 1 {
+			invalid++
+		}
+		data = data[size:]
+	}
+	return invalid
+}
+
+// Decode 按 encoding 把 data 转换为 UTF-8，encoding 为空时自动识别。开头的 BOM 会被去掉，
+// 无法解码的字节替换为 U+FFFD，invalid 为替换的次数。
+func Decode(data []byte, encoding string) (text, used string, invalid int, err error) {
+	used, err = CanonicalEncoding(encoding)
+	if err != nil {
+		return "", "", 0, err
+	}
+	if used == "" {
+		used = DetectEncoding(data)
+	}
+	for _, bom := range boms {
+		if bom.encoding == used && bytes.HasPrefix(data, bom.prefix) {
+			data = data[len(bom.prefix):]
+			break
+		}
+	}
+
+	switch used {
+	case EncodingUTF8:
+		invalid = invalidUTF8(data)
+		text = strings.ToValidUTF8(string(data), string(utf8.RuneError))
+	case EncodingGB18030:
+		text, invalid = decodeGB18030(data)
+	case EncodingUTF16LE, EncodingUTF16BE:
+		text, invalid = decodeUTF16(data, used == EncodingUTF16BE)
+	}
+	return text, used, invalid, nil
+}
+
+func decodeUTF16(data []byte, bigEndian bool) (string, int) {
+	invalid := len(data) % 2
+	units := make([]uint16, len(data)/2)
+	for i := range units {
+		if bigEndian {
+			units[i] = uint16(data[2*i])<<8 | uint16(data[2*i+1])
+		} else {
+			units[i] = uint16(data[2*i+1])<<8 | uint16(data[2*i])
+		}
+	}
+	runes := utf16.Decode(units)
+	for _, r := range runes {
+		if r == utf8.RuneError {
+			invalid++
+		}
+	}
+	if len(data)%2 == 1 {
+		runes = append(runes, utf8.RuneError)
+	}
+	return string(runes), invalid
+}
+
+// decodeGB18030 解码 GB18030（兼容 GBK、GB2312）。单字节 0x80 按 GBK 的习惯解码为欧元符号。
+func decodeGB18030(data []byte) (string, int) {
+	var b strings.Builder
+	b.Grow(len(data) * 3 / 2)
+	invalid := 0
+	for i := 0; i < len(data); {
+		c := data[i]
+		switch {
+		case c < 0x80:
+			b.WriteByte(c)
+			i++
+			continue
+		case c == 0x80:
+			b.WriteRune('€')
+			i++
+			continue
+		case c == 0xFF || i+1 >= len(data):
+			b.WriteRune(utf8.RuneError)
+			invalid++
+			i++
+			continue
+		}
+
+		c2 := data[i+1]
+		if c2 >= 0x40 && c2 <= 0xFE && c2 != 0x7F {
+			trail := int(c2) - 0x40
+			if c2 > 0x7F {
+				trail--
+			}
+			if r := gb18030TwoByte[int(c-0x81)*190+trail]; r != 0 {
+				b.WriteRune(rune(r))
+			} else {
+				b.WriteRune(utf8.RuneError)
+				invalid++
+			}
+			i += 2
+			continue
+		}
+		if c2 >= 0x30 && c2 <= 0x39 && i+3 < len(data) {
+			c3, c4 := data[i+2], data[i+3]
+			if c3 >= 0x81 && c3 <= 0xFE && c4 >= 0x30 && c4 <= 0x39 {
+				linear := ((int(c-0x81)*10+int(c2-0x30))*126+int(c3-0x81))*10 + int(c4-0x30)
+				if r, ok := gb18030FourByte(linear); ok {
+					b.WriteRune(r)
+				} else {
+					b.WriteRune(utf8.RuneError)
+					invalid++
+				}
+				i += 4
+				continue
+			}
+		}
+		b.WriteRune(utf8.RuneError)
+		invalid++
+		i++
+	}
+	return b.String(), invalid
+}
+
+// gb18030SupplementaryStart 是 0x90308130 的线性序号，从这里开始依次对应 U+10000 至 U+10FFFF。
+const gb18030SupplementaryStart = 189000
+
+func gb18030FourByte(linear int) (rune, bool) {
+	if linear >= gb18030SupplementaryStart {
+		r := rune(linear-gb18030SupplementaryStart) + 0x10000
+		return r, r <= utf8.MaxRune
+	}
+	last := gb18030FourByteRanges[len(gb18030FourByteRanges)-1]
+	if linear > int(last.linear)+0xFFFF-int(last.r) {
+		return 0, false
+	}
+	i := sort.Search(len(gb18030FourByteRanges), func(i int) bool {
+		return int(gb18030FourByteRanges[i].linear) > linear
+	}) - 1
+	entry := gb18030FourByteRanges[i]
+	return rune(int(entry.r) + linear - int(entry.linear)), true
+}
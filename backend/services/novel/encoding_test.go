@@ -0,0 +1,81 @@
+package novel
+
+import (
+	"encoding/hex"
+	"errors"
+	"testing"
+	"unicode/utf16"
+)
+
+// gb18030Sample 是 "第一回　刘姥姥进荣国府\r\n价格：€5，©2024 😀" 的 GB18030 编码，
+// 包含双字节、BMP 内的四字节（©）和补充平面的四字节（😀）字符。
+const gb18030Sample = "b5dad2bbbbd8a1a1c1f5c0d1c0d1bdf8c8d9b9fab8ae0d0abcdbb8f1a3baa2e335a3ac8130843832303234209439fc36"
+
+const sampleText = "第一回　刘姥姥进荣国府\r\n价格：€5，©2024 😀"
+
+func TestDecodeGB18030(t *testing.T) {
+	data, _ := hex.DecodeString(gb18030Sample)
+	if got := DetectEncoding(data); got != EncodingGB18030 {
+		t.Errorf("Expected gb18030 to be detected, got %s", got)
+	}
+	text, used, invalid, err := Decode(data, "")
+	if err != nil || used != EncodingGB18030 || invalid != 0 {
+		t.Fatalf("Unexpected result: %s, %d, %v", used, invalid, err)
+	}
+	if text != sampleText {
+		t.Errorf("Expected %q, got %q", sampleText, text)
+	}
+
+	// GBK 文件中的 0x80 是欧元符号。
+	if text, _, _, _ := Decode([]byte{0x80, 0xD6, 0xD0}, "gbk"); text != "€中" {
+		t.Errorf("Expected GBK euro sign, got %q", text)
+	}
+}
+
+func TestDecodeInvalidBytes(t *testing.T) {
+	text, _, invalid, _ := Decode([]byte{0xD6, 0xD0, 0xFF, 0x81}, EncodingGB18030)
+	if text != "中��" || invalid != 2 {
+		t.Errorf("Expected replacement characters, got %q (%d)", text, invalid)
+	}
+	if _, _, _, err := Decode([]byte("abc"), "shift_jis"); !errors.Is(err, ErrUnsupportedEncoding) {
+		t.Errorf("Expected ErrUnsupportedEncoding, got %v", err)
+	}
+}
+
+func TestDetectEncodingUTF8AndBOM(t *testing.T) {
+	bom := append([]byte{0xEF, 0xBB, 0xBF}, sampleText...)
+	text, used, _, _ := Decode(bom, "")
+	if used != EncodingUTF8 || text != sampleText {
+		t.Errorf("Expected BOM stripped UTF-8, got %s %q", used, text)
+	}
+
+	units := utf16.Encode([]rune("Chapter 1\nHello, world. 你好"))
+	le := make([]byte, 0, len(units)*2)
+	for _, u := range units {
+		le = append(le, byte(u), byte(u>>8))
+	}
+	if got := DetectEncoding(le); got != EncodingUTF16LE {
+		t.Errorf("Expected utf-16le without BOM, got %s", got)
+	}
+	if text, _, _, _ := Decode(append([]byte{0xFF, 0xFE}, le...), ""); text != "Chapter 1\nHello, world. 你好" {
+		t.Errorf("Unexpected UTF-16 text %q", text)
+	}
+}
+
+func TestDecodeOverride(t *testing.T) {
+	data, _ := hex.DecodeString(gb18030Sample)
+	_, used, invalid, err := Decode(data, "UTF-8")
+	if err != nil || used != EncodingUTF8 || invalid == 0 {
+		t.Errorf("Expected forced UTF-8 decoding to report invalid bytes, got %s %d %v", used, invalid, err)
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	got := Normalize("\ufeff第１章　ＡＢＣ｡\r\n｢你好｣，世界\r")
+	if want := "第1章　ABC。\n「你好」，世界\n"; got != want {
+		t.Errorf("Expected %q, got %q", want, got)
+	}
+	if Preview("一二三四五", 3) != "一二三" {
+		t.Error("Preview should cut by characters")
+	}
+}
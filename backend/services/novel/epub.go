@@ -0,0 +1,291 @@
+package novel
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/url"
+	"path"
+	"strings"
+)
+
+type epubContainer struct {
+	Rootfiles []struct {
+		FullPath string `xml:"full-path,attr"`
+	} `xml:"rootfiles>rootfile"`
+}
+
+type epubPackage struct {
+	Manifest []struct {
+		ID         string `xml:"id,attr"`
+		Href       string `xml:"href,attr"`
+		MediaType  string `xml:"media-type,attr"`
+		Properties string `xml:"properties,attr"`
+	} `xml:"manifest>item"`
+	Spine struct {
+		Toc      string `xml:"toc,attr"`
+		Itemrefs []struct {
+			IDRef string `xml:"idref,attr"`
+		} `xml:"itemref"`
+	} `xml:"spine"`
+}
+
+type ncxNavPoint struct {
+	Label   string `xml:"navLabel>text"`
+	Content struct {
+		Src string `xml:"src,attr"`
+	} `xml:"content"`
+	Children []ncxNavPoint `xml:"navPoint"`
+}
+
+// loadEPUB 按 OPF 中 spine 的顺序读取各个 XHTML 文件。章节标题取自目录（EPUB 3 的 nav 或
+// EPUB 2 的 NCX），目录中没有的文件取第一个标题元素；两者都没有的文件并入上一章，
+// 通常是被拆成多个文件的同一章。
+func loadEPUB(data []byte) (Document, error) {
+	archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return Document{}, fmt.Errorf("解析 EPUB 失败: %w", err)
+	}
+
+	containerData, err := readZipFile(archive, "META-INF/container.xml")
+	if err != nil {
+		return Document{}, fmt.Errorf("解析 EPUB 失败: %w", err)
+	}
+	var container epubContainer
+	if err := xml.Unmarshal(containerData, &container); err != nil || len(container.Rootfiles) == 0 {
+		return Document{}, fmt.Errorf("解析 EPUB 失败: container.xml 中没有 rootfile")
+	}
+	opfPath := container.Rootfiles[0].FullPath
+	opfData, err := readZipFile(archive, opfPath)
+	if err != nil {
+		return Document{}, fmt.Errorf("解析 EPUB 失败: %w", err)
+	}
+	var pkg epubPackage
+	if err := xml.Unmarshal(opfData, &pkg); err != nil {
+		return Document{}, fmt.Errorf("解析 EPUB 失败: %w", err)
+	}
+
+	base := path.Dir(opfPath)
+	hrefs := map[string]string{}
+	titles := map[string]string{}
+	for _, item := range pkg.Manifest {
+		href := resolveHref(base, item.Href)
+		hrefs[item.ID] = href
+		switch {
+		case strings.Contains(" "+item.Properties+" ", " nav "):
+			if navData, err := readZipFile(archive, href); err == nil {
+				collectNavTitles(navData, path.Dir(href), titles)
+			}
+		case item.ID == pkg.Spine.Toc || item.MediaType == "application/x-dtbncx+xml":
+			if ncxData, err := readZipFile(archive, href); err == nil {
+				collectNCXTitles(ncxData, path.Dir(href), titles)
+			}
+		}
+	}
+
+	var b documentBuilder
+	for _, ref := range pkg.Spine.Itemrefs {
+		href, ok := hrefs[ref.IDRef]
+		if !ok {
+			continue
+		}
+		content, err := readZipFile(archive, href)
+		if err != nil {
+			return Document{}, fmt.Errorf("解析 EPUB 失败: %w", err)
+		}
+		blocks := xhtmlBlocks(bytes.NewReader(content))
+		if len(blocks) == 0 {
+			continue
+		}
+
+		title := titles[href]
+		if title == "" && blocks[0].heading > 0 {
+			title = blocks[0].text
+		}
+		if title != "" {
+			b.startChapter(title)
+			if blocks[0].text == strings.TrimSpace(title) {
+				blocks = blocks[1:]
+			}
+		}
+		for _, block := range blocks {
+			b.paragraph(block.text)
+		}
+	}
+	return b.document(FormatEPUB)
+}
+
+// resolveHref 把相对于 base 的链接转换为 zip 包内的路径，去掉锚点。
+func resolveHref(base, href string) string {
+	if i := strings.IndexByte(href, '#'); i >= 0 {
+		href = href[:i]
+	}
+	if unescaped, err := url.PathUnescape(href); err == nil {
+		href = unescaped
+	}
+	return path.Join(base, href)
+}
+
+// collectNCXTitles 读取 EPUB 2 目录，同一文件只保留第一个标题。
+func collectNCXTitles(data []byte, base string, titles map[string]string) {
+	var ncx struct {
+		NavPoints []ncxNavPoint `xml:"navMap>navPoint"`
+	}
+	if err := xml.Unmarshal(data, &ncx); err != nil {
+		return
+	}
+	var walk func(points []ncxNavPoint)
+	walk = func(points []ncxNavPoint) {
+		for _, point := range points {
+			href := resolveHref(base, point.Content.Src)
+			if label := strings.TrimSpace(point.Label); label != "" && titles[href] == "" {
+				titles[href] = label
+			}
+			walk(point.Children)
+		}
+	}
+	walk(ncx.NavPoints)
+}
+
+// collectNavTitles 读取 EPUB 3 导航文件中 toc 部分的链接文字。
+func collectNavTitles(data []byte, base string, titles map[string]string) {
+	decoder := newHTMLDecoder(bytes.NewReader(data))
+	inToc, depth := false, 0
+	href, label := "", strings.Builder{}
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			return
+		}
+		switch t := token.(type) {
+		case xml.StartElement:
+			if t.Name.Local == "nav" {
+				if inToc {
+					depth++
+				} else if attr(t, "type") == "toc" {
+					inToc, depth = true, 1
+				}
+			}
+			if inToc && t.Name.Local == "a" {
+				href = attr(t, "href")
+				label.Reset()
+			}
+		case xml.CharData:
+			if href != "" {
+				label.Write(t)
+			}
+		case xml.EndElement:
+			switch {
+			case t.Name.Local == "a" && href != "":
+				resolved := resolveHref(base, href)
+				if text := strings.TrimSpace(label.String()); text != "" && titles[resolved] == "" {
+					titles[resolved] = text
+				}
+				href = ""
+			case t.Name.Local == "nav" && inToc:
+				if depth--; depth == 0 {
+					inToc = false
+				}
+			}
+		}
+	}
+}
+
+type xhtmlBlock struct {
+	text    string
+	heading int
+}
+
+// blockElements 中的元素前后换段，其余元素的文字并入当前段落。
+var blockElements = map[string]bool{
+	"p": true, "div": true, "br": true, "li": true, "tr": true, "blockquote": true,
+	"section": true, "article": true, "pre": true, "hr": true,
+	"h1": true, "h2": true, "h3": true, "h4": true, "h5": true, "h6": true,
+}
+
+// xhtmlBlocks 去掉标签，按块级元素把正文切成段落，并记录标题元素的级别。
+// head、script 和 style 中的内容会被忽略。
+func xhtmlBlocks(r io.Reader) []xhtmlBlock {
+	decoder := newHTMLDecoder(r)
+	var blocks []xhtmlBlock
+	var current strings.Builder
+	heading, skip := 0, 0
+	flush := func() {
+		if text := collapseSpace(current.String()); text != "" {
+			blocks = append(blocks, xhtmlBlock{text: text, heading: heading})
+		}
+		current.Reset()
+	}
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			break
+		}
+		switch t := token.(type) {
+		case xml.StartElement:
+			name := strings.ToLower(t.Name.Local)
+			switch {
+			case name == "head" || name == "script" || name == "style":
+				skip++
+			case blockElements[name]:
+				flush()
+				if len(name) == 2 && name[0] == 'h' && name[1] >= '1' && name[1] <= '6' {
+					heading = int(name[1] - '0')
+				}
+			}
+		case xml.EndElement:
+			name := strings.ToLower(t.Name.Local)
+			switch {
+			case name == "head" || name == "script" || name == "style":
+				skip--
+			case blockElements[name]:
+				flush()
+				heading = 0
+			}
+		case xml.CharData:
+			if skip == 0 {
+				current.Write(t)
+			}
+		}
+	}
+	flush()
+	return blocks
+}
+
+// collapseSpace 按 HTML 的规则把连续的 ASCII 空白合并为一个空格，全角空格和 &nbsp; 保持不变。
+func collapseSpace(text string) string {
+	var b strings.Builder
+	space := false
+	for _, r := range strings.Trim(text, " \t\n\r\f") {
+		if r == ' ' || r == '\t' || r == '\n' || r == '\r' || r == '\f' {
+			space = true
+			continue
+		}
+		if space {
+			b.WriteByte(' ')
+			space = false
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// newHTMLDecoder 返回宽松模式的 XML 解码器，可以处理常见的 HTML 实体和未闭合的标签。
+func newHTMLDecoder(r io.Reader) *xml.Decoder {
+	decoder := xml.NewDecoder(r)
+	decoder.Strict = false
+	decoder.AutoClose = xml.HTMLAutoClose
+	decoder.Entity = xml.HTMLEntity
+	return decoder
+}
+
+func attr(element xml.StartElement, name string) string {
+	for _, a := range element.Attr {
+		if a.Name.Local == name {
+			return a.Value
+		}
+	}
+	return ""
+}
@@ -0,0 +1,365 @@
+package novel
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"taco/backend/models"
+)
+
+// 上传目录中除小说正文外的附属文件：原始文件、章节文件和写入中的临时文件。
+const (
+	OriginalSuffix = ".orig"
+	libraryIndex   = "novels.json"
+)
+
+var (
+	ErrNovelNotFound  = errors.New("小说不存在")
+	ErrDuplicateNovel = errors.New("该小说已上传过")
+)
+
+// libraryLocks 按目录保存互斥锁，同一上传目录的索引读写串行执行。
+var libraryLocks sync.Map
+
+// pendingUploads 记录已通过重复检查、还在转换中的上传，值为小说 ID。
+var pendingUploads sync.Map
+
+type pendingUpload struct{ dir, hash string }
+
+// Library 管理一个上传目录中的小说。元数据保存在目录下的 novels.json 中，
+// 目录中有而索引中没有的文件（更早上传的小说）在列出时补齐。
+type Library struct {
+	Dir string
+}
+
+func OpenLibrary(dir string) *Library {
+	return &Library{Dir: dir}
+}
+
+// key 返回上传目录的绝对路径，同一目录的不同写法共用一把锁。
+func (l *Library) key() string {
+	if abs, err := filepath.Abs(l.Dir); err == nil {
+		return abs
+	}
+	return l.Dir
+}
+
+func (l *Library) lock() func() {
+	value, _ := libraryLocks.LoadOrStore(l.key(), &sync.Mutex{})
+	mu := value.(*sync.Mutex)
+	mu.Lock()
+	return mu.Unlock
+}
+
+// HashContent 返回原始文件内容的 SHA-256。
+func HashContent(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// Path 返回 id 对应的正文路径，id 必须是上传目录中的文件名。
+func (l *Library) Path(id string) (string, error) {
+	if !validNovelID(id) {
+		return "", ErrNovelNotFound
+	}
+	return filepath.Join(l.Dir, id), nil
+}
+
+func validNovelID(id string) bool {
+	return id != "" && id == filepath.Base(id) && id != "." && id != ".." && isNovelFile(id)
+}
+
+// isNovelFile 判断上传目录中的文件是不是小说正文。
+func isNovelFile(name string) bool {
+	if name == libraryIndex || strings.HasPrefix(name, ".") {
+		return false
+	}
+	for _, suffix := range []string{OriginalSuffix, ".chapters.json", ".tmp"} {
+		if strings.HasSuffix(name, suffix) {
+			return false
+		}
+	}
+	return true
+}
+
+// List 按上传时间从新到旧返回所有小说，并把索引与目录同步：补齐新发现的文件，去掉已不存在的文件。
+func (l *Library) List() ([]models.NovelInfo, error) {
+	defer l.lock()()
+	novels, err := l.sync()
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(novels, func(i, j int) bool {
+		return novels[i].UploadedAt.After(novels[j].UploadedAt)
+	})
+	return novels, nil
+}
+
+// Get 返回单部小说的元数据。
+func (l *Library) Get(id string) (models.NovelInfo, error) {
+	if !validNovelID(id) {
+		return models.NovelInfo{}, ErrNovelNotFound
+	}
+	defer l.lock()()
+	novels, err := l.sync()
+	if err != nil {
+		return models.NovelInfo{}, err
+	}
+	for _, info := range novels {
+		if info.ID == id {
+			return info, nil
+		}
+	}
+	return models.NovelInfo{}, ErrNovelNotFound
+}
+
+// Reserve 在同一把锁内查找原始内容为 hash 的小说，没有时把 hash 预留给正在上传的 id，
+// 同时上传的相同文件只有一个能通过检查。已有或正在上传相同内容时返回 ErrDuplicateNovel 和那部小说。
+// 预留成功后，调用方在转换结束时（无论成败）调用 release，成功时哈希已由 Record 写入索引。
+func (l *Library) Reserve(id, hash string) (models.NovelInfo, func(), error) {
+	defer l.lock()()
+	novels, err := l.sync()
+	if err != nil {
+		return models.NovelInfo{}, nil, err
+	}
+	for _, info := range novels {
+		if info.Hash == hash {
+			return info, nil, ErrDuplicateNovel
+		}
+	}
+	key := pendingUpload{dir: l.key(), hash: hash}
+	if pending, loaded := pendingUploads.LoadOrStore(key, id); loaded {
+		pendingID := pending.(string)
+		return models.NovelInfo{ID: pendingID, Title: titleFromFileName(pendingID)}, nil, ErrDuplicateNovel
+	}
+	return models.NovelInfo{}, func() { pendingUploads.Delete(key) }, nil
+}
+
+// Record 新增或更新一部小说的元数据，标题为空时保留原标题。
+func (l *Library) Record(info models.NovelInfo) (models.NovelInfo, error) {
+	if !validNovelID(info.ID) {
+		return models.NovelInfo{}, ErrNovelNotFound
+	}
+	defer l.lock()()
+	novels, err := l.load()
+	if err != nil {
+		return models.NovelInfo{}, err
+	}
+	info.FilePath = filepath.Join(l.Dir, info.ID)
+	info.Active = false
+	for i := range novels {
+		if novels[i].ID != info.ID {
+			continue
+		}
+		if info.Title == "" {
+			info.Title = novels[i].Title
+		}
+		if info.UploadedAt.IsZero() {
+			info.UploadedAt = novels[i].UploadedAt
+		}
+		novels[i] = info
+		return info, l.save(novels)
+	}
+	if info.Title == "" {
+		info.Title = titleFromFileName(info.ID)
+	}
+	if info.UploadedAt.IsZero() {
+		info.UploadedAt = time.Now()
+	}
+	return info, l.save(append(novels, info))
+}
+
+// Rename 修改小说的标题，文件名保持不变，已保存的配置不受影响。
+func (l *Library) Rename(id, title string) (models.NovelInfo, error) {
+	title = strings.TrimSpace(title)
+	if title == "" {
+		return models.NovelInfo{}, errors.New("小说标题不能为空")
+	}
+	if !validNovelID(id) {
+		return models.NovelInfo{}, ErrNovelNotFound
+	}
+	defer l.lock()()
+	novels, err := l.sync()
+	if err != nil {
+		return models.NovelInfo{}, err
+	}
+	for i := range novels {
+		if novels[i].ID == id {
+			novels[i].Title = title
+			return novels[i], l.save(novels)
+		}
+	}
+	return models.NovelInfo{}, ErrNovelNotFound
+}
+
+// Delete 删除小说的正文、原始文件和章节文件。
+func (l *Library) Delete(id string) error {
+	path, err := l.Path(id)
+	if err != nil {
+		return err
+	}
+	defer l.lock()()
+	if _, err := os.Stat(path); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return ErrNovelNotFound
+		}
+		return err
+	}
+	for _, name := range []string{path, path + OriginalSuffix, ChaptersPath(path)} {
+		if err := os.Remove(name); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+	novels, err := l.load()
+	if err != nil {
+		return err
+	}
+	kept := novels[:0]
+	for _, info := range novels {
+		if info.ID != id {
+			kept = append(kept, info)
+		}
+	}
+	return l.save(kept)
+}
+
+func (l *Library) load() ([]models.NovelInfo, error) {
+	data, err := os.ReadFile(filepath.Join(l.Dir, libraryIndex))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return []models.NovelInfo{}, nil
+		}
+		return nil, err
+	}
+	var novels []models.NovelInfo
+	if err := json.Unmarshal(data, &novels); err != nil {
+		return nil, fmt.Errorf("读取小说索引失败: %w", err)
+	}
+	return novels, nil
+}
+
+func (l *Library) save(novels []models.NovelInfo) error {
+	if err := os.MkdirAll(l.Dir, 0o755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(novels, "", "  ")
+	if err != nil {
+		return err
+	}
+	indexPath := filepath.Join(l.Dir, libraryIndex)
+	tmpPath := indexPath + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, indexPath)
+}
+
+// sync 在持有锁时调用，只在索引有变化时写回。
+func (l *Library) sync() ([]models.NovelInfo, error) {
+	novels, err := l.load()
+	if err != nil {
+		return nil, err
+	}
+	entries, err := os.ReadDir(l.Dir)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	present := map[string]os.DirEntry{}
+	for _, entry := range entries {
+		if entry.Type().IsRegular() && isNovelFile(entry.Name()) {
+			present[entry.Name()] = entry
+		}
+	}
+
+	changed := false
+	synced := make([]models.NovelInfo, 0, len(present))
+	indexed := map[string]bool{}
+	for _, info := range novels {
+		if present[info.ID] == nil || indexed[info.ID] {
+			changed = true
+			continue
+		}
+		indexed[info.ID] = true
+		info.FilePath = filepath.Join(l.Dir, info.ID)
+		synced = append(synced, info)
+	}
+	for name, entry := range present {
+		if indexed[name] {
+			continue
+		}
+		info, err := l.describe(entry)
+		if err != nil {
+			return nil, err
+		}
+		synced = append(synced, info)
+		changed = true
+	}
+	if changed {
+		if err := l.save(synced); err != nil {
+			return nil, err
+		}
+	}
+	return synced, nil
+}
+
+// uploadTimestamp 匹配上传时加在文件名前的纳秒时间戳。
+var uploadTimestamp = regexp.MustCompile(`^(\d{16,20})_`)
+
+// describe 为索引中没有的文件生成元数据。有原始文件时以原始文件识别格式和编码、计算哈希，
+// 早期上传的文件没有原始文件，直接使用文件本身。
+func (l *Library) describe(entry os.DirEntry) (models.NovelInfo, error) {
+	path := filepath.Join(l.Dir, entry.Name())
+	text, err := os.ReadFile(path)
+	if err != nil {
+		return models.NovelInfo{}, err
+	}
+	original, err := os.ReadFile(path + OriginalSuffix)
+	if err != nil {
+		original = text
+	}
+	stat, err := entry.Info()
+	if err != nil {
+		return models.NovelInfo{}, err
+	}
+
+	info := models.NovelInfo{
+		ID:         entry.Name(),
+		Title:      titleFromFileName(entry.Name()),
+		FilePath:   path,
+		Size:       int64(len(text)),
+		Format:     DetectFormat(original),
+		Encoding:   EncodingUTF8,
+		Chapters:   len(ReadChapters(path, string(text))),
+		Hash:       HashContent(original),
+		UploadedAt: stat.ModTime(),
+	}
+	if info.Format == FormatText {
+		info.Encoding = DetectEncoding(original)
+	}
+	if m := uploadTimestamp.FindStringSubmatch(entry.Name()); m != nil {
+		var nanos int64
+		if _, err := fmt.Sscan(m[1], &nanos); err == nil {
+			info.UploadedAt = time.Unix(0, nanos)
+		}
+	}
+	return info, nil
+}
+
+// titleFromFileName 去掉上传时间戳和扩展名，作为默认标题。
+func titleFromFileName(name string) string {
+	name = uploadTimestamp.ReplaceAllString(name, "")
+	if title := strings.TrimSuffix(name, filepath.Ext(name)); title != "" {
+		return title
+	}
+	return name
+}
@@ -0,0 +1,137 @@
+//go:build ignore
+
+// maketables 根据 WHATWG Encoding 标准的 GB18030 索引生成 gb18030_table.go：
+//
+//	go run maketables.go [-index 文件或URL] [-ranges 文件或URL]
+//
+// index-gb18030.txt 给出双字节编码（按指针编号）对应的码点，index-gb18030-ranges.txt
+// 给出 BMP 内四字节编码的分段起点。两份索引都可以用本地文件代替，便于离线生成。
+// 注意 WHATWG 索引会随标准更新，新版本中个别原属私用区的码位可能改为标准码点。
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"flag"
+	"fmt"
+	"go/format"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+)
+
+const (
+	twoByteSize = 126 * 190
+	// supplementaryStart 之后的线性序号按公式对应 U+10000 起的码点，不放入分段表。
+	supplementaryStart = 189000
+)
+
+func main() {
+	indexSource := flag.String("index", "https://encoding.spec.whatwg.org/index-gb18030.txt", "双字节索引的文件路径或 URL")
+	rangesSource := flag.String("ranges", "https://encoding.spec.whatwg.org/index-gb18030-ranges.txt", "四字节分段索引的文件路径或 URL")
+	output := flag.String("o", "gb18030_table.go", "输出文件")
+	flag.Parse()
+
+	twoByte := make([]uint16, twoByteSize)
+	if err := readIndex(*indexSource, func(pointer int, r rune) error {
+		if pointer < 0 || pointer >= twoByteSize || r > 0xFFFF {
+			return fmt.Errorf("双字节索引超出范围: %d -> U+%04X", pointer, r)
+		}
+		twoByte[pointer] = uint16(r)
+		return nil
+	}); err != nil {
+		log.Fatal(err)
+	}
+
+	var ranges [][2]int
+	if err := readIndex(*rangesSource, func(pointer int, r rune) error {
+		if pointer >= supplementaryStart {
+			return nil
+		}
+		if pointer > 0xFFFF || r > 0xFFFF || (len(ranges) > 0 && pointer <= ranges[len(ranges)-1][0]) {
+			return fmt.Errorf("四字节分段无效: %d -> U+%04X", pointer, r)
+		}
+		ranges = append(ranges, [2]int{pointer, int(r)})
+		return nil
+	}); err != nil {
+		log.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("// Code generated by go run maketables.go; DO NOT EDIT.\n\npackage novel\n\n")
+	buf.WriteString("// gb18030TwoByte 按 (首字节-0x81)*190 + (尾字节-0x40，跳过 0x7F) 索引双字节编码对应的码点，0 表示未定义。\n")
+	buf.WriteString("var gb18030TwoByte = [126 * 190]uint16{\n")
+	for i, r := range twoByte {
+		if i%16 == 0 {
+			buf.WriteString("\t")
+		}
+		fmt.Fprintf(&buf, "0x%04X,", r)
+		if i%16 == 15 || i == len(twoByte)-1 {
+			buf.WriteString("\n")
+		} else {
+			buf.WriteString(" ")
+		}
+	}
+	buf.WriteString("}\n\n")
+	buf.WriteString("// gb18030FourByteRanges 是 BMP 内四字节编码的分段映射：线性序号从 linear 开始连续对应从 r 开始的码点。\n")
+	buf.WriteString("var gb18030FourByteRanges = [...]struct {\n\tlinear uint16\n\tr      uint16\n}{\n")
+	for _, entry := range ranges {
+		fmt.Fprintf(&buf, "\t{0x%04X, 0x%04X},\n", entry[0], entry[1])
+	}
+	buf.WriteString("}\n")
+
+	source, err := format.Source(buf.Bytes())
+	if err != nil {
+		log.Fatalf("格式化生成的代码失败: %v", err)
+	}
+	if err := os.WriteFile(*output, source, 0o644); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// readIndex 逐行读取 WHATWG 索引文件（“指针 码点 …”，# 开头为注释），把每一项交给 add。
+func readIndex(source string, add func(pointer int, r rune) error) error {
+	body, err := open(source)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+
+	scanner := bufio.NewScanner(body)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		pointer, err := strconv.Atoi(fields[0])
+		if err != nil {
+			return fmt.Errorf("%s: 无效的指针 %q", source, fields[0])
+		}
+		r, err := strconv.ParseUint(strings.TrimPrefix(fields[1], "0x"), 16, 32)
+		if err != nil {
+			return fmt.Errorf("%s: 无效的码点 %q", source, fields[1])
+		}
+		if err := add(pointer, rune(r)); err != nil {
+			return fmt.Errorf("%s: %w", source, err)
+		}
+	}
+	return scanner.Err()
+}
+
+func open(source string) (io.ReadCloser, error) {
+	if !strings.HasPrefix(source, "http://") && !strings.HasPrefix(source, "https://") {
+		return os.Open(source)
+	}
+	resp, err := http.Get(source)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("下载 %s 失败: %s", source, resp.Status)
+	}
+	return resp.Body, nil
+}
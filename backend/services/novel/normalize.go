@@ -0,0 +1,43 @@
+package novel
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// halfWidthPunctuation 是半角片假名区中的中日文标点，统一为全角形式。
+var halfWidthPunctuation = map[rune]rune{
+	'｡': '。',
+	'｢': '「',
+	'｣': '」',
+	'､': '、',
+	'･': '・',
+}
+
+// Normalize 整理解码后的文本：去掉 BOM，换行统一为 \n，全角字母和数字转为半角，
+// 半角的中日文标点转为全角。中文的全角标点和全角空格（段首缩进）保持不变。
+func Normalize(text string) string {
+	text = strings.TrimPrefix(text, "\ufeff")
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	text = strings.ReplaceAll(text, "\r", "\n")
+
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= '０' && r <= '９', r >= 'Ａ' && r <= 'Ｚ', r >= 'ａ' && r <= 'ｚ':
+			return r - 0xFEE0
+		}
+		if full, ok := halfWidthPunctuation[r]; ok {
+			return full
+		}
+		return r
+	}, text)
+}
+
+// Preview 返回文本开头至多 limit 个字符，用于确认编码是否正确。
+func Preview(text string, limit int) string {
+	if utf8.RuneCountInString(text) <= limit {
+		return text
+	}
+	runes := []rune(text)
+	return string(runes[:limit])
+}
@@ -0,0 +1,238 @@
+// Package prompts 管理发送给 LLM 和图像模型的提示词模板。每个模板都有内置的默认内容，
+// 项目可以在配置的 prompts 中按名称覆盖；模板使用 text/template 语法。
+package prompts
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"text/template"
+
+	"taco/backend/models"
+)
+
+// 模板名称。
+const (
+	CharacterSystem          = "characterSystem"
+	CharacterExtraction      = "characterExtraction"
+	CharacterMerge           = "characterMerge"
+	SceneSystem              = "sceneSystem"
+	SceneExtraction          = "sceneExtraction"
+	CharacterImage           = "characterImage"
+	SceneImage               = "sceneImage"
+	SceneImageWithCharacters = "sceneImageWithCharacters"
+)
+
+var ErrUnknownTemplate = errors.New("未知的提示词模板")
+
+// CharacterExtractionData 是提取人物时每段的模板数据，Index 从 1 开始。
+type CharacterExtractionData struct {
+	Index int
+	Total int
+	// Known 是前文已经提取到的人物名称。
+	Known []string
+	Chunk string
+}
+
+// CharacterMergeData 是查找重复人物的模板数据，CharactersJSON 为人物名称、别名和描述的 JSON。
+type CharacterMergeData struct {
+	CharactersJSON string
+}
+
+// SceneExtractionData 是拆分场景时每段的模板数据，Quota 为 0 表示不限制场景数。
+type SceneExtractionData struct {
+	Index          int
+	Total          int
+	Quota          int
+	Chunk          string
+	CharactersJSON string
+}
+
+// CharacterImageData 是生成角色立绘的模板数据，Style 为配置的画风，可能为空。
+type CharacterImageData struct {
+	Style     string
+	Character models.CharacterProfile
+}
+
+// SceneImageData 是生成场景图的模板数据，Characters 为按出场顺序匹配到的角色。
+type SceneImageData struct {
+	Style      string
+	Scene      models.Scene
+	Characters []models.CharacterProfile
+}
+
+// SceneImageWithCharactersData 是参考角色立绘编辑场景图的模板数据，
+// References 是按参考图顺序排列的角色名称（第 1 张图对应 References[0]）。
+type SceneImageWithCharactersData struct {
+	Style      string
+	Scene      models.Scene
+	Characters []models.CharacterProfile
+	References []string
+}
+
+// Definition 描述一个模板。sample 用于在保存覆盖内容前试渲染，尽早发现引用了不存在字段的模板。
+type Definition struct {
+	Name        string
+	Title       string
+	Description string
+	Default     string
+	sample      any
+}
+
+var sampleCharacter = models.CharacterProfile{
+	ID:          "chr_sample",
+	Name:        "林黛玉",
+	Description: "体弱多病，才情出众",
+	CharacterSheet: models.CharacterSheet{
+		Gender:       "女",
+		Hair:         "乌黑长发",
+		Colors:       []string{"淡绿", "月白"},
+		VisualPrompt: "young woman, slender, long black hair, pale green hanfu",
+	},
+}
+
+var sampleScene = models.Scene{
+	ID:          "scn_sample",
+	Title:       "黛玉葬花",
+	Characters:  []string{"林黛玉", "贾宝玉"},
+	Description: "暮春时节，落花满地",
+	Dialogues:   []string{"花谢花飞花满天"},
+	Narration:   "黛玉荷锄而来。",
+}
+
+var definitions = []Definition{
+	{
+		Name:        CharacterSystem,
+		Title:       "人物提取：系统提示词",
+		Description: "没有变量。",
+		Default:     "你是一名擅长从小说中抽取人物信息的助手。",
+	},
+	{
+		Name:        CharacterExtraction,
+		Title:       "人物提取：每段的提示词",
+		Description: ".Index/.Total 为当前段序号（从 1 开始）和总段数，.Known 为前文已出现的人物名称，.Chunk 为本段小说内容。输出格式说明会自动追加在末尾。",
+		Default: `{{if gt .Total 1}}以下是一部小说的第 {{.Index}}/{{.Total}} 段。{{end}}请阅读以下小说内容，从中提取主要人物及其关键特征。{{if .Known}}
+
+前文已出现的人物：{{join .Known "、"}}。如果本段中出现的是同一人物，请使用相同的名称，并只描述本段新增的特征。{{end}}
+
+小说内容：
+{{.Chunk}}`,
+		sample: CharacterExtractionData{Index: 2, Total: 3, Known: []string{"林黛玉"}, Chunk: "小说内容"},
+	},
+	{
+		Name:        CharacterMerge,
+		Title:       "人物去重：查找同一人物",
+		Description: ".CharactersJSON 为现有人物的名称、别名和描述。系统提示词使用“人物提取：系统提示词”，输出格式说明会自动追加在末尾。",
+		Default: `以下是从一部小说中提取的人物列表。同一人物可能以不同的称呼出现为多个条目，例如全名、小名、称谓或“某氏”。请找出实际为同一人物的条目，每组给出应保留的名称和其余的名称，并简要说明理由；没有把握的不要列出。
+
+人物列表 (JSON):
+{{.CharactersJSON}}`,
+		sample: CharacterMergeData{CharactersJSON: "[]"},
+	},
+	{
+		Name:        SceneSystem,
+		Title:       "场景拆分：系统提示词",
+		Description: "没有变量。",
+		Default:     "你是一名资深的分镜师，擅长把小说拆分成动漫场景。",
+	},
+	{
+		Name:        SceneExtraction,
+		Title:       "场景拆分：每段的提示词",
+		Description: ".Index/.Total 为当前段序号和总段数，.Quota 为本段最多拆分的场景数（0 表示不限制），.Chunk 为本段小说内容，.CharactersJSON 为已有角色的名称、别名、描述和外观提示词的 JSON。输出格式说明会自动追加在末尾。",
+		Default: `{{if gt .Total 1}}以下是一部小说的第 {{.Index}}/{{.Total}} 段。{{end}}请基于以下小说内容和现有的角色信息拆分出适合制作动漫的关键场景{{if gt .Quota 0}}（不超过 {{.Quota}} 个，按剧情先后排列）{{end}}。
+
+小说内容：
+{{.Chunk}}
+
+角色信息 (JSON):
+{{.CharactersJSON}}`,
+		sample: SceneExtractionData{Index: 1, Total: 2, Quota: 3, Chunk: "小说内容", CharactersJSON: "[]"},
+	},
+	{
+		Name:        CharacterImage,
+		Title:       "角色立绘",
+		Description: ".Style 为配置的画风（可能为空），.Character 为角色（.Name、.Description、.VisualPrompt 以及 .Gender、.AgeRange、.Build、.Hair、.Eyes、.Clothing、.Colors 等设定）。",
+		Default:     `以{{or .Style "高质量动漫风格"}}绘制角色立绘，要求：角色名称：{{.Character.Name}}。{{with .Character.VisualPrompt}}外观提示词（英文，须严格遵循）：{{.}}。{{else}}{{with .Character.Gender}}性别：{{.}}。{{end}}{{with .Character.AgeRange}}年龄：{{.}}。{{end}}{{with .Character.Build}}体型：{{.}}。{{end}}{{with .Character.Hair}}发型：{{.}}。{{end}}{{with .Character.Eyes}}眼睛：{{.}}。{{end}}{{with .Character.Clothing}}服装：{{.}}。{{end}}{{with .Character.Colors}}代表色：{{join . "、"}}。{{end}}{{end}}角色特征描述：{{.Character.Description}}。画面需呈现明显的动漫风格、清晰的角色特征、柔和光效与细腻线条，适合作为角色头像或立绘使用。`,
+		sample:      CharacterImageData{Style: "水彩风格", Character: sampleCharacter},
+	},
+	{
+		Name:        SceneImage,
+		Title:       "场景图",
+		Description: ".Style 为配置的画风（可能为空），.Scene 为场景（.Title、.Description、.Characters、.Dialogues、.Narration 等），.Characters 为按名称或别名匹配到的出场角色（含 .VisualPrompt）。",
+		Default:     `以{{or .Style "高质量动漫风格"}}绘制以下场景，强调电影级光影、鲜明色彩与角色表情。场景描述：{{.Scene.Description}}{{with .Scene.Characters}}。出场角色：{{join . "、"}}{{end}}{{range .Characters}}{{if .VisualPrompt}}。{{.Name}}的外观：{{.VisualPrompt}}{{end}}{{end}}{{with .Scene.Dialogues}}。对话氛围参考：{{join . " "}}{{end}}。画面需呈现明显的动漫风格、柔和光效与细腻线条。`,
+		sample:      SceneImageData{Style: "水彩风格", Scene: sampleScene, Characters: []models.CharacterProfile{sampleCharacter}},
+	},
+	{
+		Name:        SceneImageWithCharacters,
+		Title:       "参考角色立绘的场景图",
+		Description: ".Style 为配置的画风（可能为空），.Scene 为场景，.Characters 为匹配到的出场角色（含 .VisualPrompt），.References 为按参考图顺序排列的角色名称。",
+		Default:     `{{range $i, $name := .References}}图{{add $i 1}}中的人物是{{$name}}。{{end}}请以{{or .Style "高质量动漫风格"}}绘制以下场景。场景描述：{{.Scene.Description}}{{with .Scene.Characters}}。出场角色：{{join . "、"}}{{end}}{{range .Characters}}{{if .VisualPrompt}}。{{.Name}}的外观：{{.VisualPrompt}}{{end}}{{end}}{{with .Scene.Dialogues}}。对话氛围参考：{{join . " "}}{{end}}。要求画面呈现明显的动漫风格、电影级光影、鲜明色彩、角色表情生动、柔和光效与细腻线条。`,
+		sample:      SceneImageWithCharactersData{Style: "水彩风格", Scene: sampleScene, Characters: []models.CharacterProfile{sampleCharacter}, References: []string{"林黛玉"}},
+	},
+}
+
+var funcs = template.FuncMap{
+	"join": strings.Join,
+	"add":  func(a, b int) int { return a + b },
+}
+
+// Definitions 返回所有模板的定义。
+func Definitions() []Definition {
+	return append([]Definition(nil), definitions...)
+}
+
+// Lookup 按名称查找模板定义。
+func Lookup(name string) (Definition, bool) {
+	for _, definition := range definitions {
+		if definition.Name == name {
+			return definition, true
+		}
+	}
+	return Definition{}, false
+}
+
+// Text 返回实际使用的模板内容：overrides 中有非空的覆盖时使用覆盖，否则使用默认内容。
+func Text(overrides map[string]string, name string) (string, error) {
+	definition, ok := Lookup(name)
+	if !ok {
+		return "", fmt.Errorf("%w: %s", ErrUnknownTemplate, name)
+	}
+	if text := overrides[name]; strings.TrimSpace(text) != "" {
+		return text, nil
+	}
+	return definition.Default, nil
+}
+
+// Render 用 data 渲染模板，结果去掉首尾空白。
+func Render(overrides map[string]string, name string, data any) (string, error) {
+	text, err := Text(overrides, name)
+	if err != nil {
+		return "", err
+	}
+	return execute(name, text, data)
+}
+
+// Validate 检查模板能否解析，并用示例数据试渲染。
+func Validate(name, text string) error {
+	definition, ok := Lookup(name)
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrUnknownTemplate, name)
+	}
+	if strings.TrimSpace(text) == "" {
+		return errors.New("模板内容不能为空")
+	}
+	_, err := execute(name, text, definition.sample)
+	return err
+}
+
+func execute(name, text string, data any) (string, error) {
+	tmpl, err := template.New(name).Funcs(funcs).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return "", fmt.Errorf("解析提示词模板 %s 失败: %w", name, err)
+	}
+	var b strings.Builder
+	if err := tmpl.Execute(&b, data); err != nil {
+		return "", fmt.Errorf("渲染提示词模板 %s 失败: %w", name, err)
+	}
+	return strings.TrimSpace(b.String()), nil
+}
@@ -0,0 +1,83 @@
+package prompts
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"taco/backend/models"
+)
+
+func TestRenderDefaults(t *testing.T) {
+	got, err := Render(nil, CharacterExtraction, CharacterExtractionData{Index: 2, Total: 3, Known: []string{"甲", "乙"}, Chunk: "正文"})
+	if err != nil {
+		t.Fatalf("Render failed: %v", err)
+	}
+	want := "以下是一部小说的第 2/3 段。请阅读以下小说内容，从中提取主要人物及其关键特征。\n\n前文已出现的人物：甲、乙。如果本段中出现的是同一人物，请使用相同的名称，并只描述本段新增的特征。\n\n小说内容：\n正文"
+	if got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	got, _ = Render(nil, SceneImageWithCharacters, SceneImageWithCharactersData{
+		Scene:      models.Scene{Description: "雨夜", Characters: []string{"甲", "乙"}},
+		References: []string{"甲", "乙"},
+	})
+	if !strings.HasPrefix(got, "图1中的人物是甲。图2中的人物是乙。请以高质量动漫风格绘制以下场景。场景描述：雨夜。出场角色：甲、乙。要求") {
+		t.Errorf("Unexpected scene prompt %q", got)
+	}
+}
+
+func TestRenderUsesOverride(t *testing.T) {
+	overrides := map[string]string{CharacterImage: "  {{.Character.Name}}，{{.Style}}  ", SceneImage: "   "}
+	got, err := Render(overrides, CharacterImage, CharacterImageData{Style: "水彩", Character: models.CharacterProfile{Name: "甲"}})
+	if err != nil || got != "甲，水彩" {
+		t.Errorf("Expected the override to be used, got %q, %v", got, err)
+	}
+	if text, _ := Text(overrides, SceneImage); text != definitionOf(t, SceneImage).Default {
+		t.Errorf("Expected a blank override to fall back to the default, got %q", text)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	for _, definition := range Definitions() {
+		if err := Validate(definition.Name, definition.Default); err != nil {
+			t.Errorf("Default template %s does not validate: %v", definition.Name, err)
+		}
+	}
+	for _, text := range []string{"", "{{.Character.Name", "{{.Character.Nickname}}", "{{.Missing}}"} {
+		if err := Validate(CharacterImage, text); err == nil {
+			t.Errorf("Expected %q to be rejected", text)
+		}
+	}
+	if err := Validate("nope", "x"); !errors.Is(err, ErrUnknownTemplate) {
+		t.Errorf("Expected ErrUnknownTemplate, got %v", err)
+	}
+}
+
+func definitionOf(t *testing.T, name string) Definition {
+	t.Helper()
+	definition, ok := Lookup(name)
+	if !ok {
+		t.Fatalf("Unknown template %s", name)
+	}
+	return definition
+}
+
+func TestCharacterImageUsesSheet(t *testing.T) {
+	character := models.CharacterProfile{Name: "甲", Description: "描述", CharacterSheet: models.CharacterSheet{Hair: "黑色短发", Colors: []string{"红", "黑"}}}
+	got, _ := Render(nil, CharacterImage, CharacterImageData{Character: character})
+	if !strings.Contains(got, "角色名称：甲。发型：黑色短发。代表色：红、黑。角色特征描述：描述。") {
+		t.Errorf("Expected the sheet fields in the prompt, got %q", got)
+	}
+
+	character.VisualPrompt = "young man, short black hair, red jacket"
+	got, _ = Render(nil, CharacterImage, CharacterImageData{Character: character})
+	if !strings.Contains(got, "外观提示词（英文，须严格遵循）：young man, short black hair, red jacket。角色特征描述") || strings.Contains(got, "发型") {
+		t.Errorf("Expected the visual prompt verbatim instead of the sheet fields, got %q", got)
+	}
+
+	got, _ = Render(nil, CharacterImage, CharacterImageData{Character: models.CharacterProfile{Name: "乙", Description: "描述"}})
+	if got != "以高质量动漫风格绘制角色立绘，要求：角色名称：乙。角色特征描述：描述。画面需呈现明显的动漫风格、清晰的角色特征、柔和光效与细腻线条，适合作为角色头像或立绘使用。" {
+		t.Errorf("Expected the prompt without a sheet to be unchanged, got %q", got)
+	}
+}
@@ -0,0 +1,208 @@
+// Package retry 是调用外部服务（LLM、图像、图像编辑、语音）共用的重试策略：
+// 指数退避加随机抖动，遵守 429/503 响应的 Retry-After，上下文取消后立即停止。
+package retry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"taco/backend/models"
+)
+
+// 默认策略：最多请求 3 次，第一次重试前等待约 1 秒，之后每次翻倍，单次等待不超过 30 秒。
+const (
+	DefaultMaxAttempts = 3
+	DefaultBaseDelay   = time.Second
+	DefaultMaxDelay    = 30 * time.Second
+
+	// maxRetryAfter 限制服务端要求的等待时间，避免一个异常的 Retry-After 让请求挂起太久。
+	maxRetryAfter = 5 * time.Minute
+)
+
+type Policy struct {
+	MaxAttempts int
+	BaseDelay   time.Duration
+	MaxDelay    time.Duration
+}
+
+// FromConfig 把配置转换为重试策略，未填写的字段使用默认值。
+func FromConfig(cfg models.RetryConfig) Policy {
+	policy := Policy{
+		MaxAttempts: cfg.MaxAttempts,
+		BaseDelay:   time.Duration(cfg.BaseDelayMs) * time.Millisecond,
+		MaxDelay:    time.Duration(cfg.MaxDelayMs) * time.Millisecond,
+	}
+	if policy.MaxAttempts <= 0 {
+		policy.MaxAttempts = DefaultMaxAttempts
+	}
+	if policy.BaseDelay <= 0 {
+		policy.BaseDelay = DefaultBaseDelay
+	}
+	if policy.MaxDelay <= 0 {
+		policy.MaxDelay = DefaultMaxDelay
+	}
+	if policy.MaxDelay < policy.BaseDelay {
+		policy.MaxDelay = policy.BaseDelay
+	}
+	return policy
+}
+
+// HTTPError 是外部服务返回的非 2xx 响应。
+type HTTPError struct {
+	Message    string
+	StatusCode int
+	Body       string
+	RetryAfter time.Duration
+}
+
+func (e *HTTPError) Error() string {
+	return fmt.Sprintf("%s (状态码 %d): %s", e.Message, e.StatusCode, e.Body)
+}
+
+// NewHTTPError 读取响应体和 Retry-After 生成 HTTPError，message 说明是哪个服务的请求失败。
+func NewHTTPError(resp *http.Response, message string) *HTTPError {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	text := strings.TrimSpace(string(body))
+	if text == "" {
+		text = http.StatusText(resp.StatusCode)
+	}
+	return &HTTPError{
+		Message:    message,
+		StatusCode: resp.StatusCode,
+		Body:       text,
+		RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After"), time.Now()),
+	}
+}
+
+// parseRetryAfter 支持秒数和 HTTP 日期两种格式，无法解析时返回 0。
+func parseRetryAfter(value string, now time.Time) time.Duration {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0
+		}
+		return time.Duration(seconds) * time.Second
+	}
+	if at, err := http.ParseTime(value); err == nil && at.After(now) {
+		return at.Sub(now)
+	}
+	return 0
+}
+
+// RetryableStatus 判断状态码是否值得重试：超时、限流和服务端的临时错误。
+// 其余 4xx（参数错误、鉴权失败等）重试也不会成功。
+func RetryableStatus(code int) bool {
+	switch code {
+	case http.StatusRequestTimeout, http.StatusTooEarly, http.StatusTooManyRequests:
+		return true
+	case http.StatusNotImplemented, http.StatusHTTPVersionNotSupported:
+		return false
+	}
+	return code >= 500
+}
+
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string { return e.err.Error() }
+func (e *permanentError) Unwrap() error { return e.err }
+
+// Permanent 标记不需要重试的错误，Do 会直接返回其中的原始错误。
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &permanentError{err: err}
+}
+
+// retryable 判断错误是否可以重试。网络错误、响应解析失败等默认可以重试，
+// 不可重试的状态码和上下文取消不重试。
+func retryable(ctx context.Context, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	var httpErr *HTTPError
+	if errors.As(err, &httpErr) {
+		return RetryableStatus(httpErr.StatusCode)
+	}
+	return true
+}
+
+// Delay 返回第 attempt 次失败后的等待时间（attempt 从 1 开始）：BaseDelay·2^(attempt-1)，
+// 不超过 MaxDelay，再在后一半范围内随机取值，避免多个请求同时重试。
+func (p Policy) Delay(attempt int) time.Duration {
+	delay := p.MaxDelay
+	if attempt < 32 {
+		if d := p.BaseDelay << (attempt - 1); d > 0 && d < p.MaxDelay {
+			delay = d
+		}
+	}
+	half := delay / 2
+	return half + time.Duration(rand.Int63n(int64(delay-half)+1))
+}
+
+// wait 在测试中被替换，避免真正等待。
+var wait = func(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// Do 按策略执行 fn，直到成功、遇到不可重试的错误、次数用完或上下文取消。
+// name 用于日志，例如 "LLM API"。
+func Do[T any](ctx context.Context, policy Policy, name string, fn func() (T, error)) (T, error) {
+	if policy.MaxAttempts <= 0 {
+		policy = FromConfig(models.RetryConfig{})
+	}
+	var zero T
+	for attempt := 1; ; attempt++ {
+		result, err := fn()
+		if err == nil {
+			if attempt > 1 {
+				log.Printf("[%s] 第 %d 次尝试成功", name, attempt)
+			}
+			return result, nil
+		}
+
+		var permanent *permanentError
+		if errors.As(err, &permanent) {
+			return zero, permanent.err
+		}
+		if !retryable(ctx, err) {
+			return zero, err
+		}
+		if attempt >= policy.MaxAttempts {
+			if attempt > 1 {
+				return zero, fmt.Errorf("尝试 %d 次后仍然失败: %w", attempt, err)
+			}
+			return zero, err
+		}
+
+		delay := policy.Delay(attempt)
+		var httpErr *HTTPError
+		if errors.As(err, &httpErr) && httpErr.RetryAfter > 0 {
+			delay = min(httpErr.RetryAfter, maxRetryAfter)
+		}
+		log.Printf("[%s] 第 %d/%d 次尝试失败: %v，%v 后重试", name, attempt, policy.MaxAttempts, err, delay.Round(time.Millisecond))
+		if err := wait(ctx, delay); err != nil {
+			return zero, err
+		}
+	}
+}
@@ -0,0 +1,151 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"taco/backend/models"
+)
+
+// recordWaits 用记录代替真正的等待，返回每次等待的时长。
+func recordWaits(t *testing.T) *[]time.Duration {
+	t.Helper()
+	waits := []time.Duration{}
+	original := wait
+	wait = func(ctx context.Context, d time.Duration) error {
+		waits = append(waits, d)
+		return ctx.Err()
+	}
+	t.Cleanup(func() { wait = original })
+	return &waits
+}
+
+var testPolicy = Policy{MaxAttempts: 4, BaseDelay: 100 * time.Millisecond, MaxDelay: time.Second}
+
+func TestDoRetriesRetryableErrors(t *testing.T) {
+	waits := recordWaits(t)
+	calls := 0
+	result, err := Do(context.Background(), testPolicy, "测试", func() (string, error) {
+		calls++
+		switch calls {
+		case 1:
+			return "", &HTTPError{StatusCode: http.StatusServiceUnavailable}
+		case 2:
+			return "", errors.New("connection reset")
+		}
+		return "ok", nil
+	})
+	if err != nil || result != "ok" || calls != 3 {
+		t.Fatalf("Expected success on the third call, got %q, %v after %d calls", result, err, calls)
+	}
+	if len(*waits) != 2 || (*waits)[0] < 50*time.Millisecond || (*waits)[0] > 100*time.Millisecond ||
+		(*waits)[1] < 100*time.Millisecond || (*waits)[1] > 200*time.Millisecond {
+		t.Errorf("Expected jittered exponential backoff, got %v", *waits)
+	}
+}
+
+func TestDoStopsOnNonRetryableErrors(t *testing.T) {
+	recordWaits(t)
+	for _, failure := range []error{
+		&HTTPError{StatusCode: http.StatusUnauthorized},
+		Permanent(errors.New("参数错误")),
+	} {
+		calls := 0
+		_, err := Do(context.Background(), testPolicy, "测试", func() (int, error) {
+			calls++
+			return 0, failure
+		})
+		if calls != 1 || err == nil {
+			t.Errorf("%v: expected a single call, got %d", failure, calls)
+		}
+		var permanent *permanentError
+		if errors.As(err, &permanent) {
+			t.Error("Expected the permanent wrapper to be removed")
+		}
+	}
+}
+
+func TestDoGivesUpAfterMaxAttempts(t *testing.T) {
+	recordWaits(t)
+	calls := 0
+	_, err := Do(context.Background(), testPolicy, "测试", func() (int, error) {
+		calls++
+		return 0, &HTTPError{Message: "失败", StatusCode: http.StatusBadGateway, Body: "bad gateway"}
+	})
+	var httpErr *HTTPError
+	if calls != 4 || !errors.As(err, &httpErr) || !strings.Contains(err.Error(), "尝试 4 次") {
+		t.Errorf("Expected 4 attempts and the last error, got %d: %v", calls, err)
+	}
+}
+
+func TestDoHonorsRetryAfter(t *testing.T) {
+	waits := recordWaits(t)
+	calls := 0
+	Do(context.Background(), testPolicy, "测试", func() (int, error) {
+		calls++
+		if calls == 1 {
+			return 0, &HTTPError{StatusCode: http.StatusTooManyRequests, RetryAfter: 7 * time.Second}
+		}
+		return 1, nil
+	})
+	if len(*waits) != 1 || (*waits)[0] != 7*time.Second {
+		t.Errorf("Expected to wait for Retry-After, got %v", *waits)
+	}
+}
+
+func TestDoStopsWhenContextCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	calls := 0
+	done := make(chan error)
+	go func() {
+		_, err := Do(ctx, Policy{MaxAttempts: 3, BaseDelay: time.Hour, MaxDelay: time.Hour}, "测试", func() (int, error) {
+			calls++
+			return 0, errors.New("timeout")
+		})
+		done <- err
+	}()
+	time.Sleep(10 * time.Millisecond)
+	cancel()
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.Canceled) || calls != 1 {
+			t.Errorf("Expected cancellation during the wait, got %v after %d calls", err, calls)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Do did not stop after the context was cancelled")
+	}
+}
+
+func TestFromConfigDefaults(t *testing.T) {
+	policy := FromConfig(models.RetryConfig{})
+	if policy.MaxAttempts != DefaultMaxAttempts || policy.BaseDelay != DefaultBaseDelay || policy.MaxDelay != DefaultMaxDelay {
+		t.Errorf("Unexpected default policy %+v", policy)
+	}
+	policy = FromConfig(models.RetryConfig{MaxAttempts: 5, BaseDelayMs: 200, MaxDelayMs: 800})
+	for attempt := 1; attempt <= 10; attempt++ {
+		if d := policy.Delay(attempt); d > 800*time.Millisecond || d < 100*time.Millisecond {
+			t.Errorf("attempt %d: delay %v out of range", attempt, d)
+		}
+	}
+}
+
+func TestNewHTTPError(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	if d := parseRetryAfter(now.Add(90*time.Second).Format(http.TimeFormat), now); d != 90*time.Second {
+		t.Errorf("Expected 90s from an HTTP date, got %v", d)
+	}
+
+	recorder := httptest.NewRecorder()
+	recorder.Header().Set("Retry-After", "12")
+	recorder.WriteHeader(http.StatusTooManyRequests)
+	recorder.WriteString(" 请求过于频繁 ")
+	err := NewHTTPError(recorder.Result(), "LLM 请求失败")
+	if err.RetryAfter != 12*time.Second || err.Body != "请求过于频繁" || err.Error() != "LLM 请求失败 (状态码 429): 请求过于频繁" {
+		t.Errorf("Unexpected error %+v", err)
+	}
+}
@@ -0,0 +1,63 @@
+package utils
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// AssetDirs 描述一个项目生成文件的磁盘目录以及浏览器访问这些文件的路径前缀。
+type AssetDirs struct {
+	ImagesDir       string
+	ImagesURLPrefix string
+	AudioDir        string
+	AudioURLPrefix  string
+}
+
+func DefaultAssetDirs() AssetDirs {
+	return AssetDirs{
+		ImagesDir:       GeneratedImagesDir,
+		ImagesURLPrefix: GeneratedImagesURLPrefix,
+		AudioDir:        GeneratedAudioDir,
+		AudioURLPrefix:  GeneratedAudioURLPrefix,
+	}
+}
+
+func (d AssetDirs) ImageURL(filename string) string {
+	return d.ImagesURLPrefix + filename
+}
+
+func (d AssetDirs) AudioURL(filename string) string {
+	return d.AudioURLPrefix + filename
+}
+
+// ResolveImage 将图片访问路径转换为磁盘路径，无法识别的前缀按文件名在图片目录中查找。
+func (d AssetDirs) ResolveImage(relPath string) string {
+	if strings.HasPrefix(relPath, d.ImagesURLPrefix) {
+		return filepath.Join(d.ImagesDir, filepath.Base(strings.TrimPrefix(relPath, d.ImagesURLPrefix)))
+	}
+	if strings.HasPrefix(relPath, "/") {
+		return relPath
+	}
+	return filepath.Join(d.ImagesDir, filepath.Base(relPath))
+}
+
+// ImageFile 返回图片访问路径对应的磁盘文件，只识别本项目图片前缀下的路径。
+func (d AssetDirs) ImageFile(relPath string) (string, bool) {
+	return assetFile(relPath, d.ImagesURLPrefix, d.ImagesDir)
+}
+
+// AudioFile 返回音频访问路径对应的磁盘文件，只识别本项目音频前缀下的路径。
+func (d AssetDirs) AudioFile(relPath string) (string, bool) {
+	return assetFile(relPath, d.AudioURLPrefix, d.AudioDir)
+}
+
+func assetFile(relPath, prefix, dir string) (string, bool) {
+	if !strings.HasPrefix(relPath, prefix) {
+		return "", false
+	}
+	filename := filepath.Base(strings.TrimPrefix(relPath, prefix))
+	if filename == "." || filename == "/" || filename == "" {
+		return "", false
+	}
+	return filepath.Join(dir, filename), true
+}
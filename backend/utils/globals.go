@@ -5,8 +5,10 @@ import "path/filepath"
 const (
 	ListenAddr               = ":8080"
 	MaxFileSize              = 32 << 20
+	MaxArchiveSize           = 1 << 30
 	GeneratedImagesURLPrefix = "/generated/images/"
 	GeneratedAudioURLPrefix  = "/generated/audio/"
+	ProjectsURLPrefix        = "/projects/"
 )
 
 var (
@@ -19,4 +21,19 @@ var (
 	GeneratedImagesDir = filepath.Join(GeneratedDir, "images")
 	GeneratedAudioDir  = filepath.Join(GeneratedDir, "audio")
 	WebDir             = filepath.Join(ProjectRoot, "web")
+	ProjectsDir        = filepath.Join(ProjectRoot, "projects")
 )
+
+// SetDataDir 把默认项目的 config/、uploads/、generated/ 以及 projects/ 切换到 dir 下。
+// 前端目录不受影响，需要时单独设置 WebDir。
+func SetDataDir(dir string) {
+	ProjectRoot = dir
+	ConfigPath = filepath.Join(dir, "config", "config.json")
+	CharactersPath = filepath.Join(dir, "config", "characters.json")
+	ScenesPath = filepath.Join(dir, "config", "scenes.json")
+	UploadDir = filepath.Join(dir, "uploads")
+	GeneratedDir = filepath.Join(dir, "generated")
+	GeneratedImagesDir = filepath.Join(GeneratedDir, "images")
+	GeneratedAudioDir = filepath.Join(GeneratedDir, "audio")
+	ProjectsDir = filepath.Join(dir, "projects")
+}
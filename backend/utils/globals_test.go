@@ -1,6 +1,7 @@
 package utils
 
 import (
+	"path/filepath"
 	"strings"
 	"testing"
 )
@@ -52,3 +53,25 @@ func TestGlobalPaths(t *testing.T) {
 		t.Errorf("WebDir should contain 'web', got '%s'", WebDir)
 	}
 }
+
+func TestSetDataDir(t *testing.T) {
+	original := []string{ProjectRoot, ConfigPath, CharactersPath, ScenesPath, UploadDir, GeneratedDir, GeneratedImagesDir, GeneratedAudioDir, ProjectsDir, WebDir}
+	t.Cleanup(func() {
+		ProjectRoot, ConfigPath, CharactersPath, ScenesPath = original[0], original[1], original[2], original[3]
+		UploadDir, GeneratedDir, GeneratedImagesDir, GeneratedAudioDir = original[4], original[5], original[6], original[7]
+		ProjectsDir, WebDir = original[8], original[9]
+	})
+
+	dir := filepath.Join(t.TempDir(), "data")
+	SetDataDir(dir)
+
+	if ConfigPath != filepath.Join(dir, "config", "config.json") {
+		t.Errorf("Unexpected ConfigPath %s", ConfigPath)
+	}
+	if GeneratedImagesDir != filepath.Join(dir, "generated", "images") || ProjectsDir != filepath.Join(dir, "projects") {
+		t.Errorf("Unexpected generated paths %s, %s", GeneratedImagesDir, ProjectsDir)
+	}
+	if WebDir != original[9] {
+		t.Errorf("SetDataDir should not change WebDir, got %s", WebDir)
+	}
+}
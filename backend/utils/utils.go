@@ -2,7 +2,9 @@ package utils
 
 import (
 	"context"
+	"crypto/rand"
 	"encoding/base64"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -19,11 +21,21 @@ func EnsureDir(path string) error {
 	return os.MkdirAll(path, 0o755)
 }
 
+// NewID 生成带前缀的随机标识，例如 "prj_3f9a1c0e7b2d4a6c"。
+func NewID(prefix string) string {
+	buf := make([]byte, 8)
+	if _, err := rand.Read(buf); err != nil {
+		return fmt.Sprintf("%s_%x", prefix, time.Now().UnixNano())
+	}
+	return prefix + "_" + hex.EncodeToString(buf)
+}
+
 func MustFindProjectRoot() string {
 	dir, err := os.Getwd()
 	if err != nil {
 		log.Fatalf("获取工作目录失败: %v", err)
 	}
+	cwd := dir
 	seen := map[string]bool{}
 	for {
 		if _, err := os.Stat(filepath.Join(dir, "go.mod")); err == nil {
@@ -39,12 +51,18 @@ func MustFindProjectRoot() string {
 		}
 		dir = parent
 	}
-	log.Printf("未找到 go.mod，默认使用当前目录: %s", dir)
-	return dir
+	log.Printf("未找到 go.mod，默认使用当前目录: %s", cwd)
+	return cwd
 }
 
 func WriteJSON(w http.ResponseWriter, payload any) {
+	WriteJSONStatus(w, http.StatusOK, payload)
+}
+
+// WriteJSONStatus 与 WriteJSON 相同，但使用指定的状态码。
+func WriteJSONStatus(w http.ResponseWriter, status int, payload any) {
 	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
 	if err := json.NewEncoder(w).Encode(payload); err != nil {
 		log.Printf("write json: %v", err)
 	}
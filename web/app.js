@@ -1,10 +1,18 @@
 const uploadArea = document.getElementById("upload-area");
 const uploadLabel = document.getElementById("upload-label");
 const novelInput = document.getElementById("novel-input");
+const novelPreview = document.getElementById("novel-preview");
+const novelEncoding = document.getElementById("novel-encoding");
+const novelEncodingField = document.getElementById("novel-encoding-field");
+const novelPreviewHint = document.getElementById("novel-preview-hint");
+const novelPreviewText = document.getElementById("novel-preview-text");
+const novelLibrary = document.getElementById("novel-library");
+const novelList = document.getElementById("novel-list");
 const form = document.getElementById("config-form");
 const statusEl = document.getElementById("status");
 const saveBtn = document.getElementById("save-btn");
 
+const llmProvider = document.getElementById("llm-provider");
 const llmModel = document.getElementById("llm-model");
 const llmBaseUrl = document.getElementById("llm-base-url");
 const llmApiKey = document.getElementById("llm-api-key");
@@ -20,15 +28,72 @@ const videoModel = document.getElementById("video-model");
 const characterCount = document.getElementById("character-count");
 const sceneCount = document.getElementById("scene-count");
 const animeStyle = document.getElementById("anime-style");
+const projectSelect = document.getElementById("project-select");
+const newProjectBtn = document.getElementById("new-project-btn");
+const exportProjectBtn = document.getElementById("export-project-btn");
+const importProjectBtn = document.getElementById("import-project-btn");
+const importProjectInput = document.getElementById("import-project-input");
+const profilesJson = document.getElementById("profiles-json");
+const operationSelects = document.querySelectorAll("[data-operation]");
 
 let currentFilePath = "";
 let currentImageEditConfig = null;
+// 保存页面上没有的 LLM 设置（分段大小、JSON 模式等），以便在保存时不丢失
+let currentLlmConfig = {};
+let currentRetryConfig = {};
 
 function setStatus(message, isError = false) {
   statusEl.textContent = message;
   statusEl.classList.toggle("error", isError);
 }
 
+function parseProfiles() {
+  const text = profilesJson.value.trim();
+  if (!text) {
+    return [];
+  }
+  const profiles = JSON.parse(text);
+  if (!Array.isArray(profiles)) {
+    throw new Error("配置档案必须是 JSON 数组");
+  }
+  return profiles;
+}
+
+// 根据档案列表刷新各操作的下拉框，保留仍然存在的选择。
+function renderOperationOptions(operations = null) {
+  let names = [];
+  try {
+    names = parseProfiles().map((profile) => profile?.name).filter(Boolean);
+  } catch (err) {
+    return;
+  }
+  operationSelects.forEach((select) => {
+    const selected = operations ? operations[select.dataset.operation] ?? "" : select.value;
+    select.innerHTML = "";
+    ["", ...names].forEach((name) => {
+      const option = document.createElement("option");
+      option.value = name;
+      option.textContent = name || "默认配置";
+      select.appendChild(option);
+    });
+    if (selected && !names.includes(selected)) {
+      const option = document.createElement("option");
+      option.value = selected;
+      option.textContent = `${selected}（不存在）`;
+      select.appendChild(option);
+    }
+    select.value = selected;
+  });
+}
+
+function collectOperations() {
+  const operations = {};
+  operationSelects.forEach((select) => {
+    operations[select.dataset.operation] = select.value;
+  });
+  return operations;
+}
+
 function setUploadLabel(filePath) {
   if (!filePath) {
     uploadLabel.textContent = "添加文件";
@@ -42,11 +107,14 @@ function setUploadLabel(filePath) {
 
 async function loadConfig() {
   try {
-    const response = await fetch("/api/config");
+    const response = await fetch(apiUrl("/api/config"));
     if (!response.ok) {
       throw new Error(`加载配置失败 (${response.status})`);
     }
     const data = await response.json();
+    currentLlmConfig = data.llm ?? {};
+    currentRetryConfig = data.retry ?? {};
+    llmProvider.value = data.llm?.provider || "openai";
     llmModel.value = data.llm?.model ?? data.llmModel ?? "";
     llmBaseUrl.value = data.llm?.baseUrl ?? data.llmBaseUrl ?? "";
     llmApiKey.value = data.llm?.apiKey ?? data.llmApiKey ?? "";
@@ -73,33 +141,305 @@ async function loadConfig() {
     characterCount.value = data.characterCount ?? 0;
     sceneCount.value = data.sceneCount ?? 0;
     animeStyle.value = data.animeStyle ?? "";
+    profilesJson.value = data.profiles?.length ? JSON.stringify(data.profiles, null, 2) : "";
+    renderOperationOptions(data.operations ?? {});
     setUploadLabel(data.novelFile ?? "");
     setStatus("");
+    loadNovels();
   } catch (err) {
     setStatus(err.message, true);
   }
 }
 
+async function loadProjects() {
+  try {
+    const response = await fetch("/api/projects");
+    if (!response.ok) {
+      throw new Error(`加载项目失败 (${response.status})`);
+    }
+    const projects = await response.json();
+    const currentId = getCurrentProjectId() || "default";
+    projectSelect.innerHTML = "";
+    projects.forEach((project) => {
+      const option = document.createElement("option");
+      option.value = project.id;
+      option.textContent = project.name || project.id;
+      projectSelect.appendChild(option);
+    });
+    if (projects.some((project) => project.id === currentId)) {
+      projectSelect.value = currentId;
+    } else {
+      projectSelect.value = "default";
+      setCurrentProjectId("default");
+    }
+  } catch (err) {
+    setStatus(err.message, true);
+  }
+}
+
+async function createProject() {
+  const name = window.prompt("请输入新项目名称");
+  if (!name || !name.trim()) {
+    return;
+  }
+  try {
+    const response = await fetch("/api/projects", {
+      method: "POST",
+      headers: {
+        "Content-Type": "application/json",
+      },
+      body: JSON.stringify({ name: name.trim() }),
+    });
+    if (!response.ok) {
+      const text = await response.text();
+      throw new Error(text || "创建项目失败");
+    }
+    const project = await response.json();
+    setCurrentProjectId(project.id);
+    await loadProjects();
+    await loadConfig();
+    setStatus(`已创建项目：${project.name}`);
+  } catch (err) {
+    setStatus(err.message, true);
+  }
+}
+
+projectSelect.addEventListener("change", () => {
+  setCurrentProjectId(projectSelect.value);
+  loadConfig();
+});
+
+newProjectBtn.addEventListener("click", createProject);
+
+// 导出为 zip 下载，归档中不包含 API Key。
+exportProjectBtn.addEventListener("click", () => {
+  window.location.href = apiUrl("/api/export/project");
+});
+
+async function importProject(file) {
+  const formData = new FormData();
+  formData.append("archive", file);
+  setStatus("项目导入中...");
+  try {
+    const response = await fetch("/api/import/project", {
+      method: "POST",
+      body: formData,
+    });
+    if (!response.ok) {
+      const text = await response.text();
+      throw new Error(text || "导入项目失败");
+    }
+    const project = await response.json();
+    setCurrentProjectId(project.id);
+    await loadProjects();
+    await loadConfig();
+    setStatus(`已导入项目：${project.name}`);
+  } catch (err) {
+    setStatus(err.message, true);
+  }
+}
+
+importProjectBtn.addEventListener("click", () => importProjectInput.click());
+
+importProjectInput.addEventListener("change", () => {
+  const file = importProjectInput.files[0];
+  importProjectInput.value = "";
+  if (file) {
+    importProject(file);
+  }
+});
+
 async function uploadFile(file) {
   const formData = new FormData();
   formData.append("novel", file);
   setStatus("文件上传中...");
   try {
-    const response = await fetch("/api/upload", {
+    const response = await fetch(apiUrl("/api/upload"), {
       method: "POST",
       body: formData,
     });
     if (!response.ok) {
-      throw new Error("文件上传失败");
+      const message = await response.text();
+      throw new Error(message || "文件上传失败");
     }
     const data = await response.json();
     setUploadLabel(data.filePath);
+    showNovelPreview(data);
     setStatus("文件上传成功");
+    loadNovels();
   } catch (err) {
     setStatus(err.message, true);
   }
 }
 
+// 上传后展示识别出的编码和开头的内容，出现乱码时可以手动切换编码重新转换。
+// EPUB 和 DOCX 内部固定为 UTF-8，不显示编码选择，只显示识别出的章节数。
+function showNovelPreview(upload) {
+  const isText = !upload.format || upload.format === "text";
+  novelEncodingField.hidden = !isText;
+  novelEncoding.value = upload.encoding;
+  let hint;
+  if (isText) {
+    const source = upload.detected ? "自动识别" : "手动指定";
+    hint = `${source}的编码：${novelEncoding.selectedOptions[0]?.textContent || upload.encoding}`;
+    if (upload.invalid > 0) {
+      hint += `，有 ${upload.invalid} 处无法解码，如出现乱码请切换编码`;
+    }
+  } else {
+    hint = `已从 ${upload.format.toUpperCase()} 中提取正文`;
+  }
+  if (upload.chapters > 0) {
+    hint += `，识别到 ${upload.chapters} 章`;
+  }
+  novelPreviewHint.textContent = hint;
+  novelPreviewHint.classList.toggle("error", upload.invalid > 0);
+  novelPreviewText.textContent = upload.preview;
+  novelPreview.hidden = false;
+}
+
+async function changeNovelEncoding() {
+  if (!currentFilePath) {
+    return;
+  }
+  setStatus("正在重新转换编码...");
+  try {
+    const response = await fetch(apiUrl("/api/novel/encoding"), {
+      method: "POST",
+      headers: { "Content-Type": "application/json" },
+      body: JSON.stringify({ filePath: currentFilePath, encoding: novelEncoding.value }),
+    });
+    if (!response.ok) {
+      const message = await response.text();
+      throw new Error(message || "转换编码失败");
+    }
+    showNovelPreview(await response.json());
+    setStatus("已按新编码重新转换");
+  } catch (err) {
+    setStatus(err.message, true);
+  }
+}
+
+function formatSize(bytes) {
+  if (bytes >= 1024 * 1024) {
+    return `${(bytes / 1024 / 1024).toFixed(1)} MB`;
+  }
+  return `${Math.max(1, Math.round(bytes / 1024))} KB`;
+}
+
+// 列出项目中上传过的小说，可以切换当前使用的小说、重命名或删除。
+async function loadNovels() {
+  try {
+    const response = await fetch(apiUrl("/api/novels"));
+    if (!response.ok) {
+      throw new Error(`加载小说列表失败 (${response.status})`);
+    }
+    renderNovels(await response.json());
+  } catch (err) {
+    setStatus(err.message, true);
+  }
+}
+
+function renderNovels(novels) {
+  novelList.innerHTML = "";
+  novelLibrary.hidden = novels.length === 0;
+  novels.forEach((novel) => {
+    const item = document.createElement("li");
+    item.className = "novel-item";
+    item.classList.toggle("active", novel.active);
+
+    const info = document.createElement("div");
+    info.className = "novel-item-info";
+    const title = document.createElement("span");
+    title.className = "novel-item-title";
+    title.textContent = novel.title;
+    const meta = document.createElement("span");
+    meta.className = "novel-item-meta";
+    const parts = [formatSize(novel.size), novel.format === "text" ? novel.encoding : novel.format?.toUpperCase()];
+    if (novel.chapters > 0) {
+      parts.push(`${novel.chapters} 章`);
+    }
+    meta.textContent = parts.filter(Boolean).join(" · ");
+    info.append(title, meta);
+
+    const actions = document.createElement("div");
+    actions.className = "novel-item-actions";
+    const useBtn = document.createElement("button");
+    useBtn.type = "button";
+    useBtn.textContent = novel.active ? "使用中" : "使用";
+    useBtn.disabled = novel.active;
+    useBtn.addEventListener("click", () => activateNovel(novel));
+    const renameBtn = document.createElement("button");
+    renameBtn.type = "button";
+    renameBtn.textContent = "重命名";
+    renameBtn.addEventListener("click", () => renameNovel(novel));
+    const deleteBtn = document.createElement("button");
+    deleteBtn.type = "button";
+    deleteBtn.textContent = "删除";
+    deleteBtn.addEventListener("click", () => deleteNovel(novel));
+    actions.append(useBtn, renameBtn, deleteBtn);
+
+    item.append(info, actions);
+    novelList.appendChild(item);
+  });
+}
+
+async function novelRequest(novel, method, suffix = "", body = undefined) {
+  const response = await fetch(apiUrl(`/api/novels/${encodeURIComponent(novel.id)}${suffix}`), {
+    method,
+    headers: body ? { "Content-Type": "application/json" } : undefined,
+    body: body ? JSON.stringify(body) : undefined,
+  });
+  if (!response.ok) {
+    const message = await response.text();
+    throw new Error(message || "操作失败");
+  }
+  return response.status === 204 ? null : response.json();
+}
+
+async function activateNovel(novel) {
+  try {
+    const info = await novelRequest(novel, "POST", "/activate");
+    setUploadLabel(info.filePath);
+    novelPreview.hidden = true;
+    setStatus(`当前小说：${info.title}`);
+    loadNovels();
+  } catch (err) {
+    setStatus(err.message, true);
+  }
+}
+
+async function renameNovel(novel) {
+  const title = window.prompt("请输入新的小说标题", novel.title);
+  if (!title || !title.trim() || title.trim() === novel.title) {
+    return;
+  }
+  try {
+    await novelRequest(novel, "PATCH", "", { title: title.trim() });
+    loadNovels();
+  } catch (err) {
+    setStatus(err.message, true);
+  }
+}
+
+async function deleteNovel(novel) {
+  if (!window.confirm(`确定删除《${novel.title}》吗？`)) {
+    return;
+  }
+  try {
+    await novelRequest(novel, "DELETE");
+    if (novel.active || novel.filePath === currentFilePath) {
+      setUploadLabel("");
+      novelPreview.hidden = true;
+    }
+    setStatus(`已删除：${novel.title}`);
+    loadNovels();
+  } catch (err) {
+    setStatus(err.message, true);
+  }
+}
+
+novelEncoding.addEventListener("change", changeNovelEncoding);
+
 uploadArea.addEventListener("click", () => novelInput.click());
 
 novelInput.addEventListener("change", () => {
@@ -127,13 +467,24 @@ uploadArea.addEventListener("drop", (event) => {
   }
 });
 
+profilesJson.addEventListener("change", () => renderOperationOptions());
+
 form.addEventListener("submit", async (event) => {
   event.preventDefault();
+  let profiles;
+  try {
+    profiles = parseProfiles();
+  } catch (err) {
+    setStatus(`配置档案格式错误：${err.message}`, true);
+    return;
+  }
   saveBtn.disabled = true;
   setStatus("保存配置中...");
   const payload = {
     novelFile: currentFilePath,
     llm: {
+      ...currentLlmConfig,
+      provider: llmProvider.value,
       model: llmModel.value.trim(),
       baseUrl: llmBaseUrl.value.trim(),
       apiKey: llmApiKey.value.trim(),
@@ -159,10 +510,13 @@ form.addEventListener("submit", async (event) => {
     characterCount: Number(characterCount.value || 0),
     sceneCount: Number(sceneCount.value || 0),
     animeStyle: animeStyle.value.trim(),
+    profiles,
+    operations: collectOperations(),
+    retry: currentRetryConfig,
   };
 
   try {
-    const response = await fetch("/api/config", {
+    const response = await fetch(apiUrl("/api/config"), {
       method: "POST",
       headers: {
         "Content-Type": "application/json",
@@ -183,4 +537,4 @@ form.addEventListener("submit", async (event) => {
   }
 });
 
-loadConfig();
+loadProjects().then(loadConfig);
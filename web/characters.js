@@ -3,12 +3,15 @@ const listEl = document.getElementById("character-list");
 const nextBtn = document.getElementById("next-btn");
 const reanalyseBtn = document.getElementById("reanalyse-btn");
 const generateAllBtn = document.getElementById("generate-all-btn");
+const mergeBtn = document.getElementById("merge-btn");
 const progressContainer = document.getElementById("progress-container");
 const progressBar = document.getElementById("progress-bar");
 const progressText = document.getElementById("progress-text");
 
 let charactersData = [];
 let isBusy = false;
+let charactersETag = "";
+let charactersSnapshot = new Map();
 let collapsedStates = [];
 
 function setStatus(message, isError = false) {
@@ -21,17 +24,47 @@ function setBusy(busy) {
   nextBtn.disabled = busy;
   reanalyseBtn.disabled = busy;
   generateAllBtn.disabled = busy;
+  mergeBtn.disabled = busy;
+}
+
+function toList(value) {
+  const items = Array.isArray(value) ? value : String(value ?? "").split(/[\n,，、；;]+/);
+  return items.map((item) => (typeof item === "string" ? item.trim() : "")).filter(Boolean);
+}
+
+// 角色设定字段，colors 为数组，其余为文本。
+const sheetFields = [
+  { key: "gender", label: "性别" },
+  { key: "ageRange", label: "年龄段" },
+  { key: "build", label: "体型" },
+  { key: "hair", label: "发型" },
+  { key: "eyes", label: "眼睛" },
+  { key: "clothing", label: "标志性服装" },
+  { key: "colors", label: "代表色", list: true },
+  { key: "personality", label: "性格" },
+  { key: "speechStyle", label: "说话风格" },
+  { key: "role", label: "故事中的身份" },
+];
+
+function normalizeCharacter(character) {
+  return {
+    id: character?.id ?? "",
+    name: character?.name ?? "",
+    aliases: toList(character?.aliases),
+    description: character?.description ?? "",
+    ...Object.fromEntries(
+      sheetFields.map(({ key, list }) => [key, list ? toList(character?.[key]) : character?.[key] ?? ""])
+    ),
+    visualPrompt: character?.visualPrompt ?? "",
+    imagePath: character?.imagePath ?? "",
+  };
 }
 
 function toCharacterArray(raw) {
   if (!Array.isArray(raw)) {
     return [];
   }
-  return raw.map((character) => ({
-    name: character?.name ?? "",
-    description: character?.description ?? "",
-    imagePath: character?.imagePath ?? "",
-  }));
+  return raw.map(normalizeCharacter);
 }
 
 function renderCharacters(characters) {
@@ -105,6 +138,20 @@ function renderCharacters(characters) {
     });
     bodyContent.appendChild(nameInput);
 
+    const aliasLabel = document.createElement("label");
+    aliasLabel.style.marginTop = "12px";
+    aliasLabel.textContent = "别名";
+    bodyContent.appendChild(aliasLabel);
+
+    const aliasInput = document.createElement("input");
+    aliasInput.type = "text";
+    aliasInput.value = character.aliases.join("、");
+    aliasInput.placeholder = "文中的其他称呼，用顿号分隔";
+    aliasInput.addEventListener("input", (event) => {
+      charactersData[index].aliases = toList(event.target.value);
+    });
+    bodyContent.appendChild(aliasInput);
+
     const descLabel = document.createElement("label");
     descLabel.style.marginTop = "12px";
     descLabel.textContent = "角色描述";
@@ -118,6 +165,39 @@ function renderCharacters(characters) {
     });
     bodyContent.appendChild(descInput);
 
+    const sheetGrid = document.createElement("div");
+    sheetGrid.className = "form-grid";
+    sheetGrid.style.marginTop = "12px";
+    sheetFields.forEach(({ key, label, list }) => {
+      const field = document.createElement("label");
+      field.className = "field";
+      const caption = document.createElement("span");
+      caption.textContent = label;
+      field.appendChild(caption);
+      const input = document.createElement("input");
+      input.type = "text";
+      input.value = list ? character[key].join("、") : character[key];
+      input.addEventListener("input", (event) => {
+        charactersData[index][key] = list ? toList(event.target.value) : event.target.value;
+      });
+      field.appendChild(input);
+      sheetGrid.appendChild(field);
+    });
+    bodyContent.appendChild(sheetGrid);
+
+    const visualLabel = document.createElement("label");
+    visualLabel.style.marginTop = "12px";
+    visualLabel.textContent = "外观提示词（英文，生成角色图和场景图时原样使用）";
+    bodyContent.appendChild(visualLabel);
+
+    const visualInput = document.createElement("textarea");
+    visualInput.value = character.visualPrompt;
+    visualInput.placeholder = "young woman, slender, long black hair in a bun, pale green hanfu";
+    visualInput.addEventListener("input", (event) => {
+      charactersData[index].visualPrompt = event.target.value;
+    });
+    bodyContent.appendChild(visualInput);
+
     const buttonGroup = document.createElement("div");
     buttonGroup.className = "character-button-group";
 
@@ -165,18 +245,21 @@ async function loadCharacters({ forceAnalyse = false } = {}) {
     let characters = [];
 
     if (!forceAnalyse) {
-      const response = await fetch("/api/characters");
+      const response = await fetch(apiUrl("/api/characters"));
       if (!response.ok) {
         throw new Error("读取角色信息失败");
       }
+      charactersETag = response.headers.get("ETag") || "";
       characters = toCharacterArray(await response.json());
     }
 
     if (forceAnalyse || characters.length === 0) {
       characters = await analyseCharacters();
+      charactersETag = "";
     }
 
     renderCharacters(characters);
+    charactersSnapshot = snapshotById(charactersData, normalizeCharacter);
     setStatus("");
   } catch (err) {
     renderCharacters([]);
@@ -187,14 +270,14 @@ async function loadCharacters({ forceAnalyse = false } = {}) {
 }
 
 async function analyseCharacters() {
-  const response = await fetch("/api/characters/extract", {
-    method: "POST",
+  const preview = [];
+  const data = await streamExtraction("/api/characters/extract/stream", {
+    onProgress: (progress) => setStatus(describeExtractionProgress("角色", progress)),
+    onItem: (character) => {
+      preview.push(character);
+      renderCharacters(preview);
+    },
   });
-  if (!response.ok) {
-    const message = await response.text();
-    throw new Error(message || "角色识别失败");
-  }
-  const data = await response.json();
   return toCharacterArray(data);
 }
 
@@ -205,13 +288,14 @@ async function saveCharacters() {
   try {
     setBusy(true);
     setStatus("正在保存角色信息...");
-    const response = await fetch("/api/characters", {
-      method: "POST",
-      headers: {
-        "Content-Type": "application/json",
-      },
-      body: JSON.stringify(charactersData),
-    });
+    let response = await postCharacters();
+    if (response.status === 412) {
+      const merged = await mergeWithServerCharacters(await response.json());
+      if (!merged) {
+        return;
+      }
+      response = await postCharacters();
+    }
     if (!response.ok) {
       const text = await response.text();
       throw new Error(text || "保存角色失败");
@@ -225,9 +309,117 @@ async function saveCharacters() {
   }
 }
 
+function postCharacters() {
+  const headers = {
+    "Content-Type": "application/json",
+  };
+  if (charactersETag) {
+    headers["If-Match"] = charactersETag;
+  }
+  return fetch(apiUrl("/api/characters"), {
+    method: "POST",
+    headers,
+    body: JSON.stringify(charactersData),
+  });
+}
+
+// 与场景页相同：先自动合并，同一角色两边都改过时询问用户。
+async function mergeWithServerCharacters(conflict) {
+  const serverCharacters = toCharacterArray(conflict.current);
+  const { merged, conflicts } = mergeById(charactersData, serverCharacters, charactersSnapshot, normalizeCharacter);
+
+  charactersETag = conflict.etag || "";
+  charactersSnapshot = snapshotById(serverCharacters, normalizeCharacter);
+
+  if (
+    conflicts > 0 &&
+    !window.confirm(`有 ${conflicts} 个角色已在其他页面被修改。确定：保留你的修改并保存；取消：放弃你的修改，加载最新数据。`)
+  ) {
+    renderCharacters(serverCharacters);
+    setStatus("已加载其他页面保存的最新角色，请确认后重新保存。", true);
+    return false;
+  }
+  renderCharacters(merged);
+  return true;
+}
+
 nextBtn.addEventListener("click", saveCharacters);
 reanalyseBtn.addEventListener("click", () => loadCharacters({ forceAnalyse: true }));
 generateAllBtn.addEventListener("click", generateAllCharacterImages);
+mergeBtn.addEventListener("click", suggestMerges);
+
+function hasUnsavedCharacters() {
+  const current = snapshotById(charactersData, normalizeCharacter);
+  if (current.size !== charactersSnapshot.size || charactersData.some((character) => !character.id)) {
+    return true;
+  }
+  for (const [id, json] of current) {
+    if (charactersSnapshot.get(id) !== json) {
+      return true;
+    }
+  }
+  return false;
+}
+
+// 请模型找出可能是同一人物的角色，逐组确认后合并；合并会同时改写场景中的出场人物。
+async function suggestMerges() {
+  if (isBusy) {
+    return;
+  }
+  if (hasUnsavedCharacters() && !window.confirm("合并以服务器上保存的角色为准，当前未保存的修改会丢失。是否继续？")) {
+    return;
+  }
+  try {
+    setBusy(true);
+    setStatus("正在查找可能重复的角色...");
+    const response = await fetch(apiUrl("/api/characters/merge/suggest"), { method: "POST" });
+    if (!response.ok) {
+      const text = await response.text();
+      throw new Error(text || "查找重复角色失败");
+    }
+    const suggestions = await response.json();
+    if (!suggestions.length) {
+      setStatus("没有发现重复的角色。");
+      return;
+    }
+
+    let mergedCount = 0;
+    let scenesUpdated = 0;
+    for (const suggestion of suggestions) {
+      const reason = suggestion.reason ? `\n理由：${suggestion.reason}` : "";
+      if (!window.confirm(`将「${suggestion.sources.join("、")}」合并到「${suggestion.target}」？${reason}`)) {
+        continue;
+      }
+      const headers = { "Content-Type": "application/json" };
+      if (charactersETag) {
+        headers["If-Match"] = charactersETag;
+      }
+      const mergeResponse = await fetch(apiUrl("/api/characters/merge"), {
+        method: "POST",
+        headers,
+        body: JSON.stringify({ targetId: suggestion.targetId, sourceIds: suggestion.sourceIds }),
+      });
+      if (mergeResponse.status === 412) {
+        throw new Error("角色已在其他页面被修改，请刷新后重试。");
+      }
+      if (!mergeResponse.ok) {
+        const text = await mergeResponse.text();
+        throw new Error(text || "合并角色失败");
+      }
+      const result = await mergeResponse.json();
+      charactersETag = mergeResponse.headers.get("ETag") || "";
+      renderCharacters(result.characters);
+      charactersSnapshot = snapshotById(charactersData, normalizeCharacter);
+      mergedCount += 1;
+      scenesUpdated += result.scenesUpdated;
+    }
+    setStatus(mergedCount ? `已合并 ${mergedCount} 组角色，更新了 ${scenesUpdated} 个场景。` : "未合并任何角色。");
+  } catch (err) {
+    setStatus(err.message, true);
+  } finally {
+    setBusy(false);
+  }
+}
 
 async function generateCharacterImage(index) {
   if (isBusy) {
@@ -237,12 +429,12 @@ async function generateCharacterImage(index) {
     setBusy(true);
     setStatus(`正在生成角色 ${index + 1} 的图片...`);
 
-    const response = await fetch("/api/characters/generate-image", {
+    const response = await fetch(apiUrl("/api/characters/generate-image"), {
       method: "POST",
       headers: {
         "Content-Type": "application/json",
       },
-      body: JSON.stringify({ index }),
+      body: JSON.stringify({ id: charactersData[index].id, index }),
     });
 
     if (!response.ok) {
@@ -292,12 +484,12 @@ async function generateAllCharacterImages() {
       setStatus(`正在生成角色 ${i + 1}/${total}: ${character.name}`);
 
       try {
-        const response = await fetch("/api/characters/generate-image", {
+        const response = await fetch(apiUrl("/api/characters/generate-image"), {
           method: "POST",
           headers: {
             "Content-Type": "application/json",
           },
-          body: JSON.stringify({ index: i }),
+          body: JSON.stringify({ id: character.id, index: i }),
         });
 
         if (response.ok) {
@@ -362,8 +554,11 @@ async function uploadCharacterImage(index, event) {
     const formData = new FormData();
     formData.append("image", file);
     formData.append("index", index.toString());
+    if (charactersData[index].id) {
+      formData.append("id", charactersData[index].id);
+    }
 
-    const response = await fetch("/api/characters/upload-image", {
+    const response = await fetch(apiUrl("/api/characters/upload-image"), {
       method: "POST",
       body: formData,
     });
@@ -0,0 +1,60 @@
+// 角色、场景列表的乐观并发辅助函数。
+// 读取列表时记下 ETag 和每个条目的快照，保存时带上 If-Match；
+// 服务端返回 412 时，用快照判断哪些条目在本地或服务端被改过，尽量自动合并。
+
+function snapshotById(items, normalize) {
+  const snapshot = new Map();
+  items.forEach((item) => {
+    if (item.id) {
+      snapshot.set(item.id, JSON.stringify(normalize(item)));
+    }
+  });
+  return snapshot;
+}
+
+// mergeById 以本地顺序为准合并列表，返回合并结果和双方都改过的条目数。
+// 冲突条目保留本地版本，由调用方决定是否询问用户。
+function mergeById(localItems, serverItems, snapshot, normalize) {
+  const serverById = new Map();
+  serverItems.forEach((item) => serverById.set(item.id, item));
+  const localIds = new Set(localItems.map((item) => item.id).filter(Boolean));
+
+  const merged = [];
+  let conflicts = 0;
+
+  localItems.forEach((local) => {
+    const localJSON = JSON.stringify(normalize(local));
+    const original = local.id ? snapshot.get(local.id) : undefined;
+    const server = local.id ? serverById.get(local.id) : undefined;
+
+    if (original === undefined) {
+      merged.push(local);
+      return;
+    }
+    if (server === undefined) {
+      // 服务端已删除：本地未改动则一并删除，改过则保留。
+      if (localJSON !== original) {
+        merged.push(local);
+        conflicts += 1;
+      }
+      return;
+    }
+    const serverJSON = JSON.stringify(normalize(server));
+    if (localJSON === original) {
+      merged.push(server);
+    } else if (serverJSON === original || serverJSON === localJSON) {
+      merged.push(local);
+    } else {
+      merged.push(local);
+      conflicts += 1;
+    }
+  });
+
+  serverItems.forEach((server) => {
+    if (!localIds.has(server.id) && !snapshot.has(server.id)) {
+      merged.push(server);
+    }
+  });
+
+  return { merged, conflicts };
+}
@@ -12,7 +12,7 @@ let isPaused = false;
 // 加载场景数据
 async function loadScenes() {
   try {
-    const response = await fetch("/api/scenes");
+    const response = await fetch(apiUrl("/api/scenes"));
     if (!response.ok) {
       throw new Error("加载场景失败");
     }
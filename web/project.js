@@ -0,0 +1,90 @@
+// 当前项目通过 URL 参数 ?project= 或本地存储记住，所有 API 请求都会带上项目 ID。
+const PROJECT_STORAGE_KEY = "taco.projectId";
+
+function getCurrentProjectId() {
+  const params = new URLSearchParams(window.location.search);
+  const fromUrl = params.get("project");
+  if (fromUrl) {
+    localStorage.setItem(PROJECT_STORAGE_KEY, fromUrl);
+    return fromUrl;
+  }
+  return localStorage.getItem(PROJECT_STORAGE_KEY) || "";
+}
+
+function setCurrentProjectId(projectId) {
+  if (!projectId || projectId === "default") {
+    localStorage.removeItem(PROJECT_STORAGE_KEY);
+    return;
+  }
+  localStorage.setItem(PROJECT_STORAGE_KEY, projectId);
+}
+
+function apiUrl(path) {
+  const projectId = getCurrentProjectId();
+  if (!projectId || projectId === "default") {
+    return path;
+  }
+  const separator = path.includes("?") ? "&" : "?";
+  return `${path}${separator}project=${encodeURIComponent(projectId)}`;
+}
+
+// 以事件流请求提取接口：progress 事件交给 onProgress，每个解析出的角色或场景交给 onItem，
+// 返回 done 事件中保存后的结果，error 事件或连接中断时抛出异常。
+async function streamExtraction(path, { onProgress, onItem } = {}) {
+  const response = await fetch(apiUrl(path), {
+    method: "POST",
+    headers: { Accept: "text/event-stream" },
+  });
+  if (!response.ok) {
+    const message = await response.text();
+    throw new Error(message || "提取失败");
+  }
+
+  const reader = response.body.getReader();
+  const decoder = new TextDecoder();
+  let buffer = "";
+  for (;;) {
+    const { value, done } = await reader.read();
+    buffer += decoder.decode(value || new Uint8Array(), { stream: !done });
+    let boundary;
+    while ((boundary = buffer.indexOf("\n\n")) >= 0) {
+      const block = buffer.slice(0, boundary);
+      buffer = buffer.slice(boundary + 2);
+      let event = "message";
+      const data = [];
+      block.split("\n").forEach((line) => {
+        if (line.startsWith("event:")) {
+          event = line.slice(6).trim();
+        } else if (line.startsWith("data:")) {
+          data.push(line.slice(5).trimStart());
+        }
+      });
+      if (data.length === 0) {
+        continue;
+      }
+      const payload = JSON.parse(data.join("\n"));
+      if (event === "progress" && onProgress) {
+        onProgress(payload);
+      } else if (event === "done") {
+        reader.cancel();
+        return payload;
+      } else if (event === "error") {
+        throw new Error(payload.error || "提取失败");
+      } else if (onItem && event !== "progress") {
+        onItem(payload);
+      }
+    }
+    if (done) {
+      throw new Error("提取连接意外中断");
+    }
+  }
+}
+
+// 提取进度的提示文字。
+function describeExtractionProgress(label, progress) {
+  let text = `正在识别${label}…第 ${Math.min(progress.done + 1, progress.total)}/${progress.total} 段，已找到 ${progress.found} 个`;
+  if (progress.tokens > 0) {
+    text += `，已输出约 ${progress.tokens} tokens`;
+  }
+  return text;
+}
@@ -9,6 +9,8 @@ const sceneTitleEl = document.getElementById("scene-title");
 const backBtn = document.getElementById("back-btn");
 const closeBtn = document.getElementById("close-btn");
 const generateAudioBtn = document.getElementById("generate-audio-btn");
+const imageVariantsEl = document.getElementById("image-variants");
+const audioVariantsEl = document.getElementById("audio-variants");
 
 let currentSceneIndex = null;
 let currentScene = null;
@@ -32,8 +34,14 @@ function getSceneIndex() {
   return parsed;
 }
 
+function getSceneId() {
+  const params = new URLSearchParams(window.location.search);
+  return params.get("id") || "";
+}
+
 function normalizeScene(scene = {}) {
   return {
+    id: typeof scene.id === "string" ? scene.id : "",
     title: (scene.title ?? "").trim(),
     characters: Array.isArray(scene.characters)
       ? scene.characters.map((item) => String(item ?? "").trim()).filter(Boolean)
@@ -130,19 +138,27 @@ function setupNavigation() {
 }
 
 async function loadSceneDetail() {
-  const index = getSceneIndex();
-  if (index === null) {
+  const sceneId = getSceneId();
+  let index = getSceneIndex();
+  if (index === null && !sceneId) {
     setStatus("缺少场景索引参数", true);
     return;
   }
 
   try {
     setStatus("正在加载场景详情...");
-    const response = await fetch("/api/scenes");
+    const response = await fetch(apiUrl("/api/scenes"));
     if (!response.ok) {
       throw new Error("读取场景数据失败");
     }
     const scenes = await response.json();
+    if (sceneId) {
+      const found = scenes.findIndex((item) => item?.id === sceneId);
+      if (found < 0) {
+        throw new Error("场景不存在");
+      }
+      index = found;
+    }
     if (index < 0 || index >= scenes.length) {
       throw new Error("场景索引超出范围");
     }
@@ -156,6 +172,8 @@ async function loadSceneDetail() {
 
     renderImage(scene, index);
     renderAudio(scene);
+    loadVariants("images", imageVariantsEl);
+    loadVariants("audio", audioVariantsEl);
     setBlockText(
       narrationContent,
       scene.narration,
@@ -211,14 +229,15 @@ if (generateAudioBtn) {
       generateAudioBtn.textContent = "生成中...";
       setStatus(`正在生成场景 ${currentSceneIndex + 1} 的声音，请耐心等待...`);
 
-      console.log("[生成声音] 发送 API 请求:", { index: currentSceneIndex });
+      const payload = { id: currentScene?.id ?? "", index: currentSceneIndex };
+      console.log("[生成声音] 发送 API 请求:", payload);
 
-      const response = await fetch("/api/scenes/generate-audio", {
+      const response = await fetch(apiUrl("/api/scenes/generate-audio"), {
         method: "POST",
         headers: {
           "Content-Type": "application/json",
         },
-        body: JSON.stringify({ index: currentSceneIndex }),
+        body: JSON.stringify(payload),
       });
 
       console.log("[生成声音] API 响应状态:", response.status);
@@ -233,6 +252,7 @@ if (generateAudioBtn) {
       console.log("[生成声音] 生成成功，音频路径:", updatedScene.audioPath);
       currentScene = updatedScene;
       renderAudio(updatedScene);
+      loadVariants("audio", audioVariantsEl);
       setStatus("语音生成完成！");
     } catch (err) {
       console.error("[生成声音] 错误:", err);
@@ -288,5 +308,92 @@ function renderAudio(scene) {
   }
 }
 
+// 每次生成或上传都会保留为一个版本，可以切换回之前的结果或删除不要的版本。
+async function loadVariants(asset, container) {
+  if (!container || !currentScene?.id) {
+    return;
+  }
+  container.innerHTML = "";
+  try {
+    const response = await fetch(apiUrl(`/api/scenes/${encodeURIComponent(currentScene.id)}/${asset}`));
+    if (!response.ok) {
+      throw new Error(await response.text());
+    }
+    const list = await response.json();
+    if (!list.variants.length) {
+      const placeholder = document.createElement("p");
+      placeholder.className = "detail-placeholder";
+      placeholder.textContent = "暂无历史版本。";
+      container.appendChild(placeholder);
+      return;
+    }
+    list.variants
+      .slice()
+      .reverse()
+      .forEach((variant) => {
+        container.appendChild(renderVariant(asset, variant, variant.id === list.activeId));
+      });
+  } catch (err) {
+    setStatus(`读取历史版本失败: ${err.message}`, true);
+  }
+}
+
+function renderVariant(asset, variant, active) {
+  const row = document.createElement("div");
+  row.className = "variant-item";
+  row.classList.toggle("active", active);
+
+  if (asset === "images") {
+    const thumb = document.createElement("img");
+    thumb.src = variant.path;
+    thumb.alt = "";
+    row.appendChild(thumb);
+  }
+
+  const info = document.createElement("span");
+  const createdAt = variant.createdAt && !variant.createdAt.startsWith("0001")
+    ? new Date(variant.createdAt).toLocaleString()
+    : "早期版本";
+  info.textContent = [createdAt, variant.model].filter(Boolean).join(" · ");
+  info.title = variant.prompt || "";
+  row.appendChild(info);
+
+  const useBtn = document.createElement("button");
+  useBtn.type = "button";
+  useBtn.className = "secondary";
+  useBtn.textContent = active ? "当前版本" : "使用";
+  useBtn.disabled = active;
+  useBtn.addEventListener("click", () => changeVariant(asset, variant.id, "POST", "/activate"));
+  row.appendChild(useBtn);
+
+  const deleteBtn = document.createElement("button");
+  deleteBtn.type = "button";
+  deleteBtn.className = "secondary";
+  deleteBtn.textContent = "删除";
+  deleteBtn.addEventListener("click", () => {
+    if (window.confirm("删除后文件无法恢复，确定删除这个版本吗？")) {
+      changeVariant(asset, variant.id, "DELETE", "");
+    }
+  });
+  row.appendChild(deleteBtn);
+  return row;
+}
+
+async function changeVariant(asset, variantId, method, suffix) {
+  try {
+    const url = `/api/scenes/${encodeURIComponent(currentScene.id)}/${asset}/${encodeURIComponent(variantId)}${suffix}`;
+    const response = await fetch(apiUrl(url), { method });
+    if (!response.ok) {
+      throw new Error(await response.text());
+    }
+    currentScene = normalizeScene(await response.json());
+    renderImage(currentScene, currentSceneIndex);
+    renderAudio(currentScene);
+    loadVariants(asset, asset === "images" ? imageVariantsEl : audioVariantsEl);
+  } catch (err) {
+    setStatus(`操作失败: ${err.message}`, true);
+  }
+}
+
 setupNavigation();
 loadSceneDetail();
@@ -6,9 +6,13 @@ const generateAllBtn = document.getElementById("generate-all-btn");
 const progressContainer = document.getElementById("progress-container");
 const progressBar = document.getElementById("progress-bar");
 const progressText = document.getElementById("progress-text");
+const chapterField = document.getElementById("chapter-field");
+const chapterSelect = document.getElementById("chapter-select");
 
 let scenesData = [];
 let isBusy = false;
+let scenesETag = "";
+let scenesSnapshot = new Map();
 
 function setStatus(message, isError = false) {
   statusEl.textContent = message;
@@ -37,11 +41,13 @@ function toStringArray(value) {
 
 function normalizeScene(scene = {}) {
   return {
+    id: typeof scene.id === "string" ? scene.id : "",
     title: (scene.title ?? "").trim(),
     characters: toStringArray(scene.characters),
     description: (scene.description ?? "").trim(),
     dialogues: toStringArray(scene.dialogues),
     narration: (scene.narration ?? "").trim(),
+    chapter: Number.isInteger(scene.chapter) ? scene.chapter : 0,
     imagePath:
       typeof scene.imagePath === "string" ? scene.imagePath.trim() : "",
     audioPath:
@@ -49,6 +55,14 @@ function normalizeScene(scene = {}) {
   };
 }
 
+function sceneDetailUrl(scene, index) {
+  const params = new URLSearchParams({ index: String(index) });
+  if (scene.id) {
+    params.set("id", scene.id);
+  }
+  return `scene_detail.html?${params.toString()}`;
+}
+
 function renderScenes(scenes) {
   scenesData = scenes.map((scene) => normalizeScene(scene));
   listEl.innerHTML = "";
@@ -201,18 +215,21 @@ async function loadScenes({ forceAnalyse = false } = {}) {
     let scenes = [];
 
     if (!forceAnalyse) {
-      const response = await fetch("/api/scenes");
+      const response = await fetch(apiUrl("/api/scenes"));
       if (!response.ok) {
         throw new Error("读取场景信息失败");
       }
+      scenesETag = response.headers.get("ETag") || "";
       scenes = await response.json();
     }
 
     if (forceAnalyse || scenes.length === 0) {
       scenes = await analyseScenes();
+      scenesETag = "";
     }
 
     renderScenes(scenes);
+    scenesSnapshot = snapshotById(scenesData, normalizeScene);
     setStatus("");
   } catch (err) {
     renderScenes([]);
@@ -222,15 +239,46 @@ async function loadScenes({ forceAnalyse = false } = {}) {
   }
 }
 
+// 选择某一章时只重新识别该章，其他章节的场景保持不变。
+function sceneExtractPath() {
+  const chapter = chapterSelect.value;
+  if (!chapter) {
+    return "/api/scenes/extract/stream";
+  }
+  return `/api/scenes/extract/stream?from=${encodeURIComponent(chapter)}`;
+}
+
+async function loadChapters() {
+  try {
+    const response = await fetch(apiUrl("/api/novel/chapters"));
+    if (!response.ok) {
+      return;
+    }
+    const chapters = await response.json();
+    if (!Array.isArray(chapters) || chapters.length === 0) {
+      return;
+    }
+    chapters.forEach((chapter) => {
+      const option = document.createElement("option");
+      option.value = String(chapter.index);
+      option.textContent = chapter.title;
+      chapterSelect.appendChild(option);
+    });
+    chapterField.hidden = false;
+  } catch (err) {
+    console.warn("读取章节列表失败", err);
+  }
+}
+
 async function analyseScenes() {
-  const response = await fetch("/api/scenes/extract", {
-    method: "POST",
+  const preview = [];
+  return streamExtraction(sceneExtractPath(), {
+    onProgress: (progress) => setStatus(describeExtractionProgress("场景", progress)),
+    onItem: (scene) => {
+      preview.push(scene);
+      renderScenes(preview);
+    },
   });
-  if (!response.ok) {
-    const message = await response.text();
-    throw new Error(message || "场景识别失败");
-  }
-  return response.json();
 }
 
 async function saveScenes() {
@@ -243,19 +291,18 @@ async function saveScenes() {
     setBusy(true);
     setStatus("正在保存场景信息...");
 
-    const normalizedScenes = scenesData.map(normalizeScene);
-    console.log("准备保存的场景数据:", normalizedScenes);
-
-    const response = await fetch("/api/scenes", {
-      method: "POST",
-      headers: {
-        "Content-Type": "application/json",
-      },
-      body: JSON.stringify(normalizedScenes),
-    });
-
+    let response = await postScenes();
     console.log("保存响应状态:", response.status);
 
+    if (response.status === 412) {
+      const merged = await mergeWithServerScenes(await response.json());
+      if (!merged) {
+        setBusy(false);
+        return;
+      }
+      response = await postScenes();
+    }
+
     if (!response.ok) {
       const message = await response.text();
       console.error("保存失败:", message);
@@ -277,6 +324,43 @@ async function saveScenes() {
   }
 }
 
+function postScenes() {
+  const normalizedScenes = scenesData.map(normalizeScene);
+  console.log("准备保存的场景数据:", normalizedScenes);
+  const headers = {
+    "Content-Type": "application/json",
+  };
+  if (scenesETag) {
+    headers["If-Match"] = scenesETag;
+  }
+  return fetch(apiUrl("/api/scenes"), {
+    method: "POST",
+    headers,
+    body: JSON.stringify(normalizedScenes),
+  });
+}
+
+// 其他页面在此期间保存过场景时，先合并双方修改；同一场景两边都改过时询问用户。
+// 返回 false 表示用户选择放弃本地修改，页面已切换到服务端最新数据。
+async function mergeWithServerScenes(conflict) {
+  const serverScenes = (conflict.current || []).map(normalizeScene);
+  const { merged, conflicts } = mergeById(scenesData, serverScenes, scenesSnapshot, normalizeScene);
+
+  scenesETag = conflict.etag || "";
+  scenesSnapshot = snapshotById(serverScenes, normalizeScene);
+
+  if (
+    conflicts > 0 &&
+    !window.confirm(`有 ${conflicts} 个场景已在其他页面被修改。确定：保留你的修改并保存；取消：放弃你的修改，加载最新数据。`)
+  ) {
+    renderScenes(serverScenes);
+    setStatus("已加载其他页面保存的最新场景，请确认后重新保存。", true);
+    return false;
+  }
+  renderScenes(merged);
+  return true;
+}
+
 async function handleGenerateScene(index) {
   if (isBusy) {
     return;
@@ -293,12 +377,12 @@ async function handleGenerateScene(index) {
   try {
     setBusy(true);
     setStatus(`正在生成场景 ${index + 1} 的图片...`);
-    const response = await fetch("/api/scenes/generate-image", {
+    const response = await fetch(apiUrl("/api/scenes/generate-image"), {
       method: "POST",
       headers: {
         "Content-Type": "application/json",
       },
-      body: JSON.stringify({ index }),
+      body: JSON.stringify({ id: scenesData[index].id, index }),
     });
     if (!response.ok) {
       const message = await response.text();
@@ -309,7 +393,7 @@ async function handleGenerateScene(index) {
     setStatus("图片生成完成，正在打开详情...");
     navigated = true;
     setBusy(false);
-    window.location.href = `scene_detail.html?index=${index}`;
+    window.location.href = sceneDetailUrl(scenesData[index], index);
     return;
   } catch (err) {
     setStatus(err.message, true);
@@ -339,12 +423,12 @@ async function handleGenerateSceneWithCharacters(index) {
   try {
     setBusy(true);
     setStatus(`正在使用人物图片生成场景 ${index + 1} 的图片...`);
-    const response = await fetch("/api/scenes/generate-image-with-characters", {
+    const response = await fetch(apiUrl("/api/scenes/generate-image-with-characters"), {
       method: "POST",
       headers: {
         "Content-Type": "application/json",
       },
-      body: JSON.stringify({ index }),
+      body: JSON.stringify({ id: scenesData[index].id, index }),
     });
     if (!response.ok) {
       const message = await response.text();
@@ -355,7 +439,7 @@ async function handleGenerateSceneWithCharacters(index) {
     setStatus("图片生成完成，正在打开详情...");
     navigated = true;
     setBusy(false);
-    window.location.href = `scene_detail.html?index=${index}`;
+    window.location.href = sceneDetailUrl(scenesData[index], index);
     return;
   } catch (err) {
     setStatus(err.message, true);
@@ -371,6 +455,7 @@ async function handleGenerateSceneWithCharacters(index) {
 
 saveBtn.addEventListener("click", saveScenes);
 reanalyseBtn.addEventListener("click", () => loadScenes({ forceAnalyse: true }));
+loadChapters();
 generateAllBtn.addEventListener("click", generateAllSceneImages);
 
 async function generateAllSceneImages() {
@@ -404,12 +489,12 @@ async function generateAllSceneImages() {
       setStatus(`正在生成场景 ${i + 1}/${total}: ${scene.title}`);
 
       try {
-        const response = await fetch("/api/scenes/generate-image", {
+        const response = await fetch(apiUrl("/api/scenes/generate-image"), {
           method: "POST",
           headers: {
             "Content-Type": "application/json",
           },
-          body: JSON.stringify({ index: i }),
+          body: JSON.stringify({ id: scene.id, index: i }),
         });
 
         if (response.ok) {